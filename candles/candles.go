@@ -0,0 +1,404 @@
+// Package candles builds live candlesticks from the trade stream, on top of the bars backfilled from the REST API,
+// so that indicators see one seamless series.
+package candles
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/u20024804/lighter-ex/client"
+	"github.com/u20024804/lighter-ex/decimal"
+)
+
+// Resolutions supported by GetCandlesticks
+var Resolutions = []string{"1", "5", "15", "60", "240", "1D"}
+
+const defaultMaxBars = 500
+
+// ResolutionDuration returns the length of a bar of the given resolution
+func ResolutionDuration(resolution string) (time.Duration, error) {
+	switch resolution {
+	case "1":
+		return time.Minute, nil
+	case "5":
+		return 5 * time.Minute, nil
+	case "15":
+		return 15 * time.Minute, nil
+	case "60":
+		return time.Hour, nil
+	case "240":
+		return 4 * time.Hour, nil
+	case "1D":
+		return 24 * time.Hour, nil
+	}
+	return 0, fmt.Errorf("unsupported resolution %q", resolution)
+}
+
+// Bar is a single candlestick. Timestamps are in milliseconds, OpenTime is the start of the bar.
+type Bar struct {
+	MarketId            uint8
+	Resolution          string
+	OpenTime            int64
+	Open                decimal.Decimal
+	High                decimal.Decimal
+	Low                 decimal.Decimal
+	Close               decimal.Decimal
+	Volume              decimal.Decimal
+	QuoteVolume         decimal.Decimal
+	TradesCount         int64
+	TakerBuyVolume      decimal.Decimal
+	TakerBuyQuoteVolume decimal.Decimal
+	// Closed is false for the bar still being built
+	Closed bool
+}
+
+// CloseTime returns the end of the bar, exclusive
+func (b *Bar) CloseTime() int64 {
+	d, _ := ResolutionDuration(b.Resolution)
+	return b.OpenTime + d.Milliseconds()
+}
+
+// Trade is a trade reduced to what the bars need
+type Trade struct {
+	MarketId   uint8
+	TradeId    int64
+	Price      decimal.Decimal
+	Size       decimal.Decimal
+	IsTakerBuy bool
+	// Timestamp is in milliseconds
+	Timestamp int64
+}
+
+type seriesKey struct {
+	marketId   uint8
+	resolution string
+}
+
+type series struct {
+	duration int64 // milliseconds
+	bars     []Bar // closed bars, oldest first
+	current  *Bar
+	// backfilledUntil is when the bars were fetched, the trades before are already counted in them
+	backfilledUntil int64
+}
+
+// Engine keeps the bars of a set of (market, resolution) pairs
+type Engine struct {
+	apiClient *client.HTTPClient
+	maxBars   int
+
+	mu          sync.RWMutex
+	series      map[seriesKey]*series
+	lastTradeId map[uint8]int64
+
+	listenersMu sync.RWMutex
+	onClosed    []func(Bar)
+	onUpdated   []func(Bar)
+}
+
+// NewEngine creates an engine that backfills with apiClient, which can be nil to start from the live trades only.
+// maxBars bounds the closed bars kept per series, 0 uses the default.
+func NewEngine(apiClient *client.HTTPClient, maxBars int) *Engine {
+	if maxBars <= 0 {
+		maxBars = defaultMaxBars
+	}
+	return &Engine{
+		apiClient:   apiClient,
+		maxBars:     maxBars,
+		series:      make(map[seriesKey]*series),
+		lastTradeId: make(map[uint8]int64),
+	}
+}
+
+// OnBarClosed registers a callback called once per bar, when it's closed
+func (e *Engine) OnBarClosed(callback func(Bar)) {
+	e.listenersMu.Lock()
+	defer e.listenersMu.Unlock()
+	e.onClosed = append(e.onClosed, callback)
+}
+
+// OnBarUpdated registers a callback called every time a trade changes the current bar
+func (e *Engine) OnBarUpdated(callback func(Bar)) {
+	e.listenersMu.Lock()
+	defer e.listenersMu.Unlock()
+	e.onUpdated = append(e.onUpdated, callback)
+}
+
+// Track starts keeping bars of a market for the given resolutions, all of them if none is given.
+// The bars are backfilled from GetCandlesticks if the engine has an HTTPClient.
+func (e *Engine) Track(marketId uint8, resolutions ...string) error {
+	if len(resolutions) == 0 {
+		resolutions = Resolutions
+	}
+	for _, resolution := range resolutions {
+		d, err := ResolutionDuration(resolution)
+		if err != nil {
+			return err
+		}
+		s := &series{duration: d.Milliseconds()}
+		if e.apiClient != nil {
+			if err := e.backfill(s, marketId, resolution); err != nil {
+				return fmt.Errorf("failed to backfill market %d resolution %s: %w", marketId, resolution, err)
+			}
+		}
+
+		e.mu.Lock()
+		e.series[seriesKey{marketId, resolution}] = s
+		e.mu.Unlock()
+	}
+	return nil
+}
+
+func (e *Engine) backfill(s *series, marketId uint8, resolution string) error {
+	now := time.Now().UnixMilli()
+	start := now - int64(e.maxBars+1)*s.duration
+	resp, err := e.apiClient.GetCandlesticks(marketId, resolution, start, now, int32(e.maxBars+1), nil)
+	if err != nil {
+		return err
+	}
+
+	bars := make([]Bar, 0, len(resp.Candlesticks))
+	for i := range resp.Candlesticks {
+		bars = append(bars, barFromCandlestick(marketId, resolution, &resp.Candlesticks[i]))
+	}
+
+	// the last bar is still open if the current period has started
+	currentOpen := now - now%s.duration
+	if n := len(bars); n > 0 && bars[n-1].OpenTime >= currentOpen {
+		current := bars[n-1]
+		s.current = &current
+		bars = bars[:n-1]
+	}
+	for i := range bars {
+		bars[i].Closed = true
+	}
+	if len(bars) > e.maxBars {
+		bars = bars[len(bars)-e.maxBars:]
+	}
+	s.bars = bars
+	s.backfilledUntil = now
+	return nil
+}
+
+// Attach feeds the engine with the trades of a market. The returned function unsubscribes.
+func (e *Engine) Attach(service client.LighterWebsocketPublicServiceI, marketId uint8) (func() error, error) {
+	return service.SubscribeTrades(client.LighterTradesParamKey{MarketId: marketId}, func(resp client.LighterTradesResponse) error {
+		e.ApplyTrade(TradeFromResponse(resp))
+		return nil
+	})
+}
+
+// TradeFromResponse converts a trade of the trade stream
+func TradeFromResponse(resp client.LighterTradesResponse) Trade {
+	return Trade{
+		MarketId:   resp.MarketId,
+		TradeId:    resp.TradeId,
+		Price:      resp.Price,
+		Size:       resp.Quantity,
+		IsTakerBuy: resp.Side == "buy",
+		Timestamp:  millis(resp.Timestamp),
+	}
+}
+
+// millis converts a timestamp of Lighter, in milliseconds or seconds, to milliseconds
+func millis(ts int64) int64 {
+	if ts > 0 && ts < 1e12 {
+		return ts * 1000
+	}
+	return ts
+}
+
+// ApplyTrade adds a trade to the current bar of every tracked resolution of its market.
+// Trades already seen, by trade id, already counted by the backfill or older than the current bar are ignored.
+// A Timestamp in seconds is converted to milliseconds.
+func (e *Engine) ApplyTrade(trade Trade) {
+	var closed, updated []Bar
+	trade.Timestamp = millis(trade.Timestamp)
+
+	e.mu.Lock()
+	if trade.TradeId != 0 {
+		if trade.TradeId <= e.lastTradeId[trade.MarketId] {
+			e.mu.Unlock()
+			return
+		}
+		e.lastTradeId[trade.MarketId] = trade.TradeId
+	}
+
+	for key, s := range e.series {
+		if key.marketId != trade.MarketId || trade.Timestamp <= s.backfilledUntil {
+			continue
+		}
+		openTime := trade.Timestamp - trade.Timestamp%s.duration
+		closed = append(closed, e.roll(s, key, openTime)...)
+		if s.current == nil || s.current.OpenTime != openTime {
+			// late trade, its bar is gone
+			continue
+		}
+
+		b := s.current
+		if b.TradesCount == 0 && b.Volume.IsZero() {
+			b.Open, b.High, b.Low = trade.Price, trade.Price, trade.Price
+		}
+		b.High = decimal.Max(b.High, trade.Price)
+		b.Low = decimal.Min(b.Low, trade.Price)
+		b.Close = trade.Price
+		quote := trade.Size.Mul(trade.Price)
+		b.Volume = b.Volume.Add(trade.Size)
+		b.QuoteVolume = b.QuoteVolume.Add(quote)
+		b.TradesCount++
+		if trade.IsTakerBuy {
+			b.TakerBuyVolume = b.TakerBuyVolume.Add(trade.Size)
+			b.TakerBuyQuoteVolume = b.TakerBuyQuoteVolume.Add(quote)
+		}
+		updated = append(updated, *b)
+	}
+	e.mu.Unlock()
+
+	e.emit(closed, updated)
+}
+
+// roll closes the current bar of s if it's before openTime, filling the gap with flat bars, and opens the bar
+// at openTime. Must be called with e.mu held.
+func (e *Engine) roll(s *series, key seriesKey, openTime int64) []Bar {
+	if s.current != nil && s.current.OpenTime >= openTime {
+		return nil
+	}
+
+	var closed []Bar
+	if s.current != nil {
+		last := *s.current
+		last.Closed = true
+		closed = append(closed, last)
+
+		// bars without trades, bounded by the number of bars kept
+		gapStart := last.OpenTime + s.duration
+		if gaps := (openTime - gapStart) / s.duration; gaps > int64(e.maxBars) {
+			gapStart = openTime - int64(e.maxBars)*s.duration
+		}
+		for t := gapStart; t < openTime; t += s.duration {
+			closed = append(closed, Bar{
+				MarketId:   key.marketId,
+				Resolution: key.resolution,
+				OpenTime:   t,
+				Open:       last.Close,
+				High:       last.Close,
+				Low:        last.Close,
+				Close:      last.Close,
+				Closed:     true,
+			})
+		}
+	}
+
+	s.bars = append(s.bars, closed...)
+	if len(s.bars) > e.maxBars {
+		s.bars = append([]Bar(nil), s.bars[len(s.bars)-e.maxBars:]...)
+	}
+
+	s.current = &Bar{
+		MarketId:   key.marketId,
+		Resolution: key.resolution,
+		OpenTime:   openTime,
+	}
+	if len(s.bars) > 0 {
+		price := s.bars[len(s.bars)-1].Close
+		s.current.Open, s.current.High, s.current.Low, s.current.Close = price, price, price, price
+	}
+	return closed
+}
+
+// Flush closes the bars whose period ended before now, even if no trade came in since
+func (e *Engine) Flush(now time.Time) {
+	ts := now.UnixMilli()
+	var closed []Bar
+
+	e.mu.Lock()
+	for key, s := range e.series {
+		if s.current == nil {
+			continue
+		}
+		closed = append(closed, e.roll(s, key, ts-ts%s.duration)...)
+	}
+	e.mu.Unlock()
+
+	e.emit(closed, nil)
+}
+
+// Run calls Flush every interval until ctx is done, so bars close on time in quiet markets
+func (e *Engine) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			e.Flush(now)
+		}
+	}
+}
+
+func (e *Engine) emit(closed, updated []Bar) {
+	if len(closed) == 0 && len(updated) == 0 {
+		return
+	}
+	e.listenersMu.RLock()
+	onClosed, onUpdated := e.onClosed, e.onUpdated
+	e.listenersMu.RUnlock()
+
+	for _, bar := range closed {
+		for _, callback := range onClosed {
+			callback(bar)
+		}
+	}
+	for _, bar := range updated {
+		for _, callback := range onUpdated {
+			callback(bar)
+		}
+	}
+}
+
+// Bars returns the closed bars of a series, oldest first, followed by the current one if there's any
+func (e *Engine) Bars(marketId uint8, resolution string) []Bar {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	s, ok := e.series[seriesKey{marketId, resolution}]
+	if !ok {
+		return nil
+	}
+	bars := make([]Bar, 0, len(s.bars)+1)
+	bars = append(bars, s.bars...)
+	if s.current != nil {
+		bars = append(bars, *s.current)
+	}
+	return bars
+}
+
+// Current returns the bar being built
+func (e *Engine) Current(marketId uint8, resolution string) (Bar, bool) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	s, ok := e.series[seriesKey{marketId, resolution}]
+	if !ok || s.current == nil {
+		return Bar{}, false
+	}
+	return *s.current, true
+}
+
+func barFromCandlestick(marketId uint8, resolution string, c *client.Candlestick) Bar {
+	return Bar{
+		MarketId:            marketId,
+		Resolution:          resolution,
+		OpenTime:            millis(c.Timestamp),
+		Open:                c.Open,
+		High:                c.High,
+		Low:                 c.Low,
+		Close:               c.Close,
+		Volume:              c.Volume,
+		QuoteVolume:         c.QuoteVolume,
+		TradesCount:         int64(c.TradesCount),
+		TakerBuyVolume:      c.TakerBuyVolume,
+		TakerBuyQuoteVolume: c.TakerBuyQuoteVolume,
+	}
+}
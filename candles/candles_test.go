@@ -0,0 +1,195 @@
+package candles
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/u20024804/lighter-ex/client"
+	"github.com/u20024804/lighter-ex/decimal"
+)
+
+const minute = int64(60_000)
+
+// recorder keeps the bars passed to the callbacks of an engine
+type recorder struct {
+	closed, updated []Bar
+}
+
+func newTestEngine(t *testing.T, apiClient *client.HTTPClient, maxBars int) (*Engine, *recorder) {
+	t.Helper()
+	e := NewEngine(apiClient, maxBars)
+	rec := &recorder{}
+	e.OnBarClosed(func(b Bar) { rec.closed = append(rec.closed, b) })
+	e.OnBarUpdated(func(b Bar) { rec.updated = append(rec.updated, b) })
+	return e, rec
+}
+
+func trade(id int64, ts int64, price, size string, buy bool) Trade {
+	return Trade{MarketId: 1, TradeId: id, Timestamp: ts, Price: decimal.MustParse(price), Size: decimal.MustParse(size), IsTakerBuy: buy}
+}
+
+func checkBar(t *testing.T, b Bar, openTime int64, ohlc [4]string, volume string, trades int64, closed bool) {
+	t.Helper()
+	got := [4]decimal.Decimal{b.Open, b.High, b.Low, b.Close}
+	for i, want := range ohlc {
+		if !got[i].Equal(decimal.MustParse(want)) {
+			t.Errorf("bar at %d: expected OHLC %v, got %v", openTime, ohlc, got)
+			break
+		}
+	}
+	if b.OpenTime != openTime || !b.Volume.Equal(decimal.MustParse(volume)) || b.TradesCount != trades || b.Closed != closed {
+		t.Errorf("expected bar at %d with volume %s, %d trades, closed %v, got %+v", openTime, volume, trades, closed, b)
+	}
+}
+
+func TestRollingBars(t *testing.T) {
+	e, rec := newTestEngine(t, nil, 0)
+	if err := e.Track(1, "1"); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).UnixMilli()
+	e.ApplyTrade(trade(1, start+1000, "10", "1", true))
+	e.ApplyTrade(trade(2, start+2000, "12", "2", false))
+	e.ApplyTrade(trade(3, start+3000, "9", "1", true))
+	// already seen, and another market
+	e.ApplyTrade(trade(3, start+4000, "100", "1", true))
+	e.ApplyTrade(Trade{MarketId: 2, TradeId: 4, Timestamp: start + 4000, Price: decimal.MustParse("100"), Size: decimal.MustParse("1")})
+	if len(rec.closed) != 0 || len(rec.updated) != 3 {
+		t.Fatalf("expected 3 updates and no closed bar, got %d and %d", len(rec.updated), len(rec.closed))
+	}
+
+	current, ok := e.Current(1, "1")
+	if !ok {
+		t.Fatal("expected a current bar")
+	}
+	checkBar(t, current, start, [4]string{"10", "12", "9", "9"}, "4", 3, false)
+	if !current.TakerBuyVolume.Equal(decimal.MustParse("2")) || !current.QuoteVolume.Equal(decimal.MustParse("43")) {
+		t.Errorf("expected a taker buy volume of 2 & quote volume of 43, got %v & %v", current.TakerBuyVolume, current.QuoteVolume)
+	}
+
+	// the next minute, with a timestamp in seconds
+	e.ApplyTrade(trade(5, (start+minute+5000)/1000, "11", "1", false))
+	if len(rec.closed) != 1 {
+		t.Fatalf("expected the first bar closed, got %d", len(rec.closed))
+	}
+	checkBar(t, rec.closed[0], start, [4]string{"10", "12", "9", "9"}, "4", 3, true)
+	bars := e.Bars(1, "1")
+	if len(bars) != 2 {
+		t.Fatalf("expected a closed & a current bar, got %d", len(bars))
+	}
+	// the previous close the bar was opened with is replaced by its first trade
+	checkBar(t, bars[1], start+minute, [4]string{"11", "11", "11", "11"}, "1", 1, false)
+
+	// a late trade of a closed bar is dropped
+	e.ApplyTrade(trade(6, start+6000, "50", "1", false))
+	if bars := e.Bars(1, "1"); !bars[0].Volume.Equal(decimal.MustParse("4")) || !bars[1].High.Equal(decimal.MustParse("11")) {
+		t.Errorf("expected the late trade ignored, got %+v", bars)
+	}
+}
+
+func TestGapFilling(t *testing.T) {
+	e, rec := newTestEngine(t, nil, 3)
+	if err := e.Track(1, "1"); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).UnixMilli()
+	e.ApplyTrade(trade(1, start, "10", "1", true))
+	e.ApplyTrade(trade(2, start+3*minute, "12", "1", true))
+
+	if len(rec.closed) != 3 {
+		t.Fatalf("expected the bar & 2 flat bars closed, got %d", len(rec.closed))
+	}
+	checkBar(t, rec.closed[0], start, [4]string{"10", "10", "10", "10"}, "1", 1, true)
+	for i, b := range rec.closed[1:] {
+		checkBar(t, b, start+int64(i+1)*minute, [4]string{"10", "10", "10", "10"}, "0", 0, true)
+	}
+
+	// a gap longer than the bars kept only fills the last ones
+	rec.closed = nil
+	e.ApplyTrade(trade(3, start+100*minute, "13", "1", true))
+	if len(rec.closed) != 4 {
+		t.Fatalf("expected the bar & 3 flat bars closed, got %d", len(rec.closed))
+	}
+	for i, b := range rec.closed[1:] {
+		checkBar(t, b, start+int64(97+i)*minute, [4]string{"12", "12", "12", "12"}, "0", 0, true)
+	}
+	bars := e.Bars(1, "1")
+	if len(bars) != 4 || bars[0].OpenTime != start+97*minute || bars[3].OpenTime != start+100*minute {
+		t.Errorf("expected the 3 last closed bars and the current one, got %+v", bars)
+	}
+}
+
+func TestFlush(t *testing.T) {
+	e, rec := newTestEngine(t, nil, 0)
+	if err := e.Track(1, "1", "5"); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).UnixMilli()
+	e.Flush(time.UnixMilli(start + 10*minute))
+	if len(rec.closed) != 0 {
+		t.Fatalf("expected nothing to close before the first trade, got %d", len(rec.closed))
+	}
+
+	e.ApplyTrade(trade(1, start+1000, "10", "1", true))
+	e.Flush(time.UnixMilli(start + 59_000))
+	if len(rec.closed) != 0 {
+		t.Fatalf("expected the bar kept open within its period, got %d closed", len(rec.closed))
+	}
+
+	e.Flush(time.UnixMilli(start + 2*minute))
+	if len(rec.closed) != 2 {
+		t.Fatalf("expected the 1 minute bar & a flat bar closed, got %d", len(rec.closed))
+	}
+	for i, b := range rec.closed {
+		if b.Resolution != "1" || b.OpenTime != start+int64(i)*minute || !b.Closed {
+			t.Errorf("unexpected closed bar %+v", b)
+		}
+	}
+	current, _ := e.Current(1, "1")
+	checkBar(t, current, start+2*minute, [4]string{"10", "10", "10", "10"}, "0", 0, false)
+	if current, _ := e.Current(1, "5"); current.OpenTime != start || current.Closed {
+		t.Errorf("expected the 5 minutes bar still open, got %+v", current)
+	}
+}
+
+func TestBackfillOverlap(t *testing.T) {
+	day := 24 * 60 * minute
+	now := time.Now().UnixMilli()
+	today := now - now%day
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the timestamps are in seconds
+		resp := client.CandlesticksResponse{ResultCode: client.ResultCode{Code: client.CodeOK}, Candlesticks: []client.Candlestick{
+			{Timestamp: (today - day) / 1000, Open: decimal.MustParse("9"), High: decimal.MustParse("11"), Low: decimal.MustParse("8"),
+				Close: decimal.MustParse("10"), Volume: decimal.MustParse("7"), TradesCount: 7},
+			{Timestamp: today / 1000, Open: decimal.MustParse("10"), High: decimal.MustParse("12"), Low: decimal.MustParse("10"),
+				Close: decimal.MustParse("11"), Volume: decimal.MustParse("2"), TradesCount: 2},
+		}}
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			t.Error(err)
+		}
+	}))
+	defer srv.Close()
+
+	e, rec := newTestEngine(t, client.NewHTTPClient(srv.URL), 0)
+	if err := e.Track(1, "1D"); err != nil {
+		t.Fatal(err)
+	}
+	bars := e.Bars(1, "1D")
+	if len(bars) != 2 {
+		t.Fatalf("expected a closed & a current bar, got %+v", bars)
+	}
+	checkBar(t, bars[0], today-day, [4]string{"9", "11", "8", "10"}, "7", 7, true)
+	checkBar(t, bars[1], today, [4]string{"10", "12", "10", "11"}, "2", 2, false)
+
+	// the trades up to the backfill are already counted in the bars
+	e.ApplyTrade(trade(1, now-1000, "50", "5", true))
+	e.ApplyTrade(trade(2, time.Now().UnixMilli()+1000, "13", "1", true))
+	if len(rec.closed) != 0 || len(rec.updated) != 1 {
+		t.Fatalf("expected a single update, got %d updated & %d closed", len(rec.updated), len(rec.closed))
+	}
+	current, _ := e.Current(1, "1D")
+	checkBar(t, current, today, [4]string{"10", "13", "10", "13"}, "3", 3, false)
+}
@@ -0,0 +1,448 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/u20024804/lighter-ex/decimal"
+)
+
+const defaultAccountStateMaxTrades = 1000
+
+// AccountStateEventType tells what caused an AccountState change
+type AccountStateEventType int
+
+const (
+	// AccountStateSeeded is emitted after the state was loaded from the REST API
+	AccountStateSeeded AccountStateEventType = iota
+	// AccountStateSnapshot is emitted after a subscribed/account_all message replaced the state
+	AccountStateSnapshot
+	// AccountStateUpdate is emitted after an update/account_all message was merged into the state
+	AccountStateUpdate
+)
+
+// AccountStateEvent describes a single change of an AccountState
+type AccountStateEvent struct {
+	Type         AccountStateEventType
+	AccountIndex int64
+	// Markets lists the markets whose position changed
+	Markets []uint8
+	// Trades holds the trades received with this change, if any
+	Trades []WSTrade
+	// SharesChanged is true if the public pool shares were replaced
+	SharesChanged bool
+}
+
+// AccountStats holds the trading volume & count counters sent on the account_all stream
+type AccountStats struct {
+	DailyTradesCount   int             `json:"daily_trades_count"`
+	DailyVolume        decimal.Decimal `json:"daily_volume"`
+	WeeklyTradesCount  int             `json:"weekly_trades_count"`
+	WeeklyVolume       decimal.Decimal `json:"weekly_volume"`
+	MonthlyTradesCount int             `json:"monthly_trades_count"`
+	MonthlyVolume      decimal.Decimal `json:"monthly_volume"`
+	TotalTradesCount   int             `json:"total_trades_count"`
+	TotalVolume        decimal.Decimal `json:"total_volume"`
+}
+
+// AccountState keeps a live view of an account, seeded from GetAccount and kept current by the
+// subscribed/account_all & update/account_all messages. All getters return copies and are safe for concurrent use.
+type AccountState struct {
+	accountIndex int64
+	maxTrades    int
+
+	mu               sync.RWMutex
+	account          *Account // last REST snapshot, for the fields the stream doesn't carry
+	positions        map[uint8]*WSPosition
+	shares           []WSShare
+	trades           map[uint8][]WSTrade
+	fundingHistories map[uint8][]WSPositionFunding
+	stats            AccountStats
+	updatedAt        time.Time
+	hasSnapshot      bool
+
+	listenersMu sync.RWMutex
+	listeners   []func(AccountStateEvent)
+}
+
+// NewAccountState creates an empty state for the given account. Use Seed and/or Attach to fill it.
+func NewAccountState(accountIndex int64) *AccountState {
+	return &AccountState{
+		accountIndex:     accountIndex,
+		maxTrades:        defaultAccountStateMaxTrades,
+		positions:        make(map[uint8]*WSPosition),
+		trades:           make(map[uint8][]WSTrade),
+		fundingHistories: make(map[uint8][]WSPositionFunding),
+	}
+}
+
+// SetMaxTrades limits how many trades are kept per market. The oldest ones are dropped first.
+func (s *AccountState) SetMaxTrades(n int) *AccountState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.maxTrades = n
+	return s
+}
+
+// OnChange registers a callback that's called after every change. Callbacks run on the goroutine that applied
+// the change, so they should not block.
+func (s *AccountState) OnChange(callback func(AccountStateEvent)) {
+	s.listenersMu.Lock()
+	defer s.listenersMu.Unlock()
+	s.listeners = append(s.listeners, callback)
+}
+
+// Seed loads the account from the REST API, replacing positions & shares
+func (s *AccountState) Seed(apiClient *HTTPClient) error {
+	if apiClient == nil {
+		return fmt.Errorf("HTTPClient is nil")
+	}
+	resp, err := apiClient.GetAccount(s.accountIndex)
+	if err != nil {
+		return err
+	}
+	if len(resp.Accounts) == 0 {
+		return fmt.Errorf("account %d not found", s.accountIndex)
+	}
+	s.ApplyAccount(&resp.Accounts[0])
+	return nil
+}
+
+// ApplyAccount replaces positions & shares with the ones from a REST account snapshot
+func (s *AccountState) ApplyAccount(account *Account) {
+	event := AccountStateEvent{
+		Type:          AccountStateSeeded,
+		AccountIndex:  s.accountIndex,
+		SharesChanged: true,
+	}
+
+	s.mu.Lock()
+	accountCopy := *account
+	s.account = &accountCopy
+	s.positions = make(map[uint8]*WSPosition, len(account.Positions))
+	for _, p := range account.Positions {
+		s.positions[p.MarketId] = positionToWS(&p)
+		event.Markets = append(event.Markets, p.MarketId)
+	}
+	s.shares = make([]WSShare, 0, len(account.Shares))
+	for _, share := range account.Shares {
+		s.shares = append(s.shares, WSShare{
+			PublicPoolIndex: share.PublicPoolIndex,
+			SharesAmount:    int64(share.SharesAmount),
+			EntryUsdc:       share.EntryUsdc,
+		})
+	}
+	s.updatedAt = time.Now()
+	s.mu.Unlock()
+
+	s.emit(event)
+}
+
+// Apply merges an account_all message into the state.
+// A snapshot (subscribed/account_all) replaces everything, while an update only carries what changed:
+// positions are replaced per market, trades & funding histories are appended and shares are replaced as a whole.
+func (s *AccountState) Apply(update *WSAccountUpdate) error {
+	if update == nil {
+		return nil
+	}
+	if update.Account != 0 && update.Account != s.accountIndex {
+		return fmt.Errorf("account update for %d applied to state of account %d", update.Account, s.accountIndex)
+	}
+
+	isSnapshot := update.Type == MessageTypeAccountSubscribed
+	event := AccountStateEvent{
+		Type:         AccountStateUpdate,
+		AccountIndex: s.accountIndex,
+	}
+	if isSnapshot {
+		event.Type = AccountStateSnapshot
+	}
+
+	// the keys are parsed before anything is changed, so an invalid message leaves the state as it was
+	positions := make(map[uint8]*WSPosition, len(update.Positions))
+	for key, position := range update.Positions {
+		if position == nil {
+			continue
+		}
+		marketId, err := marketIdFromKey(key, position.MarketId)
+		if err != nil {
+			return err
+		}
+		p := *position
+		p.MarketId = marketId
+		positions[marketId] = &p
+	}
+	trades := make(map[uint8][]WSTrade, len(update.Trades))
+	for key, t := range update.Trades {
+		marketId, err := marketIdFromKey(key, 0)
+		if err != nil {
+			return err
+		}
+		trades[marketId] = append(trades[marketId], t...)
+	}
+	fundingHistories := make(map[uint8][]WSPositionFunding, len(update.FundingHistories))
+	for key, histories := range update.FundingHistories {
+		marketId, err := marketIdFromKey(key, 0)
+		if err != nil {
+			return err
+		}
+		fundingHistories[marketId] = append(fundingHistories[marketId], histories...)
+	}
+
+	s.mu.Lock()
+	if isSnapshot {
+		s.positions = make(map[uint8]*WSPosition, len(positions))
+		s.trades = make(map[uint8][]WSTrade)
+		s.fundingHistories = make(map[uint8][]WSPositionFunding)
+		s.hasSnapshot = true
+	}
+
+	for marketId, p := range positions {
+		s.positions[marketId] = p
+		event.Markets = append(event.Markets, marketId)
+	}
+
+	if isSnapshot || update.Shares != nil {
+		s.shares = append([]WSShare(nil), update.Shares...)
+		event.SharesChanged = true
+	}
+
+	for marketId, t := range trades {
+		added := s.appendTrades(marketId, t)
+		event.Trades = append(event.Trades, added...)
+	}
+
+	for marketId, histories := range fundingHistories {
+		s.fundingHistories[marketId] = append(s.fundingHistories[marketId], histories...)
+	}
+
+	s.stats = AccountStats{
+		DailyTradesCount:   update.DailyTradesCount,
+		DailyVolume:        update.DailyVolume,
+		WeeklyTradesCount:  update.WeeklyTradesCount,
+		WeeklyVolume:       update.WeeklyVolume,
+		MonthlyTradesCount: update.MonthlyTradesCount,
+		MonthlyVolume:      update.MonthlyVolume,
+		TotalTradesCount:   update.TotalTradesCount,
+		TotalVolume:        update.TotalVolume,
+	}
+	s.updatedAt = time.Now()
+	s.mu.Unlock()
+
+	s.emit(event)
+	return nil
+}
+
+// appendTrades adds the trades not seen yet and returns them. Must be called with s.mu held.
+func (s *AccountState) appendTrades(marketId uint8, trades []WSTrade) []WSTrade {
+	existing := s.trades[marketId]
+	seen := make(map[int64]struct{}, len(existing))
+	for _, t := range existing {
+		seen[t.TradeId] = struct{}{}
+	}
+
+	added := make([]WSTrade, 0, len(trades))
+	for _, t := range trades {
+		if _, ok := seen[t.TradeId]; ok {
+			continue
+		}
+		seen[t.TradeId] = struct{}{}
+		added = append(added, t)
+	}
+
+	existing = append(existing, added...)
+	if s.maxTrades > 0 && len(existing) > s.maxTrades {
+		existing = append([]WSTrade(nil), existing[len(existing)-s.maxTrades:]...)
+	}
+	s.trades[marketId] = existing
+	return added
+}
+
+// Attach subscribes to the account_all stream of the account and keeps the state current.
+// The returned function unsubscribes.
+func (s *AccountState) Attach(service LighterWebsocketPrivateServiceI) (func() error, error) {
+	return service.SubscribeAccount(LighterAccountParamKey{AccountId: s.accountIndex}, func(resp LighterAccountResponse) error {
+		return s.Apply(resp.RawAccountUpdate)
+	})
+}
+
+// Start seeds the state from the REST API, if apiClient is set, and attaches it to the private service
+func (s *AccountState) Start(ctx context.Context, apiClient *HTTPClient, service LighterWebsocketPrivateServiceI) (func() error, error) {
+	if apiClient != nil {
+		if err := s.Seed(apiClient); err != nil {
+			return nil, fmt.Errorf("failed to seed account state: %w", err)
+		}
+	}
+	unsub, err := s.Attach(service)
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		<-ctx.Done()
+		unsub()
+	}()
+	return unsub, nil
+}
+
+func (s *AccountState) emit(event AccountStateEvent) {
+	s.listenersMu.RLock()
+	listeners := s.listeners
+	s.listenersMu.RUnlock()
+
+	for _, listener := range listeners {
+		listener(event)
+	}
+}
+
+// AccountIndex returns the index of the tracked account
+func (s *AccountState) AccountIndex() int64 {
+	return s.accountIndex
+}
+
+// Ready returns true once a stream snapshot has been applied
+func (s *AccountState) Ready() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.hasSnapshot
+}
+
+// LastUpdate returns when the state last changed
+func (s *AccountState) LastUpdate() time.Time {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.updatedAt
+}
+
+// Account returns the last REST snapshot of the account, or nil if the state was never seeded
+func (s *AccountState) Account() *Account {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.account == nil {
+		return nil
+	}
+	account := *s.account
+	return &account
+}
+
+// Position returns the position on a market
+func (s *AccountState) Position(marketId uint8) (WSPosition, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	p, ok := s.positions[marketId]
+	if !ok {
+		return WSPosition{}, false
+	}
+	return *p, true
+}
+
+// Positions returns all positions keyed by market
+func (s *AccountState) Positions() map[uint8]WSPosition {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	positions := make(map[uint8]WSPosition, len(s.positions))
+	for marketId, p := range s.positions {
+		positions[marketId] = *p
+	}
+	return positions
+}
+
+// Shares returns the public pool shares of the account
+func (s *AccountState) Shares() []WSShare {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]WSShare(nil), s.shares...)
+}
+
+// Trades returns the trades received for a market, oldest first
+func (s *AccountState) Trades(marketId uint8) []WSTrade {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]WSTrade(nil), s.trades[marketId]...)
+}
+
+// FundingHistories returns the funding payments received for a market, oldest first
+func (s *AccountState) FundingHistories(marketId uint8) []WSPositionFunding {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]WSPositionFunding(nil), s.fundingHistories[marketId]...)
+}
+
+// Stats returns the trading counters of the account
+func (s *AccountState) Stats() AccountStats {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.stats
+}
+
+// MarketStats returns the positions in the AccountMarketStats format used by LighterAccountResponse
+func (s *AccountState) MarketStats() []AccountMarketStats {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return positionsToMarketStats(s.positions)
+}
+
+func positionToWS(p *Position) *WSPosition {
+	return &WSPosition{
+		MarketId:               p.MarketId,
+		Symbol:                 p.Symbol,
+		InitialMarginFraction:  p.InitialMarginFraction,
+		OpenOrderCount:         p.OpenOrderCount,
+		PendingOrderCount:      p.PendingOrderCount,
+		PositionTiedOrderCount: p.PositionTiedOrderCount,
+		Sign:                   int8(p.Sign),
+		Position:               p.Position,
+		AvgEntryPrice:          p.AvgEntryPrice,
+		PositionValue:          p.PositionValue,
+		UnrealizedPnl:          p.UnrealizedPnl,
+		RealizedPnl:            p.RealizedPnl,
+		LiquidationPrice:       p.LiquidationPrice,
+		MarginMode:             p.MarginMode,
+		AllocatedMargin:        p.AllocatedMargin,
+	}
+}
+
+func positionsToMarketStats[K comparable](positions map[K]*WSPosition) []AccountMarketStats {
+	stats := make([]AccountMarketStats, 0, len(positions))
+	for _, p := range positions {
+		if p == nil {
+			continue
+		}
+		stats = append(stats, AccountMarketStats{
+			MarketId:       p.MarketId,
+			OpenOrderCount: int64(p.OpenOrderCount),
+			Sign:           p.Sign,
+			Position:       p.Position,
+			AvgEntryPrice:  p.AvgEntryPrice,
+			PositionValue:  p.PositionValue,
+			UnrealizedPnl:  p.UnrealizedPnl,
+			RealizedPnl:    p.RealizedPnl,
+		})
+	}
+	return stats
+}
+
+// marketIdFromKey parses the market id used as key in the account_all maps
+func marketIdFromKey(key string, fallback uint8) (uint8, error) {
+	if key == "" {
+		return fallback, nil
+	}
+	id, err := strconv.ParseUint(key, 10, 8)
+	if err != nil {
+		return 0, fmt.Errorf("invalid market id %q in account update", key)
+	}
+	return uint8(id), nil
+}
+
+// OpenOrderCount returns the number of open orders across all positions
+func (s *AccountState) OpenOrderCount() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	count := 0
+	for _, p := range s.positions {
+		count += p.OpenOrderCount
+	}
+	return count
+}
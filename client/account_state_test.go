@@ -0,0 +1,81 @@
+package client
+
+import (
+	"testing"
+
+	"github.com/u20024804/lighter-ex/decimal"
+)
+
+func TestAccountStateApply(t *testing.T) {
+	s := NewAccountState(7)
+	err := s.Apply(&WSAccountUpdate{
+		Account:   7,
+		Type:      MessageTypeAccountSubscribed,
+		Positions: map[string]*WSPosition{"1": {Position: decimal.MustParse("2")}},
+		Trades:    map[string][]WSTrade{"1": {{TradeId: 1}, {TradeId: 2}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var events []AccountStateEvent
+	s.OnChange(func(event AccountStateEvent) {
+		events = append(events, event)
+	})
+	err = s.Apply(&WSAccountUpdate{
+		Account:   7,
+		Type:      MessageTypeAccount,
+		Positions: map[string]*WSPosition{"2": {Position: decimal.MustParse("3")}},
+		Trades:    map[string][]WSTrade{"1": {{TradeId: 2}, {TradeId: 3}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p, ok := s.Position(1); !ok || !p.Position.Equal(decimal.MustParse("2")) || p.MarketId != 1 {
+		t.Errorf("position 1 = %+v, %v", p, ok)
+	}
+	if p, ok := s.Position(2); !ok || !p.Position.Equal(decimal.MustParse("3")) {
+		t.Errorf("position 2 = %+v, %v", p, ok)
+	}
+	if trades := s.Trades(1); len(trades) != 3 {
+		t.Errorf("got %d trades, expected 3 without the duplicate", len(trades))
+	}
+	if len(events) != 1 || len(events[0].Trades) != 1 || events[0].Trades[0].TradeId != 3 {
+		t.Errorf("unexpected events %+v", events)
+	}
+}
+
+func TestAccountStateApplyInvalidKey(t *testing.T) {
+	tests := []struct {
+		name   string
+		update *WSAccountUpdate
+	}{
+		{"position", &WSAccountUpdate{
+			Positions: map[string]*WSPosition{"1": {}, "x": {}},
+		}},
+		{"trade", &WSAccountUpdate{
+			Positions: map[string]*WSPosition{"1": {}},
+			Trades:    map[string][]WSTrade{"1": {{TradeId: 1}}, "300": {{TradeId: 2}}},
+		}},
+		{"funding", &WSAccountUpdate{
+			Positions:        map[string]*WSPosition{"1": {}},
+			Trades:           map[string][]WSTrade{"1": {{TradeId: 1}}},
+			FundingHistories: map[string][]WSPositionFunding{"-1": {{}}},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewAccountState(7)
+			changed := false
+			s.OnChange(func(AccountStateEvent) { changed = true })
+
+			tt.update.Type = MessageTypeAccount
+			if err := s.Apply(tt.update); err == nil {
+				t.Fatal("expected an error")
+			}
+			if changed || len(s.Positions()) != 0 || len(s.Trades(1)) != 0 || !s.LastUpdate().IsZero() {
+				t.Error("the state was changed by an invalid update")
+			}
+		})
+	}
+}
@@ -0,0 +1,186 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/u20024804/lighter-ex/types"
+	"github.com/u20024804/lighter-ex/types/txtypes"
+)
+
+// ErrOrdersHeld is returned for new orders while the private stream is down or not resynced yet
+var ErrOrdersHeld = errors.New("new orders are held until the account state is resynced")
+
+// CancelOnDisconnectConfig configures a CancelOnDisconnect policy
+type CancelOnDisconnectConfig struct {
+	// GracePeriod is how long the private stream may stay down before the orders get cancelled
+	GracePeriod time.Duration
+	// Markets restricts the cancellation to these markets, by cancelling their active orders one by one.
+	// Empty means every order of the account is cancelled with a single CancelAllOrders tx.
+	Markets []uint8
+	// RetryDelay is how long a failed cancellation waits before it's retried, doubled after every failure up to
+	// MaxRetryDelay. It's retried until it succeeds or the account is resynced. Default 1s and 30s.
+	RetryDelay    time.Duration
+	MaxRetryDelay time.Duration
+	// OnError is called every time the cancellation fails. Optional.
+	OnError func(error)
+}
+
+// CancelOnDisconnect cancels the orders of an account when its private stream stays down longer than a grace period,
+// and holds new orders sent with the TxClient from the disconnect until a fresh account snapshot is received.
+// Enable it with LighterWebsocketPrivateService.SetCancelOnDisconnect, which tells how the snapshot is received again.
+type CancelOnDisconnect struct {
+	client *TxClient
+	config CancelOnDisconnectConfig
+
+	mu           sync.Mutex
+	timer        *time.Timer
+	held         bool
+	disconnected time.Time
+	retryDelay   time.Duration
+}
+
+// NewCancelOnDisconnect creates a policy that cancels with this client and holds its new orders
+func (c *TxClient) NewCancelOnDisconnect(config CancelOnDisconnectConfig) (*CancelOnDisconnect, error) {
+	if config.GracePeriod < 0 {
+		return nil, fmt.Errorf("grace period should not be negative")
+	}
+	if config.RetryDelay == 0 {
+		config.RetryDelay = time.Second
+	}
+	if config.MaxRetryDelay == 0 {
+		config.MaxRetryDelay = 30 * time.Second
+	}
+	if config.RetryDelay < 0 || config.MaxRetryDelay < config.RetryDelay {
+		return nil, fmt.Errorf("retry delay should be positive and at most the max retry delay")
+	}
+	if len(config.Markets) > 0 && c.apiClient == nil {
+		return nil, fmt.Errorf("HTTPClient is nil, can't get the active orders to cancel")
+	}
+	if c.sender == nil {
+		return nil, fmt.Errorf("no TxSender, can't send the cancel txs")
+	}
+	p := &CancelOnDisconnect{
+		client: c,
+		config: config,
+	}
+	c.cancelOnDisconnect = p
+	return p, nil
+}
+
+// Held returns true while new orders are held
+func (p *CancelOnDisconnect) Held() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.held
+}
+
+// OnDisconnected holds new orders and schedules the cancellation after the grace period
+func (p *CancelOnDisconnect) OnDisconnected() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.held = true
+	if p.timer != nil {
+		return
+	}
+	p.disconnected = time.Now()
+	p.retryDelay = p.config.RetryDelay
+	p.timer = time.AfterFunc(p.config.GracePeriod, p.fire)
+	logger().Warn("Private stream is down, cancelling orders unless resynced", LogKeyAccount, p.client.accountIndex, "grace_period", p.config.GracePeriod)
+}
+
+// OnResynced is called when a fresh account snapshot was received. It stops a pending cancellation and releases new orders.
+func (p *CancelOnDisconnect) OnResynced() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.timer != nil {
+		p.timer.Stop()
+		p.timer = nil
+		logger().Info("Account resynced", LogKeyAccount, p.client.accountIndex, "after", time.Since(p.disconnected).Truncate(time.Millisecond))
+	}
+	p.held = false
+}
+
+// fire cancels the orders, and reschedules itself with a growing delay until that succeeds or the account is resynced
+func (p *CancelOnDisconnect) fire() {
+	p.mu.Lock()
+	stillDown := p.timer != nil
+	p.mu.Unlock()
+	if !stillDown {
+		return
+	}
+
+	logger().Warn("Private stream down for more than the grace period, cancelling orders", LogKeyAccount, p.client.accountIndex, "grace_period", p.config.GracePeriod)
+	err := p.cancelOrders()
+	if err == nil {
+		return
+	}
+
+	p.mu.Lock()
+	retryDelay := p.retryDelay
+	if p.timer != nil {
+		p.timer = time.AfterFunc(retryDelay, p.fire)
+		p.retryDelay = min(2*retryDelay, p.config.MaxRetryDelay)
+	}
+	p.mu.Unlock()
+
+	err = fmt.Errorf("cancel on disconnect failed: %w", err)
+	logger().Error("Cancel on disconnect failed", LogKeyAccount, p.client.accountIndex, LogKeyError, err, "retry_in", retryDelay)
+	if p.config.OnError != nil {
+		p.config.OnError(err)
+	}
+}
+
+func (p *CancelOnDisconnect) cancelOrders() error {
+	c := p.client
+	// cancels bypass the hold & risk checks, they only reduce exposure
+	if len(p.config.Markets) == 0 {
+		tx, err := c.GetCancelAllOrdersTransaction(&types.CancelAllOrdersTxReq{
+			TimeInForce: txtypes.ImmediateCancelAll,
+		}, nil)
+		if err != nil {
+			return err
+		}
+		_, err = c.SendTx(tx)
+		return err
+	}
+
+	auth, err := c.GetAuthToken(time.Now().Add(time.Minute))
+	if err != nil {
+		return err
+	}
+	// the nonces are set by SendTxs
+	zero := int64(0)
+	var txs []txtypes.TxInfo
+	for _, marketId := range p.config.Markets {
+		orders, err := c.apiClient.GetActiveOrders(c.accountIndex, marketId, auth)
+		if err != nil {
+			return fmt.Errorf("failed to get active orders of market %d: %w", marketId, err)
+		}
+		for _, order := range orders.Orders {
+			tx, err := c.GetCancelOrderTransaction(&types.CancelOrderTxReq{
+				MarketIndex: marketId,
+				Index:       order.OrderIndex,
+			}, &types.TransactOpts{Nonce: &zero})
+			if err != nil {
+				return err
+			}
+			txs = append(txs, tx)
+		}
+	}
+	_, err = c.SendTxs(context.Background(), txs, nil)
+	return err
+}
+
+// checkHold returns ErrOrdersHeld while the cancel-on-disconnect policy of the client holds new orders
+func (c *TxClient) checkHold() error {
+	if c.cancelOnDisconnect != nil && c.cancelOnDisconnect.Held() {
+		return ErrOrdersHeld
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package client
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/u20024804/lighter-ex/types/txtypes"
+)
+
+func newTestCancelOnDisconnect(t *testing.T, config CancelOnDisconnectConfig) (*TxClient, *CancelOnDisconnect, *cancelAllSender) {
+	t.Helper()
+	c, _ := newTestTxClient(t, 7, 2)
+	sender := &cancelAllSender{nonce: 10}
+	c.SetTxSender(sender)
+	p, err := c.NewCancelOnDisconnect(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c, p, sender
+}
+
+func TestCancelOnDisconnectGraceExpiry(t *testing.T) {
+	c, p, sender := newTestCancelOnDisconnect(t, CancelOnDisconnectConfig{GracePeriod: 20 * time.Millisecond})
+
+	p.OnDisconnected()
+	if !errors.Is(c.checkHold(), ErrOrdersHeld) {
+		t.Error("expected new orders held once disconnected")
+	}
+	// disconnecting again doesn't push the cancellation back
+	p.OnDisconnected()
+	waitFor(t, "cancel-all", func() bool { return len(sender.kinds()) > 0 })
+	time.Sleep(40 * time.Millisecond)
+	if kinds := sender.kinds(); len(kinds) != 1 || kinds[0] != txtypes.ImmediateCancelAll {
+		t.Errorf("expected a single immediate cancel-all, got %v", kinds)
+	}
+	if !p.Held() {
+		t.Error("expected new orders held until resynced")
+	}
+
+	p.OnResynced()
+	if err := c.checkHold(); err != nil {
+		t.Errorf("expected new orders released once resynced, got %v", err)
+	}
+}
+
+func TestCancelOnDisconnectRecovery(t *testing.T) {
+	c, p, sender := newTestCancelOnDisconnect(t, CancelOnDisconnectConfig{GracePeriod: 50 * time.Millisecond})
+
+	p.OnDisconnected()
+	time.Sleep(10 * time.Millisecond)
+	p.OnResynced()
+	time.Sleep(80 * time.Millisecond)
+	if kinds := sender.kinds(); len(kinds) != 0 {
+		t.Errorf("expected no cancellation when resynced within the grace period, got %v", kinds)
+	}
+	if err := c.checkHold(); err != nil {
+		t.Errorf("expected new orders released, got %v", err)
+	}
+
+	// the next disconnect gets a full grace period again
+	p.OnDisconnected()
+	waitFor(t, "cancel-all", func() bool { return len(sender.kinds()) == 1 })
+}
+
+func TestCancelOnDisconnectRetries(t *testing.T) {
+	var failures atomic.Int32
+	config := CancelOnDisconnectConfig{
+		RetryDelay:    5 * time.Millisecond,
+		MaxRetryDelay: 20 * time.Millisecond,
+		OnError:       func(error) { failures.Add(1) },
+	}
+
+	t.Run("until it succeeds", func(t *testing.T) {
+		failures.Store(0)
+		_, p, sender := newTestCancelOnDisconnect(t, config)
+		sender.failing.Store(true)
+		p.OnDisconnected()
+		waitFor(t, "failed cancellations", func() bool { return failures.Load() >= 3 })
+		sender.failing.Store(false)
+		waitFor(t, "cancel-all", func() bool { return len(sender.kinds()) > 0 })
+
+		failed := failures.Load()
+		time.Sleep(50 * time.Millisecond)
+		if kinds := sender.kinds(); len(kinds) != 1 || failures.Load() != failed {
+			t.Errorf("expected no retry once cancelled, got %v and %d more failures", kinds, failures.Load()-failed)
+		}
+	})
+
+	t.Run("until resynced", func(t *testing.T) {
+		failures.Store(0)
+		_, p, sender := newTestCancelOnDisconnect(t, config)
+		sender.failing.Store(true)
+		p.OnDisconnected()
+		waitFor(t, "failed cancellations", func() bool { return failures.Load() >= 2 })
+		p.OnResynced()
+
+		failed := failures.Load()
+		time.Sleep(50 * time.Millisecond)
+		// a retry in flight when resynced may still fail once
+		if got := failures.Load(); got > failed+1 {
+			t.Errorf("expected the retries to stop once resynced, got %d more failures", got-failed)
+		}
+		sender.failing.Store(false)
+		time.Sleep(30 * time.Millisecond)
+		if kinds := sender.kinds(); len(kinds) != 0 {
+			t.Errorf("expected no cancellation once resynced, got %v", kinds)
+		}
+	})
+}
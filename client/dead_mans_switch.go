@@ -0,0 +1,286 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/u20024804/lighter-ex/types"
+	"github.com/u20024804/lighter-ex/types/txtypes"
+)
+
+const (
+	minDeadMansSwitchTimeout = time.Duration(txtypes.MinOrderCancelAllPeriod) * time.Millisecond
+	maxDeadMansSwitchTimeout = time.Duration(txtypes.MaxOrderCancelAllPeriod) * time.Millisecond
+)
+
+// HealthCheck reports whether the process is still able to manage its orders
+type HealthCheck func(ctx context.Context) error
+
+// DeadMansSwitchConfig configures a DeadMansSwitch
+type DeadMansSwitchConfig struct {
+	// ApiKeyIndex & ApiKeyPrivateKey are the API key the renewals are signed with. Required, and it must not be the API key
+	// of the client: both fetch their nonces from Lighter, so sharing a key would make the renewals & the orders collide.
+	ApiKeyIndex      uint8
+	ApiKeyPrivateKey string
+	// Timeout is how long after the last renewal every order gets cancelled.
+	// It's bounded by MinOrderCancelAllPeriod and MaxOrderCancelAllPeriod, defaults to the minimum.
+	Timeout time.Duration
+	// RenewInterval is how often the scheduled cancel-all is pushed forward, defaults to Timeout / 3
+	RenewInterval time.Duration
+	// HealthChecks run before every renewal. If any fails, the switch is not renewed and fires at its deadline.
+	HealthChecks []HealthCheck
+	// OnError is called with renewal & health check errors. Optional.
+	OnError func(error)
+}
+
+// DeadMansSwitch keeps a scheduled cancel-all pending on the account and pushes it forward while the process is healthy.
+// If the process crashes, hangs or fails its health checks, the renewals stop and the exchange cancels every order.
+type DeadMansSwitch struct {
+	client *TxClient
+	config DeadMansSwitchConfig
+
+	mu          sync.Mutex
+	cancel      context.CancelFunc
+	done        chan struct{}
+	deadline    time.Time
+	lastRenewal time.Time
+	healthy     bool
+	// starting is closed once the Start in flight has armed the switch or failed to
+	starting chan struct{}
+}
+
+// NewDeadMansSwitch creates a switch for the account of this client, signed with the dedicated API key of the config
+// and sent with the TxSender of the client. Call Start to arm it.
+func (c *TxClient) NewDeadMansSwitch(config DeadMansSwitchConfig) (*DeadMansSwitch, error) {
+	if config.ApiKeyIndex == c.apiKeyIndex {
+		return nil, fmt.Errorf("dead man's switch needs a dedicated API key, %d is used by the client", config.ApiKeyIndex)
+	}
+	if config.Timeout == 0 {
+		config.Timeout = minDeadMansSwitchTimeout
+	}
+	if config.Timeout < minDeadMansSwitchTimeout || config.Timeout > maxDeadMansSwitchTimeout {
+		return nil, fmt.Errorf("dead man's switch timeout should be between %v and %v", minDeadMansSwitchTimeout, maxDeadMansSwitchTimeout)
+	}
+	if config.RenewInterval == 0 {
+		config.RenewInterval = config.Timeout / 3
+	}
+	if config.RenewInterval <= 0 || config.RenewInterval >= config.Timeout {
+		return nil, fmt.Errorf("dead man's switch renew interval should be positive and shorter than the timeout")
+	}
+	if c.sender == nil {
+		return nil, fmt.Errorf("no TxSender, can't send the scheduled cancel-all txs")
+	}
+
+	client, err := NewTxClient(c.apiClient, config.ApiKeyPrivateKey, c.accountIndex, config.ApiKeyIndex, c.chainId)
+	if err != nil {
+		return nil, fmt.Errorf("invalid dead man's switch API key: %w", err)
+	}
+	client.SetTxSender(c.sender)
+
+	return &DeadMansSwitch{
+		client: client,
+		config: config,
+	}, nil
+}
+
+// Start arms the switch and renews it in the background until Stop is called or ctx is done.
+// Cancelling ctx stops the renewals but leaves the switch armed, so the orders still get cancelled; use Stop on clean shutdown.
+// Start can be called again once the renewals stopped.
+func (d *DeadMansSwitch) Start(ctx context.Context) error {
+	d.mu.Lock()
+	if d.cancel != nil || d.starting != nil {
+		d.mu.Unlock()
+		return fmt.Errorf("dead man's switch already started")
+	}
+	starting := make(chan struct{})
+	d.starting = starting
+	d.mu.Unlock()
+
+	defer func() {
+		d.mu.Lock()
+		d.starting = nil
+		d.mu.Unlock()
+		close(starting)
+	}()
+
+	if err := d.runHealthChecks(ctx); err != nil {
+		return fmt.Errorf("not arming dead man's switch, health check failed: %w", err)
+	}
+	deadline, err := d.renew()
+	if err != nil {
+		return fmt.Errorf("failed to arm dead man's switch: %w", err)
+	}
+
+	runCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+
+	d.mu.Lock()
+	d.healthy = true
+	d.cancel = cancel
+	d.done = done
+	d.mu.Unlock()
+	go d.run(runCtx, done)
+
+	logger().Info("Dead man's switch armed, orders get cancelled unless renewed", LogKeyAccount, d.client.accountIndex, "deadline", deadline)
+	return nil
+}
+
+// Stop stops the renewals and aborts the pending scheduled cancel-all, leaving the orders in place.
+// A Start in flight is waited for, so what it arms is disarmed.
+func (d *DeadMansSwitch) Stop() error {
+	d.mu.Lock()
+	starting := d.starting
+	d.mu.Unlock()
+	if starting != nil {
+		<-starting
+	}
+
+	d.mu.Lock()
+	cancel, done := d.cancel, d.done
+	d.cancel, d.done = nil, nil
+	d.mu.Unlock()
+
+	if cancel != nil {
+		cancel()
+		<-done
+	}
+	// the renewals may have stopped with ctx, the cancel-all is still pending until its deadline
+	if d.Deadline().IsZero() {
+		return nil
+	}
+
+	if _, err := d.send(txtypes.AbortScheduledCancelAll, 0); err != nil {
+		return fmt.Errorf("failed to abort scheduled cancel-all: %w", err)
+	}
+
+	d.mu.Lock()
+	d.deadline = time.Time{}
+	d.mu.Unlock()
+	logger().Info("Dead man's switch disarmed", LogKeyAccount, d.client.accountIndex)
+	return nil
+}
+
+// Deadline returns when the orders get cancelled if the switch is not renewed. Zero if it's not armed.
+func (d *DeadMansSwitch) Deadline() time.Time {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.deadline
+}
+
+// LastRenewal returns when the switch was last pushed forward
+func (d *DeadMansSwitch) LastRenewal() time.Time {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.lastRenewal
+}
+
+// Healthy returns false while the health checks fail and the switch is left to fire
+func (d *DeadMansSwitch) Healthy() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.healthy
+}
+
+func (d *DeadMansSwitch) run(ctx context.Context, done chan struct{}) {
+	defer func() {
+		// stopped with the ctx of Start rather than by Stop: allow another Start
+		d.mu.Lock()
+		if d.done == done {
+			d.cancel, d.done = nil, nil
+		}
+		d.mu.Unlock()
+		close(done)
+	}()
+
+	ticker := time.NewTicker(d.config.RenewInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if err := d.runHealthChecks(ctx); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			d.setHealthy(false)
+			d.reportError(fmt.Errorf("health check failed, not renewing dead man's switch (fires at %v): %w", d.Deadline(), err))
+			continue
+		}
+
+		if _, err := d.renew(); err != nil {
+			d.reportError(fmt.Errorf("failed to renew dead man's switch (fires at %v): %w", d.Deadline(), err))
+			continue
+		}
+		d.setHealthy(true)
+	}
+}
+
+func (d *DeadMansSwitch) setHealthy(healthy bool) {
+	d.mu.Lock()
+	changed := d.healthy != healthy
+	d.healthy = healthy
+	d.mu.Unlock()
+
+	if changed && healthy {
+		logger().Info("Dead man's switch health checks recovered, renewing again", LogKeyAccount, d.client.accountIndex)
+	}
+}
+
+// renew schedules the cancel-all Timeout from now. The tx is sent without holding d.mu, only the result is stored under it.
+func (d *DeadMansSwitch) renew() (time.Time, error) {
+	deadline := time.Now().Add(d.config.Timeout)
+	if _, err := d.send(txtypes.ScheduledCancelAll, deadline.UnixMilli()); err != nil {
+		return time.Time{}, err
+	}
+
+	d.mu.Lock()
+	d.deadline = deadline
+	d.lastRenewal = time.Now()
+	d.mu.Unlock()
+	return deadline, nil
+}
+
+func (d *DeadMansSwitch) send(timeInForce uint8, t int64) (string, error) {
+	tx, err := d.client.GetCancelAllOrdersTransaction(&types.CancelAllOrdersTxReq{
+		TimeInForce: timeInForce,
+		Time:        t,
+	}, nil)
+	if err != nil {
+		return "", err
+	}
+	return d.client.SendTx(tx)
+}
+
+func (d *DeadMansSwitch) runHealthChecks(ctx context.Context) error {
+	var errs []error
+	for _, check := range d.config.HealthChecks {
+		if err := check(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (d *DeadMansSwitch) reportError(err error) {
+	logger().Error("Dead man's switch failed", LogKeyAccount, d.client.accountIndex, LogKeyError, err)
+	if d.config.OnError != nil {
+		d.config.OnError(err)
+	}
+}
+
+// StaleHealthCheck fails when lastUpdate is older than maxAge, e.g. with AccountState.LastUpdate to detect a dead stream
+func StaleHealthCheck(name string, lastUpdate func() time.Time, maxAge time.Duration) HealthCheck {
+	return func(ctx context.Context) error {
+		if age := time.Since(lastUpdate()); age > maxAge {
+			return fmt.Errorf("%s was last updated %v ago", name, age.Truncate(time.Second))
+		}
+		return nil
+	}
+}
@@ -0,0 +1,248 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/u20024804/lighter-ex/types/txtypes"
+)
+
+// cancelAllSender records the cancel-all txs sent from a background goroutine, failing them while failing is set
+type cancelAllSender struct {
+	mu      sync.Mutex
+	nonce   int64
+	sent    []*txtypes.L2CancelAllOrdersTxInfo
+	failing atomic.Bool
+}
+
+func (s *cancelAllSender) GetNextNonce(int64, uint8) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.nonce, nil
+}
+
+func (s *cancelAllSender) SendRawTx(tx txtypes.TxInfo) (string, error) {
+	if s.failing.Load() {
+		return "", errors.New("send failed")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sent = append(s.sent, tx.(*txtypes.L2CancelAllOrdersTxInfo))
+	s.nonce++
+	return tx.GetTxHash(), nil
+}
+
+// kinds returns the TimeInForce of the txs sent so far, e.g. ScheduledCancelAll or AbortScheduledCancelAll
+func (s *cancelAllSender) kinds() []uint8 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var kinds []uint8
+	for _, tx := range s.sent {
+		kinds = append(kinds, tx.TimeInForce)
+	}
+	return kinds
+}
+
+func newTestSwitch(t *testing.T, config DeadMansSwitchConfig) (*DeadMansSwitch, *cancelAllSender) {
+	t.Helper()
+	c, _ := newTestTxClient(t, 7, 2)
+	sender := &cancelAllSender{nonce: 10}
+	c.SetTxSender(sender)
+	config.ApiKeyIndex = 3
+	config.ApiKeyPrivateKey = strings.Repeat("02", 40)
+	if config.RenewInterval == 0 {
+		config.RenewInterval = 10 * time.Millisecond
+	}
+	d, err := c.NewDeadMansSwitch(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d, sender
+}
+
+func countKind(kinds []uint8, kind uint8) int {
+	n := 0
+	for _, k := range kinds {
+		if k == kind {
+			n++
+		}
+	}
+	return n
+}
+
+func TestDeadMansSwitchRenews(t *testing.T) {
+	d, sender := newTestSwitch(t, DeadMansSwitchConfig{})
+	start := time.Now()
+	if err := d.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Start(context.Background()); err == nil {
+		t.Error("expected a second Start to fail")
+	}
+	if deadline := d.Deadline(); deadline.Before(start.Add(minDeadMansSwitchTimeout)) {
+		t.Errorf("expected a deadline %v from now, got %v", minDeadMansSwitchTimeout, deadline.Sub(start))
+	}
+	armed := d.LastRenewal()
+	waitFor(t, "renewals", func() bool { return countKind(sender.kinds(), txtypes.ScheduledCancelAll) >= 3 })
+	if !d.LastRenewal().After(armed) || !d.Healthy() {
+		t.Errorf("expected a healthy switch renewed after %v, got %v", armed, d.LastRenewal())
+	}
+
+	if err := d.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	kinds := sender.kinds()
+	if kinds[len(kinds)-1] != txtypes.AbortScheduledCancelAll || countKind(kinds, txtypes.AbortScheduledCancelAll) != 1 {
+		t.Errorf("expected the renewals then a single abort, got %v", kinds)
+	}
+	if !d.Deadline().IsZero() {
+		t.Errorf("expected no deadline once stopped, got %v", d.Deadline())
+	}
+	time.Sleep(30 * time.Millisecond)
+	if after := sender.kinds(); len(after) != len(kinds) {
+		t.Errorf("expected no renewal after Stop, got %v", after[len(kinds):])
+	}
+	if err := d.Stop(); err != nil || len(sender.kinds()) != len(kinds) {
+		t.Errorf("expected a second Stop to do nothing, got %v", err)
+	}
+}
+
+func TestDeadMansSwitchHealthCheck(t *testing.T) {
+	var unhealthy atomic.Bool
+	var reported atomic.Int32
+	d, sender := newTestSwitch(t, DeadMansSwitchConfig{
+		HealthChecks: []HealthCheck{func(context.Context) error {
+			if unhealthy.Load() {
+				return errors.New("stream is stale")
+			}
+			return nil
+		}},
+		OnError: func(error) { reported.Add(1) },
+	})
+
+	unhealthy.Store(true)
+	if err := d.Start(context.Background()); err == nil {
+		t.Fatal("expected Start to refuse arming while unhealthy")
+	}
+	if len(sender.kinds()) != 0 || !d.Deadline().IsZero() {
+		t.Fatalf("expected nothing sent, got %v", sender.kinds())
+	}
+
+	unhealthy.Store(false)
+	if err := d.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer d.Stop()
+	unhealthy.Store(true)
+	waitFor(t, "unhealthy", func() bool { return !d.Healthy() && reported.Load() > 0 })
+	// no renewal while unhealthy: the switch is left to fire at its deadline
+	renewals := countKind(sender.kinds(), txtypes.ScheduledCancelAll)
+	deadline := d.Deadline()
+	time.Sleep(30 * time.Millisecond)
+	if got := countKind(sender.kinds(), txtypes.ScheduledCancelAll); got != renewals || !d.Deadline().Equal(deadline) {
+		t.Errorf("expected no renewal while unhealthy, got %d more", got-renewals)
+	}
+
+	unhealthy.Store(false)
+	waitFor(t, "recovery", func() bool { return d.Healthy() && d.Deadline().After(deadline) })
+
+	// a failed renewal is reported but doesn't stop the switch
+	sender.failing.Store(true)
+	failures := reported.Load()
+	waitFor(t, "renewal error", func() bool { return reported.Load() > failures })
+	sender.failing.Store(false)
+	renewed := d.LastRenewal()
+	waitFor(t, "renewal", func() bool { return d.LastRenewal().After(renewed) })
+}
+
+func TestDeadMansSwitchContextDone(t *testing.T) {
+	d, sender := newTestSwitch(t, DeadMansSwitchConfig{})
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := d.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	waitFor(t, "renewals to stop", func() bool {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		return d.cancel == nil
+	})
+	// the cancel-all stays pending, and the switch can be armed again
+	if d.Deadline().IsZero() || countKind(sender.kinds(), txtypes.AbortScheduledCancelAll) != 0 {
+		t.Errorf("expected the switch to stay armed, got %v", sender.kinds())
+	}
+	if err := d.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Stop aborts the pending cancel-all even once the renewals stopped with ctx
+	ctx, cancel = context.WithCancel(context.Background())
+	if err := d.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	waitFor(t, "renewals to stop", func() bool {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		return d.cancel == nil
+	})
+	if err := d.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if kinds := sender.kinds(); countKind(kinds, txtypes.AbortScheduledCancelAll) != 2 || !d.Deadline().IsZero() {
+		t.Errorf("expected 2 aborts, got %v", kinds)
+	}
+}
+
+func TestDeadMansSwitchStopDuringStart(t *testing.T) {
+	checking, release := make(chan struct{}), make(chan struct{})
+	var once sync.Once
+	d, sender := newTestSwitch(t, DeadMansSwitchConfig{
+		HealthChecks: []HealthCheck{func(context.Context) error {
+			once.Do(func() {
+				close(checking)
+				<-release
+			})
+			return nil
+		}},
+	})
+
+	started := make(chan error, 1)
+	go func() { started <- d.Start(context.Background()) }()
+	<-checking
+	stopped := make(chan error, 1)
+	go func() { stopped <- d.Stop() }()
+	select {
+	case err := <-stopped:
+		t.Fatalf("expected Stop to wait for the Start in flight, returned %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(release)
+
+	if err := <-started; err != nil {
+		t.Fatal(err)
+	}
+	if err := <-stopped; err != nil {
+		t.Fatal(err)
+	}
+	kinds := sender.kinds()
+	if len(kinds) == 0 || kinds[len(kinds)-1] != txtypes.AbortScheduledCancelAll {
+		t.Errorf("expected what Start armed to be aborted, got %v", kinds)
+	}
+	if !d.Deadline().IsZero() {
+		t.Errorf("expected the switch disarmed, got a deadline %v", d.Deadline())
+	}
+	// and disarmed for good: no renewal after Stop returned
+	time.Sleep(30 * time.Millisecond)
+	if after := sender.kinds(); len(after) != len(kinds) {
+		t.Errorf("expected no renewal after Stop, got %v", after[len(kinds):])
+	}
+}
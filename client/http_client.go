@@ -2,6 +2,7 @@ package client
 
 import (
 	"crypto/tls"
+	"log/slog"
 	"net"
 	"net/http"
 	"time"
@@ -30,6 +31,7 @@ type HTTPClient struct {
 	endpoint            string
 	channelName         string
 	fatFingerProtection bool
+	logger              *slog.Logger
 }
 
 func NewHTTPClient(baseUrl string) *HTTPClient {
@@ -47,3 +49,12 @@ func NewHTTPClient(baseUrl string) *HTTPClient {
 func (c *HTTPClient) SetFatFingerProtection(enabled bool) {
 	c.fatFingerProtection = enabled
 }
+
+// SetLogger replaces the logger set with SetLogger for this client. Use DiscardLogger to silence it.
+func (c *HTTPClient) SetLogger(l *slog.Logger) {
+	c.logger = l
+}
+
+func (c *HTTPClient) log() *slog.Logger {
+	return loggerOr(c.logger)
+}
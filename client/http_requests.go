@@ -5,11 +5,11 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/u20024804/lighter-ex/types/txtypes"
 )
@@ -25,7 +25,24 @@ func (c *HTTPClient) parseResultStatus(respBody []byte) error {
 	return nil
 }
 
-func (c *HTTPClient) getAndParseL2HTTPResponse(path string, params map[string]any, result interface{}) error {
+// responseCode returns the result code of a response, or its HTTP status when it has none
+func responseCode(status int, body []byte) int32 {
+	resultStatus := &ResultCode{}
+	if err := json.Unmarshal(body, resultStatus); err != nil || resultStatus.Code == 0 {
+		return int32(status)
+	}
+	return resultStatus.Code
+}
+
+func (c *HTTPClient) getAndParseL2HTTPResponse(path string, params map[string]any, result interface{}) (err error) {
+	start := time.Now()
+	defer func() {
+		metrics().HTTPRequest(path, time.Since(start), err)
+		if err != nil {
+			c.log().Debug("Request failed", "endpoint", path, LogKeyError, err)
+		}
+	}()
+
 	u, err := url.Parse(c.endpoint)
 	if err != nil {
 		return err
@@ -77,8 +94,15 @@ func (c *HTTPClient) GetApiKey(accountIndex int64, apiKeyIndex uint8) (*AccountA
 	return result, nil
 }
 
-func (c *HTTPClient) SendRawTx(tx txtypes.TxInfo) (string, error) {
+func (c *HTTPClient) SendRawTx(tx txtypes.TxInfo) (_ string, err error) {
 	txType := tx.GetTxType()
+	start := time.Now()
+	var code int32
+	defer func() {
+		metrics().HTTPRequest("api/v1/sendTx", time.Since(start), err)
+		metrics().TxSent(txType, code)
+	}()
+
 	txInfo, err := tx.GetTxInfo()
 	if err != nil {
 		return "", err
@@ -102,6 +126,7 @@ func (c *HTTPClient) SendRawTx(tx txtypes.TxInfo) (string, error) {
 	if err != nil {
 		return "", err
 	}
+	code = responseCode(resp.StatusCode, body)
 	if resp.StatusCode != http.StatusOK {
 		return "", errors.New(string(body))
 	}
@@ -117,7 +142,16 @@ func (c *HTTPClient) SendRawTx(tx txtypes.TxInfo) (string, error) {
 }
 
 // SendTxBatch sends multiple transactions in a batch using /api/v1/sendTxBatch endpoint
-func (c *HTTPClient) SendTxBatch(txTypes []int, txInfos []string) ([]string, error) {
+func (c *HTTPClient) SendTxBatch(txTypes []int, txInfos []string) (_ []string, err error) {
+	start := time.Now()
+	var code int32
+	defer func() {
+		metrics().HTTPRequest("api/v1/sendTxBatch", time.Since(start), err)
+		for _, txType := range txTypes {
+			metrics().TxSent(uint8(txType), code)
+		}
+	}()
+
 	// Convert slices to JSON strings as required by the API
 	txTypesJson, err := json.Marshal(txTypes)
 	if err != nil {
@@ -147,6 +181,7 @@ func (c *HTTPClient) SendTxBatch(txTypes []int, txInfos []string) ([]string, err
 	if err != nil {
 		return nil, err
 	}
+	code = responseCode(resp.StatusCode, body)
 
 	if resp.StatusCode != http.StatusOK {
 		return nil, errors.New(string(body))
@@ -196,9 +231,8 @@ func (c *HTTPClient) GetAccountByL1Address(l1Address string) (*AccountByL1Addres
 	}, result)
 	if err != nil {
 		return nil, err
-	} else {
-		log.Println("Detailed Account: ", result)
 	}
+	c.log().Debug("Got accounts by L1 address", "sub_accounts", len(result.SubAccounts))
 	return result, nil
 }
 
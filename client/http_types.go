@@ -1,5 +1,7 @@
 package client
 
+import "github.com/u20024804/lighter-ex/decimal"
+
 const (
 	CodeOK = 200
 )
@@ -51,7 +53,7 @@ type AccountInfo struct {
 	TotalOrderCount         int64                `json:"total_order_count,omitempty"`
 	TotalIsolatedOrderCount int64                `json:"total_isolated_order_count,omitempty"`
 	PendingOrderCount       int64                `json:"pending_order_count,omitempty"`
-	AvailableBalance        string               `json:"available_balance,omitempty"`
+	AvailableBalance        decimal.Decimal      `json:"available_balance,omitempty"`
 	Status                  uint8                `json:"status,omitempty"`
 	CreatedAt               int64                `json:"created_at,omitempty"`
 	LastActiveAt            int64                `json:"last_active_at,omitempty"`
@@ -59,14 +61,14 @@ type AccountInfo struct {
 }
 
 type AccountMarketStats struct {
-	MarketId       uint8  `json:"market_id,omitempty"`
-	OpenOrderCount int64  `json:"open_order_count,omitempty"`
-	Sign           int8   `json:"sign,omitempty"`
-	Position       string `json:"position,omitempty"`
-	AvgEntryPrice  string `json:"avg_entry_price,omitempty"`
-	PositionValue  string `json:"position_value,omitempty"`
-	UnrealizedPnl  string `json:"unrealized_pnl,omitempty"`
-	RealizedPnl    string `json:"realized_pnl,omitempty"`
+	MarketId       uint8           `json:"market_id,omitempty"`
+	OpenOrderCount int64           `json:"open_order_count,omitempty"`
+	Sign           int8            `json:"sign,omitempty"`
+	Position       decimal.Decimal `json:"position,omitempty"`
+	AvgEntryPrice  decimal.Decimal `json:"avg_entry_price,omitempty"`
+	PositionValue  decimal.Decimal `json:"position_value,omitempty"`
+	UnrealizedPnl  decimal.Decimal `json:"unrealized_pnl,omitempty"`
+	RealizedPnl    decimal.Decimal `json:"realized_pnl,omitempty"`
 }
 
 type DetailedAccountsResponse struct {
@@ -76,17 +78,17 @@ type DetailedAccountsResponse struct {
 }
 
 type SubAccount struct {
-	Code                    int    `json:"code"`
-	AccountType             int    `json:"account_type"`
-	Index                   int64  `json:"index"`
-	L1Address               string `json:"l1_address"`
-	CancelAllTime           int    `json:"cancel_all_time"`
-	TotalOrderCount         int    `json:"total_order_count"`
-	TotalIsolatedOrderCount int    `json:"total_isolated_order_count"`
-	PendingOrderCount       int    `json:"pending_order_count"`
-	AvailableBalance        string `json:"available_balance"`
-	Status                  int    `json:"status"`
-	Collateral              string `json:"collateral"`
+	Code                    int             `json:"code"`
+	AccountType             int             `json:"account_type"`
+	Index                   int64           `json:"index"`
+	L1Address               string          `json:"l1_address"`
+	CancelAllTime           int             `json:"cancel_all_time"`
+	TotalOrderCount         int             `json:"total_order_count"`
+	TotalIsolatedOrderCount int             `json:"total_isolated_order_count"`
+	PendingOrderCount       int             `json:"pending_order_count"`
+	AvailableBalance        decimal.Decimal `json:"available_balance"`
+	Status                  int             `json:"status"`
+	Collateral              decimal.Decimal `json:"collateral"`
 }
 
 type AccountByL1AddressResponse struct {
@@ -96,50 +98,50 @@ type AccountByL1AddressResponse struct {
 }
 
 type Position struct {
-	MarketId               uint8  `json:"market_id"`
-	Symbol                 string `json:"symbol"`
-	InitialMarginFraction  string `json:"initial_margin_fraction"`
-	OpenOrderCount         int    `json:"open_order_count"`
-	PendingOrderCount      int    `json:"pending_order_count"`
-	PositionTiedOrderCount int    `json:"position_tied_order_count"`
-	Sign                   int    `json:"sign"`
-	Position               string `json:"position"`
-	AvgEntryPrice          string `json:"avg_entry_price"`
-	PositionValue          string `json:"position_value"`
-	UnrealizedPnl          string `json:"unrealized_pnl"`
-	RealizedPnl            string `json:"realized_pnl"`
-	LiquidationPrice       string `json:"liquidation_price"`
-	MarginMode             int    `json:"margin_mode"`
-	AllocatedMargin        string `json:"allocated_margin"`
+	MarketId               uint8           `json:"market_id"`
+	Symbol                 string          `json:"symbol"`
+	InitialMarginFraction  decimal.Decimal `json:"initial_margin_fraction"`
+	OpenOrderCount         int             `json:"open_order_count"`
+	PendingOrderCount      int             `json:"pending_order_count"`
+	PositionTiedOrderCount int             `json:"position_tied_order_count"`
+	Sign                   int             `json:"sign"`
+	Position               decimal.Decimal `json:"position"`
+	AvgEntryPrice          decimal.Decimal `json:"avg_entry_price"`
+	PositionValue          decimal.Decimal `json:"position_value"`
+	UnrealizedPnl          decimal.Decimal `json:"unrealized_pnl"`
+	RealizedPnl            decimal.Decimal `json:"realized_pnl"`
+	LiquidationPrice       decimal.Decimal `json:"liquidation_price"`
+	MarginMode             int             `json:"margin_mode"`
+	AllocatedMargin        decimal.Decimal `json:"allocated_margin"`
 }
 
 type Share struct {
-	PublicPoolIndex int64  `json:"public_pool_index"`
-	SharesAmount    int    `json:"shares_amount"`
-	EntryUsdc       string `json:"entry_usdc"`
+	PublicPoolIndex int64           `json:"public_pool_index"`
+	SharesAmount    int             `json:"shares_amount"`
+	EntryUsdc       decimal.Decimal `json:"entry_usdc"`
 }
 
 type Account struct {
-	Code                     int        `json:"code"`
-	AccountType              int        `json:"account_type"`
-	Index                    int64      `json:"index"`
-	L1Address                string     `json:"l1_address"`
-	CancelAllTime            int        `json:"cancel_all_time"`
-	TotalOrderCount          int        `json:"total_order_count"`
-	TotalIsolatedOrderCount  int        `json:"total_isolated_order_count"`
-	PendingOrderCount        int        `json:"pending_order_count"`
-	AvailableBalance         string     `json:"available_balance"`
-	Status                   int        `json:"status"`
-	Collateral               string     `json:"collateral"`
-	AccountIndex             int64      `json:"account_index"`
-	Name                     string     `json:"name"`
-	Description              string     `json:"description"`
-	CanInvite                bool       `json:"can_invite"`
-	ReferralPointsPercentage string     `json:"referral_points_percentage"`
-	Positions                []Position `json:"positions"`
-	TotalAssetValue          string     `json:"total_asset_value"`
-	CrossAssetValue          string     `json:"cross_asset_value"`
-	Shares                   []Share    `json:"shares"`
+	Code                     int             `json:"code"`
+	AccountType              int             `json:"account_type"`
+	Index                    int64           `json:"index"`
+	L1Address                string          `json:"l1_address"`
+	CancelAllTime            int             `json:"cancel_all_time"`
+	TotalOrderCount          int             `json:"total_order_count"`
+	TotalIsolatedOrderCount  int             `json:"total_isolated_order_count"`
+	PendingOrderCount        int             `json:"pending_order_count"`
+	AvailableBalance         decimal.Decimal `json:"available_balance"`
+	Status                   int             `json:"status"`
+	Collateral               decimal.Decimal `json:"collateral"`
+	AccountIndex             int64           `json:"account_index"`
+	Name                     string          `json:"name"`
+	Description              string          `json:"description"`
+	CanInvite                bool            `json:"can_invite"`
+	ReferralPointsPercentage decimal.Decimal `json:"referral_points_percentage"`
+	Positions                []Position      `json:"positions"`
+	TotalAssetValue          decimal.Decimal `json:"total_asset_value"`
+	CrossAssetValue          decimal.Decimal `json:"cross_asset_value"`
+	Shares                   []Share         `json:"shares"`
 }
 
 type AccountResponse struct {
@@ -154,17 +156,17 @@ type OrderBookResponse struct {
 }
 
 type OrderBook struct {
-	Symbol                 string `json:"symbol"`
-	MarketId               uint8  `json:"market_id"`
-	Status                 string `json:"status"`
-	TakerFee               string `json:"taker_fee"`
-	MakerFee               string `json:"maker_fee"`
-	LiquidationFee         string `json:"liquidation_fee"`
-	MinBaseAmount          string `json:"min_base_amount"`
-	MinQuoteAmount         string `json:"min_quote_amount"`
-	SupportedSizeDecimals  uint8  `json:"supported_size_decimals"`
-	SupportedPriceDecimals uint8  `json:"supported_price_decimals"`
-	SupportedQuoteDecimals uint8  `json:"supported_quote_decimals"`
+	Symbol                 string          `json:"symbol"`
+	MarketId               uint8           `json:"market_id"`
+	Status                 string          `json:"status"`
+	TakerFee               decimal.Decimal `json:"taker_fee"`
+	MakerFee               decimal.Decimal `json:"maker_fee"`
+	LiquidationFee         decimal.Decimal `json:"liquidation_fee"`
+	MinBaseAmount          decimal.Decimal `json:"min_base_amount"`
+	MinQuoteAmount         decimal.Decimal `json:"min_quote_amount"`
+	SupportedSizeDecimals  uint8           `json:"supported_size_decimals"`
+	SupportedPriceDecimals uint8           `json:"supported_price_decimals"`
+	SupportedQuoteDecimals uint8           `json:"supported_quote_decimals"`
 }
 
 type OrderBookData struct {
@@ -182,11 +184,11 @@ type OrderBookDetail struct {
 	Symbol                       string                 `json:"symbol"`
 	MarketId                     uint8                  `json:"market_id"`
 	Status                       string                 `json:"status"`
-	TakerFee                     string                 `json:"taker_fee"`
-	MakerFee                     string                 `json:"maker_fee"`
-	LiquidationFee               string                 `json:"liquidation_fee"`
-	MinBaseAmount                string                 `json:"min_base_amount"`
-	MinQuoteAmount               string                 `json:"min_quote_amount"`
+	TakerFee                     decimal.Decimal        `json:"taker_fee"`
+	MakerFee                     decimal.Decimal        `json:"maker_fee"`
+	LiquidationFee               decimal.Decimal        `json:"liquidation_fee"`
+	MinBaseAmount                decimal.Decimal        `json:"min_base_amount"`
+	MinQuoteAmount               decimal.Decimal        `json:"min_quote_amount"`
 	SupportedSizeDecimals        uint8                  `json:"supported_size_decimals"`
 	SupportedPriceDecimals       uint8                  `json:"supported_price_decimals"`
 	SupportedQuoteDecimals       uint8                  `json:"supported_quote_decimals"`
@@ -197,14 +199,14 @@ type OrderBookDetail struct {
 	MinInitialMarginFraction     uint32                 `json:"min_initial_margin_fraction"`
 	MaintenanceMarginFraction    uint32                 `json:"maintenance_margin_fraction"`
 	CloseoutMarginFraction       uint32                 `json:"closeout_margin_fraction"`
-	LastTradePrice               float64                `json:"last_trade_price"`
+	LastTradePrice               decimal.Decimal        `json:"last_trade_price"`
 	DailyTradesCount             uint32                 `json:"daily_trades_count"`
-	DailyBaseTokenVolume         float64                `json:"daily_base_token_volume"`
-	DailyQuoteTokenVolume        float64                `json:"daily_quote_token_volume"`
-	DailyPriceLow                float64                `json:"daily_price_low"`
-	DailyPriceHigh               float64                `json:"daily_price_high"`
-	DailyPriceChange             float64                `json:"daily_price_change"`
-	OpenInterest                 float64                `json:"open_interest"`
+	DailyBaseTokenVolume         decimal.Decimal        `json:"daily_base_token_volume"`
+	DailyQuoteTokenVolume        decimal.Decimal        `json:"daily_quote_token_volume"`
+	DailyPriceLow                decimal.Decimal        `json:"daily_price_low"`
+	DailyPriceHigh               decimal.Decimal        `json:"daily_price_high"`
+	DailyPriceChange             decimal.Decimal        `json:"daily_price_change"`
+	OpenInterest                 decimal.Decimal        `json:"open_interest"`
 	DailyChart                   map[string]interface{} `json:"daily_chart"`
 }
 
@@ -214,8 +216,8 @@ type OrderBookDetailsResponse struct {
 }
 
 type PriceLevel struct {
-	Price    string `json:"price,omitempty"`
-	Quantity string `json:"quantity,omitempty"`
+	Price    decimal.Decimal `json:"price,omitempty"`
+	Quantity decimal.Decimal `json:"quantity,omitempty"`
 }
 
 type OrdersResponse struct {
@@ -224,10 +226,10 @@ type OrdersResponse struct {
 }
 
 type FundingRate struct {
-	MarketId int     `json:"market_id"`
-	Exchange string  `json:"exchange"`
-	Symbol   string  `json:"symbol"`
-	Rate     float64 `json:"rate"`
+	MarketId int             `json:"market_id"`
+	Exchange string          `json:"exchange"`
+	Symbol   string          `json:"symbol"`
+	Rate     decimal.Decimal `json:"rate"`
 }
 
 type FundingRatesResponse struct {
@@ -247,19 +249,19 @@ type Order struct {
 	OwnerAccountIndex int64 `json:"owner_account_index,omitempty"`
 
 	// Order details - using human-readable formats from API
-	InitialBaseAmount   string `json:"initial_base_amount,omitempty"`   // e.g. "0.100"
-	Price               string `json:"price,omitempty"`                 // e.g. "203.577"
-	RemainingBaseAmount string `json:"remaining_base_amount,omitempty"` // e.g. "0.100"
-	FilledBaseAmount    string `json:"filled_base_amount,omitempty"`    // e.g. "0.000"
-	FilledQuoteAmount   string `json:"filled_quote_amount,omitempty"`   // e.g. "0.000000"
+	InitialBaseAmount   decimal.Decimal `json:"initial_base_amount,omitempty"`   // e.g. "0.100"
+	Price               decimal.Decimal `json:"price,omitempty"`                 // e.g. "203.577"
+	RemainingBaseAmount decimal.Decimal `json:"remaining_base_amount,omitempty"` // e.g. "0.100"
+	FilledBaseAmount    decimal.Decimal `json:"filled_base_amount,omitempty"`    // e.g. "0.000"
+	FilledQuoteAmount   decimal.Decimal `json:"filled_quote_amount,omitempty"`   // e.g. "0.000000"
 
 	// Order properties
-	IsAsk        bool   `json:"is_ask,omitempty"`
-	Side         string `json:"side,omitempty"`          // "buy", "sell", or ""
-	Type         string `json:"type,omitempty"`          // "limit", "market", etc.
-	TimeInForce  string `json:"time_in_force,omitempty"` // "post-only", "good-till-time", etc.
-	ReduceOnly   bool   `json:"reduce_only,omitempty"`
-	TriggerPrice string `json:"trigger_price,omitempty"`
+	IsAsk        bool            `json:"is_ask,omitempty"`
+	Side         string          `json:"side,omitempty"`          // "buy", "sell", or ""
+	Type         string          `json:"type,omitempty"`          // "limit", "market", etc.
+	TimeInForce  string          `json:"time_in_force,omitempty"` // "post-only", "good-till-time", etc.
+	ReduceOnly   bool            `json:"reduce_only,omitempty"`
+	TriggerPrice decimal.Decimal `json:"trigger_price,omitempty"`
 
 	// Status and timing
 	Status      string `json:"status,omitempty"`    // "open", "filled", "cancelled", etc.
@@ -288,19 +290,19 @@ type Order struct {
 
 // Candlestick represents a single candlestick data point
 type Candlestick struct {
-	MarketId            uint8  `json:"market_id"`
-	Symbol              string `json:"symbol"`
-	Resolution          string `json:"resolution"`             // "1", "5", "15", "60", "240", "1D"
-	Timestamp           int64  `json:"timestamp"`              // Unix timestamp
-	Open                string `json:"open"`                   // Opening price
-	High                string `json:"high"`                   // Highest price
-	Low                 string `json:"low"`                    // Lowest price
-	Close               string `json:"close"`                  // Closing price
-	Volume              string `json:"volume"`                 // Trading volume
-	QuoteVolume         string `json:"quote_volume"`           // Quote volume
-	TradesCount         int32  `json:"trades_count"`           // Number of trades
-	TakerBuyVolume      string `json:"taker_buy_volume"`       // Taker buy volume
-	TakerBuyQuoteVolume string `json:"taker_buy_quote_volume"` // Taker buy quote volume
+	MarketId            uint8           `json:"market_id"`
+	Symbol              string          `json:"symbol"`
+	Resolution          string          `json:"resolution"`             // "1", "5", "15", "60", "240", "1D"
+	Timestamp           int64           `json:"timestamp"`              // Unix timestamp
+	Open                decimal.Decimal `json:"open"`                   // Opening price
+	High                decimal.Decimal `json:"high"`                   // Highest price
+	Low                 decimal.Decimal `json:"low"`                    // Lowest price
+	Close               decimal.Decimal `json:"close"`                  // Closing price
+	Volume              decimal.Decimal `json:"volume"`                 // Trading volume
+	QuoteVolume         decimal.Decimal `json:"quote_volume"`           // Quote volume
+	TradesCount         int32           `json:"trades_count"`           // Number of trades
+	TakerBuyVolume      decimal.Decimal `json:"taker_buy_volume"`       // Taker buy volume
+	TakerBuyQuoteVolume decimal.Decimal `json:"taker_buy_quote_volume"` // Taker buy quote volume
 }
 
 // CandlesticksResponse represents the response for candlesticks API
@@ -311,14 +313,14 @@ type CandlesticksResponse struct {
 
 // FundingHistory represents historical funding data
 type FundingHistory struct {
-	MarketId        uint8  `json:"market_id"`
-	Symbol          string `json:"symbol"`
-	Timestamp       int64  `json:"timestamp"`         // Unix timestamp
-	FundingRate     string `json:"funding_rate"`      // Funding rate
-	IndexPrice      string `json:"index_price"`       // Index price at funding time
-	MarkPrice       string `json:"mark_price"`        // Mark price at funding time
-	PremiumRate     string `json:"premium_rate"`      // Premium rate
-	NextFundingTime int64  `json:"next_funding_time"` // Next funding timestamp
+	MarketId        uint8           `json:"market_id"`
+	Symbol          string          `json:"symbol"`
+	Timestamp       int64           `json:"timestamp"`         // Unix timestamp
+	FundingRate     decimal.Decimal `json:"funding_rate"`      // Funding rate
+	IndexPrice      decimal.Decimal `json:"index_price"`       // Index price at funding time
+	MarkPrice       decimal.Decimal `json:"mark_price"`        // Mark price at funding time
+	PremiumRate     decimal.Decimal `json:"premium_rate"`      // Premium rate
+	NextFundingTime int64           `json:"next_funding_time"` // Next funding timestamp
 }
 
 // FundingsResponse represents the response for fundings history API
@@ -329,17 +331,17 @@ type FundingsResponse struct {
 
 // Trade represents a single trade
 type Trade struct {
-	TradeId       int64  `json:"trade_id"`
-	TxHash        string `json:"tx_hash"`
-	MarketId      uint8  `json:"market_id"`
-	Symbol        string `json:"symbol"`
-	Price         string `json:"price"`
-	Size          string `json:"size"`
-	QuoteQuantity string `json:"quote_quantity"`
-	Side          string `json:"side"` // "buy" or "sell"
-	IsMakerAsk    bool   `json:"is_maker_ask"`
-	Timestamp     int64  `json:"timestamp"`
-	BlockHeight   int64  `json:"block_height"`
+	TradeId       int64           `json:"trade_id"`
+	TxHash        string          `json:"tx_hash"`
+	MarketId      uint8           `json:"market_id"`
+	Symbol        string          `json:"symbol"`
+	Price         decimal.Decimal `json:"price"`
+	Size          decimal.Decimal `json:"size"`
+	QuoteQuantity decimal.Decimal `json:"quote_quantity"`
+	Side          string          `json:"side"` // "buy" or "sell"
+	IsMakerAsk    bool            `json:"is_maker_ask"`
+	Timestamp     int64           `json:"timestamp"`
+	BlockHeight   int64           `json:"block_height"`
 
 	// Optional account info for user trades
 	MakerAccountIndex int64  `json:"maker_account_index,omitempty"`
@@ -362,14 +364,14 @@ type RecentTradesResponse struct {
 
 // ExchangeStats represents exchange-wide statistics
 type ExchangeStats struct {
-	TotalVolume24h      string `json:"total_volume_24h"`      // 24h total volume
-	TotalTrades24h      int64  `json:"total_trades_24h"`      // 24h total trades count
-	TotalUsers          int64  `json:"total_users"`           // Total registered users
-	ActiveUsers24h      int64  `json:"active_users_24h"`      // Active users in 24h
-	TotalMarkets        int32  `json:"total_markets"`         // Total number of markets
-	ActiveMarkets       int32  `json:"active_markets"`        // Currently active markets
-	SystemStatus        string `json:"system_status"`         // "normal", "maintenance", etc
-	LastUpdateTimestamp int64  `json:"last_update_timestamp"` // Last update time
+	TotalVolume24h      decimal.Decimal `json:"total_volume_24h"`      // 24h total volume
+	TotalTrades24h      int64           `json:"total_trades_24h"`      // 24h total trades count
+	TotalUsers          int64           `json:"total_users"`           // Total registered users
+	ActiveUsers24h      int64           `json:"active_users_24h"`      // Active users in 24h
+	TotalMarkets        int32           `json:"total_markets"`         // Total number of markets
+	ActiveMarkets       int32           `json:"active_markets"`        // Currently active markets
+	SystemStatus        string          `json:"system_status"`         // "normal", "maintenance", etc
+	LastUpdateTimestamp int64           `json:"last_update_timestamp"` // Last update time
 }
 
 // ExchangeStatsResponse represents the response for exchange stats API
@@ -403,19 +405,19 @@ type ExportResponse struct {
 
 // AccountLimits represents account trading limits and restrictions
 type AccountLimits struct {
-	AccountIndex       int64    `json:"account_index"`
-	MaxDailyTrades     int32    `json:"max_daily_trades"`
-	MaxOrderCount      int32    `json:"max_order_count"`
-	MaxPositionSize    string   `json:"max_position_size"`   // In base currency
-	MaxOrderSize       string   `json:"max_order_size"`      // In base currency
-	MaxNotionalValue   string   `json:"max_notional_value"`  // In quote currency
-	WithdrawalLimit    string   `json:"withdrawal_limit"`    // Daily withdrawal limit
-	DepositLimit       string   `json:"deposit_limit"`       // Daily deposit limit
-	TierLevel          int32    `json:"tier_level"`          // Account tier (0=basic, 1=verified, etc)
-	RequiresKyc        bool     `json:"requires_kyc"`        // Whether KYC is required
-	RestrictedMarkets  []uint8  `json:"restricted_markets"`  // Markets user cannot trade
-	RestrictedFeatures []string `json:"restricted_features"` // Features not available
-	LastUpdated        int64    `json:"last_updated"`        // When limits were last updated
+	AccountIndex       int64           `json:"account_index"`
+	MaxDailyTrades     int32           `json:"max_daily_trades"`
+	MaxOrderCount      int32           `json:"max_order_count"`
+	MaxPositionSize    decimal.Decimal `json:"max_position_size"`   // In base currency
+	MaxOrderSize       decimal.Decimal `json:"max_order_size"`      // In base currency
+	MaxNotionalValue   decimal.Decimal `json:"max_notional_value"`  // In quote currency
+	WithdrawalLimit    decimal.Decimal `json:"withdrawal_limit"`    // Daily withdrawal limit
+	DepositLimit       decimal.Decimal `json:"deposit_limit"`       // Daily deposit limit
+	TierLevel          int32           `json:"tier_level"`          // Account tier (0=basic, 1=verified, etc)
+	RequiresKyc        bool            `json:"requires_kyc"`        // Whether KYC is required
+	RestrictedMarkets  []uint8         `json:"restricted_markets"`  // Markets user cannot trade
+	RestrictedFeatures []string        `json:"restricted_features"` // Features not available
+	LastUpdated        int64           `json:"last_updated"`        // When limits were last updated
 }
 
 // AccountLimitsResponse represents the response for account limits API
@@ -431,8 +433,8 @@ type AccountMetadata struct {
 	CreatedAt         int64                  `json:"created_at"`
 	LastActiveAt      int64                  `json:"last_active_at"`
 	TotalTradeCount   int64                  `json:"total_trade_count"`
-	TotalVolume       string                 `json:"total_volume"`
-	AverageTradeSize  string                 `json:"average_trade_size"`
+	TotalVolume       decimal.Decimal        `json:"total_volume"`
+	AverageTradeSize  decimal.Decimal        `json:"average_trade_size"`
 	PreferredMarkets  []uint8                `json:"preferred_markets"`
 	ReferralCode      string                 `json:"referral_code,omitempty"`
 	ReferredBy        string                 `json:"referred_by,omitempty"`
@@ -450,20 +452,20 @@ type AccountMetadataResponse struct {
 
 // Liquidation represents a single liquidation event
 type Liquidation struct {
-	LiquidationId    int64  `json:"liquidation_id"`
-	AccountIndex     int64  `json:"account_index"`
-	MarketId         uint8  `json:"market_id"`
-	Symbol           string `json:"symbol"`
-	LiquidatedSize   string `json:"liquidated_size"`   // Size liquidated
-	LiquidationPrice string `json:"liquidation_price"` // Price at liquidation
-	MarkPrice        string `json:"mark_price"`        // Mark price at liquidation
-	UnrealizedPnL    string `json:"unrealized_pnl"`    // PnL realized from liquidation
-	Fee              string `json:"fee"`               // Liquidation fee charged
-	LiquidationType  string `json:"liquidation_type"`  // "auto", "forced", "insurance"
-	TriggerReason    string `json:"trigger_reason"`    // "margin_call", "adl", etc
-	Timestamp        int64  `json:"timestamp"`         // When liquidation occurred
-	BlockHeight      int64  `json:"block_height"`
-	TxHash           string `json:"tx_hash"`
+	LiquidationId    int64           `json:"liquidation_id"`
+	AccountIndex     int64           `json:"account_index"`
+	MarketId         uint8           `json:"market_id"`
+	Symbol           string          `json:"symbol"`
+	LiquidatedSize   decimal.Decimal `json:"liquidated_size"`   // Size liquidated
+	LiquidationPrice decimal.Decimal `json:"liquidation_price"` // Price at liquidation
+	MarkPrice        decimal.Decimal `json:"mark_price"`        // Mark price at liquidation
+	UnrealizedPnL    decimal.Decimal `json:"unrealized_pnl"`    // PnL realized from liquidation
+	Fee              decimal.Decimal `json:"fee"`               // Liquidation fee charged
+	LiquidationType  string          `json:"liquidation_type"`  // "auto", "forced", "insurance"
+	TriggerReason    string          `json:"trigger_reason"`    // "margin_call", "adl", etc
+	Timestamp        int64           `json:"timestamp"`         // When liquidation occurred
+	BlockHeight      int64           `json:"block_height"`
+	TxHash           string          `json:"tx_hash"`
 }
 
 // LiquidationsResponse represents the response for liquidations API
@@ -474,19 +476,19 @@ type LiquidationsResponse struct {
 
 // PnLEntry represents a single profit/loss record
 type PnLEntry struct {
-	AccountIndex   int64  `json:"account_index"`
-	MarketId       uint8  `json:"market_id"`
-	Symbol         string `json:"symbol"`
-	Date           string `json:"date"`            // YYYY-MM-DD format
-	RealizedPnL    string `json:"realized_pnl"`    // Daily realized PnL
-	UnrealizedPnL  string `json:"unrealized_pnl"`  // End of day unrealized PnL
-	TradingFees    string `json:"trading_fees"`    // Total fees paid
-	FundingFees    string `json:"funding_fees"`    // Total funding fees
-	NetPnL         string `json:"net_pnl"`         // Net PnL (realized - fees)
-	OpeningBalance string `json:"opening_balance"` // Balance at start of day
-	ClosingBalance string `json:"closing_balance"` // Balance at end of day
-	TradesCount    int32  `json:"trades_count"`    // Number of trades
-	Volume         string `json:"volume"`          // Total trading volume
+	AccountIndex   int64           `json:"account_index"`
+	MarketId       uint8           `json:"market_id"`
+	Symbol         string          `json:"symbol"`
+	Date           string          `json:"date"`            // YYYY-MM-DD format
+	RealizedPnL    decimal.Decimal `json:"realized_pnl"`    // Daily realized PnL
+	UnrealizedPnL  decimal.Decimal `json:"unrealized_pnl"`  // End of day unrealized PnL
+	TradingFees    decimal.Decimal `json:"trading_fees"`    // Total fees paid
+	FundingFees    decimal.Decimal `json:"funding_fees"`    // Total funding fees
+	NetPnL         decimal.Decimal `json:"net_pnl"`         // Net PnL (realized - fees)
+	OpeningBalance decimal.Decimal `json:"opening_balance"` // Balance at start of day
+	ClosingBalance decimal.Decimal `json:"closing_balance"` // Balance at end of day
+	TradesCount    int32           `json:"trades_count"`    // Number of trades
+	Volume         decimal.Decimal `json:"volume"`          // Total trading volume
 }
 
 // PnLResponse represents the response for PnL history API
@@ -497,16 +499,16 @@ type PnLResponse struct {
 
 // PositionFunding represents funding fee for a position
 type PositionFunding struct {
-	AccountIndex     int64  `json:"account_index"`
-	MarketId         uint8  `json:"market_id"`
-	Symbol           string `json:"symbol"`
-	PositionSize     string `json:"position_size"`     // Size of position when funding was applied
-	FundingRate      string `json:"funding_rate"`      // Funding rate applied
-	FundingFee       string `json:"funding_fee"`       // Fee paid (negative) or received (positive)
-	IndexPrice       string `json:"index_price"`       // Index price at funding time
-	MarkPrice        string `json:"mark_price"`        // Mark price at funding time
-	FundingTimestamp int64  `json:"funding_timestamp"` // When funding was applied
-	BlockHeight      int64  `json:"block_height"`
+	AccountIndex     int64           `json:"account_index"`
+	MarketId         uint8           `json:"market_id"`
+	Symbol           string          `json:"symbol"`
+	PositionSize     decimal.Decimal `json:"position_size"`     // Size of position when funding was applied
+	FundingRate      decimal.Decimal `json:"funding_rate"`      // Funding rate applied
+	FundingFee       decimal.Decimal `json:"funding_fee"`       // Fee paid (negative) or received (positive)
+	IndexPrice       decimal.Decimal `json:"index_price"`       // Index price at funding time
+	MarkPrice        decimal.Decimal `json:"mark_price"`        // Mark price at funding time
+	FundingTimestamp int64           `json:"funding_timestamp"` // When funding was applied
+	BlockHeight      int64           `json:"block_height"`
 }
 
 // PositionFundingResponse represents the response for position funding API
@@ -517,21 +519,21 @@ type PositionFundingResponse struct {
 
 // PublicPool represents information about a public liquidity pool
 type PublicPool struct {
-	PoolIndex     int64  `json:"pool_index"`
-	Symbol        string `json:"symbol"`
-	Name          string `json:"name"`
-	Description   string `json:"description"`
-	TotalShares   string `json:"total_shares"`   // Total shares outstanding
-	TotalValue    string `json:"total_value"`    // Total value in USDC
-	SharePrice    string `json:"share_price"`    // Current price per share
-	DailyReturn   string `json:"daily_return"`   // 24h return percentage
-	WeeklyReturn  string `json:"weekly_return"`  // 7d return percentage
-	MonthlyReturn string `json:"monthly_return"` // 30d return percentage
-	CreatedAt     int64  `json:"created_at"`
-	Manager       string `json:"manager"`        // Pool manager address
-	Fee           string `json:"fee"`            // Management fee percentage
-	MinInvestment string `json:"min_investment"` // Minimum investment
-	Status        string `json:"status"`         // "active", "closed", "paused"
+	PoolIndex     int64           `json:"pool_index"`
+	Symbol        string          `json:"symbol"`
+	Name          string          `json:"name"`
+	Description   string          `json:"description"`
+	TotalShares   decimal.Decimal `json:"total_shares"`   // Total shares outstanding
+	TotalValue    decimal.Decimal `json:"total_value"`    // Total value in USDC
+	SharePrice    decimal.Decimal `json:"share_price"`    // Current price per share
+	DailyReturn   decimal.Decimal `json:"daily_return"`   // 24h return percentage
+	WeeklyReturn  decimal.Decimal `json:"weekly_return"`  // 7d return percentage
+	MonthlyReturn decimal.Decimal `json:"monthly_return"` // 30d return percentage
+	CreatedAt     int64           `json:"created_at"`
+	Manager       string          `json:"manager"`        // Pool manager address
+	Fee           decimal.Decimal `json:"fee"`            // Management fee percentage
+	MinInvestment decimal.Decimal `json:"min_investment"` // Minimum investment
+	Status        string          `json:"status"`         // "active", "closed", "paused"
 }
 
 // PublicPoolsResponse represents the response for public pools API
@@ -588,7 +590,7 @@ type Transaction struct {
 	Status       string                 `json:"status"` // "pending", "confirmed", "failed"
 	BlockHeight  int64                  `json:"block_height,omitempty"`
 	Timestamp    int64                  `json:"timestamp"`
-	GasFee       string                 `json:"gas_fee,omitempty"`
+	GasFee       decimal.Decimal        `json:"gas_fee,omitempty"`
 	TxData       map[string]interface{} `json:"tx_data,omitempty"` // Transaction specific data
 }
 
@@ -605,7 +607,7 @@ type BlockTx struct {
 	TxTypeName  string                 `json:"tx_type_name"`
 	TxIndex     int32                  `json:"tx_index"` // Position in block
 	Status      string                 `json:"status"`
-	GasFee      string                 `json:"gas_fee"`
+	GasFee      decimal.Decimal        `json:"gas_fee"`
 	FromAccount int64                  `json:"from_account"`
 	ToAccount   int64                  `json:"to_account,omitempty"`
 	TxData      map[string]interface{} `json:"tx_data"`
@@ -628,19 +630,19 @@ type BlockTxsResponse struct {
 
 // DepositHistoryItem represents a single deposit record
 type DepositHistoryItem struct {
-	DepositId     int64  `json:"deposit_id"`
-	AccountIndex  int64  `json:"account_index"`
-	L1TxHash      string `json:"l1_tx_hash"` // L1 transaction hash
-	L2TxHash      string `json:"l2_tx_hash"` // L2 transaction hash
-	Amount        string `json:"amount"`     // Deposit amount in USDC
-	Status        string `json:"status"`     // "pending", "confirmed", "failed"
-	L1BlockHeight int64  `json:"l1_block_height"`
-	L2BlockHeight int64  `json:"l2_block_height"`
-	CreatedAt     int64  `json:"created_at"`             // When deposit was initiated
-	ConfirmedAt   int64  `json:"confirmed_at,omitempty"` // When deposit was confirmed
-	Fee           string `json:"fee,omitempty"`          // Bridge fee
-	FromAddress   string `json:"from_address"`           // L1 source address
-	ToAddress     string `json:"to_address"`             // L2 destination address
+	DepositId     int64           `json:"deposit_id"`
+	AccountIndex  int64           `json:"account_index"`
+	L1TxHash      string          `json:"l1_tx_hash"` // L1 transaction hash
+	L2TxHash      string          `json:"l2_tx_hash"` // L2 transaction hash
+	Amount        decimal.Decimal `json:"amount"`     // Deposit amount in USDC
+	Status        string          `json:"status"`     // "pending", "confirmed", "failed"
+	L1BlockHeight int64           `json:"l1_block_height"`
+	L2BlockHeight int64           `json:"l2_block_height"`
+	CreatedAt     int64           `json:"created_at"`             // When deposit was initiated
+	ConfirmedAt   int64           `json:"confirmed_at,omitempty"` // When deposit was confirmed
+	Fee           decimal.Decimal `json:"fee,omitempty"`          // Bridge fee
+	FromAddress   string          `json:"from_address"`           // L1 source address
+	ToAddress     string          `json:"to_address"`             // L2 destination address
 }
 
 // DepositHistoryResponse represents the response for deposit history API
@@ -651,17 +653,17 @@ type DepositHistoryResponse struct {
 
 // TransferHistoryItem represents a single transfer record
 type TransferHistoryItem struct {
-	TransferId   int64  `json:"transfer_id"`
-	FromAccount  int64  `json:"from_account"`
-	ToAccount    int64  `json:"to_account"`
-	Amount       string `json:"amount"` // Transfer amount
-	Fee          string `json:"fee"`    // Transfer fee
-	TxHash       string `json:"tx_hash"`
-	Status       string `json:"status"` // "pending", "confirmed", "failed"
-	BlockHeight  int64  `json:"block_height"`
-	Timestamp    int64  `json:"timestamp"`
-	TransferType string `json:"transfer_type"`   // "internal", "external"
-	Notes        string `json:"notes,omitempty"` // Optional transfer notes
+	TransferId   int64           `json:"transfer_id"`
+	FromAccount  int64           `json:"from_account"`
+	ToAccount    int64           `json:"to_account"`
+	Amount       decimal.Decimal `json:"amount"` // Transfer amount
+	Fee          decimal.Decimal `json:"fee"`    // Transfer fee
+	TxHash       string          `json:"tx_hash"`
+	Status       string          `json:"status"` // "pending", "confirmed", "failed"
+	BlockHeight  int64           `json:"block_height"`
+	Timestamp    int64           `json:"timestamp"`
+	TransferType string          `json:"transfer_type"`   // "internal", "external"
+	Notes        string          `json:"notes,omitempty"` // Optional transfer notes
 }
 
 // TransferHistoryResponse represents the response for transfer history API
@@ -672,20 +674,20 @@ type TransferHistoryResponse struct {
 
 // WithdrawHistoryItem represents a single withdrawal record
 type WithdrawHistoryItem struct {
-	WithdrawId    int64  `json:"withdraw_id"`
-	AccountIndex  int64  `json:"account_index"`
-	L2TxHash      string `json:"l2_tx_hash"` // L2 transaction hash
-	L1TxHash      string `json:"l1_tx_hash"` // L1 transaction hash (when completed)
-	Amount        string `json:"amount"`     // Withdrawal amount
-	Fee           string `json:"fee"`        // Withdrawal fee
-	Status        string `json:"status"`     // "pending", "processing", "completed", "failed"
-	L2BlockHeight int64  `json:"l2_block_height"`
-	L1BlockHeight int64  `json:"l1_block_height,omitempty"`
-	RequestedAt   int64  `json:"requested_at"`           // When withdrawal was requested
-	ProcessedAt   int64  `json:"processed_at,omitempty"` // When withdrawal was processed
-	CompletedAt   int64  `json:"completed_at,omitempty"` // When withdrawal completed on L1
-	ToAddress     string `json:"to_address"`             // L1 destination address
-	WithdrawDelay int32  `json:"withdraw_delay"`         // Delay in seconds before processing
+	WithdrawId    int64           `json:"withdraw_id"`
+	AccountIndex  int64           `json:"account_index"`
+	L2TxHash      string          `json:"l2_tx_hash"` // L2 transaction hash
+	L1TxHash      string          `json:"l1_tx_hash"` // L1 transaction hash (when completed)
+	Amount        decimal.Decimal `json:"amount"`     // Withdrawal amount
+	Fee           decimal.Decimal `json:"fee"`        // Withdrawal fee
+	Status        string          `json:"status"`     // "pending", "processing", "completed", "failed"
+	L2BlockHeight int64           `json:"l2_block_height"`
+	L1BlockHeight int64           `json:"l1_block_height,omitempty"`
+	RequestedAt   int64           `json:"requested_at"`           // When withdrawal was requested
+	ProcessedAt   int64           `json:"processed_at,omitempty"` // When withdrawal was processed
+	CompletedAt   int64           `json:"completed_at,omitempty"` // When withdrawal completed on L1
+	ToAddress     string          `json:"to_address"`             // L1 destination address
+	WithdrawDelay int32           `json:"withdraw_delay"`         // Delay in seconds before processing
 }
 
 // WithdrawHistoryResponse represents the response for withdraw history API
@@ -3,6 +3,8 @@ package client
 // LighterWebsocketClient is the main entry point, similar to bybit.NewWebsocketClient()
 type LighterWebsocketClient struct {
 	config *WSConfig
+	// pool is shared by the services, nil for a connection per service
+	pool *WSPool
 }
 
 // NewLighterWebsocketClient creates a new Lighter WebSocket client
@@ -18,14 +20,27 @@ func (c *LighterWebsocketClient) SetConfig(config *WSConfig) *LighterWebsocketCl
 	return c
 }
 
+// SetPool makes the services returned by Public & Private share the connections of pool, instead of a connection
+// per service. The pool is not closed with the services.
+func (c *LighterWebsocketClient) SetPool(pool *WSPool) *LighterWebsocketClient {
+	c.pool = pool
+	return c
+}
+
 // Public returns the public market data service
 func (c *LighterWebsocketClient) Public() (LighterWebsocketPublicServiceI, error) {
+	if c.pool != nil {
+		return NewLighterWebsocketPublicServiceWithPool(c.pool), nil
+	}
 	service := NewLighterWebsocketPublicService(c.config)
 	return service, nil
 }
 
 // Private returns the private account data service
 func (c *LighterWebsocketClient) Private(tokenGen TokenGenerator) (LighterWebsocketPrivateServiceI, error) {
+	if c.pool != nil {
+		return NewLighterWebsocketPrivateServiceWithPool(c.pool, tokenGen), nil
+	}
 	service := NewLighterWebsocketPrivateService(c.config, tokenGen)
 	return service, nil
 }
\ No newline at end of file
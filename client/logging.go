@@ -0,0 +1,150 @@
+package client
+
+import (
+	"context"
+	"log/slog"
+	"regexp"
+	"sync/atomic"
+)
+
+// Log attribute keys
+const (
+	LogKeyChannel = "channel"
+	LogKeyMarket  = "market"
+	LogKeyAccount = "account"
+	LogKeyURL     = "url"
+	LogKeyType    = "type"
+	LogKeyError   = "err"
+)
+
+type loggerHolder struct {
+	*slog.Logger
+}
+
+var currentLogger atomic.Pointer[loggerHolder]
+
+// SetLogger sets the logger of the SDK. It's used by the components without a logger of their own, see
+// WSConfig.Logger and HTTPClient.SetLogger. nil restores the default, slog.Default(). Use DiscardLogger to silence
+// the SDK. Auth tokens are redacted from every record, whatever the logger.
+func SetLogger(l *slog.Logger) {
+	if l == nil {
+		currentLogger.Store(nil)
+		return
+	}
+	currentLogger.Store(&loggerHolder{redactLogger(l)})
+}
+
+func logger() *slog.Logger {
+	if l := currentLogger.Load(); l != nil {
+		return l.Logger
+	}
+	return redactLogger(slog.Default())
+}
+
+// Logger returns the logger of the SDK, for the packages built on it to log like the client does
+func Logger() *slog.Logger {
+	return logger()
+}
+
+// loggerOr returns l with redaction, or the logger of the SDK if l is nil
+func loggerOr(l *slog.Logger) *slog.Logger {
+	if l == nil {
+		return logger()
+	}
+	return redactLogger(l)
+}
+
+// DiscardLogger returns a logger dropping every record
+func DiscardLogger() *slog.Logger {
+	return slog.New(discardHandler{})
+}
+
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (d discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return d }
+func (d discardHandler) WithGroup(string) slog.Handler           { return d }
+
+const redacted = "[REDACTED]"
+
+// sensitiveLogKeys are the attributes whose values are never logged
+var sensitiveLogKeys = map[string]bool{
+	"auth":          true,
+	"auth_token":    true,
+	"authToken":     true,
+	"authorization": true,
+	"Authorization": true,
+	"token":         true,
+}
+
+// authPattern matches the auth tokens in URLs & headers, e.g. in the errors of net/http
+var authPattern = regexp.MustCompile(`((?i:auth|authorization|token)=|(?i:bearer) )[^&\s"']+`)
+
+func redactString(s string) string {
+	return authPattern.ReplaceAllString(s, "${1}"+redacted)
+}
+
+// NewRedactingHandler wraps h to redact auth tokens: the values of the auth attributes are replaced, and tokens are
+// removed from the messages, strings & errors of the records. The loggers of the SDK are always wrapped.
+func NewRedactingHandler(h slog.Handler) slog.Handler {
+	if _, ok := h.(redactingHandler); ok {
+		return h
+	}
+	return redactingHandler{h}
+}
+
+func redactLogger(l *slog.Logger) *slog.Logger {
+	if _, ok := l.Handler().(redactingHandler); ok {
+		return l
+	}
+	return slog.New(redactingHandler{l.Handler()})
+}
+
+type redactingHandler struct {
+	slog.Handler
+}
+
+func (h redactingHandler) Handle(ctx context.Context, r slog.Record) error {
+	r2 := slog.NewRecord(r.Time, r.Level, redactString(r.Message), r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		r2.AddAttrs(redactAttr(a))
+		return true
+	})
+	return h.Handler.Handle(ctx, r2)
+}
+
+func (h redactingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redactedAttrs := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		redactedAttrs[i] = redactAttr(a)
+	}
+	return redactingHandler{h.Handler.WithAttrs(redactedAttrs)}
+}
+
+func (h redactingHandler) WithGroup(name string) slog.Handler {
+	return redactingHandler{h.Handler.WithGroup(name)}
+}
+
+func redactAttr(a slog.Attr) slog.Attr {
+	if sensitiveLogKeys[a.Key] {
+		return slog.String(a.Key, redacted)
+	}
+	a.Value = a.Value.Resolve()
+	switch a.Value.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, redactString(a.Value.String()))
+	case slog.KindGroup:
+		group := a.Value.Group()
+		redactedGroup := make([]slog.Attr, len(group))
+		for i, ga := range group {
+			redactedGroup[i] = redactAttr(ga)
+		}
+		return slog.Attr{Key: a.Key, Value: slog.GroupValue(redactedGroup...)}
+	case slog.KindAny:
+		if err, ok := a.Value.Any().(error); ok {
+			return slog.String(a.Key, redactString(err.Error()))
+		}
+	}
+	return a
+}
@@ -0,0 +1,101 @@
+package client
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+// secretValue logs a URL with a token once resolved
+type secretValue struct{}
+
+func (secretValue) LogValue() slog.Value {
+	return slog.StringValue("wss://example/stream?auth=secret")
+}
+
+func TestRedactingHandler(t *testing.T) {
+	tests := []struct {
+		name string
+		log  func(l *slog.Logger)
+		want []string
+	}{
+		{"message", func(l *slog.Logger) {
+			l.Info("GET https://example/api/v1/orders?auth=secret&market_id=1")
+		}, []string{`msg="GET https://example/api/v1/orders?auth=[REDACTED]&market_id=1"`}},
+		{"auth attrs", func(l *slog.Logger) {
+			l.Info("sent", "auth", "secret", "authToken", "secret", "Authorization", "secret", "token", 42)
+		}, []string{"auth=[REDACTED] authToken=[REDACTED] Authorization=[REDACTED] token=[REDACTED]"}},
+		{"url attr", func(l *slog.Logger) {
+			l.Info("connecting", LogKeyURL, "wss://example/stream?readonly=true&token=secret")
+		}, []string{`url="wss://example/stream?readonly=true&token=[REDACTED]"`}},
+		{"auth query, any case", func(l *slog.Logger) {
+			l.Info("connecting", "query", "AUTH=secret&Authorization=secret")
+		}, []string{`query="AUTH=[REDACTED]&Authorization=[REDACTED]"`}},
+		{"bearer", func(l *slog.Logger) {
+			l.Info("header", "value", "Bearer secret", "lower", "bearer secret")
+		}, []string{`value="Bearer [REDACTED]" lower="bearer [REDACTED]"`}},
+		{"error", func(l *slog.Logger) {
+			l.Error("failed", LogKeyError, fmt.Errorf("request failed: %w", errors.New(`Get "https://example/api?auth=secret": EOF`)))
+		}, []string{`err="request failed: Get \"https://example/api?auth=[REDACTED]\": EOF"`}},
+		{"group", func(l *slog.Logger) {
+			l.Info("request", slog.Group("http", "auth", "secret", slog.Group("headers", "Authorization", "Bearer secret"), "path", "/api?token=secret"))
+		}, []string{`http.auth=[REDACTED] http.headers.Authorization=[REDACTED] http.path="/api?token=[REDACTED]"`}},
+		{"log valuer", func(l *slog.Logger) {
+			l.Info("connecting", "target", secretValue{})
+		}, []string{`target="wss://example/stream?auth=[REDACTED]"`}},
+		{"with attrs", func(l *slog.Logger) {
+			l.With("auth", "secret", LogKeyURL, "https://example?auth=secret").Info("request")
+		}, []string{`auth=[REDACTED] url="https://example?auth=[REDACTED]"`}},
+		{"with group", func(l *slog.Logger) {
+			l.WithGroup("ws").With("token", "secret").Info("request", "header", "Bearer secret")
+		}, []string{`ws.token=[REDACTED] ws.header="Bearer [REDACTED]"`}},
+		{"untouched", func(l *slog.Logger) {
+			l.Info("order sent", LogKeyAccount, 7, "path", "api/v1/sendTx", "author", "me")
+		}, []string{`msg="order sent" account=7 path=api/v1/sendTx author=me`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			l := slog.New(NewRedactingHandler(slog.NewTextHandler(&buf, &slog.HandlerOptions{
+				ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+					if a.Key == slog.TimeKey && len(groups) == 0 {
+						return slog.Attr{}
+					}
+					return a
+				},
+			})))
+			tt.log(l)
+			out := buf.String()
+			if strings.Contains(out, "secret") {
+				t.Errorf("expected the secret redacted, got %s", out)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(out, want) {
+					t.Errorf("expected %s in %s", want, out)
+				}
+			}
+		})
+	}
+}
+
+func TestRedactingHandlerWrapsOnce(t *testing.T) {
+	h := NewRedactingHandler(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	if _, ok := NewRedactingHandler(h).(redactingHandler).Handler.(redactingHandler); ok {
+		t.Error("expected a redacting handler not to be wrapped again")
+	}
+	l := redactLogger(slog.New(h))
+	if _, ok := l.Handler().(redactingHandler).Handler.(redactingHandler); ok {
+		t.Error("expected a redacting logger not to be wrapped again")
+	}
+
+	var buf bytes.Buffer
+	SetLogger(slog.New(slog.NewTextHandler(&buf, nil)))
+	defer SetLogger(nil)
+	Logger().Info("connecting", "auth", "secret")
+	if strings.Contains(buf.String(), "secret") || !strings.Contains(buf.String(), "auth=[REDACTED]") {
+		t.Errorf("expected the logger of the SDK to redact, got %s", buf.String())
+	}
+}
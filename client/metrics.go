@@ -0,0 +1,106 @@
+package client
+
+import (
+	"context"
+	"hash"
+	"sync/atomic"
+	"time"
+
+	"github.com/u20024804/lighter-ex/signer"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Metrics receives the measurements of the SDK. Implementations must be safe for concurrent use and should not block.
+// Embed NopMetrics to only implement some of the hooks. See the metrics/prom package for a Prometheus adapter.
+type Metrics interface {
+	// HTTPRequest is called after every REST call, endpoint is the path of the API, e.g. "api/v1/account"
+	HTTPRequest(endpoint string, duration time.Duration, err error)
+	// TxSent is called for every tx sent with sendTx or sendTxBatch, with the result code returned by Lighter.
+	// The code is the HTTP status when the response has no result code, and 0 when no response was received.
+	TxSent(txType uint8, code int32)
+	// SignDuration is called after every tx signature
+	SignDuration(duration time.Duration)
+	// NonceFetch is called when a TxClient fetches the next nonce of an (account, apiKey) pair from its TxSender,
+	// which it does for every tx or batch sent without an explicit nonce
+	NonceFetch(accountIndex int64, apiKeyIndex uint8)
+
+	// WSConnectionState is called when a WebSocket connection is established or lost
+	WSConnectionState(url string, connected bool)
+	// WSReconnect is called when a WSClient connects again after it was disconnected
+	WSReconnect(url string)
+	// WSMessage is called for every received message, msgType is the type of the message, e.g. "update/order_book"
+	WSMessage(msgType string)
+	// WSHandlerDuration is called after the handlers of a message ran
+	WSHandlerDuration(msgType string, duration time.Duration)
+	// OrderBookGap is called when an order book update doesn't follow the previous message of the market:
+	// it arrives before the snapshot, or its offset isn't after the previous one
+	OrderBookGap(marketId uint8)
+	// OrderBookResync is called when a new snapshot replaces the order book of a market
+	OrderBookResync(marketId uint8)
+	// WSQueueDepth is called when the number of messages pending in the queue of a subscription changes,
+	// subscription is the channel of the messages, e.g. "order_book:1"
+	WSQueueDepth(subscription string, depth int)
+	// WSQueueOverflow is called when a message arrives while the queue of a subscription is full, before the
+	// policy applies
+	WSQueueOverflow(subscription string, policy QueuePolicy)
+	// WSStale is called when a subscription goes stale or recovers, see StalenessConfig. stale tells whether the
+	// subscription is stale for any reason, it may still lag once it's no longer quiet.
+	WSStale(subscription string, stale bool)
+}
+
+// NopMetrics ignores every measurement, it's the default
+type NopMetrics struct{}
+
+func (NopMetrics) HTTPRequest(string, time.Duration, error) {}
+func (NopMetrics) TxSent(uint8, int32)                      {}
+func (NopMetrics) SignDuration(time.Duration)               {}
+func (NopMetrics) NonceFetch(int64, uint8)                  {}
+func (NopMetrics) WSConnectionState(string, bool)           {}
+func (NopMetrics) WSReconnect(string)                       {}
+func (NopMetrics) WSMessage(string)                         {}
+func (NopMetrics) WSHandlerDuration(string, time.Duration)  {}
+func (NopMetrics) OrderBookGap(uint8)                       {}
+func (NopMetrics) OrderBookResync(uint8)                    {}
+func (NopMetrics) WSQueueDepth(string, int)                 {}
+func (NopMetrics) WSQueueOverflow(string, QueuePolicy)      {}
+func (NopMetrics) WSStale(string, bool)                     {}
+
+type metricsHolder struct {
+	Metrics
+}
+
+var currentMetrics atomic.Pointer[metricsHolder]
+
+func init() {
+	currentMetrics.Store(&metricsHolder{NopMetrics{}})
+}
+
+// SetMetrics sets where the SDK reports its measurements, nil disables them
+func SetMetrics(m Metrics) {
+	if m == nil {
+		m = NopMetrics{}
+	}
+	currentMetrics.Store(&metricsHolder{m})
+}
+
+func metrics() Metrics {
+	return currentMetrics.Load().Metrics
+}
+
+// instrumentedKeyManager reports the duration of the signatures, and traces them under ctx if set
+type instrumentedKeyManager struct {
+	signer.KeyManager
+	ctx context.Context
+}
+
+func (k instrumentedKeyManager) Sign(message []byte, hFunc hash.Hash) ([]byte, error) {
+	span := noopSpan
+	if k.ctx != nil {
+		_, span = tracer().Start(k.ctx, "lighter.Sign", trace.WithAttributes(signAttribute(message)))
+	}
+	start := time.Now()
+	sig, err := k.KeyManager.Sign(message, hFunc)
+	metrics().SignDuration(time.Since(start))
+	endSpan(span, err)
+	return sig, err
+}
@@ -0,0 +1,110 @@
+package client
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/u20024804/lighter-ex/decimal"
+)
+
+// MidPriceSource provides the current mid price of a market, in human readable units
+type MidPriceSource interface {
+	MidPrice(marketId uint8) (decimal.Decimal, bool)
+}
+
+// bookSide maps the normalized prices of a side to their sizes: a level may be sent as 1.50 then removed as 1.5
+type bookSide map[decimal.Decimal]decimal.Decimal
+
+func (b bookSide) apply(levels []PriceLevel) {
+	for _, level := range levels {
+		price := level.Price.Normalize()
+		if level.Quantity.IsZero() {
+			delete(b, price)
+			continue
+		}
+		b[price] = level.Quantity
+	}
+}
+
+func (b bookSide) best(isAsk bool) (decimal.Decimal, bool) {
+	var best decimal.Decimal
+	found := false
+	for price := range b {
+		if !found || (isAsk && price.LessThan(best)) || (!isAsk && price.GreaterThan(best)) {
+			best, found = price, true
+		}
+	}
+	return best, found
+}
+
+type trackedBook struct {
+	bids      bookSide
+	asks      bookSide
+	updatedAt time.Time
+}
+
+// MidPriceTracker keeps the order books of a few markets from the order_book stream and serves their mid price
+type MidPriceTracker struct {
+	// MaxAge makes MidPrice report no price when the book of the market wasn't updated for that long. 0 disables the check.
+	MaxAge time.Duration
+
+	mu    sync.RWMutex
+	books map[uint8]*trackedBook
+}
+
+func NewMidPriceTracker() *MidPriceTracker {
+	return &MidPriceTracker{books: make(map[uint8]*trackedBook)}
+}
+
+// Attach subscribes to the order book of a market. The returned function unsubscribes.
+func (t *MidPriceTracker) Attach(service LighterWebsocketPublicServiceI, marketId uint8) (func() error, error) {
+	return service.SubscribeOrderBook(LighterOrderBookParamKey{MarketId: marketId}, func(resp LighterOrderBookResponse) error {
+		t.Apply(resp)
+		return nil
+	})
+}
+
+// Apply merges an order book message into the tracked book of its market
+func (t *MidPriceTracker) Apply(resp LighterOrderBookResponse) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	book, ok := t.books[resp.MarketId]
+	if !ok || resp.IsSnapshot {
+		book = &trackedBook{bids: make(bookSide), asks: make(bookSide)}
+		t.books[resp.MarketId] = book
+	}
+	book.bids.apply(resp.Bids)
+	book.asks.apply(resp.Asks)
+	book.updatedAt = time.Now()
+}
+
+// BestBidAsk returns the top of the book of a market
+func (t *MidPriceTracker) BestBidAsk(marketId uint8) (bid, ask decimal.Decimal, err error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	book, ok := t.books[marketId]
+	if !ok {
+		return bid, ask, fmt.Errorf("no order book for market %d", marketId)
+	}
+	if t.MaxAge > 0 && time.Since(book.updatedAt) > t.MaxAge {
+		return bid, ask, fmt.Errorf("order book of market %d is older than %v", marketId, t.MaxAge)
+	}
+	bid, hasBid := book.bids.best(false)
+	ask, hasAsk := book.asks.best(true)
+	if !hasBid || !hasAsk {
+		return bid, ask, fmt.Errorf("order book of market %d is one sided", marketId)
+	}
+	return bid, ask, nil
+}
+
+// MidPrice implements MidPriceSource
+func (t *MidPriceTracker) MidPrice(marketId uint8) (decimal.Decimal, bool) {
+	bid, ask, err := t.BestBidAsk(marketId)
+	if err != nil {
+		return decimal.Zero, false
+	}
+	return bid.Add(ask).Div(decimal.NewFromInt(2)), true
+}
@@ -0,0 +1,40 @@
+package client
+
+import (
+	"testing"
+
+	"github.com/u20024804/lighter-ex/decimal"
+)
+
+func TestMidPriceTrackerScales(t *testing.T) {
+	level := func(price, size string) PriceLevel {
+		return PriceLevel{Price: decimal.MustParse(price), Quantity: decimal.MustParse(size)}
+	}
+	tracker := NewMidPriceTracker()
+	tracker.Apply(LighterOrderBookResponse{MarketId: 1, IsSnapshot: true,
+		Bids: []PriceLevel{level("99.50", "1"), level("99", "2")},
+		Asks: []PriceLevel{level("100.5", "1"), level("101", "2")},
+	})
+	// the levels are removed & resized whatever the number of decimals of their price
+	tracker.Apply(LighterOrderBookResponse{MarketId: 1,
+		Bids: []PriceLevel{level("99.5", "0")},
+		Asks: []PriceLevel{level("100.500", "0"), level("101.0", "3")},
+	})
+
+	bid, ask, err := tracker.BestBidAsk(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bid.Equal(decimal.MustParse("99")) || !ask.Equal(decimal.MustParse("101")) {
+		t.Errorf("expected 99 / 101, got %v / %v", bid, ask)
+	}
+	if mid, ok := tracker.MidPrice(1); !ok || !mid.Equal(decimal.MustParse("100")) {
+		t.Errorf("expected a mid price of 100, got %v, %v", mid, ok)
+	}
+	tracker.mu.RLock()
+	asks := len(tracker.books[1].asks)
+	tracker.mu.RUnlock()
+	if asks != 1 {
+		t.Errorf("expected a single ask level, got %d", asks)
+	}
+}
@@ -0,0 +1,387 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/u20024804/lighter-ex/types"
+	"github.com/u20024804/lighter-ex/types/txtypes"
+)
+
+// QuoteLevel is a price level of a quote ladder, in the integer units of the txs
+type QuoteLevel struct {
+	IsAsk bool
+	Price uint32
+	Size  int64
+}
+
+// QuoterConfig configures a Quoter
+type QuoterConfig struct {
+	// Market is the quoted market, its decimals turn the order stream amounts into integer units
+	Market OrderBookDetail
+	// PostOnly makes the quotes post-only instead of good-till-time
+	PostOnly bool
+	// Expiry is the lifetime of new quotes, defaults to 24 hours
+	Expiry time.Duration
+	// FirstClientOrderIndex is the client order index of the first quote, defaults to the current time in ms
+	// so indexes don't collide across restarts
+	FirstClientOrderIndex int64
+}
+
+// Quote is an order of a Quoter. OrderIndex is 0 until the order was seen on the order stream.
+type Quote struct {
+	QuoteLevel
+	ClientOrderIndex int64
+	OrderIndex       int64
+}
+
+// index returns the index to modify or cancel the quote with
+func (q *Quote) index() int64 {
+	if q.OrderIndex != 0 {
+		return q.OrderIndex
+	}
+	return q.ClientOrderIndex
+}
+
+// Quoter keeps a ladder of orders on a market. Quote diffs the desired ladder against the live orders and sends
+// the fewest modify, cancel & create txs as one batch. The live orders are tracked from the order stream, see Attach.
+type Quoter struct {
+	client *TxClient
+	config QuoterConfig
+
+	// sendMu serializes Quote calls, mu guards the quotes
+	sendMu          sync.Mutex
+	mu              sync.Mutex
+	quotes          map[int64]*Quote // by client order index
+	nextClientIndex int64
+}
+
+// NewQuoter creates a quoter that sends its txs with this client
+func (c *TxClient) NewQuoter(config QuoterConfig) (*Quoter, error) {
+	if config.Expiry == 0 {
+		config.Expiry = 24 * time.Hour
+	}
+	if config.Expiry < time.Duration(txtypes.MinOrderExpiryPeriod)*time.Millisecond ||
+		config.Expiry > time.Duration(txtypes.MaxOrderExpiryPeriod)*time.Millisecond {
+		return nil, fmt.Errorf("quote expiry should be between %v and %v",
+			time.Duration(txtypes.MinOrderExpiryPeriod)*time.Millisecond, time.Duration(txtypes.MaxOrderExpiryPeriod)*time.Millisecond)
+	}
+	if config.FirstClientOrderIndex == 0 {
+		config.FirstClientOrderIndex = time.Now().UnixMilli()
+	}
+	if config.FirstClientOrderIndex < txtypes.MinClientOrderIndex || config.FirstClientOrderIndex > txtypes.MaxClientOrderIndex {
+		return nil, fmt.Errorf("first client order index should be between %d and %d", txtypes.MinClientOrderIndex, txtypes.MaxClientOrderIndex)
+	}
+	if c.sender == nil {
+		return nil, fmt.Errorf("no TxSender, can't send the quote txs")
+	}
+
+	return &Quoter{
+		client:          c,
+		config:          config,
+		quotes:          make(map[int64]*Quote),
+		nextClientIndex: config.FirstClientOrderIndex,
+	}, nil
+}
+
+// Attach subscribes to the order stream of the account to track the quotes. The returned function unsubscribes.
+func (q *Quoter) Attach(service LighterWebsocketPrivateServiceI) (func() error, error) {
+	param := LighterOrdersParamKey{AccountId: q.client.accountIndex, MarketIds: []uint8{q.config.Market.MarketId}}
+	return service.SubscribeOrders(param, func(resp LighterOrdersResponse) error {
+		return q.Apply(resp)
+	})
+}
+
+// Apply updates a quote from an order stream message. Orders that aren't quotes of this Quoter are ignored.
+func (q *Quoter) Apply(resp LighterOrdersResponse) error {
+	if resp.MarketId != q.config.Market.MarketId {
+		return nil
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	quote, ok := q.quotes[resp.ClientOrderIndex]
+	if !ok {
+		return nil
+	}
+	if resp.Status != "open" && resp.Status != "pending" {
+		delete(q.quotes, resp.ClientOrderIndex)
+		return nil
+	}
+
+	orderIndex, err := strconv.ParseInt(resp.OrderId, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid order id %q: %w", resp.OrderId, err)
+	}
+	price, err := resp.Price.Ticks(q.config.Market.PriceDecimals)
+	if err != nil {
+		return fmt.Errorf("invalid price of order %d: %w", orderIndex, err)
+	}
+	size, err := resp.BaseQuantity.Sub(resp.FilledQuantity).Ticks(q.config.Market.SizeDecimals)
+	if err != nil {
+		return fmt.Errorf("invalid size of order %d: %w", orderIndex, err)
+	}
+	quote.OrderIndex = orderIndex
+	quote.Price = uint32(price)
+	quote.Size = size
+	return nil
+}
+
+// Quotes returns the live quotes, best price first on each side
+func (q *Quoter) Quotes() []Quote {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	quotes := make([]Quote, 0, len(q.quotes))
+	for _, quote := range q.quotes {
+		quotes = append(quotes, *quote)
+	}
+	sortQuotes(quotes)
+	return quotes
+}
+
+// sortQuotes sorts bids then asks, best price first
+func sortQuotes(quotes []Quote) {
+	sort.Slice(quotes, func(i, j int) bool {
+		a, b := quotes[i], quotes[j]
+		if a.IsAsk != b.IsAsk {
+			return !a.IsAsk
+		}
+		if a.IsAsk {
+			return a.Price < b.Price
+		}
+		return a.Price > b.Price
+	})
+}
+
+// quoteChange is a tx of a Quote call with what's needed to undo its optimistic update
+type quoteChange struct {
+	tx       txtypes.TxInfo
+	clientId int64
+	previous *Quote // nil for creates
+	next     *Quote // nil for cancels
+}
+
+// Quote replaces the live quotes by the given ladder. Levels already quoted at the same price & size are left alone,
+// quotes at the same price are resized, then the remaining quotes are moved to the remaining levels,
+// and only what's left over is cancelled or created. The txs are sent as one batch with SendTxs:
+// cancels first, then modifies, then creates.
+func (q *Quoter) Quote(ctx context.Context, levels []QuoteLevel) ([]TxResult, error) {
+	if err := checkLadder(levels); err != nil {
+		return nil, err
+	}
+
+	q.sendMu.Lock()
+	defer q.sendMu.Unlock()
+
+	q.mu.Lock()
+	changes, err := q.diff(levels)
+	if err == nil {
+		// the quotes are updated before sending, so stream messages received meanwhile find them
+		for _, change := range changes {
+			q.setQuote(change.clientId, change.next)
+		}
+	}
+	q.mu.Unlock()
+	if err != nil || len(changes) == 0 {
+		return nil, err
+	}
+
+	txs := make([]txtypes.TxInfo, len(changes))
+	for i, change := range changes {
+		txs[i] = change.tx
+	}
+	results, err := q.client.SendTxs(ctx, txs, nil)
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for i, change := range changes {
+		if results == nil || results[i].Err != nil {
+			q.setQuote(change.clientId, change.previous)
+		}
+	}
+	return results, err
+}
+
+// CancelAll cancels every quote
+func (q *Quoter) CancelAll(ctx context.Context) ([]TxResult, error) {
+	return q.Quote(ctx, nil)
+}
+
+func (q *Quoter) setQuote(clientId int64, quote *Quote) {
+	if quote == nil {
+		delete(q.quotes, clientId)
+		return
+	}
+	q.quotes[clientId] = quote
+}
+
+func checkLadder(levels []QuoteLevel) error {
+	seen := make(map[QuoteLevel]bool, len(levels))
+	for _, level := range levels {
+		if level.Size <= 0 {
+			return fmt.Errorf("quote size should be positive, got %d at %d", level.Size, level.Price)
+		}
+		key := QuoteLevel{IsAsk: level.IsAsk, Price: level.Price}
+		if seen[key] {
+			return fmt.Errorf("price %d is quoted twice on the same side", level.Price)
+		}
+		seen[key] = true
+	}
+	return nil
+}
+
+// diff returns the txs turning the quotes into levels. Must be called with q.mu held.
+func (q *Quoter) diff(levels []QuoteLevel) ([]quoteChange, error) {
+	var cancels, modifies, creates []quoteChange
+	for _, isAsk := range []bool{false, true} {
+		var wanted []QuoteLevel
+		for _, level := range levels {
+			if level.IsAsk == isAsk {
+				wanted = append(wanted, level)
+			}
+		}
+		live := make(map[uint32]*Quote)
+		var unmatched []*Quote
+		for _, quote := range q.quotes {
+			if quote.IsAsk != isAsk {
+				continue
+			}
+			if _, ok := live[quote.Price]; ok {
+				unmatched = append(unmatched, quote)
+			} else {
+				live[quote.Price] = quote
+			}
+		}
+
+		// same price: keep or resize
+		var missing []QuoteLevel
+		for _, level := range wanted {
+			quote, ok := live[level.Price]
+			if !ok {
+				missing = append(missing, level)
+				continue
+			}
+			delete(live, level.Price)
+			if quote.Size != level.Size {
+				change, err := q.modify(quote, level)
+				if err != nil {
+					return nil, err
+				}
+				modifies = append(modifies, change)
+			}
+		}
+		for _, quote := range live {
+			unmatched = append(unmatched, quote)
+		}
+
+		// other prices: move the quotes closest to the touch to the best missing levels
+		sortLevels(missing)
+		sort.Slice(unmatched, func(i, j int) bool {
+			if isAsk {
+				return unmatched[i].Price < unmatched[j].Price
+			}
+			return unmatched[i].Price > unmatched[j].Price
+		})
+		for i, quote := range unmatched {
+			if i < len(missing) {
+				change, err := q.modify(quote, missing[i])
+				if err != nil {
+					return nil, err
+				}
+				modifies = append(modifies, change)
+				continue
+			}
+			change, err := q.cancel(quote)
+			if err != nil {
+				return nil, err
+			}
+			cancels = append(cancels, change)
+		}
+		for i := len(unmatched); i < len(missing); i++ {
+			change, err := q.create(missing[i])
+			if err != nil {
+				return nil, err
+			}
+			creates = append(creates, change)
+		}
+	}
+
+	changes := append(cancels, modifies...)
+	return append(changes, creates...), nil
+}
+
+// sortLevels sorts the levels of one side, best price first
+func sortLevels(levels []QuoteLevel) {
+	sort.Slice(levels, func(i, j int) bool {
+		if levels[i].IsAsk {
+			return levels[i].Price < levels[j].Price
+		}
+		return levels[i].Price > levels[j].Price
+	})
+}
+
+// quoteOps leaves the nonce to SendTxs
+func quoteOps() *types.TransactOpts {
+	nonce := int64(0)
+	return &types.TransactOpts{Nonce: &nonce}
+}
+
+func (q *Quoter) modify(quote *Quote, level QuoteLevel) (quoteChange, error) {
+	tx, err := q.client.GetModifyOrderTransaction(&types.ModifyOrderTxReq{
+		MarketIndex: q.config.Market.MarketId,
+		Index:       quote.index(),
+		BaseAmount:  level.Size,
+		Price:       level.Price,
+	}, quoteOps())
+	if err != nil {
+		return quoteChange{}, err
+	}
+	next := *quote
+	next.QuoteLevel = level
+	return quoteChange{tx: tx, clientId: quote.ClientOrderIndex, previous: quote, next: &next}, nil
+}
+
+func (q *Quoter) cancel(quote *Quote) (quoteChange, error) {
+	tx, err := q.client.GetCancelOrderTransaction(&types.CancelOrderTxReq{
+		MarketIndex: q.config.Market.MarketId,
+		Index:       quote.index(),
+	}, quoteOps())
+	if err != nil {
+		return quoteChange{}, err
+	}
+	return quoteChange{tx: tx, clientId: quote.ClientOrderIndex, previous: quote}, nil
+}
+
+func (q *Quoter) create(level QuoteLevel) (quoteChange, error) {
+	if q.nextClientIndex > txtypes.MaxClientOrderIndex {
+		return quoteChange{}, fmt.Errorf("client order indexes exhausted")
+	}
+	var isAsk uint8
+	if level.IsAsk {
+		isAsk = 1
+	}
+	timeInForce := uint8(txtypes.GoodTillTime)
+	if q.config.PostOnly {
+		timeInForce = txtypes.PostOnly
+	}
+	tx, err := q.client.GetCreateOrderTransaction(&types.CreateOrderTxReq{
+		MarketIndex:      q.config.Market.MarketId,
+		ClientOrderIndex: q.nextClientIndex,
+		BaseAmount:       level.Size,
+		Price:            level.Price,
+		IsAsk:            isAsk,
+		Type:             txtypes.LimitOrder,
+		TimeInForce:      timeInForce,
+		OrderExpiry:      time.Now().Add(q.config.Expiry).UnixMilli(),
+	}, quoteOps())
+	if err != nil {
+		return quoteChange{}, err
+	}
+	quote := &Quote{QuoteLevel: level, ClientOrderIndex: q.nextClientIndex}
+	q.nextClientIndex++
+	return quoteChange{tx: tx, clientId: quote.ClientOrderIndex, next: quote}, nil
+}
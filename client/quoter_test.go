@@ -0,0 +1,175 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/u20024804/lighter-ex/decimal"
+	"github.com/u20024804/lighter-ex/types/txtypes"
+)
+
+// describeQuoteTx describes a tx of a Quoter, with the index of the order & the price x size
+func describeQuoteTx(tx txtypes.TxInfo) string {
+	switch t := tx.(type) {
+	case *txtypes.L2CancelOrderTxInfo:
+		return fmt.Sprintf("cancel %d", t.Index)
+	case *txtypes.L2ModifyOrderTxInfo:
+		return fmt.Sprintf("modify %d %dx%d", t.Index, t.Price, t.BaseAmount)
+	case *txtypes.L2CreateOrderTxInfo:
+		side := "bid"
+		if t.IsAsk == 1 {
+			side = "ask"
+		}
+		return fmt.Sprintf("create %d %s %dx%d", t.ClientOrderIndex, side, t.Price, t.BaseAmount)
+	}
+	return fmt.Sprintf("tx type %d", tx.GetTxType())
+}
+
+func bid(price uint32, size int64) QuoteLevel {
+	return QuoteLevel{Price: price, Size: size}
+}
+
+func ask(price uint32, size int64) QuoteLevel {
+	return QuoteLevel{IsAsk: true, Price: price, Size: size}
+}
+
+func TestQuoterDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		// live is quoted first, with the client order indexes 1, 2...
+		live   []QuoteLevel
+		levels []QuoteLevel
+		txs    []string
+	}{
+		{"first ladder", nil, []QuoteLevel{ask(1010, 100), bid(990, 100), bid(1000, 100)},
+			[]string{"create 1 bid 1000x100", "create 2 bid 990x100", "create 3 ask 1010x100"}},
+		{"unchanged", []QuoteLevel{bid(1000, 100), ask(1010, 100)}, []QuoteLevel{ask(1010, 100), bid(1000, 100)}, nil},
+		{"resized", []QuoteLevel{bid(1000, 100), ask(1010, 100)}, []QuoteLevel{bid(1000, 50), ask(1010, 100)},
+			[]string{"modify 1 1000x50"}},
+		// 1000 stays, the bid at 990 is the one moved
+		{"shifted up", []QuoteLevel{bid(1000, 100), bid(990, 100)}, []QuoteLevel{bid(1010, 100), bid(1000, 100)},
+			[]string{"modify 2 1010x100"}},
+		{"moved", []QuoteLevel{bid(1000, 100), bid(990, 100)}, []QuoteLevel{bid(980, 100), bid(970, 100)},
+			[]string{"modify 1 980x100", "modify 2 970x100"}},
+		// the quote furthest from the touch is cancelled
+		{"fewer levels", []QuoteLevel{ask(1010, 100), ask(1020, 100), ask(1030, 100)}, []QuoteLevel{ask(1005, 100), ask(1010, 100)},
+			[]string{"cancel 3", "modify 2 1005x100"}},
+		{"more levels", []QuoteLevel{ask(1010, 100)}, []QuoteLevel{ask(1010, 100), ask(1020, 200), ask(1030, 300)},
+			[]string{"create 2 ask 1020x200", "create 3 ask 1030x300"}},
+		// cancels, then modifies, then creates
+		{"both sides", []QuoteLevel{bid(1000, 100), bid(990, 100), ask(1010, 100)}, []QuoteLevel{bid(995, 100), ask(1010, 50), ask(1020, 100)},
+			[]string{"cancel 2", "modify 1 995x100", "modify 3 1010x50", "create 4 ask 1020x100"}},
+		{"cancel all", []QuoteLevel{bid(1000, 100), ask(1010, 100)}, nil, []string{"cancel 1", "cancel 2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, sender := newTestTxClient(t, 7, 2)
+			q, err := c.NewQuoter(QuoterConfig{Market: OrderBookDetail{MarketId: 1, SizeDecimals: 2, PriceDecimals: 1}, FirstClientOrderIndex: 1})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := q.Quote(context.Background(), tt.live); err != nil {
+				t.Fatal(err)
+			}
+			sent := len(sender.sent)
+
+			results, err := q.Quote(context.Background(), tt.levels)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var txs []string
+			for _, tx := range sender.sent[sent:] {
+				txs = append(txs, describeQuoteTx(tx))
+			}
+			if !slices.Equal(txs, tt.txs) || len(results) != len(tt.txs) {
+				t.Fatalf("expected the txs %q, got %q", tt.txs, txs)
+			}
+
+			quotes := q.Quotes()
+			levels := make([]QuoteLevel, len(quotes))
+			for i, quote := range quotes {
+				levels[i] = quote.QuoteLevel
+			}
+			wanted := slices.Clone(tt.levels)
+			sortQuoteLevels(wanted)
+			if !slices.Equal(levels, wanted) {
+				t.Errorf("expected the quotes %v, got %v", wanted, levels)
+			}
+		})
+	}
+}
+
+// sortQuoteLevels sorts like Quotes: bids then asks, best price first
+func sortQuoteLevels(levels []QuoteLevel) {
+	quotes := make([]Quote, len(levels))
+	for i, level := range levels {
+		quotes[i].QuoteLevel = level
+	}
+	sortQuotes(quotes)
+	for i := range quotes {
+		levels[i] = quotes[i].QuoteLevel
+	}
+}
+
+// failingSender rejects every tx
+type failingSender struct {
+	testSender
+}
+
+func (s *failingSender) SendRawTx(txtypes.TxInfo) (string, error) {
+	return "", errors.New("rejected")
+}
+
+func TestQuoterRestoresRejectedQuotes(t *testing.T) {
+	c, _ := newTestTxClient(t, 7, 2)
+	q, err := c.NewQuoter(QuoterConfig{Market: OrderBookDetail{MarketId: 1, SizeDecimals: 2, PriceDecimals: 1}, FirstClientOrderIndex: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.Quote(context.Background(), []QuoteLevel{bid(1000, 100)}); err != nil {
+		t.Fatal(err)
+	}
+
+	c.SetTxSender(&failingSender{})
+	if _, err := q.Quote(context.Background(), []QuoteLevel{bid(990, 100), bid(980, 100)}); err == nil {
+		t.Fatal("expected the batch to fail")
+	}
+	if quotes := q.Quotes(); len(quotes) != 1 || quotes[0].QuoteLevel != bid(1000, 100) {
+		t.Errorf("expected the quotes to be restored, got %v", quotes)
+	}
+}
+
+func TestQuoterApply(t *testing.T) {
+	c, _ := newTestTxClient(t, 7, 2)
+	q, err := c.NewQuoter(QuoterConfig{Market: OrderBookDetail{MarketId: 1, SizeDecimals: 2, PriceDecimals: 1}, FirstClientOrderIndex: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.Quote(context.Background(), []QuoteLevel{bid(1000, 100), ask(1010, 100)}); err != nil {
+		t.Fatal(err)
+	}
+
+	updates := []LighterOrdersResponse{
+		// partially filled
+		{MarketId: 1, ClientOrderIndex: 1, OrderId: "281474976710656", Status: "open", Price: decimal.MustParse("100"), BaseQuantity: decimal.MustParse("1"), FilledQuantity: decimal.MustParse("0.25")},
+		{MarketId: 1, ClientOrderIndex: 2, OrderId: "281474976710657", Status: "filled"},
+		// not a quote
+		{MarketId: 1, ClientOrderIndex: 9, OrderId: "281474976710658", Status: "open"},
+		{MarketId: 2, ClientOrderIndex: 1, OrderId: "281474976710659", Status: "filled"},
+	}
+	for _, update := range updates {
+		if err := q.Apply(update); err != nil {
+			t.Fatal(err)
+		}
+	}
+	quotes := q.Quotes()
+	if len(quotes) != 1 {
+		t.Fatalf("expected a single quote left, got %v", quotes)
+	}
+	if quotes[0].OrderIndex != 281474976710656 || quotes[0].QuoteLevel != bid(1000, 75) {
+		t.Errorf("expected the bid at 1000 with 75 left, got %+v", quotes[0])
+	}
+}
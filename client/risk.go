@@ -0,0 +1,320 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/u20024804/lighter-ex/decimal"
+	"github.com/u20024804/lighter-ex/types"
+	"github.com/u20024804/lighter-ex/types/txtypes"
+)
+
+var (
+	ErrRiskMarketDisabled  = errors.New("market is not enabled for trading")
+	ErrRiskUnknownMarket   = errors.New("market decimals are unknown")
+	ErrRiskMaxNotional     = errors.New("order notional is above the limit")
+	ErrRiskMaxPosition     = errors.New("resulting position is above the limit")
+	ErrRiskPriceBand       = errors.New("order price is outside of the allowed band")
+	ErrRiskNoMidPrice      = errors.New("no mid price to check the price band against")
+	ErrRiskMaxOpenOrders   = errors.New("too many open orders")
+	ErrRiskNoAccountSource = errors.New("no account state to check positions & open orders against")
+)
+
+// RiskError is returned when an order is rejected by the RiskChecker. It unwraps to one of the ErrRisk* errors.
+type RiskError struct {
+	Err      error
+	MarketId uint8
+	Detail   string
+}
+
+func (e *RiskError) Error() string {
+	return fmt.Sprintf("risk check failed for market %d: %v: %s", e.MarketId, e.Err, e.Detail)
+}
+
+func (e *RiskError) Unwrap() error {
+	return e.Err
+}
+
+// RiskLimits are the limits applied to the orders of a market. A zero value disables the corresponding check.
+type RiskLimits struct {
+	// MaxOrderNotional is the largest size * price of a single order, in USDC
+	MaxOrderNotional decimal.Decimal
+	// MaxPosition is the largest absolute position, in base units, an order can lead to
+	MaxPosition decimal.Decimal
+	// PriceBand is the largest relative distance of an order price from the mid price, e.g. 0.05 for 5%
+	PriceBand decimal.Decimal
+}
+
+// RiskConfig configures a RiskChecker
+type RiskConfig struct {
+	// EnabledMarkets restricts trading to the listed markets. Empty means every market is enabled.
+	EnabledMarkets []uint8
+	// Default limits apply to markets without an entry in Markets
+	Default RiskLimits
+	Markets map[uint8]RiskLimits
+	// MaxOpenOrders is the largest number of open orders across all markets. 0 disables the check.
+	MaxOpenOrders int
+}
+
+// RiskAccountSource provides the account data the position & open order checks need. AccountState implements it.
+type RiskAccountSource interface {
+	Position(marketId uint8) (WSPosition, bool)
+	OpenOrderCount() int
+}
+
+// RiskOrder is an order reduced to what the risk checks need
+type RiskOrder struct {
+	MarketIndex uint8
+	BaseAmount  int64
+	Price       uint32
+	IsAsk       bool
+	// SideUnknown is set for modifications, the position check then assumes the order increases the position
+	SideUnknown bool
+	ReduceOnly  bool
+	// IsTrigger orders are not checked against the price band, as their price is relative to the trigger price
+	IsTrigger bool
+}
+
+type marketScale struct {
+	sizeDecimals  uint8
+	priceDecimals uint8
+}
+
+// RiskChecker validates orders before they are signed. Set it on a TxClient with SetRiskChecker.
+type RiskChecker struct {
+	mu      sync.RWMutex
+	config  RiskConfig
+	enabled map[uint8]bool
+	markets map[uint8]marketScale
+	mids    MidPriceSource
+	account RiskAccountSource
+}
+
+// NewRiskChecker creates a checker. Markets have to be loaded with SetMarkets or LoadMarkets before orders can pass.
+func NewRiskChecker(config RiskConfig) *RiskChecker {
+	r := &RiskChecker{markets: make(map[uint8]marketScale)}
+	r.SetConfig(config)
+	return r
+}
+
+// SetConfig replaces the limits
+func (r *RiskChecker) SetConfig(config RiskConfig) {
+	enabled := make(map[uint8]bool, len(config.EnabledMarkets))
+	for _, marketId := range config.EnabledMarkets {
+		enabled[marketId] = true
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.config = config
+	r.enabled = enabled
+}
+
+// SetMidPriceSource sets where the price band check gets mid prices from, usually a MidPriceTracker
+func (r *RiskChecker) SetMidPriceSource(mids MidPriceSource) *RiskChecker {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.mids = mids
+	return r
+}
+
+// SetAccountSource sets where the position & open order checks get their data from, usually an AccountState
+func (r *RiskChecker) SetAccountSource(account RiskAccountSource) *RiskChecker {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.account = account
+	return r
+}
+
+// SetMarkets sets the size & price decimals used to turn integer amounts into human readable ones
+func (r *RiskChecker) SetMarkets(details []OrderBookDetail) *RiskChecker {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, d := range details {
+		r.markets[d.MarketId] = marketScale{
+			sizeDecimals:  d.SizeDecimals,
+			priceDecimals: d.PriceDecimals,
+		}
+	}
+	return r
+}
+
+// LoadMarkets fetches the decimals of every market from the API
+func (r *RiskChecker) LoadMarkets(apiClient *HTTPClient) error {
+	resp, err := apiClient.GetOrderBookDetails(0) // 0 returns every market
+	if err != nil {
+		return err
+	}
+	r.SetMarkets(resp.OrderBookDetails)
+	return nil
+}
+
+func (r *RiskChecker) limits(marketId uint8) RiskLimits {
+	if limits, ok := r.config.Markets[marketId]; ok {
+		return limits
+	}
+	return r.config.Default
+}
+
+// CheckOrders runs every check on a set of orders that are sent together
+func (r *RiskChecker) CheckOrders(orders ...RiskOrder) error {
+	if len(orders) == 0 {
+		return nil
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.config.MaxOpenOrders > 0 {
+		if r.account == nil {
+			return &RiskError{Err: ErrRiskNoAccountSource, MarketId: orders[0].MarketIndex, Detail: "required by MaxOpenOrders"}
+		}
+		if open := r.account.OpenOrderCount(); open+len(orders) > r.config.MaxOpenOrders {
+			return &RiskError{Err: ErrRiskMaxOpenOrders, MarketId: orders[0].MarketIndex, Detail: fmt.Sprintf("%d open + %d new > %d", open, len(orders), r.config.MaxOpenOrders)}
+		}
+	}
+
+	for i := range orders {
+		if err := r.checkOrder(&orders[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *RiskChecker) checkOrder(o *RiskOrder) error {
+	fail := func(err error, format string, args ...interface{}) error {
+		return &RiskError{Err: err, MarketId: o.MarketIndex, Detail: fmt.Sprintf(format, args...)}
+	}
+
+	if len(r.enabled) > 0 && !r.enabled[o.MarketIndex] {
+		return fail(ErrRiskMarketDisabled, "not in EnabledMarkets")
+	}
+	scale, ok := r.markets[o.MarketIndex]
+	if !ok {
+		return fail(ErrRiskUnknownMarket, "call SetMarkets or LoadMarkets first")
+	}
+	limits := r.limits(o.MarketIndex)
+	size := decimal.FromTicks(o.BaseAmount, scale.sizeDecimals)
+	price := decimal.FromTicks(int64(o.Price), scale.priceDecimals)
+
+	if notional := size.Mul(price); limits.MaxOrderNotional.IsPositive() && notional.GreaterThan(limits.MaxOrderNotional) {
+		return fail(ErrRiskMaxNotional, "%s > %s", notional.StringFixed(2), limits.MaxOrderNotional)
+	}
+
+	if limits.PriceBand.IsPositive() && !o.IsTrigger {
+		if r.mids == nil {
+			return fail(ErrRiskNoMidPrice, "no MidPriceSource set")
+		}
+		mid, ok := r.mids.MidPrice(o.MarketIndex)
+		if !ok || !mid.IsPositive() {
+			return fail(ErrRiskNoMidPrice, "mid price is not available")
+		}
+		if distance := price.Sub(mid).Abs().Div(mid); distance.GreaterThan(limits.PriceBand) {
+			hundred := decimal.NewFromInt(100)
+			return fail(ErrRiskPriceBand, "price %s is %s%% away from mid %s, band is %s%%", price, distance.Mul(hundred).StringFixed(2), mid, limits.PriceBand.Mul(hundred).StringFixed(2))
+		}
+	}
+
+	if limits.MaxPosition.IsPositive() && !o.ReduceOnly {
+		if r.account == nil {
+			return fail(ErrRiskNoAccountSource, "required by MaxPosition")
+		}
+		var current decimal.Decimal
+		if p, ok := r.account.Position(o.MarketIndex); ok {
+			current = p.Position.Abs()
+			if p.Sign < 0 {
+				current = current.Neg()
+			}
+		}
+
+		var next decimal.Decimal
+		switch {
+		case o.SideUnknown:
+			next = current.Abs().Add(size)
+		case o.IsAsk:
+			next = current.Sub(size)
+		default:
+			next = current.Add(size)
+		}
+		// orders that reduce the position are always allowed
+		if next.Abs().GreaterThan(limits.MaxPosition) && next.Abs().GreaterThan(current.Abs()) {
+			return fail(ErrRiskMaxPosition, "|%s| > %s", next, limits.MaxPosition)
+		}
+	}
+
+	return nil
+}
+
+// riskOrderFromCreate converts a create order request for the checker
+func riskOrderFromCreate(tx *types.CreateOrderTxReq) RiskOrder {
+	return RiskOrder{
+		MarketIndex: tx.MarketIndex,
+		BaseAmount:  tx.BaseAmount,
+		Price:       tx.Price,
+		IsAsk:       tx.IsAsk == 1,
+		ReduceOnly:  tx.ReduceOnly == 1,
+		IsTrigger:   tx.TriggerPrice != 0,
+	}
+}
+
+// riskOrderFromInfo converts an order of a signed tx for the checker
+func riskOrderFromInfo(o *txtypes.OrderInfo) RiskOrder {
+	return RiskOrder{
+		MarketIndex: o.MarketIndex,
+		BaseAmount:  o.BaseAmount,
+		Price:       o.Price,
+		IsAsk:       o.IsAsk == 1,
+		ReduceOnly:  o.ReduceOnly == 1,
+		IsTrigger:   o.TriggerPrice != 0,
+	}
+}
+
+// riskOrdersOf returns the orders a signed tx creates or modifies, nil if it's not an order tx
+func riskOrdersOf(tx txtypes.TxInfo) []RiskOrder {
+	switch t := tx.(type) {
+	case *txtypes.L2CreateOrderTxInfo:
+		return []RiskOrder{riskOrderFromInfo(t.OrderInfo)}
+	case *txtypes.L2CreateGroupedOrdersTxInfo:
+		orders := make([]RiskOrder, 0, len(t.Orders))
+		for _, o := range t.Orders {
+			orders = append(orders, riskOrderFromInfo(o))
+		}
+		return orders
+	case *txtypes.L2ModifyOrderTxInfo:
+		return []RiskOrder{{
+			MarketIndex: t.MarketIndex,
+			BaseAmount:  t.BaseAmount,
+			Price:       t.Price,
+			SideUnknown: true,
+			IsTrigger:   t.TriggerPrice != 0,
+		}}
+	}
+	return nil
+}
+
+// checkRisk runs the order hold & checker of the client, if any, unless the caller asked to skip them
+func (c *TxClient) checkRisk(ops *types.TransactOpts, orders ...RiskOrder) error {
+	if ops != nil && ops.SkipRiskChecks {
+		return nil
+	}
+	if err := c.checkHold(); err != nil {
+		return err
+	}
+	if c.riskChecker == nil || len(orders) == 0 {
+		return nil
+	}
+	return c.riskChecker.CheckOrders(orders...)
+}
+
+// SetRiskChecker makes every order created, modified or grouped by this client go through r before signing.
+// Pass nil to disable the checks. Individual txs can skip them with TransactOpts.SkipRiskChecks.
+func (c *TxClient) SetRiskChecker(r *RiskChecker) {
+	c.riskChecker = r
+}
+
+// GetRiskChecker returns the checker set with SetRiskChecker
+func (c *TxClient) GetRiskChecker() *RiskChecker {
+	return c.riskChecker
+}
@@ -0,0 +1,130 @@
+package client
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/u20024804/lighter-ex/decimal"
+)
+
+type testMids map[uint8]decimal.Decimal
+
+func (m testMids) MidPrice(marketId uint8) (decimal.Decimal, bool) {
+	mid, ok := m[marketId]
+	return mid, ok
+}
+
+type testAccount struct {
+	positions  map[uint8]WSPosition
+	openOrders int
+}
+
+func (a *testAccount) Position(marketId uint8) (WSPosition, bool) {
+	p, ok := a.positions[marketId]
+	return p, ok
+}
+
+func (a *testAccount) OpenOrderCount() int {
+	return a.openOrders
+}
+
+// newTestRiskChecker checks market 1, with 2 size & 1 price decimals, and market 2 which has tighter limits
+func newTestRiskChecker() *RiskChecker {
+	r := NewRiskChecker(RiskConfig{
+		EnabledMarkets: []uint8{1, 2},
+		Default: RiskLimits{
+			MaxOrderNotional: decimal.MustParse("1000"),
+			MaxPosition:      decimal.MustParse("5"),
+			PriceBand:        decimal.MustParse("0.05"),
+		},
+		Markets: map[uint8]RiskLimits{
+			2: {MaxOrderNotional: decimal.MustParse("10")},
+		},
+		MaxOpenOrders: 3,
+	})
+	r.SetMarkets([]OrderBookDetail{
+		{MarketId: 1, SizeDecimals: 2, PriceDecimals: 1},
+		{MarketId: 2, SizeDecimals: 2, PriceDecimals: 1},
+		{MarketId: 3, SizeDecimals: 2, PriceDecimals: 1},
+	})
+	r.SetMidPriceSource(testMids{1: decimal.MustParse("100"), 2: decimal.MustParse("100")})
+	r.SetAccountSource(&testAccount{
+		positions: map[uint8]WSPosition{1: {MarketId: 1, Sign: -1, Position: decimal.MustParse("3")}},
+	})
+	return r
+}
+
+func TestRiskCheckerCheckOrders(t *testing.T) {
+	// 1 = 0.01 in size ticks, 1000 = 100.0 in price ticks
+	tests := []struct {
+		name   string
+		orders []RiskOrder
+		err    error
+	}{
+		{"no order", nil, nil},
+		{"valid", []RiskOrder{{MarketIndex: 1, BaseAmount: 100, Price: 1000}}, nil},
+		{"disabled market", []RiskOrder{{MarketIndex: 3, BaseAmount: 100, Price: 1000}}, ErrRiskMarketDisabled},
+		{"notional", []RiskOrder{{MarketIndex: 1, BaseAmount: 1001, Price: 1000}}, ErrRiskMaxNotional},
+		{"market limits", []RiskOrder{{MarketIndex: 2, BaseAmount: 11, Price: 1000}}, ErrRiskMaxNotional},
+		{"price band", []RiskOrder{{MarketIndex: 1, BaseAmount: 100, Price: 1060}}, ErrRiskPriceBand},
+		{"price band of the bid", []RiskOrder{{MarketIndex: 1, BaseAmount: 100, Price: 940}}, ErrRiskPriceBand},
+		{"trigger outside the band", []RiskOrder{{MarketIndex: 1, BaseAmount: 100, Price: 1200, IsTrigger: true}}, nil},
+		// the short of 3 becomes a long of 5
+		{"buy up to the limit", []RiskOrder{{MarketIndex: 1, BaseAmount: 800, Price: 1000}}, nil},
+		{"buy above the limit", []RiskOrder{{MarketIndex: 1, BaseAmount: 801, Price: 1000}}, ErrRiskMaxPosition},
+		{"sell above the limit", []RiskOrder{{MarketIndex: 1, BaseAmount: 201, Price: 1000, IsAsk: true}}, ErrRiskMaxPosition},
+		{"reduce only", []RiskOrder{{MarketIndex: 1, BaseAmount: 900, Price: 1000, ReduceOnly: true}}, nil},
+		// a modification may increase the short
+		{"unknown side", []RiskOrder{{MarketIndex: 1, BaseAmount: 201, Price: 1000, SideUnknown: true}}, ErrRiskMaxPosition},
+		{"open orders", []RiskOrder{
+			{MarketIndex: 1, BaseAmount: 1, Price: 1000},
+			{MarketIndex: 1, BaseAmount: 1, Price: 1000},
+			{MarketIndex: 1, BaseAmount: 1, Price: 1000},
+			{MarketIndex: 1, BaseAmount: 1, Price: 1000},
+		}, ErrRiskMaxOpenOrders},
+		{"one invalid order of a batch", []RiskOrder{
+			{MarketIndex: 1, BaseAmount: 1, Price: 1000},
+			{MarketIndex: 1, BaseAmount: 1, Price: 2000},
+		}, ErrRiskPriceBand},
+	}
+	r := newTestRiskChecker()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := r.CheckOrders(tt.orders...)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("expected %v, got %v", tt.err, err)
+			}
+			var riskErr *RiskError
+			if err != nil && (!errors.As(err, &riskErr) || riskErr.MarketId != tt.orders[len(tt.orders)-1].MarketIndex) {
+				t.Errorf("expected a RiskError of the market, got %v", err)
+			}
+		})
+	}
+}
+
+func TestRiskCheckerMissingSources(t *testing.T) {
+	order := RiskOrder{MarketIndex: 1, BaseAmount: 100, Price: 1000}
+
+	r := NewRiskChecker(RiskConfig{})
+	if err := r.CheckOrders(order); !errors.Is(err, ErrRiskUnknownMarket) {
+		t.Errorf("expected ErrRiskUnknownMarket, got %v", err)
+	}
+
+	r = NewRiskChecker(RiskConfig{Default: RiskLimits{PriceBand: decimal.MustParse("0.05")}})
+	r.SetMarkets([]OrderBookDetail{{MarketId: 1, SizeDecimals: 2, PriceDecimals: 1}})
+	if err := r.CheckOrders(order); !errors.Is(err, ErrRiskNoMidPrice) {
+		t.Errorf("expected ErrRiskNoMidPrice, got %v", err)
+	}
+	r.SetMidPriceSource(testMids{})
+	if err := r.CheckOrders(order); !errors.Is(err, ErrRiskNoMidPrice) {
+		t.Errorf("expected ErrRiskNoMidPrice without a mid, got %v", err)
+	}
+
+	r = NewRiskChecker(RiskConfig{MaxOpenOrders: 1})
+	if err := r.CheckOrders(order); !errors.Is(err, ErrRiskNoAccountSource) {
+		t.Errorf("expected ErrRiskNoAccountSource, got %v", err)
+	}
+	if err := r.CheckOrders(); err != nil {
+		t.Errorf("expected no error without orders, got %v", err)
+	}
+}
@@ -0,0 +1,203 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	ethCommon "github.com/ethereum/go-ethereum/common"
+	"github.com/u20024804/lighter-ex/types/txtypes"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/u20024804/lighter-ex/client"
+
+// Span attributes
+const (
+	AttrAccountIndex     = attribute.Key("lighter.account_index")
+	AttrApiKeyIndex      = attribute.Key("lighter.api_key_index")
+	AttrTxType           = attribute.Key("lighter.tx_type")
+	AttrTxHash           = attribute.Key("lighter.tx_hash")
+	AttrNonce            = attribute.Key("lighter.nonce")
+	AttrMarketId         = attribute.Key("lighter.market_id")
+	AttrClientOrderIndex = attribute.Key("lighter.client_order_index")
+	AttrOrderIndex       = attribute.Key("lighter.order_index")
+	AttrSender           = attribute.Key("lighter.sender")
+)
+
+type tracerProviderHolder struct {
+	trace.TracerProvider
+}
+
+var currentTracerProvider atomic.Pointer[tracerProviderHolder]
+
+// SetTracerProvider sets the OpenTelemetry provider of the spans of the SDK.
+// By default the global provider is used, which doesn't record anything until otel.SetTracerProvider is called.
+// Spans are started under the context given to TxClient.WithContext:
+//
+//	lighter.FullFillDefaultOps → lighter.GetNextNonce
+//	lighter.Sign
+//	lighter.SendTx → lighter.SendRawTx, then lighter.AccountUpdate for the fills of the tx
+//
+// SendTxs starts its spans under the context it's given: lighter.SendTxs → lighter.GetNextNonce, lighter.Sign, and
+// lighter.SendTxBatch or lighter.SendRawTx for every chunk or tx sent. The calls to the TxSender are traced whatever
+// the sender is, with its type in the lighter.sender attribute.
+func SetTracerProvider(tp trace.TracerProvider) {
+	if tp == nil {
+		currentTracerProvider.Store(nil)
+		return
+	}
+	currentTracerProvider.Store(&tracerProviderHolder{tp})
+}
+
+func tracer() trace.Tracer {
+	if tp := currentTracerProvider.Load(); tp != nil {
+		return tp.Tracer(tracerName)
+	}
+	return otel.GetTracerProvider().Tracer(tracerName)
+}
+
+// endSpan records err, if any, and ends the span
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// WithContext returns a copy of the client whose spans are started under ctx, so the signature & sending of a tx
+// show up in the trace of the caller. The copy shares everything else with the client.
+func (c *TxClient) WithContext(ctx context.Context) *TxClient {
+	c2 := *c
+	c2.ctx = ctx
+	c2.keyManager = instrumentedKeyManager{KeyManager: c.keyManager.KeyManager, ctx: ctx}
+	return &c2
+}
+
+// noopSpan is returned when there's nothing to trace
+var noopSpan = trace.SpanFromContext(context.Background())
+
+// startSpan starts a span under the context of the client and returns a copy of the client under the span,
+// so the nested stages become its children. Nothing is traced for clients without a context, see WithContext.
+func (c *TxClient) startSpan(name string, attrs ...attribute.KeyValue) (*TxClient, trace.Span) {
+	if c.ctx == nil {
+		return c, noopSpan
+	}
+	attrs = append(attrs, AttrAccountIndex.Int64(c.accountIndex), AttrApiKeyIndex.Int(int(c.apiKeyIndex)))
+	ctx, span := tracer().Start(c.ctx, name, trace.WithAttributes(attrs...))
+	if !span.IsRecording() {
+		return c, span
+	}
+	return c.WithContext(ctx), span
+}
+
+// senderAttribute returns the type of the TxSender of the client, e.g. "*client.HTTPClient"
+func (c *TxClient) senderAttribute() attribute.KeyValue {
+	return AttrSender.String(fmt.Sprintf("%T", c.sender))
+}
+
+// getNextNonce fetches the next nonce of the pair from the TxSender of the client
+func (c *TxClient) getNextNonce(accountIndex int64, apiKeyIndex uint8) (nonce int64, err error) {
+	_, span := c.startSpan("lighter.GetNextNonce", c.senderAttribute())
+	defer func() {
+		if err == nil {
+			span.SetAttributes(AttrNonce.Int64(nonce))
+		}
+		endSpan(span, err)
+	}()
+	nonce, err = c.sender.GetNextNonce(accountIndex, apiKeyIndex)
+	if err != nil {
+		return -1, err
+	}
+	metrics().NonceFetch(accountIndex, apiKeyIndex)
+	return nonce, nil
+}
+
+// sendRawTx sends a signed tx with the TxSender of the client
+func (c *TxClient) sendRawTx(tx txtypes.TxInfo) (hash string, err error) {
+	_, span := c.startSpan("lighter.SendRawTx", append(txAttributes(tx), c.senderAttribute())...)
+	defer func() { endSpan(span, err) }()
+	return c.sender.SendRawTx(tx)
+}
+
+// sendTxBatch sends signed txs with a single call to the TxSender of the client
+func (c *TxClient) sendTxBatch(batchSender TxBatchSender, txTypes []int, txInfos []string) (hashes []string, err error) {
+	_, span := c.startSpan("lighter.SendTxBatch", attribute.Int("lighter.tx_count", len(txTypes)), c.senderAttribute())
+	defer func() { endSpan(span, err) }()
+	return batchSender.SendTxBatch(txTypes, txInfos)
+}
+
+// txAttributes returns the hash & nonce attributes of a signed tx
+func txAttributes(tx txtypes.TxInfo) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{AttrTxType.Int(int(tx.GetTxType())), AttrTxHash.String(tx.GetTxHash())}
+	if f, err := getBatchFields(tx); err == nil {
+		attrs = append(attrs, AttrNonce.Int64(*f.nonce))
+	}
+	return attrs
+}
+
+// maxTracedTxs bounds how many sent txs are remembered to link the account updates showing their fills
+const maxTracedTxs = 10000
+
+// tracedTxs maps the hashes of the txs sent under a recording span to the span
+var tracedTxs = struct {
+	sync.Mutex
+	spans map[string]trace.SpanContext
+	order []string
+}{spans: make(map[string]trace.SpanContext)}
+
+func rememberTxSpan(txHash string, span trace.Span) {
+	if txHash == "" || !span.IsRecording() {
+		return
+	}
+	tracedTxs.Lock()
+	defer tracedTxs.Unlock()
+	if _, ok := tracedTxs.spans[txHash]; !ok {
+		tracedTxs.order = append(tracedTxs.order, txHash)
+	}
+	tracedTxs.spans[txHash] = span.SpanContext()
+	if len(tracedTxs.order) > maxTracedTxs {
+		delete(tracedTxs.spans, tracedTxs.order[0])
+		tracedTxs.order = tracedTxs.order[1:]
+	}
+}
+
+func txSpan(txHash string) (trace.SpanContext, bool) {
+	tracedTxs.Lock()
+	defer tracedTxs.Unlock()
+	sc, ok := tracedTxs.spans[txHash]
+	return sc, ok
+}
+
+// startAccountUpdateSpan starts a span for an account message, linked to the spans that sent the txs of its trades
+func startAccountUpdateSpan(update *WSAccountUpdate) trace.Span {
+	var links []trace.Link
+	for _, trades := range update.Trades {
+		for _, t := range trades {
+			if sc, ok := txSpan(t.TxHash); ok {
+				links = append(links, trace.Link{SpanContext: sc, Attributes: []attribute.KeyValue{
+					AttrTxHash.String(t.TxHash), AttrMarketId.Int(t.MarketId),
+				}})
+			}
+		}
+	}
+	// updates without fills of traced txs are not worth a span
+	if len(links) == 0 {
+		return noopSpan
+	}
+	_, span := tracer().Start(context.Background(), "lighter.AccountUpdate",
+		trace.WithLinks(links...),
+		trace.WithAttributes(AttrAccountIndex.Int64(update.Account), attribute.String("lighter.message_type", update.Type)),
+	)
+	return span
+}
+
+// signAttribute returns the attributes of the signature of a message hash, which is the tx hash
+func signAttribute(msgHash []byte) attribute.KeyValue {
+	return AttrTxHash.String(ethCommon.Bytes2Hex(msgHash))
+}
@@ -0,0 +1,136 @@
+package client
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/u20024804/lighter-ex/types"
+	"github.com/u20024804/lighter-ex/types/txtypes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// batchTestSender also sends batches, of the txs it's given: the hashes aren't part of the tx infos
+type batchTestSender struct {
+	testSender
+	txs []txtypes.TxInfo
+}
+
+func (s *batchTestSender) SendTxBatch(_ []int, txInfos []string) ([]string, error) {
+	hashes := make([]string, len(txInfos))
+	for i := range txInfos {
+		hashes[i] = s.txs[len(s.sent)].GetTxHash()
+		s.sent = append(s.sent, s.txs[len(s.sent)])
+	}
+	return hashes, nil
+}
+
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	recorder := tracetest.NewSpanRecorder()
+	SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { SetTracerProvider(nil) })
+	return recorder
+}
+
+// spanTree describes the ended spans as "parent > child", in the order they ended
+func spanTree(recorder *tracetest.SpanRecorder) []string {
+	spans := recorder.Ended()
+	names := make(map[[8]byte]string, len(spans))
+	for _, s := range spans {
+		names[s.SpanContext().SpanID()] = s.Name()
+	}
+	var tree []string
+	for _, s := range spans {
+		name := s.Name()
+		if parent, ok := names[s.Parent().SpanID()]; ok {
+			name = parent + " > " + name
+		}
+		tree = append(tree, name)
+	}
+	return tree
+}
+
+func TestTraceSendTx(t *testing.T) {
+	recorder := recordSpans(t)
+	c, sender := newTestTxClient(t, 7, 2)
+	c = c.WithContext(context.Background())
+
+	tx, err := c.GetCancelOrderTransaction(&types.CancelOrderTxReq{MarketIndex: 1, Index: 1}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.SendTx(tx); err != nil {
+		t.Fatal(err)
+	}
+
+	wanted := []string{
+		"lighter.FullFillDefaultOps > lighter.GetNextNonce",
+		"lighter.CancelOrder > lighter.FullFillDefaultOps",
+		"lighter.CancelOrder > lighter.Sign",
+		"lighter.CancelOrder",
+		"lighter.SendTx > lighter.SendRawTx",
+		"lighter.SendTx",
+	}
+	if tree := spanTree(recorder); !slices.Equal(tree, wanted) {
+		t.Fatalf("expected the spans %q, got %q", wanted, tree)
+	}
+	if len(sender.sent) != 1 {
+		t.Fatalf("expected a tx to be sent, got %d", len(sender.sent))
+	}
+	for _, s := range recorder.Ended() {
+		if s.Name() != "lighter.SendRawTx" {
+			continue
+		}
+		attrs := map[string]string{}
+		for _, attr := range s.Attributes() {
+			attrs[string(attr.Key)] = attr.Value.Emit()
+		}
+		if attrs[string(AttrSender)] != "*client.testSender" || attrs[string(AttrTxHash)] != tx.GetTxHash() {
+			t.Errorf("expected the sender & the hash of the tx in the attributes, got %v", attrs)
+		}
+	}
+}
+
+func TestTraceSendTxs(t *testing.T) {
+	for _, batched := range []bool{false, true} {
+		recorder := recordSpans(t)
+		c, _ := newTestTxClient(t, 7, 2)
+
+		zero := int64(0)
+		var txs []txtypes.TxInfo
+		for i := int64(1); i <= 2; i++ {
+			tx, err := c.GetCancelOrderTransaction(&types.CancelOrderTxReq{MarketIndex: 1, Index: i}, &types.TransactOpts{Nonce: &zero})
+			if err != nil {
+				t.Fatal(err)
+			}
+			txs = append(txs, tx)
+		}
+		var sender TxSender = &testSender{nonce: 10}
+		send := "lighter.SendTxs > lighter.SendRawTx"
+		if batched {
+			sender = &batchTestSender{testSender: testSender{nonce: 10}, txs: txs}
+			send = "lighter.SendTxs > lighter.SendTxBatch"
+		}
+		c.SetTxSender(sender)
+		recorder.Reset()
+		if _, err := c.SendTxs(context.Background(), txs, nil); err != nil {
+			t.Fatal(err)
+		}
+
+		wanted := []string{
+			"lighter.SendTxs > lighter.GetNextNonce",
+			"lighter.SendTxs > lighter.Sign",
+			"lighter.SendTxs > lighter.Sign",
+			send,
+		}
+		if !batched {
+			wanted = append(wanted, send)
+		}
+		wanted = append(wanted, "lighter.SendTxs")
+		if tree := spanTree(recorder); !slices.Equal(tree, wanted) {
+			t.Errorf("batched %v: expected the spans %q, got %q", batched, wanted, tree)
+		}
+	}
+}
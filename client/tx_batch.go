@@ -0,0 +1,240 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	p2 "github.com/elliottech/poseidon_crypto/hash/poseidon2_goldilocks"
+	ethCommon "github.com/ethereum/go-ethereum/common"
+	"github.com/u20024804/lighter-ex/types"
+	"github.com/u20024804/lighter-ex/types/txtypes"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// MaxTxBatchSize is the number of txs accepted by a single sendTxBatch call, SendTxs splits larger batches
+const MaxTxBatchSize = 50
+
+// ErrTxNotSent is the error of the txs of a batch that weren't sent because a previous chunk failed,
+// their nonces come after the rejected ones
+var ErrTxNotSent = errors.New("tx not sent, a previous tx of the batch failed")
+
+// TxBatchSender is implemented by the TxSenders able to send several txs in one call, like HTTPClient.
+// SendTxs sends the txs one by one to the others.
+type TxBatchSender interface {
+	SendTxBatch(txTypes []int, txInfos []string) ([]string, error)
+}
+
+// TxResult is the outcome of a tx sent with SendTxs
+type TxResult struct {
+	Tx   txtypes.TxInfo
+	Hash string
+	Err  error
+}
+
+// SendTxs sends txs in order with consecutive nonces, in chunks of MaxTxBatchSize txs.
+// The txs must belong to the (account, apiKey) pair of the client, as they're built by its Get*Transaction methods:
+// their nonces are overwritten, starting from the next nonce of the pair, and they're signed again with the key of the client.
+// Transfers & pub key changes can't be part of a batch, as their L1 signature covers the nonce.
+//
+// The order txs go through the order hold & risk checker of the client again, as they may have been built before a
+// hold started: if any is refused, nothing is sent. Cancels are never held. Set ops.SkipRiskChecks to send them anyway,
+// e.g. to flatten positions while the orders are held; the other fields of ops are not used. ops can be nil.
+//
+// A result is returned per tx, with the hash returned by Lighter. When a chunk fails, the following ones aren't sent
+// and their txs get ErrTxNotSent. The returned error is the first failure, if any.
+func (c *TxClient) SendTxs(ctx context.Context, txs []txtypes.TxInfo, ops *types.TransactOpts) (_ []TxResult, err error) {
+	if c.sender == nil {
+		return nil, fmt.Errorf("no TxSender, either provide a HTTPClient or set a TxSender")
+	}
+	if len(txs) == 0 {
+		return nil, nil
+	}
+	spanCtx, span := tracer().Start(ctx, "lighter.SendTxs", trace.WithAttributes(
+		AttrAccountIndex.Int64(c.accountIndex), attribute.Int("lighter.tx_count", len(txs))))
+	defer func() { endSpan(span, err) }()
+	if span.IsRecording() {
+		// the nonce fetch, the signatures & the sends become children of the span
+		c = c.WithContext(spanCtx)
+	}
+
+	fields := make([]batchFields, len(txs))
+	var orders []RiskOrder
+	for i, tx := range txs {
+		f, err := getBatchFields(tx)
+		if err != nil {
+			return nil, fmt.Errorf("tx %d: %w", i, err)
+		}
+		if f.accountIndex != c.accountIndex || f.apiKeyIndex != c.apiKeyIndex {
+			return nil, fmt.Errorf("tx %d: built for account %d & api key %d, but the client signs for account %d & api key %d",
+				i, f.accountIndex, f.apiKeyIndex, c.accountIndex, c.apiKeyIndex)
+		}
+		fields[i] = f
+		orders = append(orders, riskOrdersOf(tx)...)
+	}
+	if len(orders) > 0 {
+		if err := c.checkRisk(ops, orders...); err != nil {
+			return nil, err
+		}
+	}
+
+	nonce, err := c.getNextNonce(fields[0].accountIndex, fields[0].apiKeyIndex)
+	if err != nil {
+		return nil, err
+	}
+	for i, tx := range txs {
+		*fields[i].nonce = nonce + int64(i)
+		if err := c.resign(tx, fields[i]); err != nil {
+			return nil, fmt.Errorf("tx %d: %w", i, err)
+		}
+	}
+
+	results := make([]TxResult, len(txs))
+	for i, tx := range txs {
+		results[i].Tx = tx
+		rememberTxSpan(tx.GetTxHash(), span)
+	}
+	span.SetAttributes(AttrNonce.Int64(nonce))
+	var firstErr error
+	for start := 0; start < len(txs); start += MaxTxBatchSize {
+		end := min(start+MaxTxBatchSize, len(txs))
+		if firstErr == nil {
+			firstErr = ctx.Err()
+		}
+		if firstErr != nil {
+			for i := start; i < end; i++ {
+				results[i].Err = ErrTxNotSent
+			}
+			continue
+		}
+		if err := c.sendChunk(results[start:end]); err != nil {
+			firstErr = err
+		}
+	}
+	return results, firstErr
+}
+
+// sendChunk sends the txs of results as a batch and sets their hashes, or their errors
+func (c *TxClient) sendChunk(results []TxResult) error {
+	batchSender, ok := c.sender.(TxBatchSender)
+	if !ok {
+		var firstErr error
+		for i := range results {
+			if firstErr != nil {
+				results[i].Err = ErrTxNotSent
+				continue
+			}
+			results[i].Hash, results[i].Err = c.sendRawTx(results[i].Tx)
+			if results[i].Err == nil {
+				results[i].Err = checkTxHash(results[i].Tx, results[i].Hash)
+			}
+			firstErr = results[i].Err
+		}
+		return firstErr
+	}
+
+	txTypes := make([]int, len(results))
+	txInfos := make([]string, len(results))
+	for i, r := range results {
+		txInfo, err := r.Tx.GetTxInfo()
+		if err != nil {
+			return failChunk(results, err)
+		}
+		txTypes[i] = int(r.Tx.GetTxType())
+		txInfos[i] = txInfo
+	}
+	hashes, err := c.sendTxBatch(batchSender, txTypes, txInfos)
+	if err != nil {
+		return failChunk(results, err)
+	}
+	if len(hashes) != len(results) {
+		return failChunk(results, fmt.Errorf("sendTxBatch returned %d hashes for %d txs", len(hashes), len(results)))
+	}
+
+	var firstErr error
+	for i := range results {
+		results[i].Hash = hashes[i]
+		results[i].Err = checkTxHash(results[i].Tx, hashes[i])
+		if firstErr == nil {
+			firstErr = results[i].Err
+		}
+	}
+	return firstErr
+}
+
+func failChunk(results []TxResult, err error) error {
+	for i := range results {
+		results[i].Err = err
+	}
+	return err
+}
+
+// checkTxHash checks the hash returned by Lighter against the one signed
+func checkTxHash(tx txtypes.TxInfo, hash string) error {
+	if !strings.EqualFold(strings.TrimPrefix(hash, "0x"), strings.TrimPrefix(tx.GetTxHash(), "0x")) {
+		return fmt.Errorf("tx hash mismatch, signed %s but got %s", tx.GetTxHash(), hash)
+	}
+	return nil
+}
+
+// batchFields gives access to the fields of a tx SendTxs changes
+type batchFields struct {
+	accountIndex int64
+	apiKeyIndex  uint8
+	nonce        *int64
+	sig          *[]byte
+	signedHash   *string
+}
+
+func getBatchFields(tx txtypes.TxInfo) (batchFields, error) {
+	switch t := tx.(type) {
+	case *txtypes.L2CreateOrderTxInfo:
+		return batchFields{t.AccountIndex, t.ApiKeyIndex, &t.Nonce, &t.Sig, &t.SignedHash}, nil
+	case *txtypes.L2CreateGroupedOrdersTxInfo:
+		return batchFields{t.AccountIndex, t.ApiKeyIndex, &t.Nonce, &t.Sig, &t.SignedHash}, nil
+	case *txtypes.L2CancelOrderTxInfo:
+		return batchFields{t.AccountIndex, t.ApiKeyIndex, &t.Nonce, &t.Sig, &t.SignedHash}, nil
+	case *txtypes.L2ModifyOrderTxInfo:
+		return batchFields{t.AccountIndex, t.ApiKeyIndex, &t.Nonce, &t.Sig, &t.SignedHash}, nil
+	case *txtypes.L2CancelAllOrdersTxInfo:
+		return batchFields{t.AccountIndex, t.ApiKeyIndex, &t.Nonce, &t.Sig, &t.SignedHash}, nil
+	case *txtypes.L2UpdateLeverageTxInfo:
+		return batchFields{t.AccountIndex, t.ApiKeyIndex, &t.Nonce, &t.Sig, &t.SignedHash}, nil
+	case *txtypes.L2UpdateMarginTxInfo:
+		return batchFields{t.AccountIndex, t.ApiKeyIndex, &t.Nonce, &t.Sig, &t.SignedHash}, nil
+	case *txtypes.L2WithdrawTxInfo:
+		return batchFields{t.FromAccountIndex, t.ApiKeyIndex, &t.Nonce, &t.Sig, &t.SignedHash}, nil
+	case *txtypes.L2CreateSubAccountTxInfo:
+		return batchFields{t.AccountIndex, t.ApiKeyIndex, &t.Nonce, &t.Sig, &t.SignedHash}, nil
+	case *txtypes.L2CreatePublicPoolTxInfo:
+		return batchFields{t.AccountIndex, t.ApiKeyIndex, &t.Nonce, &t.Sig, &t.SignedHash}, nil
+	case *txtypes.L2UpdatePublicPoolTxInfo:
+		return batchFields{t.AccountIndex, t.ApiKeyIndex, &t.Nonce, &t.Sig, &t.SignedHash}, nil
+	case *txtypes.L2MintSharesTxInfo:
+		return batchFields{t.AccountIndex, t.ApiKeyIndex, &t.Nonce, &t.Sig, &t.SignedHash}, nil
+	case *txtypes.L2BurnSharesTxInfo:
+		return batchFields{t.AccountIndex, t.ApiKeyIndex, &t.Nonce, &t.Sig, &t.SignedHash}, nil
+	default:
+		return batchFields{}, fmt.Errorf("tx type %d can't be sent in a batch", tx.GetTxType())
+	}
+}
+
+// resign signs a tx again after its nonce changed
+func (c *TxClient) resign(tx txtypes.TxInfo, f batchFields) error {
+	if err := tx.Validate(); err != nil {
+		return err
+	}
+	msgHash, err := tx.Hash(c.chainId)
+	if err != nil {
+		return err
+	}
+	sig, err := c.keyManager.Sign(msgHash, p2.NewPoseidon2())
+	if err != nil {
+		return err
+	}
+	*f.sig = sig
+	*f.signedHash = ethCommon.Bytes2Hex(msgHash)
+	return nil
+}
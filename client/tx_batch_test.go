@@ -0,0 +1,134 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/u20024804/lighter-ex/decimal"
+	"github.com/u20024804/lighter-ex/types"
+	"github.com/u20024804/lighter-ex/types/txtypes"
+)
+
+// testSender accepts every tx and counts the nonce fetches
+type testSender struct {
+	nonce      int64
+	nonceCalls int
+	sent       []txtypes.TxInfo
+}
+
+func (s *testSender) GetNextNonce(int64, uint8) (int64, error) {
+	s.nonceCalls++
+	return s.nonce, nil
+}
+
+func (s *testSender) SendRawTx(tx txtypes.TxInfo) (string, error) {
+	s.sent = append(s.sent, tx)
+	s.nonce++
+	return tx.GetTxHash(), nil
+}
+
+func newTestTxClient(t *testing.T, accountIndex int64, apiKeyIndex uint8) (*TxClient, *testSender) {
+	t.Helper()
+	c, err := NewTxClient(nil, strings.Repeat("01", 40), accountIndex, apiKeyIndex, 304)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sender := &testSender{nonce: 10}
+	c.SetTxSender(sender)
+	return c, sender
+}
+
+func TestSendTxs(t *testing.T) {
+	build := func(t *testing.T, c *TxClient, isCreate bool, price uint32) txtypes.TxInfo {
+		t.Helper()
+		// the txs are built with a nonce, so without fetching it
+		zero := int64(0)
+		ops := &types.TransactOpts{Nonce: &zero, SkipRiskChecks: true}
+		var tx txtypes.TxInfo
+		var err error
+		if isCreate {
+			tx, err = c.GetCreateOrderTransaction(&types.CreateOrderTxReq{
+				MarketIndex: 1, ClientOrderIndex: 1, BaseAmount: 100, Price: price,
+				Type: txtypes.LimitOrder, TimeInForce: txtypes.PostOnly, OrderExpiry: txtypes.MaxOrderExpiry,
+			}, ops)
+		} else {
+			tx, err = c.GetCancelOrderTransaction(&types.CancelOrderTxReq{MarketIndex: 1, Index: 1}, ops)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tx
+	}
+	checker := NewRiskChecker(RiskConfig{Default: RiskLimits{MaxOrderNotional: decimal.MustParse("1000")}})
+	checker.SetMarkets([]OrderBookDetail{{MarketId: 1, SizeDecimals: 2, PriceDecimals: 1}})
+
+	tests := []struct {
+		name string
+		// the txs are built by a client of this account & api key
+		accountIndex int64
+		apiKeyIndex  uint8
+		creates      []bool
+		price        uint32
+		held         bool
+		skipRisk     bool
+		err          error
+	}{
+		{name: "orders", accountIndex: 7, apiKeyIndex: 2, creates: []bool{true, false, true}, price: 1000},
+		{name: "other account", accountIndex: 8, apiKeyIndex: 2, creates: []bool{true}, price: 1000, err: errWrongKey},
+		{name: "other api key", accountIndex: 7, apiKeyIndex: 3, creates: []bool{false}, price: 1000, err: errWrongKey},
+		{name: "held", accountIndex: 7, apiKeyIndex: 2, creates: []bool{false, true}, price: 1000, held: true, err: ErrOrdersHeld},
+		{name: "held cancels", accountIndex: 7, apiKeyIndex: 2, creates: []bool{false, false}, price: 1000, held: true},
+		{name: "risk", accountIndex: 7, apiKeyIndex: 2, creates: []bool{false, true}, price: 20000, err: ErrRiskMaxNotional},
+		// flattening while the orders are held, or beyond the limits
+		{name: "held skipping the checks", accountIndex: 7, apiKeyIndex: 2, creates: []bool{false, true}, price: 1000, held: true, skipRisk: true},
+		{name: "risk skipped", accountIndex: 7, apiKeyIndex: 2, creates: []bool{true}, price: 20000, skipRisk: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, sender := newTestTxClient(t, 7, 2)
+			c.SetRiskChecker(checker)
+			if tt.held {
+				c.cancelOnDisconnect = &CancelOnDisconnect{client: c, held: true}
+			}
+			builder, _ := newTestTxClient(t, tt.accountIndex, tt.apiKeyIndex)
+			txs := make([]txtypes.TxInfo, len(tt.creates))
+			for i, isCreate := range tt.creates {
+				txs[i] = build(t, builder, isCreate, tt.price)
+			}
+
+			results, err := c.SendTxs(context.Background(), txs, &types.TransactOpts{SkipRiskChecks: tt.skipRisk})
+			switch {
+			case tt.err == errWrongKey:
+				if err == nil || !strings.Contains(err.Error(), "but the client signs for account 7 & api key 2") {
+					t.Fatalf("expected an account & api key error, got %v", err)
+				}
+			case !errors.Is(err, tt.err):
+				t.Fatalf("expected %v, got %v", tt.err, err)
+			}
+			if tt.err != nil {
+				if sender.nonceCalls != 0 || len(sender.sent) != 0 {
+					t.Errorf("expected nothing fetched nor sent, got %d nonce fetches & %d txs", sender.nonceCalls, len(sender.sent))
+				}
+				return
+			}
+
+			if len(results) != len(txs) || len(sender.sent) != len(txs) {
+				t.Fatalf("expected %d txs sent, got %d results & %d txs", len(txs), len(results), len(sender.sent))
+			}
+			for i, r := range results {
+				if r.Err != nil {
+					t.Errorf("tx %d: %v", i, r.Err)
+				}
+				f, _ := getBatchFields(r.Tx)
+				if *f.nonce != 10+int64(i) {
+					t.Errorf("tx %d: expected the nonce %d, got %d", i, 10+i, *f.nonce)
+				}
+			}
+		})
+	}
+}
+
+// errWrongKey stands for the error of txs built for another account or api key
+var errWrongKey = errors.New("wrong account or api key")
@@ -1,24 +1,42 @@
 package client
 
 import (
+	"context"
 	"encoding/hex"
 	"fmt"
+	"strings"
 	"time"
 
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/u20024804/lighter-ex/signer"
 	"github.com/u20024804/lighter-ex/types"
+	"github.com/u20024804/lighter-ex/types/txtypes"
 )
 
 const (
 	defaultExpireTime = time.Minute*10 - time.Second // we need to give a second margin, to eliminate millisecond differences
 )
 
+// TxSender is where a TxClient gets nonces from and sends its txs to. HTTPClient sends them to Lighter,
+// while a simulator can implement it to trade on paper with the same TxClient calls.
+type TxSender interface {
+	GetNextNonce(accountIndex int64, apiKeyIndex uint8) (int64, error)
+	SendRawTx(tx txtypes.TxInfo) (string, error)
+}
+
 type TxClient struct {
 	apiClient    *HTTPClient
+	sender       TxSender
 	chainId      uint32
-	keyManager   signer.KeyManager
+	keyManager   instrumentedKeyManager
 	accountIndex int64
 	apiKeyIndex  uint8
+	riskChecker  *RiskChecker
+
+	cancelOnDisconnect *CancelOnDisconnect
+
+	// ctx is the parent of the spans, see WithContext
+	ctx context.Context
 }
 
 // NewTxClient is linked to a specific (account, apiKey) pair
@@ -41,16 +59,44 @@ func NewTxClient(apiClient *HTTPClient, apiKeyPrivateKey string, accountIndex in
 		return nil, err
 	}
 
-	return &TxClient{
+	c := &TxClient{
 		apiClient:    apiClient,
 		apiKeyIndex:  apiKeyIndex,
 		accountIndex: accountIndex,
 		chainId:      chainId,
-		keyManager:   keyManager,
-	}, nil
+		keyManager:   instrumentedKeyManager{KeyManager: keyManager},
+	}
+	if apiClient != nil {
+		c.sender = apiClient
+	}
+	return c, nil
 }
 
-func (c *TxClient) FullFillDefaultOps(ops *types.TransactOpts) (*types.TransactOpts, error) {
+// SetTxSender replaces where nonces come from & txs are sent to, which is the HTTPClient by default
+func (c *TxClient) SetTxSender(sender TxSender) {
+	c.sender = sender
+}
+
+// SendTx sends a signed tx with the TxSender of the client and returns its hash
+func (c *TxClient) SendTx(tx txtypes.TxInfo) (hash string, err error) {
+	if c.sender == nil {
+		return "", fmt.Errorf("no TxSender, either provide a HTTPClient or set a TxSender")
+	}
+	c, span := c.startSpan("lighter.SendTx", txAttributes(tx)...)
+	defer func() { endSpan(span, err) }()
+	rememberTxSpan(tx.GetTxHash(), span)
+	return c.sendRawTx(tx)
+}
+
+func (c *TxClient) FullFillDefaultOps(ops *types.TransactOpts) (_ *types.TransactOpts, err error) {
+	c, span := c.startSpan("lighter.FullFillDefaultOps")
+	defer func() {
+		if err == nil {
+			span.SetAttributes(AttrNonce.Int64(*ops.Nonce))
+		}
+		endSpan(span, err)
+	}()
+
 	if ops == nil {
 		ops = new(types.TransactOpts)
 	}
@@ -64,10 +110,10 @@ func (c *TxClient) FullFillDefaultOps(ops *types.TransactOpts) (*types.TransactO
 		ops.ApiKeyIndex = &c.apiKeyIndex
 	}
 	if ops.Nonce == nil {
-		if c.apiClient == nil {
+		if c.sender == nil {
 			return nil, fmt.Errorf("nonce was not provided & HTTPClient is nil. Either provide the nonce or enable HTTPClient to get the nonce from Lighter")
 		}
-		nonce, err := c.apiClient.GetNextNonce(*ops.FromAccountIndex, *ops.ApiKeyIndex)
+		nonce, err := c.getNextNonce(*ops.FromAccountIndex, *ops.ApiKeyIndex)
 		if err != nil {
 			return nil, err
 		}
@@ -86,7 +132,7 @@ func (c *TxClient) GetApiKeyIndex() uint8 {
 }
 
 func (c *TxClient) GetKeyManager() signer.KeyManager {
-	return c.keyManager
+	return c.keyManager.KeyManager
 }
 
 func (c *TxClient) HTTP() *HTTPClient {
@@ -104,6 +150,32 @@ func (c *TxClient) GetAuthToken(deadline time.Time) (string, error) {
 	})
 }
 
+// CheckApiKey checks that the API key registered on Lighter for (account, apiKey) matches the private key of this client
+func (c *TxClient) CheckApiKey() error {
+	if c.apiClient == nil {
+		return fmt.Errorf("HTTPClient is nil, can't fetch the API key from Lighter")
+	}
+
+	key, err := c.apiClient.GetApiKey(c.accountIndex, c.apiKeyIndex)
+	if err != nil {
+		return fmt.Errorf("failed to get Api Keys. err: %v", err)
+	}
+	if len(key.ApiKeys) == 0 {
+		return fmt.Errorf("no api key registered on Lighter. accountIndex: %v apiKeyIndex: %v", c.accountIndex, c.apiKeyIndex)
+	}
+
+	pubKeyBytes := c.keyManager.PubKeyBytes()
+	pubKeyStr := hexutil.Encode(pubKeyBytes[:])
+	pubKeyStr = strings.Replace(pubKeyStr, "0x", "", 1)
+
+	ak := key.ApiKeys[0]
+	if ak.PublicKey != pubKeyStr {
+		return fmt.Errorf("private key does not match the one on Lighter. ownPubKey: %s response: %+v", pubKeyStr, ak)
+	}
+
+	return nil
+}
+
 func (c *TxClient) SwitchAPIKey(apiKey uint8) {
 	c.apiKeyIndex = apiKey
 }
@@ -89,8 +89,14 @@ func (c *TxClient) GetWithdrawTransaction(tx *types.WithdrawTxReq, ops *types.Tr
 	return txInfo, nil
 }
 
-func (c *TxClient) GetCreateOrderTransaction(tx *types.CreateOrderTxReq, ops *types.TransactOpts) (*txtypes.L2CreateOrderTxInfo, error) {
-	ops, err := c.FullFillDefaultOps(ops)
+func (c *TxClient) GetCreateOrderTransaction(tx *types.CreateOrderTxReq, ops *types.TransactOpts) (_ *txtypes.L2CreateOrderTxInfo, err error) {
+	c, span := c.startSpan("lighter.CreateOrder", AttrMarketId.Int(int(tx.MarketIndex)), AttrClientOrderIndex.Int64(tx.ClientOrderIndex))
+	defer func() { endSpan(span, err) }()
+
+	if err := c.checkRisk(ops, riskOrderFromCreate(tx)); err != nil {
+		return nil, err
+	}
+	ops, err = c.FullFillDefaultOps(ops)
 	if err != nil {
 		return nil, err
 	}
@@ -98,11 +104,38 @@ func (c *TxClient) GetCreateOrderTransaction(tx *types.CreateOrderTxReq, ops *ty
 	if err != nil {
 		return nil, err
 	}
+	span.SetAttributes(txAttributes(txInfo)...)
 	return txInfo, nil
 }
 
-func (c *TxClient) GetCancelOrderTransaction(tx *types.CancelOrderTxReq, ops *types.TransactOpts) (*txtypes.L2CancelOrderTxInfo, error) {
-	ops, err := c.FullFillDefaultOps(ops)
+func (c *TxClient) GetCreateGroupedOrdersTransaction(tx *types.CreateGroupedOrdersTxReq, ops *types.TransactOpts) (_ *txtypes.L2CreateGroupedOrdersTxInfo, err error) {
+	c, span := c.startSpan("lighter.CreateGroupedOrders")
+	defer func() { endSpan(span, err) }()
+
+	orders := make([]RiskOrder, 0, len(tx.Orders))
+	for _, order := range tx.Orders {
+		orders = append(orders, riskOrderFromCreate(order))
+	}
+	if err := c.checkRisk(ops, orders...); err != nil {
+		return nil, err
+	}
+	ops, err = c.FullFillDefaultOps(ops)
+	if err != nil {
+		return nil, err
+	}
+	txInfo, err := types.ConstructL2CreateGroupedOrdersTx(c.keyManager, c.chainId, tx, ops)
+	if err != nil {
+		return nil, err
+	}
+	span.SetAttributes(txAttributes(txInfo)...)
+	return txInfo, nil
+}
+
+func (c *TxClient) GetCancelOrderTransaction(tx *types.CancelOrderTxReq, ops *types.TransactOpts) (_ *txtypes.L2CancelOrderTxInfo, err error) {
+	c, span := c.startSpan("lighter.CancelOrder", AttrMarketId.Int(int(tx.MarketIndex)), AttrOrderIndex.Int64(tx.Index))
+	defer func() { endSpan(span, err) }()
+
+	ops, err = c.FullFillDefaultOps(ops)
 	if err != nil {
 		return nil, err
 	}
@@ -110,11 +143,25 @@ func (c *TxClient) GetCancelOrderTransaction(tx *types.CancelOrderTxReq, ops *ty
 	if err != nil {
 		return nil, err
 	}
+	span.SetAttributes(txAttributes(txInfo)...)
 	return txInfo, nil
 }
 
-func (c *TxClient) GetModifyOrderTransaction(tx *types.ModifyOrderTxReq, ops *types.TransactOpts) (*txtypes.L2ModifyOrderTxInfo, error) {
-	ops, err := c.FullFillDefaultOps(ops)
+func (c *TxClient) GetModifyOrderTransaction(tx *types.ModifyOrderTxReq, ops *types.TransactOpts) (_ *txtypes.L2ModifyOrderTxInfo, err error) {
+	c, span := c.startSpan("lighter.ModifyOrder", AttrMarketId.Int(int(tx.MarketIndex)), AttrOrderIndex.Int64(tx.Index))
+	defer func() { endSpan(span, err) }()
+
+	err = c.checkRisk(ops, RiskOrder{
+		MarketIndex: tx.MarketIndex,
+		BaseAmount:  tx.BaseAmount,
+		Price:       tx.Price,
+		SideUnknown: true,
+		IsTrigger:   tx.TriggerPrice != 0,
+	})
+	if err != nil {
+		return nil, err
+	}
+	ops, err = c.FullFillDefaultOps(ops)
 	if err != nil {
 		return nil, err
 	}
@@ -124,11 +171,15 @@ func (c *TxClient) GetModifyOrderTransaction(tx *types.ModifyOrderTxReq, ops *ty
 		return nil, err
 	}
 
+	span.SetAttributes(txAttributes(txInfo)...)
 	return txInfo, nil
 }
 
-func (c *TxClient) GetCancelAllOrdersTransaction(tx *types.CancelAllOrdersTxReq, ops *types.TransactOpts) (*txtypes.L2CancelAllOrdersTxInfo, error) {
-	ops, err := c.FullFillDefaultOps(ops)
+func (c *TxClient) GetCancelAllOrdersTransaction(tx *types.CancelAllOrdersTxReq, ops *types.TransactOpts) (_ *txtypes.L2CancelAllOrdersTxInfo, err error) {
+	c, span := c.startSpan("lighter.CancelAllOrders")
+	defer func() { endSpan(span, err) }()
+
+	ops, err = c.FullFillDefaultOps(ops)
 	if err != nil {
 		return nil, err
 	}
@@ -136,6 +187,7 @@ func (c *TxClient) GetCancelAllOrdersTransaction(tx *types.CancelAllOrdersTxReq,
 	if err != nil {
 		return nil, err
 	}
+	span.SetAttributes(txAttributes(txInfo)...)
 	return txInfo, nil
 }
 
@@ -176,14 +228,10 @@ func (c *TxClient) GetUpdateLeverageTransaction(tx *types.UpdateLeverageTxReq, o
 }
 
 func (c *TxClient) GetUpdateMarginTransaction(tx *types.UpdateMarginTxReq, ops *types.TransactOpts) (*txtypes.L2UpdateMarginTxInfo, error) {
-	if c.keyManager == nil {
-		return nil, fmt.Errorf("key manager is nil")
-	}
-
-	if ops == nil {
-		ops = new(types.TransactOpts)
+	ops, err := c.FullFillDefaultOps(ops)
+	if err != nil {
+		return nil, err
 	}
-
 	txInfo, err := types.ConstructUpdateMarginTx(c.keyManager, c.chainId, tx, ops)
 	if err != nil {
 		return nil, err
@@ -1,12 +1,15 @@
 package client
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"net/url"
+	"strings"
 	"sync"
 	"time"
 
@@ -18,11 +21,17 @@ type WSClient struct {
 	conn        *websocket.Conn
 	mu          sync.RWMutex
 	writeMu     sync.Mutex // Separate mutex for write operations
-	handlers    map[string][]WSHandler
+	handlers    map[string][]registeredHandler
 	isConnected bool
+	connId      uint64
 	stopCh      chan struct{}
 	authToken   string
 	stopped     bool // Flag to track if stopCh is closed
+	// hasConnected tells reconnects apart from the first connection
+	hasConnected bool
+
+	// lastHandlerId identifies the handlers added with addFrameHandler
+	lastHandlerId uint64
 
 	// For managing subscriptions
 	subscriptions map[string]bool
@@ -33,8 +42,29 @@ type WSClient struct {
 	// Connection state callbacks - like Python version
 	onConnected    func()
 	onDisconnected func()
+	// onError gets the errors sent by Lighter outside of a subscription
+	onError func(error)
+
+	// subscribeMu makes subscriptions one at a time: the error messages don't tell which channel they're about
+	subscribeMu sync.Mutex
+	// pendingSub is the subscription waiting for its confirmation
+	pendingSub *pendingSubscription
+}
+
+// pendingSubscription is a subscription sent to Lighter and not confirmed yet
+type pendingSubscription struct {
+	// channel is the subscribed channel, e.g. order_book/1 confirmed by a message of the "order_book:1" channel
+	channel string
+	done    chan error
+}
+
+// registeredHandler is a handler with the id removeFrameHandler removes it by
+type registeredHandler struct {
+	id      uint64
+	handler WSFrameHandler
 }
 
+// WSHandler handles the messages of a type, data is only valid during the call
 type WSHandler func(data []byte) error
 
 // NewWSClient creates a new WebSocket client
@@ -45,7 +75,7 @@ func NewWSClient(config *WSConfig) *WSClient {
 
 	return &WSClient{
 		config:          config,
-		handlers:        make(map[string][]WSHandler),
+		handlers:        make(map[string][]registeredHandler),
 		subscriptions:   make(map[string]bool),
 		orderBookStates: make(map[uint8]*WSOrderBookState),
 		stopCh:          make(chan struct{}),
@@ -66,6 +96,13 @@ func (ws *WSClient) SetOnConnected(callback func()) {
 	ws.onConnected = callback
 }
 
+// SetOnError sets the callback of the errors sent by Lighter which aren't the answer to a subscription
+func (ws *WSClient) SetOnError(callback func(error)) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	ws.onError = callback
+}
+
 // SetOnDisconnected sets callback for when connection is lost
 func (ws *WSClient) SetOnDisconnected(callback func()) {
 	ws.mu.Lock()
@@ -88,7 +125,16 @@ func (ws *WSClient) Connect(ctx context.Context) error {
 		ws.stopped = false
 	}
 
-	log.Println("[WSClient] Connecting to Lighter WebSocket...", ws.config.URL)
+	if ws.config.Replay != nil {
+		ws.connId = wsConnCounter.Add(1)
+		ws.isConnected = true
+		ws.config.Replay.attach(ws)
+		ws.reportConnected()
+		ws.logger().Info("Connected to recording replay")
+		return nil
+	}
+
+	ws.logger().Info("Connecting to Lighter WebSocket", LogKeyURL, ws.config.URL)
 	u, err := url.Parse(ws.config.URL)
 	if err != nil {
 		return fmt.Errorf("invalid WebSocket URL: %v", err)
@@ -109,13 +155,16 @@ func (ws *WSClient) Connect(ctx context.Context) error {
 	}
 
 	ws.conn = conn
+	ws.connId = wsConnCounter.Add(1)
 	ws.isConnected = true
 
+	ws.reportConnected()
+
 	// Start message handler goroutines
 	go ws.readMessages(ctx)
 	go ws.ping(ctx)
 
-	log.Println("[WSClient] Connected to Lighter WebSocket")
+	ws.logger().Info("Connected to Lighter WebSocket", LogKeyURL, ws.config.URL)
 	return nil
 }
 
@@ -134,6 +183,7 @@ func (ws *WSClient) Disconnect() error {
 		ws.stopped = true
 	}
 	ws.isConnected = false
+	metrics().WSConnectionState(ws.config.URL, false)
 
 	if ws.conn != nil {
 		// Ensure no writes are in progress before closing
@@ -144,10 +194,24 @@ func (ws *WSClient) Disconnect() error {
 		return err
 	}
 
-	log.Println("[WSClient] Disconnected from Lighter WebSocket")
+	ws.logger().Info("Disconnected from Lighter WebSocket", LogKeyURL, ws.config.URL)
 	return nil
 }
 
+// reportConnected reports a new connection. Must be called with ws.mu held.
+func (ws *WSClient) reportConnected() {
+	if ws.hasConnected {
+		metrics().WSReconnect(ws.config.URL)
+	}
+	ws.hasConnected = true
+	metrics().WSConnectionState(ws.config.URL, true)
+}
+
+// logger returns the logger of the client, see WSConfig.Logger
+func (ws *WSClient) logger() *slog.Logger {
+	return loggerOr(ws.config.Logger)
+}
+
 // IsConnected returns connection status
 func (ws *WSClient) IsConnected() bool {
 	ws.mu.RLock()
@@ -155,11 +219,19 @@ func (ws *WSClient) IsConnected() bool {
 	return ws.isConnected
 }
 
-// Subscribe subscribes to a WebSocket channel
+// Subscribe subscribes to a WebSocket channel and waits for Lighter to confirm it, up to WSConfig.SubscribeTimeout.
+// A rejected or unconfirmed subscription returns a *SubscribeError. Add the handlers of the channel first:
+// the confirmation carries the first message, e.g. the order book snapshot.
 func (ws *WSClient) Subscribe(channel, symbol string) error {
-	ws.mu.Lock()
-	defer ws.mu.Unlock()
+	return ws.subscribe(channel, symbol, "")
+}
+
+// subscribe sends a subscription with the auth token of the channel, if it has one
+func (ws *WSClient) subscribe(channel, symbol, auth string) error {
+	ws.subscribeMu.Lock()
+	defer ws.subscribeMu.Unlock()
 
+	ws.mu.Lock()
 	subscriptionKey := channel
 	if symbol != "" {
 		subscriptionKey = fmt.Sprintf("%s:%s", channel, symbol)
@@ -168,17 +240,121 @@ func (ws *WSClient) Subscribe(channel, symbol string) error {
 	ws.subscriptions[subscriptionKey] = true
 
 	if !ws.isConnected {
-		return fmt.Errorf("WebSocket not connected")
+		ws.mu.Unlock()
+		return ErrNotConnected
 	}
 
 	msg := WSSubscribeMessage{
 		Type:    MessageTypeSubscribe,
 		Channel: channel,
 		Symbol:  symbol,
+		Auth:    auth,
 	}
 
-	log.Printf("[WSClient] Subscribing to channel: %s (symbol: %s, key: %s)", channel, symbol, subscriptionKey)
-	return ws.sendMessage(msg)
+	ws.logger().Debug("Subscribing", LogKeyChannel, channel, "symbol", symbol)
+	// a replay has no server to confirm
+	if ws.config.Replay != nil {
+		ws.mu.Unlock()
+		return ws.sendMessage(msg)
+	}
+	pending := &pendingSubscription{channel: channel, done: make(chan error, 1)}
+	ws.pendingSub = pending
+	stopCh := ws.stopCh
+	err := ws.sendMessage(msg)
+	ws.mu.Unlock()
+
+	if err == nil {
+		timeout := ws.config.SubscribeTimeout
+		if timeout <= 0 {
+			timeout = DefaultSubscribeTimeout
+		}
+		timer := time.NewTimer(timeout)
+		select {
+		case err = <-pending.done:
+		case <-timer.C:
+			err = ErrSubscribeTimeout
+		case <-stopCh:
+			err = ErrNotConnected
+		}
+		timer.Stop()
+	}
+
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	if ws.pendingSub == pending {
+		ws.pendingSub = nil
+	}
+	if err != nil {
+		delete(ws.subscriptions, subscriptionKey)
+		return &SubscribeError{Channel: channel, Err: err}
+	}
+	return nil
+}
+
+// confirmSubscription ends the pending subscription when its confirmation arrives
+func (ws *WSClient) confirmSubscription(channel string) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	if p := ws.pendingSub; p != nil && (channel == "" || channelMatches(channel, p.channel)) {
+		ws.pendingSub = nil
+		p.done <- nil
+	}
+}
+
+// isPending tells whether the pending subscription is the one of channel
+func (ws *WSClient) isPending(channel string) bool {
+	ws.mu.RLock()
+	defer ws.mu.RUnlock()
+	return ws.pendingSub != nil && channelMatches(channel, ws.pendingSub.channel)
+}
+
+// channelMatches tells whether msgChannel, the channel of a message like "order_book:1", is the one of a subscribed
+// channel like order_book/1. The messages of some private channels leave out the account: the ones of
+// account_orders/1/7 have the "account_orders:1" channel.
+func channelMatches(msgChannel, channel string) bool {
+	if len(msgChannel) > len(channel) {
+		return false
+	}
+	for i := 0; i < len(msgChannel); i++ {
+		c, m := channel[i], msgChannel[i]
+		if c != m && !(c == '/' && m == ':') {
+			return false
+		}
+	}
+	return len(msgChannel) == len(channel) || channel[len(msgChannel)] == '/'
+}
+
+// handleServerError fails the pending subscription with an error sent by Lighter when it rejects it, see rejects.
+// The other errors are passed to the onError callback, and the subscription keeps waiting for its confirmation.
+func (ws *WSClient) handleServerError(wsErr *WSError, msgChannel string) {
+	ws.mu.Lock()
+	p := ws.pendingSub
+	if p != nil && rejects(wsErr, msgChannel, p.channel) {
+		ws.pendingSub = nil
+	} else {
+		p = nil
+	}
+	onError := ws.onError
+	ws.mu.Unlock()
+
+	if p != nil {
+		p.done <- wsErr
+		return
+	}
+	ws.logger().Error("WebSocket error", "code", wsErr.Code, "message", wsErr.Message)
+	if onError != nil {
+		go onError(wsErr)
+	}
+}
+
+// rejects tells whether an error of Lighter is about the subscription to channel: the message names the channel,
+// or it names none and the error is one of a subscription
+func rejects(wsErr *WSError, msgChannel, channel string) bool {
+	if msgChannel != "" {
+		return channelMatches(msgChannel, channel)
+	}
+	return errors.Is(wsErr, ErrInvalidChannel) || errors.Is(wsErr, ErrAuthFailed) || errors.Is(wsErr, ErrSubscriptionLimit)
 }
 
 // Unsubscribe unsubscribes from a WebSocket channel
@@ -210,13 +386,42 @@ func (ws *WSClient) Unsubscribe(channel, symbol string) error {
 
 // AddHandler adds a message handler for a specific channel
 func (ws *WSClient) AddHandler(channel string, handler WSHandler) {
+	ws.AddFrameHandler(channel, func(frame *WSFrame) error {
+		return handler(frame.Data)
+	})
+}
+
+// AddFrameHandler adds a handler of the messages of a type, which gets their peeked type & channel
+func (ws *WSClient) AddFrameHandler(msgType string, handler WSFrameHandler) {
+	ws.addFrameHandler(msgType, handler)
+}
+
+// addFrameHandler adds a handler and returns its id, to remove it alone with removeFrameHandler
+func (ws *WSClient) addFrameHandler(msgType string, handler WSFrameHandler) uint64 {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	ws.lastHandlerId++
+	ws.handlers[msgType] = append(ws.handlers[msgType], registeredHandler{id: ws.lastHandlerId, handler: handler})
+	return ws.lastHandlerId
+}
+
+// removeFrameHandler removes the handler with the id returned by addFrameHandler, and keeps the others of its type
+func (ws *WSClient) removeFrameHandler(msgType string, id uint64) {
 	ws.mu.Lock()
 	defer ws.mu.Unlock()
 
-	if ws.handlers[channel] == nil {
-		ws.handlers[channel] = make([]WSHandler, 0)
+	handlers := ws.handlers[msgType]
+	for i, h := range handlers {
+		if h.id == id {
+			// copied, handleMessage may be iterating over the former slice
+			ws.handlers[msgType] = append(handlers[:i:i], handlers[i+1:]...)
+			break
+		}
+	}
+	if len(ws.handlers[msgType]) == 0 {
+		delete(ws.handlers, msgType)
 	}
-	ws.handlers[channel] = append(ws.handlers[channel], handler)
 }
 
 // RemoveHandler removes all handlers for a channel
@@ -232,8 +437,13 @@ func (ws *WSClient) sendMessage(msg interface{}) error {
 	ws.writeMu.Lock()
 	defer ws.writeMu.Unlock()
 
+	// a replay has no server to talk to
+	if ws.config.Replay != nil {
+		return nil
+	}
+
 	if ws.conn == nil {
-		return fmt.Errorf("WebSocket not connected")
+		return ErrNotConnected
 	}
 
 	data, err := json.Marshal(msg)
@@ -248,10 +458,11 @@ func (ws *WSClient) sendMessage(msg interface{}) error {
 func (ws *WSClient) readMessages(ctx context.Context) {
 	defer func() {
 		if r := recover(); r != nil {
-			log.Printf("[WSClient] Panic in readMessages: %v", r)
+			ws.logger().Error("Panic in readMessages", "panic", r)
 		}
 	}()
 
+	var readBuf bytes.Buffer
 	for {
 		select {
 		case <-ctx.Done():
@@ -265,42 +476,55 @@ func (ws *WSClient) readMessages(ctx context.Context) {
 			}
 
 			ws.conn.SetReadDeadline(time.Now().Add(ws.config.ReadTimeout))
-			_, data, err := ws.conn.ReadMessage()
+			// the buffer is reused for every message, the handlers don't keep the data
+			readBuf.Reset()
+			_, r, err := ws.conn.NextReader()
+			if err == nil {
+				_, err = readBuf.ReadFrom(r)
+			}
 			if err != nil {
-				log.Printf("[WSClient] Read error: %v", err)
+				ws.logger().Warn("Read error", LogKeyError, err)
 				ws.handleDisconnect(ctx)
 				return
 			}
+			data := readBuf.Bytes()
 
+			if ws.config.Recorder != nil {
+				ws.config.Recorder.Record(ws.connId, time.Now(), data)
+			}
 			ws.handleMessage(data)
 		}
 	}
 }
 
+// handleMessage peeks the type & channel of a message once and routes it to the handlers of its type
 func (ws *WSClient) handleMessage(data []byte) {
-	// First check if this is an error message
-	var errorMsg struct {
-		Error struct {
-			Code    int    `json:"code"`
-			Message string `json:"message"`
-		} `json:"error"`
-	}
+	msg := framePool.Get().(*WSFrame)
+	defer framePool.Put(msg)
 
-	if err := json.Unmarshal(data, &errorMsg); err == nil && errorMsg.Error.Code != 0 {
-		log.Printf("[WSClient] WebSocket Error %d: %s", errorMsg.Error.Code, errorMsg.Error.Message)
+	var err error
+	if *msg, err = PeekWSFrame(data); err != nil {
+		ws.logger().Warn("Failed to unmarshal message", LogKeyError, err)
 		return
 	}
-
-	var msg WSMessage
-	if err := json.Unmarshal(data, &msg); err != nil {
-		log.Printf("[WSClient] Failed to unmarshal message: %v", err)
+	if msg.Error != nil {
+		// the frame is reused, the error isn't
+		wsErr := *msg.Error
+		ws.handleServerError(&wsErr, msg.Channel)
 		return
 	}
+	metrics().WSMessage(msg.Type)
+
+	if msg.Type == MessageTypeSubscribed || strings.HasPrefix(msg.Type, MessageTypeSubscribed+"/") {
+		ws.confirmSubscription(msg.Channel)
+	} else if msg.Channel != "" && ws.isPending(msg.Channel) {
+		// some channels, like height, start with an update
+		ws.confirmSubscription(msg.Channel)
+	}
 
-	// Debug: Print parsed message type (skip frequent messages)
-	if msg.Type != "update/order_book" && msg.Type != MessageTypePong && msg.Type != MessageTypePing {
-		log.Printf("[WSClient] Received message: %s", string(data))
-		log.Printf("[WSClient] Parsed message type: %s", msg.Type)
+	// only the type & channel are logged, the messages carry private account data
+	if msg.Type != MessageTypeOrderBookUpdate && msg.Type != MessageTypePong && msg.Type != MessageTypePing {
+		ws.logger().Debug("Received message", LogKeyType, msg.Type, LogKeyChannel, msg.Channel)
 	}
 
 	// Handle specific message types like Python version
@@ -309,29 +533,29 @@ func (ws *WSClient) handleMessage(data []byte) {
 		// Server sent ping, respond with pong
 		pong := WSMessage{Type: MessageTypePong}
 		if err := ws.sendMessage(pong); err != nil {
-			log.Printf("[WSClient] Failed to send pong: %v", err)
+			ws.logger().Warn("Failed to send pong", LogKeyError, err)
 		}
 		return
 	case MessageTypePong:
 		// Server responded to our ping, nothing to do
 		return
 	case MessageTypeConnected:
-		log.Println("[WSClient] Connected to Lighter WebSocket")
+		ws.logger().Debug("Connection acknowledged", LogKeyURL, ws.config.URL)
 		if ws.onConnected != nil {
 			go ws.onConnected()
 		}
 		return
 	case MessageTypeSubscribed:
-		log.Printf("[WSClient] Successfully subscribed")
+		ws.logger().Debug("Subscribed", LogKeyChannel, msg.Channel)
 		return
 	case MessageTypeUnsubscribed:
-		log.Printf("[WSClient] Successfully unsubscribed")
+		ws.logger().Debug("Unsubscribed", LogKeyChannel, msg.Channel)
 		return
 	case MessageTypeOrderBookSubscribed:
-		log.Printf("[WSClient] Order book subscription confirmed - processing snapshot")
+		ws.logger().Debug("Order book subscription confirmed", LogKeyChannel, msg.Channel)
 		// Remove built-in snapshot handling - let registered handlers in new architecture handle this
 	case MessageTypeAccountSubscribed:
-		log.Printf("[WSClient] Account subscription confirmed - processing snapshot")
+		ws.logger().Debug("Account subscription confirmed", LogKeyChannel, msg.Channel)
 		// ws.handleAccountSnapshot(data)
 		// return
 	case MessageTypeOrderBookUpdate:
@@ -344,19 +568,23 @@ func (ws *WSClient) handleMessage(data []byte) {
 	ws.mu.RLock()
 	handlers := ws.handlers[msg.Type]
 	ws.mu.RUnlock()
+	if len(handlers) > 0 {
+		start := time.Now()
+		defer func() { metrics().WSHandlerDuration(msg.Type, time.Since(start)) }()
+	}
 
 	for _, handler := range handlers {
-		func(h WSHandler) {
+		func(h WSFrameHandler) {
 			defer func() {
 				if r := recover(); r != nil {
-					log.Printf("[WSClient] Handler panic: %v", r)
+					ws.logger().Error("Handler panic", LogKeyType, msg.Type, LogKeyChannel, msg.Channel, "panic", r)
 				}
 			}()
 
-			if err := h(data); err != nil {
-				log.Printf("[WSClient] Handler error: %v", err)
+			if err := h(msg); err != nil {
+				ws.logger().Error("Handler error", LogKeyType, msg.Type, LogKeyChannel, msg.Channel, LogKeyError, err)
 			}
-		}(handler)
+		}(handler.handler)
 	}
 }
 
@@ -374,7 +602,7 @@ func (ws *WSClient) ping(ctx context.Context) {
 			if ws.isConnected && ws.conn != nil {
 				ping := WSMessage{Type: MessageTypePing}
 				if err := ws.sendMessage(ping); err != nil {
-					log.Printf("[WSClient] Failed to send ping: %v", err)
+					ws.logger().Warn("Failed to send ping", LogKeyError, err)
 				}
 			}
 		}
@@ -383,6 +611,9 @@ func (ws *WSClient) ping(ctx context.Context) {
 
 func (ws *WSClient) handleDisconnect(ctx context.Context) {
 	ws.mu.Lock()
+	if ws.isConnected {
+		metrics().WSConnectionState(ws.config.URL, false)
+	}
 	ws.isConnected = false
 	if ws.conn != nil {
 		// Ensure no writes are in progress before closing
@@ -391,10 +622,14 @@ func (ws *WSClient) handleDisconnect(ctx context.Context) {
 		ws.conn = nil
 		ws.writeMu.Unlock()
 	}
+	if p := ws.pendingSub; p != nil {
+		ws.pendingSub = nil
+		p.done <- ErrNotConnected
+	}
 	onDisconnected := ws.onDisconnected
 	ws.mu.Unlock()
 
-	log.Println("[WSClient] Connection lost")
+	ws.logger().Warn("Connection lost", LogKeyURL, ws.config.URL)
 
 	// Call disconnect callback to notify external
 	if onDisconnected != nil {
@@ -406,43 +641,27 @@ func (ws *WSClient) handleDisconnect(ctx context.Context) {
 
 // handleAccountSnapshot handles complete account snapshot (subscribed/account_all)
 func (ws *WSClient) handleAccountSnapshot(data []byte) {
-	var accountSnapshot struct {
-		Type               string                 `json:"type"`
-		Channel            string                 `json:"channel"`
-		Account            int64                  `json:"account"`
-		DailyTradesCount   int                    `json:"daily_trades_count"`
-		DailyVolume        float64                `json:"daily_volume"`
-		MonthlyTradesCount int                    `json:"monthly_trades_count"`
-		MonthlyVolume      float64                `json:"monthly_volume"`
-		TotalTradesCount   int                    `json:"total_trades_count"`
-		TotalVolume        float64                `json:"total_volume"`
-		WeeklyTradesCount  int                    `json:"weekly_trades_count"`
-		WeeklyVolume       float64                `json:"weekly_volume"`
-		Positions          map[string]interface{} `json:"positions"`
-		Shares             []interface{}          `json:"shares"`
-		Trades             map[string]interface{} `json:"trades"`
-		FundingHistories   map[string]interface{} `json:"funding_histories"`
-	}
+	var accountSnapshot WSAccountUpdate
 
 	if err := json.Unmarshal(data, &accountSnapshot); err != nil {
-		log.Printf("[WSClient] Failed to unmarshal account snapshot: %v", err)
+		ws.logger().Warn("Failed to unmarshal account snapshot", LogKeyError, err)
 		return
 	}
 
-	log.Printf("[WSClient] Loaded account snapshot for account %d: %d positions, %d total trades",
-		accountSnapshot.Account, len(accountSnapshot.Positions), accountSnapshot.TotalTradesCount)
+	ws.logger().Debug("Loaded account snapshot", LogKeyAccount, accountSnapshot.Account,
+		"positions", len(accountSnapshot.Positions), "total_trades", accountSnapshot.TotalTradesCount)
 }
 
 // handleAccountMessage handles incremental account updates (update/account_all)
 func (ws *WSClient) handleAccountMessage(data []byte) {
 	var accountUpdate WSAccountUpdate
 	if err := json.Unmarshal(data, &accountUpdate); err != nil {
-		log.Printf("[WSClient] Failed to unmarshal account update: %v", err)
+		ws.logger().Warn("Failed to unmarshal account update", LogKeyError, err)
 		return
 	}
 
-	log.Printf("[WSClient] Account update for account %d, %d positions, type: %s",
-		accountUpdate.Account, len(accountUpdate.Positions), accountUpdate.Type)
+	ws.logger().Debug("Account update", LogKeyAccount, accountUpdate.Account,
+		"positions", len(accountUpdate.Positions), LogKeyType, accountUpdate.Type)
 }
 
 // GetOrderBookState returns current order book state for a market (like Python version)
@@ -0,0 +1,102 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWSErrorIs(t *testing.T) {
+	tests := []struct {
+		message string
+		target  error
+	}{
+		{"Invalid channel", ErrInvalidChannel},
+		{"channel not found: order_book/300", ErrInvalidChannel},
+		{"Auth failed", ErrAuthFailed},
+		{"invalid auth token", ErrAuthFailed},
+		{"expired TOKEN", ErrAuthFailed},
+		{"subscription limit reached", ErrSubscriptionLimit},
+		{"Too many subscriptions", ErrSubscriptionLimit},
+		{"internal server error", nil},
+	}
+	for _, tt := range tests {
+		wsErr := &WSError{Code: 30000, Message: tt.message}
+		for _, target := range []error{ErrInvalidChannel, ErrAuthFailed, ErrSubscriptionLimit} {
+			if got := errors.Is(wsErr, target); got != (target == tt.target) {
+				t.Errorf("%q: expected errors.Is(%v) to be %v", tt.message, target, target == tt.target)
+			}
+		}
+		// through a SubscribeError too
+		if tt.target != nil && !errors.Is(&SubscribeError{Channel: "trade/1", Err: wsErr}, tt.target) {
+			t.Errorf("%q: expected the SubscribeError to match %v", tt.message, tt.target)
+		}
+	}
+}
+
+func TestWSClientSubscribe(t *testing.T) {
+	const channel = "trade/1"
+	confirmation := fmt.Sprintf(`{"type":"subscribed","channel":%q}`, channel)
+	tests := []struct {
+		name    string
+		replies []string
+		// err is matched with errors.Is, nil for a confirmed subscription
+		err error
+		// onError is the number of errors passed to the onError callback
+		onError int
+	}{
+		{name: "confirmed", replies: []string{confirmation}},
+		{name: "confirmed by an update", replies: []string{`{"type":"update/trade","channel":"trade:1","trades":[]}`}},
+		{name: "timeout", replies: nil, err: ErrSubscribeTimeout},
+		{name: "rejected", replies: []string{`{"error":{"code":30003,"message":"Invalid channel"}}`}, err: ErrInvalidChannel},
+		{name: "rejected channel", replies: []string{`{"error":{"code":30001,"message":"Auth failed"},"channel":"trade:1"}`}, err: ErrAuthFailed},
+		{name: "unrelated error", replies: []string{`{"error":{"code":20000,"message":"internal server error"}}`, confirmation}, onError: 1},
+		{name: "error of another channel", replies: []string{`{"error":{"code":30003,"message":"Invalid channel"},"channel":"trade:2"}`, confirmation}, onError: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			release := make(chan struct{})
+			close(release)
+			server := newTestWSServer(t, release)
+			server.respond = func(WSSubscribeMessage) []string { return tt.replies }
+			server.config.SubscribeTimeout = 100 * time.Millisecond
+
+			ws := NewWSClient(server.config)
+			var mu sync.Mutex
+			var errs []error
+			ws.SetOnError(func(err error) {
+				mu.Lock()
+				defer mu.Unlock()
+				errs = append(errs, err)
+			})
+			if err := ws.Connect(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			defer ws.Disconnect()
+
+			err := ws.Subscribe(channel, "")
+			var subErr *SubscribeError
+			switch {
+			case tt.err == nil && err != nil:
+				t.Fatalf("expected the subscription confirmed, got %v", err)
+			case tt.err != nil && (!errors.Is(err, tt.err) || !errors.As(err, &subErr) || subErr.Channel != channel):
+				t.Fatalf("expected a SubscribeError of %s matching %v, got %v", channel, tt.err, err)
+			}
+			if tt.onError > 0 {
+				waitFor(t, "onError", func() bool {
+					mu.Lock()
+					defer mu.Unlock()
+					return len(errs) == tt.onError
+				})
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if len(errs) != tt.onError {
+				t.Errorf("expected %d errors passed to onError, got %v", tt.onError, errs)
+			}
+		})
+	}
+}
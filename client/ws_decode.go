@@ -0,0 +1,450 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/u20024804/lighter-ex/decimal"
+)
+
+// WSFrame is a received message whose type & channel were peeked once, handlers decode the rest of Data themselves.
+// The frame & Data are only valid during the call of the handlers, they're reused for the next messages.
+type WSFrame struct {
+	Type    string
+	Channel string
+	Data    []byte
+	// Error is set for the error messages, which have no type
+	Error *WSError
+}
+
+// WSFrameHandler handles the frames of a message type. Unlike a WSHandler it gets the type & channel of the
+// message, so it can skip the channels it's not interested in without decoding them.
+type WSFrameHandler func(frame *WSFrame) error
+
+var framePool = sync.Pool{New: func() any { return new(WSFrame) }}
+
+// PeekWSFrame reads the type, channel & error of a message without decoding the rest of it
+func PeekWSFrame(data []byte) (WSFrame, error) {
+	frame := WSFrame{Data: data}
+	s := jsonScanner{data: data}
+	err := s.readObject(func(key []byte) error {
+		switch string(key) {
+		case "type":
+			v, err := s.readStringValue()
+			frame.Type = v
+			return err
+		case "channel":
+			v, err := s.readStringValue()
+			frame.Channel = v
+			return err
+		case "error":
+			start := s.pos
+			if err := s.skipValue(); err != nil {
+				return err
+			}
+			var wsErr WSError
+			if err := json.Unmarshal(data[start:s.pos], &wsErr); err == nil && wsErr.Code != 0 {
+				frame.Error = &wsErr
+			}
+			return nil
+		}
+		return s.skipValue()
+	})
+	return frame, err
+}
+
+// WSBookLevel is a price level of an order book message, a zero size removes the level from the book
+type WSBookLevel struct {
+	Price decimal.Decimal
+	Size  decimal.Decimal
+}
+
+// WSOrderBookFrame is an order book snapshot or update, decoded without allocating.
+// The frames passed to callbacks are pooled: they're only valid during the call, copy what must be kept.
+type WSOrderBookFrame struct {
+	MarketId   uint8
+	IsSnapshot bool
+	Offset     int64
+	Timestamp  int64
+	Bids       []WSBookLevel
+	Asks       []WSBookLevel
+}
+
+var orderBookFramePool = sync.Pool{New: func() any { return new(WSOrderBookFrame) }}
+
+// DecodeOrderBookFrame decodes a subscribed/order_book or update/order_book message into f, reusing its slices.
+// The market id is read from the channel of the message.
+// It decodes like encoding/json into a map: the last of repeated keys wins and null is the zero value.
+func DecodeOrderBookFrame(data []byte, f *WSOrderBookFrame) error {
+	*f = WSOrderBookFrame{Bids: f.Bids[:0], Asks: f.Asks[:0]}
+	s := jsonScanner{data: data}
+	err := s.readObject(func(key []byte) error {
+		switch string(key) {
+		case "type":
+			v, err := s.readStringValue()
+			f.IsSnapshot = v == MessageTypeOrderBookSubscribed
+			return err
+		case "channel":
+			v, err := s.readStringValue()
+			if err != nil {
+				return err
+			}
+			f.MarketId, err = parseChannelMarket(v)
+			return err
+		case "timestamp":
+			v, err := s.readInt()
+			f.Timestamp = v
+			return err
+		case "order_book":
+			f.Bids, f.Asks, f.Offset = f.Bids[:0], f.Asks[:0], 0
+			if s.peek() == 'n' {
+				return s.skipValue()
+			}
+			return s.readObject(func(key []byte) error {
+				switch string(key) {
+				case "asks":
+					return s.readBookLevels(&f.Asks)
+				case "bids":
+					return s.readBookLevels(&f.Bids)
+				case "offset":
+					v, err := s.readInt()
+					f.Offset = v
+					return err
+				}
+				return s.skipValue()
+			})
+		}
+		return s.skipValue()
+	})
+	if err != nil {
+		return fmt.Errorf("failed to decode order book message: %w", err)
+	}
+	return nil
+}
+
+// parseChannelMarket returns the market id of a channel like "order_book:1"
+func parseChannelMarket(channel string) (uint8, error) {
+	for i := len(channel) - 1; i >= 0; i-- {
+		if channel[i] == ':' || channel[i] == '/' {
+			var id uint64
+			digits := channel[i+1:]
+			if len(digits) == 0 || len(digits) > 3 {
+				break
+			}
+			for _, c := range []byte(digits) {
+				if c < '0' || c > '9' {
+					return 0, fmt.Errorf("invalid channel %q", channel)
+				}
+				id = id*10 + uint64(c-'0')
+			}
+			if id > 255 {
+				break
+			}
+			return uint8(id), nil
+		}
+	}
+	return 0, fmt.Errorf("invalid channel %q", channel)
+}
+
+// internedStrings holds the types & channels of the messages, so peeking them doesn't allocate
+var internedStrings = struct {
+	sync.RWMutex
+	m map[string]string
+}{m: make(map[string]string)}
+
+// maxInternedStrings bounds the interned strings, the others are allocated
+const maxInternedStrings = 4096
+
+func intern(b []byte) string {
+	internedStrings.RLock()
+	s, ok := internedStrings.m[string(b)]
+	internedStrings.RUnlock()
+	if ok {
+		return s
+	}
+	s = string(b)
+	internedStrings.Lock()
+	if len(internedStrings.m) < maxInternedStrings {
+		internedStrings.m[s] = s
+	}
+	internedStrings.Unlock()
+	return s
+}
+
+var errInvalidJSON = errors.New("invalid JSON")
+
+// jsonScanner reads JSON values in place, for the messages decoded on the hot path
+type jsonScanner struct {
+	data []byte
+	pos  int
+}
+
+func (s *jsonScanner) skipSpace() {
+	for s.pos < len(s.data) {
+		switch s.data[s.pos] {
+		case ' ', '\t', '\n', '\r':
+			s.pos++
+		default:
+			return
+		}
+	}
+}
+
+func (s *jsonScanner) peek() byte {
+	s.skipSpace()
+	if s.pos >= len(s.data) {
+		return 0
+	}
+	return s.data[s.pos]
+}
+
+func (s *jsonScanner) expect(c byte) error {
+	if s.peek() != c {
+		return fmt.Errorf("%w: expected %q at offset %d", errInvalidJSON, c, s.pos)
+	}
+	s.pos++
+	return nil
+}
+
+// readObject calls fn with every key of an object, fn must read the value.
+// Keys with escapes are unescaped, which allocates.
+func (s *jsonScanner) readObject(fn func(key []byte) error) error {
+	if err := s.expect('{'); err != nil {
+		return err
+	}
+	if s.peek() == '}' {
+		s.pos++
+		return nil
+	}
+	for {
+		start := s.pos
+		key, escaped, err := s.readString()
+		if err != nil {
+			return err
+		}
+		if escaped {
+			var str string
+			if err := json.Unmarshal(s.data[start:s.pos], &str); err != nil {
+				return err
+			}
+			key = []byte(str)
+		}
+		if err := s.expect(':'); err != nil {
+			return err
+		}
+		if err := fn(key); err != nil {
+			return err
+		}
+		switch s.peek() {
+		case ',':
+			s.pos++
+		case '}':
+			s.pos++
+			return nil
+		default:
+			return fmt.Errorf("%w: unterminated object at offset %d", errInvalidJSON, s.pos)
+		}
+	}
+}
+
+// readArray calls fn for every element of an array, fn must read the element
+func (s *jsonScanner) readArray(fn func() error) error {
+	if err := s.expect('['); err != nil {
+		return err
+	}
+	if s.peek() == ']' {
+		s.pos++
+		return nil
+	}
+	for {
+		if err := fn(); err != nil {
+			return err
+		}
+		switch s.peek() {
+		case ',':
+			s.pos++
+		case ']':
+			s.pos++
+			return nil
+		default:
+			return fmt.Errorf("%w: unterminated array at offset %d", errInvalidJSON, s.pos)
+		}
+	}
+}
+
+// readString returns the raw content of a string, and whether it has escapes
+func (s *jsonScanner) readString() ([]byte, bool, error) {
+	if err := s.expect('"'); err != nil {
+		return nil, false, err
+	}
+	start, escaped := s.pos, false
+	for s.pos < len(s.data) {
+		switch s.data[s.pos] {
+		case '\\':
+			escaped = true
+			s.pos += 2
+			continue
+		case '"':
+			s.pos++
+			return s.data[start : s.pos-1], escaped, nil
+		}
+		s.pos++
+	}
+	return nil, false, fmt.Errorf("%w: unterminated string", errInvalidJSON)
+}
+
+// readStringValue reads a string, or null as ""
+func (s *jsonScanner) readStringValue() (string, error) {
+	if s.peek() == 'n' {
+		return "", s.skipValue()
+	}
+	start := s.pos
+	v, escaped, err := s.readString()
+	if err != nil {
+		return "", err
+	}
+	if escaped {
+		var str string
+		err := json.Unmarshal(s.data[start:s.pos], &str)
+		return str, err
+	}
+	return intern(v), nil
+}
+
+// readNumber returns the raw content of a number, which may be quoted
+func (s *jsonScanner) readNumber() ([]byte, error) {
+	if s.peek() == '"' {
+		v, _, err := s.readString()
+		return v, err
+	}
+	start := s.pos
+	for s.pos < len(s.data) {
+		c := s.data[s.pos]
+		if (c < '0' || c > '9') && c != '-' && c != '+' && c != '.' && c != 'e' && c != 'E' {
+			break
+		}
+		s.pos++
+	}
+	if s.pos == start {
+		return nil, fmt.Errorf("%w: expected a number at offset %d", errInvalidJSON, s.pos)
+	}
+	return s.data[start:s.pos], nil
+}
+
+func (s *jsonScanner) readInt() (int64, error) {
+	if s.peek() == 'n' {
+		return 0, s.skipValue()
+	}
+	v, err := s.readNumber()
+	if err != nil {
+		return 0, err
+	}
+	var n int64
+	digits := v
+	neg := len(digits) > 0 && digits[0] == '-'
+	if neg {
+		digits = digits[1:]
+	}
+	if len(digits) == 0 || len(digits) > 18 {
+		return strconv.ParseInt(string(v), 10, 64)
+	}
+	for _, c := range digits {
+		if c < '0' || c > '9' {
+			return strconv.ParseInt(string(v), 10, 64)
+		}
+		n = n*10 + int64(c-'0')
+	}
+	if neg {
+		n = -n
+	}
+	return n, nil
+}
+
+func (s *jsonScanner) readDecimal() (decimal.Decimal, error) {
+	if s.peek() == 'n' {
+		return decimal.Zero, s.skipValue()
+	}
+	v, err := s.readNumber()
+	if err != nil {
+		return decimal.Zero, err
+	}
+	if len(v) == 0 {
+		return decimal.Zero, nil
+	}
+	return decimal.ParseBytes(v)
+}
+
+// readBookLevels reads the {"price", "size"} levels of an array into levels, null is no level
+func (s *jsonScanner) readBookLevels(levels *[]WSBookLevel) error {
+	*levels = (*levels)[:0]
+	if s.peek() == 'n' {
+		return s.skipValue()
+	}
+	return s.readArray(func() error {
+		var level WSBookLevel
+		if s.peek() == 'n' {
+			*levels = append(*levels, level)
+			return s.skipValue()
+		}
+		err := s.readObject(func(key []byte) error {
+			var err error
+			switch string(key) {
+			case "price":
+				level.Price, err = s.readDecimal()
+			case "size":
+				level.Size, err = s.readDecimal()
+			default:
+				err = s.skipValue()
+			}
+			return err
+		})
+		*levels = append(*levels, level)
+		return err
+	})
+}
+
+// skipValue skips a value of any type
+func (s *jsonScanner) skipValue() error {
+	switch c := s.peek(); c {
+	case '"':
+		_, _, err := s.readString()
+		return err
+	case '{', '[':
+		depth := 0
+		for s.pos < len(s.data) {
+			switch s.data[s.pos] {
+			case '"':
+				if _, _, err := s.readString(); err != nil {
+					return err
+				}
+				continue
+			case '{', '[':
+				depth++
+			case '}', ']':
+				depth--
+				if depth == 0 {
+					s.pos++
+					return nil
+				}
+			}
+			s.pos++
+		}
+		return fmt.Errorf("%w: unterminated %q", errInvalidJSON, c)
+	case 't', 'f', 'n':
+		start := s.pos
+		for s.pos < len(s.data) && s.data[s.pos] >= 'a' && s.data[s.pos] <= 'z' {
+			s.pos++
+		}
+		switch string(s.data[start:s.pos]) {
+		case "true", "false", "null":
+			return nil
+		}
+		return fmt.Errorf("%w: invalid literal at offset %d", errInvalidJSON, start)
+	default:
+		_, err := s.readNumber()
+		return err
+	}
+}
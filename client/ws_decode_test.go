@@ -0,0 +1,392 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/u20024804/lighter-ex/decimal"
+)
+
+func TestPeekWSFrame(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		frame   WSFrame
+		invalid bool
+	}{
+		{"message", `{"channel":"order_book:1","offset":4021,"type":"update/order_book"}`,
+			WSFrame{Type: "update/order_book", Channel: "order_book:1"}, false},
+		{"escapes", `{"type":"update\/order_book","channel":"trade:1","info":"say \"hi\" \\"}`,
+			WSFrame{Type: "update/order_book", Channel: "trade:1"}, false},
+		{"escaped key", `{"\u0074ype":"connected"}`, WSFrame{Type: "connected"}, false},
+		{"null", `{"type":null,"channel":null,"error":null}`, WSFrame{}, false},
+		{"nested", ` { "data" : {"a":[1,{"b":"}]"},[]],"type":"nope"} , "type" : "ping" } `, WSFrame{Type: "ping"}, false},
+		{"literals", `{"a":true,"b":false,"c":-1.5e+3,"type":"pong"}`, WSFrame{Type: "pong"}, false},
+		{"error", `{"error":{"code":30003,"message":"Invalid Channel"}}`,
+			WSFrame{Error: &WSError{Code: 30003, Message: "Invalid Channel"}}, false},
+		{"no error code", `{"error":{"message":"?"},"type":"ping"}`, WSFrame{Type: "ping"}, false},
+		{"truncated object", `{"type":"ping"`, WSFrame{}, true},
+		{"truncated string", `{"type":"pi`, WSFrame{}, true},
+		{"truncated escape", `{"type":"ping\`, WSFrame{}, true},
+		{"truncated nested", `{"data":{"a":[1,2`, WSFrame{}, true},
+		{"empty", ``, WSFrame{}, true},
+		{"not an object", `["type","ping"]`, WSFrame{}, true},
+		{"invalid literal", `{"a":nul,"type":"ping"}`, WSFrame{}, true},
+		{"missing colon", `{"type" "ping"}`, WSFrame{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			frame, err := PeekWSFrame([]byte(tt.data))
+			if tt.invalid {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", frame)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if frame.Type != tt.frame.Type || frame.Channel != tt.frame.Channel {
+				t.Errorf("expected %q on %q, got %q on %q", tt.frame.Type, tt.frame.Channel, frame.Type, frame.Channel)
+			}
+			if (frame.Error == nil) != (tt.frame.Error == nil) || frame.Error != nil && *frame.Error != *tt.frame.Error {
+				t.Errorf("expected the error %v, got %v", tt.frame.Error, frame.Error)
+			}
+		})
+	}
+}
+
+func TestDecodeOrderBookFrame(t *testing.T) {
+	level := func(price, size string) WSBookLevel {
+		return WSBookLevel{Price: decimal.MustParse(price), Size: decimal.MustParse(size)}
+	}
+	tests := []struct {
+		name    string
+		data    string
+		frame   WSOrderBookFrame
+		invalid bool
+	}{
+		{"snapshot", `{"channel":"order_book:12","order_book":{"code":0,"asks":[{"price":"3024.66","size":"0.1234"}],"bids":[{"price":"3024.65","size":"1"}],"offset":41},"timestamp":1760000000000,"type":"subscribed/order_book"}`,
+			WSOrderBookFrame{MarketId: 12, IsSnapshot: true, Offset: 41, Timestamp: 1760000000000,
+				Asks: []WSBookLevel{level("3024.66", "0.1234")}, Bids: []WSBookLevel{level("3024.65", "1")}}, false},
+		{"update", `{"type":"update/order_book","channel":"order_book/3","order_book":{"asks":[{"price":"10","size":"0"}],"offset":42}}`,
+			WSOrderBookFrame{MarketId: 3, Offset: 42, Asks: []WSBookLevel{level("10", "0")}}, false},
+		{"numbers", `{"channel":"order_book:1","order_book":{"bids":[{"price":10.5,"size":2e-1}],"offset":"43"},"timestamp":"-1"}`,
+			WSOrderBookFrame{MarketId: 1, Offset: 43, Timestamp: -1, Bids: []WSBookLevel{level("10.5", "0.2")}}, false},
+		{"escapes", `{"type":"subscribed\/order_book","channel":"order_book:7","order_book":{"asks":[{"price":"1","size":"2","note":"\"}]"}]}}`,
+			WSOrderBookFrame{MarketId: 7, IsSnapshot: true, Asks: []WSBookLevel{level("1", "2")}}, false},
+		{"null", `{"type":null,"timestamp":null,"order_book":{"asks":null,"bids":[null,{"price":null,"size":"1"}],"offset":null}}`,
+			WSOrderBookFrame{Bids: []WSBookLevel{{}, level("0", "1")}}, false},
+		{"null book", `{"order_book":null}`, WSOrderBookFrame{}, false},
+		{"empty strings", `{"order_book":{"bids":[{"price":"","size":""}]}}`, WSOrderBookFrame{Bids: []WSBookLevel{{}}}, false},
+		{"nested", `{"meta":{"a":[{"b":[]},"x"]},"order_book":{"extra":[[1,2],{"c":{}}],"asks":[{"price":"1","size":"1","more":{"d":[null]}}]}}`,
+			WSOrderBookFrame{Asks: []WSBookLevel{level("1", "1")}}, false},
+		// like encoding/json into a map, the last key wins
+		{"repeated keys", `{"timestamp":1,"timestamp":2,"order_book":{"asks":[{"price":"1","size":"1"}]},"order_book":{"bids":[{"price":"2","size":"2"}],"bids":[{"price":"3","size":"3"}]}}`,
+			WSOrderBookFrame{Timestamp: 2, Bids: []WSBookLevel{level("3", "3")}}, false},
+		{"invalid channel", `{"channel":"order_book:256"}`, WSOrderBookFrame{}, true},
+		{"invalid price", `{"order_book":{"asks":[{"price":"1x","size":"1"}]}}`, WSOrderBookFrame{}, true},
+		{"invalid offset", `{"order_book":{"offset":1.5}}`, WSOrderBookFrame{}, true},
+		{"truncated", `{"channel":"order_book:1","order_book":{"asks":[{"price":"1","size":"1"}`, WSOrderBookFrame{}, true},
+		{"truncated number", `{"timestamp":`, WSOrderBookFrame{}, true},
+		{"truncated level", `{"order_book":{"asks":[{"price":"1","si`, WSOrderBookFrame{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// the slices of a previous message are reused
+			f := WSOrderBookFrame{Bids: []WSBookLevel{level("9", "9")}, Asks: make([]WSBookLevel, 1, 8), Offset: 1}
+			err := DecodeOrderBookFrame([]byte(tt.data), &f)
+			if tt.invalid {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", f)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := diffOrderBookFrames(&f, &tt.frame); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func diffOrderBookFrames(f, expected *WSOrderBookFrame) error {
+	if f.MarketId != expected.MarketId || f.IsSnapshot != expected.IsSnapshot || f.Offset != expected.Offset || f.Timestamp != expected.Timestamp {
+		return fmt.Errorf("expected market %d, snapshot %v, offset %d at %d, got market %d, snapshot %v, offset %d at %d",
+			expected.MarketId, expected.IsSnapshot, expected.Offset, expected.Timestamp, f.MarketId, f.IsSnapshot, f.Offset, f.Timestamp)
+	}
+	for _, side := range []struct {
+		name      string
+		got, want []WSBookLevel
+	}{{"bids", f.Bids, expected.Bids}, {"asks", f.Asks, expected.Asks}} {
+		if len(side.got) != len(side.want) {
+			return fmt.Errorf("expected the %s %v, got %v", side.name, side.want, side.got)
+		}
+		for i := range side.got {
+			if side.got[i] != side.want[i] {
+				return fmt.Errorf("expected the %s %v, got %v", side.name, side.want, side.got)
+			}
+		}
+	}
+	return nil
+}
+
+// decodeOrderBookJSON decodes an order book message with encoding/json, keeping the keys as they are: unlike in
+// a struct they're case sensitive, and the last of repeated keys wins
+func decodeOrderBookJSON(data []byte) (*WSOrderBookFrame, string, error) {
+	var msg map[string]json.RawMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return nil, "", err
+	}
+	if msg == nil {
+		return nil, "", errors.New("null message")
+	}
+	var f WSOrderBookFrame
+	var msgType, channel string
+	if err := unmarshalIfSet(msg, "type", &msgType); err != nil {
+		return nil, "", err
+	}
+	f.IsSnapshot = msgType == MessageTypeOrderBookSubscribed
+	if err := unmarshalIfSet(msg, "channel", &channel); err != nil {
+		return nil, "", err
+	}
+	if err := unmarshalIfSet(msg, "timestamp", &f.Timestamp); err != nil {
+		return nil, "", err
+	}
+	var book map[string]json.RawMessage
+	if err := unmarshalIfSet(msg, "order_book", &book); err != nil {
+		return nil, "", err
+	}
+	if err := unmarshalIfSet(book, "offset", &f.Offset); err != nil {
+		return nil, "", err
+	}
+	for key, levels := range map[string]*[]WSBookLevel{"bids": &f.Bids, "asks": &f.Asks} {
+		var raw []map[string]json.RawMessage
+		if err := unmarshalIfSet(book, key, &raw); err != nil {
+			return nil, "", err
+		}
+		for _, r := range raw {
+			var level WSBookLevel
+			if err := unmarshalIfSet(r, "price", &level.Price); err != nil {
+				return nil, "", err
+			}
+			if err := unmarshalIfSet(r, "size", &level.Size); err != nil {
+				return nil, "", err
+			}
+			*levels = append(*levels, level)
+		}
+	}
+	return &f, channel, nil
+}
+
+// hasRepeatedKeys returns whether an object of a valid JSON value has a key twice
+func hasRepeatedKeys(data []byte) bool {
+	type container struct {
+		keys      map[string]bool
+		expectKey bool
+	}
+	var stack []*container
+	dec := json.NewDecoder(bytes.NewReader(data))
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return false
+		}
+		var top *container
+		if len(stack) > 0 {
+			top = stack[len(stack)-1]
+		}
+		if key, ok := tok.(string); ok && top != nil && top.expectKey {
+			if top.keys[key] {
+				return true
+			}
+			top.keys[key], top.expectKey = true, false
+			continue
+		}
+		switch tok {
+		case json.Delim('{'):
+			stack = append(stack, &container{keys: make(map[string]bool), expectKey: true})
+			continue
+		case json.Delim('['):
+			stack = append(stack, &container{})
+			continue
+		case json.Delim('}'), json.Delim(']'):
+			stack = stack[:len(stack)-1]
+		}
+		// a value ended, the object it belongs to expects the next key
+		if len(stack) > 0 && stack[len(stack)-1].keys != nil {
+			stack[len(stack)-1].expectKey = true
+		}
+	}
+}
+
+func unmarshalIfSet(m map[string]json.RawMessage, key string, v any) error {
+	raw, ok := m[key]
+	if !ok {
+		return nil
+	}
+	return json.Unmarshal(raw, v)
+}
+
+func FuzzDecodeOrderBookFrame(f *testing.F) {
+	f.Add(orderBookMessage(MessageTypeOrderBookSubscribed, 3))
+	f.Add(orderBookMessage(MessageTypeOrderBookUpdate, 1))
+	f.Add([]byte(`{"type":"subscribed\/order_book","channel":"order_book:7","order_book":{"asks":[null,{"price":1.5,"size":"2"}],"offset":null}}`))
+	f.Add([]byte(`{"timestamp":2,"order_book":{"bids":[{"price":"2","size":"2"}],"asks":null},"x":[{"y":"]}"}]}`))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var frame WSOrderBookFrame
+		err := DecodeOrderBookFrame(data, &frame)
+		expected, channel, jsonErr := decodeOrderBookJSON(data)
+		// the scanner doesn't validate what it skips, so it only has to agree with the messages encoding/json decodes.
+		// It also reads the values of the repeated keys encoding/json overwrites without decoding.
+		if jsonErr != nil || hasRepeatedKeys(data) {
+			return
+		}
+		if channel != "" {
+			market, channelErr := parseChannelMarket(channel)
+			if channelErr != nil {
+				if err == nil {
+					t.Fatalf("%s: expected an error for the channel %q", data, channel)
+				}
+				return
+			}
+			expected.MarketId = market
+		} else if err != nil && strings.Contains(err.Error(), "invalid channel") {
+			// an empty channel is invalid, unless it's absent or null
+			return
+		}
+		if err != nil {
+			t.Fatalf("%s: encoding/json decodes %+v, but got %v", data, expected, err)
+		}
+		if err := diffOrderBookFrames(&frame, expected); err != nil {
+			t.Fatalf("%s: %v", data, err)
+		}
+	})
+}
+
+// orderBookMessage returns an order book message of market 1 with n levels per side
+func orderBookMessage(msgType string, n int) []byte {
+	levels := func(start, step float64) string {
+		parts := make([]string, n)
+		for i := range parts {
+			parts[i] = fmt.Sprintf(`{"price":"%.2f","size":"%.4f"}`, start+float64(i)*step, 0.1234+float64(i))
+		}
+		return strings.Join(parts, ",")
+	}
+	return []byte(fmt.Sprintf(`{"channel":"order_book:1","offset":4021,"order_book":{"code":0,"asks":[%s],"bids":[%s],"offset":4021},"timestamp":1760000000000,"type":"%s"}`,
+		levels(3024.66, 0.01), levels(3024.65, -0.01), msgType))
+}
+
+// legacyDecode is the decoding before the frames: the error, the envelope & the order book were unmarshalled
+// one after the other, then the levels were copied
+func legacyDecode(data []byte) ([]PriceLevel, []PriceLevel) {
+	var errorMsg struct {
+		Error struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(data, &errorMsg); err != nil || errorMsg.Error.Code != 0 {
+		panic(err)
+	}
+	var msg WSMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		panic(err)
+	}
+	var book struct {
+		Type      string `json:"type"`
+		Channel   string `json:"channel"`
+		OrderBook struct {
+			Code   int            `json:"code"`
+			Asks   []WSPriceLevel `json:"asks"`
+			Bids   []WSPriceLevel `json:"bids"`
+			Offset int64          `json:"offset"`
+		} `json:"order_book"`
+		Timestamp int64 `json:"timestamp"`
+	}
+	if err := json.Unmarshal(data, &book); err != nil {
+		panic(err)
+	}
+	bids := make([]PriceLevel, 0, len(book.OrderBook.Bids))
+	for _, bid := range book.OrderBook.Bids {
+		bids = append(bids, PriceLevel{Price: bid.Price, Quantity: bid.Size})
+	}
+	asks := make([]PriceLevel, 0, len(book.OrderBook.Asks))
+	for _, ask := range book.OrderBook.Asks {
+		asks = append(asks, PriceLevel{Price: ask.Price, Quantity: ask.Size})
+	}
+	return bids, asks
+}
+
+// BenchmarkOrderBookDecoding compares the former triple json.Unmarshal, the peek of the type & channel, and the in
+// place decoding of PeekWSFrame + DecodeOrderBookFrame. Run it with `just bench-ws`.
+func BenchmarkOrderBookDecoding(b *testing.B) {
+	for _, c := range []struct {
+		name string
+		data []byte
+	}{
+		{"update/20", orderBookMessage(MessageTypeOrderBookUpdate, 20)},
+		{"snapshot/500", orderBookMessage(MessageTypeOrderBookSubscribed, 500)},
+	} {
+		var frame WSOrderBookFrame
+		if err := DecodeOrderBookFrame(c.data, &frame); err != nil {
+			b.Fatal(err)
+		}
+		bids, _ := legacyDecode(c.data)
+		if len(frame.Bids) != len(bids) || !frame.Bids[0].Price.Equal(bids[0].Price) || frame.MarketId != 1 {
+			b.Fatal("decodings differ")
+		}
+
+		b.Run(c.name+"/legacy", func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(c.data)))
+			for i := 0; i < b.N; i++ {
+				legacyDecode(c.data)
+			}
+		})
+		b.Run(c.name+"/peek", func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(c.data)))
+			for i := 0; i < b.N; i++ {
+				if _, err := PeekWSFrame(c.data); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+		b.Run(c.name+"/frame", func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(c.data)))
+			var f WSOrderBookFrame
+			for i := 0; i < b.N; i++ {
+				if _, err := PeekWSFrame(c.data); err != nil {
+					b.Fatal(err)
+				}
+				if err := DecodeOrderBookFrame(c.data, &f); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkDecimalParse(b *testing.B) {
+	b.Run("Parse", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			decimal.Parse("3024.6612")
+		}
+	})
+	price := []byte("3024.6612")
+	b.Run("ParseBytes", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			decimal.ParseBytes(price)
+		}
+	})
+}
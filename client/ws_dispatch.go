@@ -0,0 +1,234 @@
+package client
+
+import (
+	"fmt"
+	"sync"
+)
+
+// QueuePolicy is what a subscription does when the queue of its callback is full
+type QueuePolicy int
+
+const (
+	// QueueBlock waits for room in the queue, which stalls the connection until the callback catches up
+	QueueBlock QueuePolicy = iota
+	// QueueDropOldest drops the oldest pending message. Order book subscriptions refuse it with
+	// ErrOrderBookDropOldest, as their updates only make sense on top of each other: conflate them instead.
+	QueueDropOldest
+	// QueueConflate merges the pending order book messages into one. The subscriptions whose messages can't be
+	// merged, like trades & account updates, block instead.
+	QueueConflate
+)
+
+func (p QueuePolicy) String() string {
+	switch p {
+	case QueueBlock:
+		return "block"
+	case QueueDropOldest:
+		return "drop_oldest"
+	case QueueConflate:
+		return "conflate"
+	}
+	return fmt.Sprintf("QueuePolicy(%d)", int(p))
+}
+
+// DefaultQueueSize is the queue size of the subscriptions without one
+const DefaultQueueSize = 1024
+
+// DispatchConfig sets the queue between the connection & the callback of a subscription. Every subscription has its
+// own goroutine calling its callback, so a slow callback doesn't hold the others nor the connection.
+type DispatchConfig struct {
+	// QueueSize is the maximum number of pending messages, DefaultQueueSize if 0
+	QueueSize int
+	// Policy applies when the queue is full, QueueBlock by default
+	Policy QueuePolicy
+}
+
+// dispatchConfig returns the config of a subscription: its own if set, else the one of the connection
+func (c *WSConfig) dispatchConfig(override *DispatchConfig) DispatchConfig {
+	config := c.Dispatch
+	if override != nil {
+		config = *override
+	}
+	if config.QueueSize <= 0 {
+		config.QueueSize = DefaultQueueSize
+	}
+	return config
+}
+
+// callbackErrorHandler logs the errors returned by the callback of a subscription
+func (p *WSPool) callbackErrorHandler(subscription string) func(error) {
+	return func(err error) {
+		p.logger().Error("Callback error", LogKeyChannel, subscription, LogKeyError, err)
+	}
+}
+
+// dispatcher calls the callback of a subscription from its own goroutine, through a bounded queue
+type dispatcher[T any] struct {
+	name    string
+	config  DispatchConfig
+	deliver func(T) error
+	// merge merges src into dst and returns dst, nil if the messages can't be merged
+	merge func(dst, src T) T
+	// release is called once a message is delivered or dropped, nil if nothing has to be released
+	release func(T)
+	onError func(error)
+
+	mu     sync.Mutex
+	cond   *sync.Cond
+	queue  []T
+	head   int
+	count  int
+	closed bool
+}
+
+func newDispatcher[T any](name string, config DispatchConfig, deliver func(T) error, onError func(error)) *dispatcher[T] {
+	d := &dispatcher[T]{
+		name:    name,
+		config:  config,
+		deliver: deliver,
+		onError: onError,
+		queue:   make([]T, config.QueueSize),
+	}
+	d.cond = sync.NewCond(&d.mu)
+	return d
+}
+
+func (d *dispatcher[T]) start() *dispatcher[T] {
+	go d.run()
+	return d
+}
+
+// push queues a message, applying the policy when the queue is full
+func (d *dispatcher[T]) push(msg T) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.count == len(d.queue) && !d.closed {
+		metrics().WSQueueOverflow(d.name, d.config.Policy)
+	}
+	for d.count == len(d.queue) && !d.closed {
+		switch {
+		case d.config.Policy == QueueDropOldest:
+			d.doRelease(d.pop())
+		case d.config.Policy == QueueConflate && d.merge != nil:
+			merged := d.pop()
+			for d.count > 0 {
+				next := d.pop()
+				merged = d.merge(merged, next)
+				d.doRelease(next)
+			}
+			merged = d.merge(merged, msg)
+			d.doRelease(msg)
+			msg = merged
+		default:
+			d.cond.Wait()
+		}
+	}
+	if d.closed {
+		d.doRelease(msg)
+		return
+	}
+	d.queue[(d.head+d.count)%len(d.queue)] = msg
+	d.count++
+	metrics().WSQueueDepth(d.name, d.count)
+	d.cond.Broadcast()
+}
+
+// pop removes the oldest message. Must be called with d.mu held and a non empty queue.
+func (d *dispatcher[T]) pop() T {
+	var zero T
+	msg := d.queue[d.head]
+	d.queue[d.head] = zero
+	d.head = (d.head + 1) % len(d.queue)
+	d.count--
+	return msg
+}
+
+func (d *dispatcher[T]) doRelease(msg T) {
+	if d.release != nil {
+		d.release(msg)
+	}
+}
+
+func (d *dispatcher[T]) run() {
+	for {
+		d.mu.Lock()
+		for d.count == 0 && !d.closed {
+			d.cond.Wait()
+		}
+		if d.closed {
+			d.mu.Unlock()
+			return
+		}
+		msg := d.pop()
+		metrics().WSQueueDepth(d.name, d.count)
+		d.cond.Broadcast()
+		d.mu.Unlock()
+
+		if err := d.call(msg); err != nil && d.onError != nil {
+			d.onError(err)
+		}
+	}
+}
+
+func (d *dispatcher[T]) call(msg T) (err error) {
+	defer d.doRelease(msg)
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic in callback of %s: %v", d.name, r)
+		}
+	}()
+	return d.deliver(msg)
+}
+
+// close stops the goroutine and drops the pending messages
+func (d *dispatcher[T]) close() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.closed {
+		return
+	}
+	d.closed = true
+	for d.count > 0 {
+		d.doRelease(d.pop())
+	}
+	metrics().WSQueueDepth(d.name, 0)
+	d.cond.Broadcast()
+}
+
+// mergeOrderBookFrames merges the order book message src into dst: a snapshot replaces dst, the levels of an update
+// replace the ones of dst at the same price
+func mergeOrderBookFrames(dst, src *WSOrderBookFrame) *WSOrderBookFrame {
+	if src.IsSnapshot {
+		dst.IsSnapshot = true
+		dst.Bids = append(dst.Bids[:0], src.Bids...)
+		dst.Asks = append(dst.Asks[:0], src.Asks...)
+	} else {
+		dst.Bids = mergeBookLevels(dst.Bids, src.Bids, dst.IsSnapshot)
+		dst.Asks = mergeBookLevels(dst.Asks, src.Asks, dst.IsSnapshot)
+	}
+	dst.Offset = src.Offset
+	dst.Timestamp = src.Timestamp
+	return dst
+}
+
+// mergeBookLevels applies the levels of an update to levels. A snapshot has no empty level, so the levels removed by
+// the update are deleted from it.
+func mergeBookLevels(levels, update []WSBookLevel, isSnapshot bool) []WSBookLevel {
+	for _, u := range update {
+		i := 0
+		for i < len(levels) && !levels[i].Price.Equal(u.Price) {
+			i++
+		}
+		switch {
+		case i < len(levels) && isSnapshot && u.Size.IsZero():
+			levels = append(levels[:i], levels[i+1:]...)
+		case i < len(levels):
+			levels[i].Size = u.Size
+		case !isSnapshot || !u.Size.IsZero():
+			levels = append(levels, u)
+		}
+	}
+	return levels
+}
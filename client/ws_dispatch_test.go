@@ -0,0 +1,217 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/u20024804/lighter-ex/decimal"
+)
+
+// testDelivery records the delivered messages, the callback waits for release while it's held
+type testDelivery struct {
+	mu        sync.Mutex
+	delivered []int
+	released  []int
+	started   chan int
+	hold      chan struct{}
+}
+
+func newTestDelivery() *testDelivery {
+	return &testDelivery{started: make(chan int, 100), hold: make(chan struct{})}
+}
+
+func (d *testDelivery) deliver(msg int) error {
+	d.started <- msg
+	<-d.hold
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.delivered = append(d.delivered, msg)
+	return nil
+}
+
+func (d *testDelivery) release(msg int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.released = append(d.released, msg)
+}
+
+func (d *testDelivery) state() ([]int, []int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return slices.Clone(d.delivered), slices.Clone(d.released)
+}
+
+// waitFor polls cond for a second
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second); !cond(); {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestDispatcherPolicies(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy QueuePolicy
+		merge  func(dst, src int) int
+		// delivered with a queue of 2, while 1 is held in the callback and 2..5 are pushed: the queue is full at 4
+		delivered []int
+		released  []int
+	}{
+		{"block", QueueBlock, nil, []int{1, 2, 3, 4, 5}, []int{1, 2, 3, 4, 5}},
+		{"drop oldest", QueueDropOldest, nil, []int{1, 4, 5}, []int{2, 3, 1, 4, 5}},
+		{"conflate", QueueConflate, func(dst, src int) int { return dst*10 + src }, []int{1, 234, 5}, []int{3, 4, 1, 234, 5}},
+		// the messages which can't be merged block instead
+		{"conflate without merge", QueueConflate, nil, []int{1, 2, 3, 4, 5}, []int{1, 2, 3, 4, 5}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := newTestDelivery()
+			queue := newDispatcher("test", DispatchConfig{QueueSize: 2, Policy: tt.policy}, d.deliver, nil)
+			queue.merge = tt.merge
+			queue.release = d.release
+			queue.start()
+			defer queue.close()
+
+			queue.push(1)
+			<-d.started
+			pushed := make(chan struct{})
+			go func() {
+				defer close(pushed)
+				for i := 2; i <= 5; i++ {
+					queue.push(i)
+				}
+			}()
+			if tt.policy == QueueBlock || tt.merge == nil && tt.policy == QueueConflate {
+				select {
+				case <-pushed:
+					t.Fatal("expected the pushes to wait for the callback")
+				case <-time.After(20 * time.Millisecond):
+				}
+			} else {
+				<-pushed
+			}
+			close(d.hold)
+			<-pushed
+
+			waitFor(t, "the deliveries", func() bool {
+				delivered, _ := d.state()
+				return len(delivered) == len(tt.delivered)
+			})
+			delivered, released := d.state()
+			if !slices.Equal(delivered, tt.delivered) || !slices.Equal(released, tt.released) {
+				t.Errorf("expected the deliveries %v & releases %v, got %v & %v", tt.delivered, tt.released, delivered, released)
+			}
+		})
+	}
+}
+
+func TestDispatcherClose(t *testing.T) {
+	d := newTestDelivery()
+	queue := newDispatcher("test", DispatchConfig{QueueSize: 1}, d.deliver, nil)
+	queue.release = d.release
+	queue.start()
+
+	queue.push(1)
+	<-d.started
+	queue.push(2)
+	pushed := make(chan struct{})
+	go func() {
+		queue.push(3)
+		close(pushed)
+	}()
+
+	// the pending message is released and the blocked push returns
+	queue.close()
+	<-pushed
+	queue.push(4)
+	close(d.hold)
+	waitFor(t, "the callback", func() bool {
+		_, released := d.state()
+		return len(released) == 4
+	})
+	delivered, released := d.state()
+	slices.Sort(released)
+	if !slices.Equal(delivered, []int{1}) || !slices.Equal(released, []int{1, 2, 3, 4}) {
+		t.Errorf("expected 1 delivered and everything released, got %v & %v", delivered, released)
+	}
+}
+
+func TestDispatcherCallbackErrors(t *testing.T) {
+	errs := make(chan error, 2)
+	queue := newDispatcher("test", DispatchConfig{QueueSize: 2}, func(msg int) error {
+		if msg == 1 {
+			return errors.New("failed")
+		}
+		panic("boom")
+	}, func(err error) { errs <- err }).start()
+	defer queue.close()
+
+	queue.push(1)
+	queue.push(2)
+	if err := <-errs; err.Error() != "failed" {
+		t.Errorf("expected the error of the callback, got %v", err)
+	}
+	if err := <-errs; !strings.Contains(err.Error(), "panic in callback of test: boom") {
+		t.Errorf("expected the panic of the callback, got %v", err)
+	}
+}
+
+func TestMergeOrderBookFrames(t *testing.T) {
+	level := func(price, size string) WSBookLevel {
+		return WSBookLevel{Price: decimal.MustParse(price), Size: decimal.MustParse(size)}
+	}
+	tests := []struct {
+		name     string
+		dst, src WSOrderBookFrame
+		merged   WSOrderBookFrame
+	}{
+		{"updates",
+			WSOrderBookFrame{Offset: 1, Bids: []WSBookLevel{level("10", "1"), level("9", "0")}},
+			WSOrderBookFrame{Offset: 2, Timestamp: 5, Bids: []WSBookLevel{level("10", "0"), level("8", "2")}, Asks: []WSBookLevel{level("11", "1")}},
+			WSOrderBookFrame{Offset: 2, Timestamp: 5, Bids: []WSBookLevel{level("10", "0"), level("9", "0"), level("8", "2")}, Asks: []WSBookLevel{level("11", "1")}}},
+		// the levels removed from a snapshot are deleted
+		{"update of a snapshot",
+			WSOrderBookFrame{IsSnapshot: true, Offset: 1, Bids: []WSBookLevel{level("10", "1"), level("9", "1")}},
+			WSOrderBookFrame{Offset: 2, Bids: []WSBookLevel{level("10", "0"), level("9", "3"), level("7", "0")}},
+			WSOrderBookFrame{IsSnapshot: true, Offset: 2, Bids: []WSBookLevel{level("9", "3")}}},
+		{"snapshot",
+			WSOrderBookFrame{Offset: 1, Bids: []WSBookLevel{level("10", "1")}, Asks: []WSBookLevel{level("11", "1")}},
+			WSOrderBookFrame{IsSnapshot: true, Offset: 3, Bids: []WSBookLevel{level("9", "1")}},
+			WSOrderBookFrame{IsSnapshot: true, Offset: 3, Bids: []WSBookLevel{level("9", "1")}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			merged := mergeOrderBookFrames(&tt.dst, &tt.src)
+			if err := diffOrderBookFrames(merged, &tt.merged); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestOrderBookRefusesDropOldest(t *testing.T) {
+	config := DefaultWSConfig()
+	config.Dispatch = DispatchConfig{Policy: QueueDropOldest}
+	s := NewLighterWebsocketPublicService(config)
+	s.ctx = context.Background()
+
+	// the policy of the connection applies, unless the subscription sets its own
+	_, err := s.SubscribeOrderBookFrames(LighterOrderBookParamKey{MarketId: 1}, func(*WSOrderBookFrame) error { return nil })
+	if !errors.Is(err, ErrOrderBookDropOldest) {
+		t.Errorf("expected ErrOrderBookDropOldest, got %v", err)
+	}
+	config.Dispatch = DispatchConfig{}
+	_, err = s.OrderBookStream(1, DispatchConfig{Policy: QueueDropOldest})
+	if !errors.Is(err, ErrOrderBookDropOldest) {
+		t.Errorf("expected ErrOrderBookDropOldest, got %v", err)
+	}
+}
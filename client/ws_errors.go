@@ -0,0 +1,65 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	// ErrNotConnected is returned when sending on a WebSocket which isn't connected
+	ErrNotConnected = errors.New("WebSocket not connected")
+	// ErrSubscribeTimeout is returned when a subscription isn't confirmed within WSConfig.SubscribeTimeout
+	ErrSubscribeTimeout = errors.New("subscription not confirmed in time")
+	// ErrInvalidChannel is matched by the errors of Lighter rejecting an unknown or malformed channel
+	ErrInvalidChannel = errors.New("invalid channel")
+	// ErrAuthFailed is matched by the errors of Lighter rejecting the auth token of a private channel
+	ErrAuthFailed = errors.New("authentication failed")
+	// ErrSubscriptionLimit is matched by the errors of Lighter refusing more subscriptions on a connection
+	ErrSubscriptionLimit = errors.New("subscription limit reached")
+	// ErrPoolFull is returned when no connection of a WSPool has room for a subscription
+	ErrPoolFull = errors.New("WebSocket pool full")
+	// ErrOrderBookDropOldest is returned when an order book subscription is queued with QueueDropOldest: a dropped
+	// update would leave the book wrong until the next snapshot
+	ErrOrderBookDropOldest = errors.New("order book updates can't be dropped, use QueueConflate or QueueBlock")
+)
+
+// WSError is an error message sent by Lighter. Use errors.Is with ErrInvalidChannel, ErrAuthFailed or
+// ErrSubscriptionLimit to tell the usual causes apart.
+type WSError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *WSError) Error() string {
+	return fmt.Sprintf("websocket error %d: %s", e.Code, e.Message)
+}
+
+// Is matches the sentinel errors from the message, the codes aren't documented by Lighter
+func (e *WSError) Is(target error) bool {
+	msg := strings.ToLower(e.Message)
+	switch target {
+	case ErrInvalidChannel:
+		return strings.Contains(msg, "channel")
+	case ErrAuthFailed:
+		return strings.Contains(msg, "auth") || strings.Contains(msg, "token")
+	case ErrSubscriptionLimit:
+		return strings.Contains(msg, "limit") || strings.Contains(msg, "too many")
+	}
+	return false
+}
+
+// SubscribeError is returned by WSClient.Subscribe when a subscription is rejected or not confirmed.
+// Err is a *WSError sent by Lighter, ErrSubscribeTimeout or ErrNotConnected.
+type SubscribeError struct {
+	Channel string
+	Err     error
+}
+
+func (e *SubscribeError) Error() string {
+	return fmt.Sprintf("failed to subscribe to %s: %v", e.Channel, e.Err)
+}
+
+func (e *SubscribeError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,594 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultWSPoolConnections is the maximum number of connections of a pool without one
+const DefaultWSPoolConnections = 4
+
+// WSPoolConfig sets how a WSPool spreads its subscriptions
+type WSPoolConfig struct {
+	// MaxConnections is the maximum number of connections, DefaultWSPoolConnections if 0
+	MaxConnections int
+	// MaxSubscriptionsPerConnection is the maximum number of channels of a connection, no limit if 0
+	MaxSubscriptionsPerConnection int
+	// Reconnect subscribes the channels of a lost connection again, on the least loaded connections. Each channel is
+	// retried up to WSConfig.MaxReconnects times, WSConfig.ReconnectDelay apart.
+	// When the lost connection couldn't be replaced right away, its channels pile up on the others: once a replacement
+	// is opened, subscriptions are moved to it from the most loaded connections until the loads differ by one at most.
+	// A moved subscription is subscribed again, it starts over with a snapshot like a restored one.
+	Reconnect bool
+}
+
+// WSPool spreads the subscriptions of one or more services over several connections: a subscription goes to the
+// least loaded connection with room, and a new connection is opened while the pool has fewer than MaxConnections.
+// A channel is subscribed at most once per connection, so the same channel of two services goes to two connections.
+//
+// Share a pool between services with NewLighterWebsocketPublicServiceWithPool, NewLighterWebsocketPrivateServiceWithPool
+// or LighterWebsocketClient.SetPool. The private channels are authenticated by the token of their subscription, so
+// several accounts can share the connections.
+type WSPool struct {
+	config     *WSConfig
+	poolConfig WSPoolConfig
+	// authToken generates the token sent when connecting, for the private service owning its pool
+	authToken TokenGenerator
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu sync.Mutex
+	// dialed is signaled when a dial ends or the pool closes
+	dialed *sync.Cond
+	conns  []*poolConn
+	owners map[poolOwner]struct{}
+	// dialing is the number of connections being opened, they count against MaxConnections
+	dialing int
+	// lost is the number of lost connections not replaced yet, the next dials are reported as reconnects
+	lost        int
+	rebalancing bool
+	closed      bool
+}
+
+// poolConn is a connection of a pool with its subscriptions
+type poolConn struct {
+	ws   *WSClient
+	subs map[*poolSubscription]struct{}
+	// full is set when Lighter refuses more subscriptions on the connection
+	full bool
+}
+
+// carries tells whether the connection has a subscription of the channel
+func (c *poolConn) carries(channel string) bool {
+	for sub := range c.subs {
+		if sub.channel == channel {
+			return true
+		}
+	}
+	return false
+}
+
+// poolSubscription is a channel subscribed through a pool, with the handlers of its messages
+type poolSubscription struct {
+	channel string
+	// auth generates the token of a private channel, nil for the public ones
+	auth TokenGenerator
+	// handlers are the handlers of the channel by message type, they must filter on the channel of the frames
+	handlers map[string]WSFrameHandler
+	owner    poolOwner
+
+	// guarded by the mutex of the pool
+	conn       *poolConn
+	handlerIds map[string]uint64
+	cancelled  bool
+	// pending is set while the subscription waits for its confirmation, it's not moved meanwhile
+	pending bool
+}
+
+// poolOwner is a service with subscriptions in a pool
+type poolOwner interface {
+	// connectionLost is called when a connection carrying subscriptions of the owner is lost, with ErrConnectionLost
+	// or an error wrapping it. restoring tells whether the pool subscribes them again.
+	connectionLost(err error, restoring bool)
+	// serverError gets the errors sent by Lighter outside of a subscription
+	serverError(err error)
+}
+
+// NewWSPool creates a pool of connections to config.URL. The connections are opened on demand, close the pool once
+// the services using it are closed.
+func NewWSPool(config *WSConfig, poolConfig WSPoolConfig) *WSPool {
+	if config == nil {
+		config = DefaultWSConfig()
+	}
+	if poolConfig.MaxConnections <= 0 {
+		poolConfig.MaxConnections = DefaultWSPoolConnections
+	}
+	// a replay feeds every frame to every connection
+	if config.Replay != nil {
+		poolConfig.MaxConnections = 1
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &WSPool{
+		config:     config,
+		poolConfig: poolConfig,
+		ctx:        ctx,
+		cancel:     cancel,
+		owners:     make(map[poolOwner]struct{}),
+	}
+	p.dialed = sync.NewCond(&p.mu)
+	return p
+}
+
+// newServicePool returns the pool of a service which doesn't share one: a single connection, as before the pools
+func newServicePool(config *WSConfig, authToken TokenGenerator) *WSPool {
+	p := NewWSPool(config, WSPoolConfig{MaxConnections: 1})
+	p.authToken = authToken
+	return p
+}
+
+func (p *WSPool) logger() *slog.Logger {
+	return loggerOr(p.config.Logger)
+}
+
+// Load returns the number of subscriptions of every connection of the pool
+func (p *WSPool) Load() []int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	load := make([]int, len(p.conns))
+	for i, c := range p.conns {
+		load[i] = len(c.subs)
+	}
+	return load
+}
+
+// Close closes the connections of the pool, close the services using it first
+func (p *WSPool) Close() error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil
+	}
+	p.closed = true
+	conns := p.conns
+	p.conns = nil
+	p.dialed.Broadcast()
+	p.mu.Unlock()
+
+	p.cancel()
+	var errs []error
+	for _, c := range conns {
+		if err := c.ws.Disconnect(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	p.logger().Info("WebSocket pool closed", "connections", len(conns))
+	return errors.Join(errs...)
+}
+
+// register adds a service to the pool and makes sure the pool has a connection, or is opening one
+func (p *WSPool) register(owner poolOwner) error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return ErrNotConnected
+	}
+	p.owners[owner] = struct{}{}
+	if len(p.conns) > 0 || p.dialing > 0 {
+		p.mu.Unlock()
+		return nil
+	}
+	p.mu.Unlock()
+
+	_, err := p.dial()
+	// another registration took the last connection to open
+	if errors.Is(err, ErrPoolFull) {
+		return nil
+	}
+	return err
+}
+
+func (p *WSPool) unregister(owner poolOwner) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.owners, owner)
+}
+
+// dial opens a new connection and adds it to the pool. Must be called without p.mu held: the slot of the connection
+// is reserved while it connects, ErrPoolFull is returned when the pool has no slot left.
+func (p *WSPool) dial() (*poolConn, error) {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil, ErrNotConnected
+	}
+	if len(p.conns)+p.dialing >= p.poolConfig.MaxConnections {
+		p.mu.Unlock()
+		return nil, ErrPoolFull
+	}
+	p.dialing++
+	p.mu.Unlock()
+
+	ws := NewWSClient(p.config)
+	if token := generateToken(p.authToken); token != "" {
+		ws.SetAuthToken(token)
+	}
+	c := &poolConn{ws: ws, subs: make(map[*poolSubscription]struct{})}
+	ws.SetOnError(func(err error) { p.serverError(c, err) })
+	ws.SetOnDisconnected(func() { p.connectionLost(c) })
+	err := ws.Connect(p.ctx)
+
+	p.mu.Lock()
+	p.dialing--
+	p.dialed.Broadcast()
+	if err == nil && p.closed {
+		err = ErrNotConnected
+	} else if err == nil && !ws.IsConnected() {
+		// lost before it was added, connectionLost ignored it
+		err = ErrConnectionLost
+	}
+	if err != nil {
+		p.mu.Unlock()
+		ws.Disconnect()
+		return nil, err
+	}
+	replacement := p.lost > 0
+	if replacement {
+		p.lost--
+		metrics().WSReconnect(p.config.URL)
+	}
+	p.conns = append(p.conns, c)
+	conns := len(p.conns)
+	p.mu.Unlock()
+
+	p.logger().Debug("WebSocket pool connection opened", "connections", conns)
+	if replacement && p.poolConfig.Reconnect {
+		go p.rebalance()
+	}
+	return c, nil
+}
+
+// pick returns the connection of a new subscription, the least loaded one with room. dial is set when a new
+// connection should be opened instead: the pool has room for one and every connection has subscriptions.
+// Must be called with p.mu held.
+func (p *WSPool) pick(channel string, canDial bool) (best *poolConn, dial bool, err error) {
+	for _, c := range p.conns {
+		if c.full || c.carries(channel) || !c.ws.IsConnected() {
+			continue
+		}
+		if limit := p.poolConfig.MaxSubscriptionsPerConnection; limit > 0 && len(c.subs) >= limit {
+			continue
+		}
+		if best == nil || len(c.subs) < len(best.subs) {
+			best = c
+		}
+	}
+	if canDial && len(p.conns)+p.dialing < p.poolConfig.MaxConnections && (best == nil || len(best.subs) > 0) {
+		return best, true, nil
+	}
+	if best == nil {
+		return nil, false, ErrPoolFull
+	}
+	return best, false, nil
+}
+
+// subscribe places a subscription on a connection, adds its handlers and waits for its confirmation
+func (p *WSPool) subscribe(sub *poolSubscription) error {
+	canDial := true
+	for {
+		p.mu.Lock()
+		if p.closed || sub.cancelled {
+			p.mu.Unlock()
+			return &SubscribeError{Channel: sub.channel, Err: ErrNotConnected}
+		}
+		c, dial, err := p.pick(sub.channel, canDial)
+		if errors.Is(err, ErrPoolFull) && p.dialing > 0 {
+			// the connection being opened may have room
+			p.dialed.Wait()
+			p.mu.Unlock()
+			continue
+		}
+		if err != nil {
+			p.mu.Unlock()
+			return &SubscribeError{Channel: sub.channel, Err: err}
+		}
+		if dial {
+			p.mu.Unlock()
+			// the new connection is picked on the next turn, unless others filled it meanwhile
+			if _, err := p.dial(); err != nil {
+				if c == nil && !errors.Is(err, ErrPoolFull) {
+					return &SubscribeError{Channel: sub.channel, Err: err}
+				}
+				if !errors.Is(err, ErrPoolFull) {
+					p.logger().Warn("Failed to open a WebSocket pool connection", LogKeyError, err)
+				}
+				canDial = false
+			}
+			continue
+		}
+		p.attach(c, sub)
+		p.mu.Unlock()
+
+		// a new token for every attempt, the one of the first subscription may have expired since
+		err = c.ws.subscribe(sub.channel, "", generateToken(sub.auth))
+
+		p.mu.Lock()
+		sub.pending = false
+		if err == nil {
+			p.mu.Unlock()
+			return nil
+		}
+		if sub.conn == c {
+			p.detach(sub)
+		}
+		// Lighter refuses more channels on the connection, try another one
+		full := errors.Is(err, ErrSubscriptionLimit)
+		if full {
+			c.full = true
+		}
+		p.mu.Unlock()
+		if !full {
+			return err
+		}
+		p.logger().Warn("WebSocket pool connection full", LogKeyChannel, sub.channel, "subscriptions", len(c.subs))
+	}
+}
+
+// unsubscribe removes a subscription from its connection, it isn't restored anymore
+func (p *WSPool) unsubscribe(sub *poolSubscription) error {
+	p.mu.Lock()
+	sub.cancelled = true
+	c := sub.conn
+	p.detach(sub)
+	p.mu.Unlock()
+
+	if c == nil {
+		return nil
+	}
+	return c.ws.Unsubscribe(sub.channel, "")
+}
+
+// attached tells whether a subscription is on a connection, it isn't while it's restored or once it's lost
+func (p *WSPool) attached(sub *poolSubscription) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return sub.conn != nil
+}
+
+// attach adds a subscription & its handlers to a connection, pending until the caller subscribed it.
+// Must be called with p.mu held.
+func (p *WSPool) attach(c *poolConn, sub *poolSubscription) {
+	sub.conn = c
+	sub.pending = true
+	c.subs[sub] = struct{}{}
+	sub.handlerIds = make(map[string]uint64, len(sub.handlers))
+	for msgType, handler := range sub.handlers {
+		sub.handlerIds[msgType] = c.ws.addFrameHandler(msgType, handler)
+	}
+}
+
+// detach removes a subscription & its handlers from its connection. Must be called with p.mu held.
+func (p *WSPool) detach(sub *poolSubscription) {
+	c := sub.conn
+	if c == nil {
+		return
+	}
+	for msgType, id := range sub.handlerIds {
+		c.ws.removeFrameHandler(msgType, id)
+	}
+	delete(c.subs, sub)
+	sub.conn = nil
+	sub.handlerIds = nil
+}
+
+// connectionLost removes a lost connection from the pool and notifies the owners of its subscriptions, which are
+// restored on the other connections if the pool reconnects
+func (p *WSPool) connectionLost(c *poolConn) {
+	p.mu.Lock()
+	// the connections lost while they're opened aren't in the pool yet, dial drops them
+	if p.closed || !slices.Contains(p.conns, c) {
+		p.mu.Unlock()
+		return
+	}
+	p.conns = slices.DeleteFunc(p.conns, func(conn *poolConn) bool { return conn == c })
+	p.lost++
+	subs := make([]*poolSubscription, 0, len(c.subs))
+	owners := make(map[poolOwner]struct{})
+	for sub := range c.subs {
+		subs = append(subs, sub)
+		owners[sub.owner] = struct{}{}
+		p.detach(sub)
+	}
+	// the services without subscriptions lost their connection too when it was the last one
+	if len(p.conns) == 0 {
+		for owner := range p.owners {
+			owners[owner] = struct{}{}
+		}
+	}
+	restoring := p.poolConfig.Reconnect && len(subs) > 0
+	p.mu.Unlock()
+
+	p.logger().Warn("WebSocket pool connection lost", "subscriptions", len(subs), "restoring", restoring)
+	for owner := range owners {
+		owner.connectionLost(ErrConnectionLost, restoring)
+	}
+	if restoring {
+		go p.restore(subs)
+	}
+}
+
+// restore subscribes the subscriptions of a lost connection again, on the least loaded connections
+func (p *WSPool) restore(subs []*poolSubscription) {
+	sort.Slice(subs, func(i, j int) bool { return subs[i].channel < subs[j].channel })
+	attempts := max(p.config.MaxReconnects, 1)
+	var lastErr error
+	for attempt := 0; attempt < attempts && len(subs) > 0; attempt++ {
+		select {
+		case <-p.ctx.Done():
+			return
+		case <-time.After(p.config.ReconnectDelay):
+		}
+
+		var failed []*poolSubscription
+		for _, sub := range subs {
+			err := p.subscribe(sub)
+			if err == nil {
+				p.logger().Info("WebSocket subscription restored", LogKeyChannel, sub.channel)
+				continue
+			}
+			p.mu.Lock()
+			cancelled := sub.cancelled || p.closed
+			p.mu.Unlock()
+			if !cancelled {
+				failed = append(failed, sub)
+				lastErr = err
+			}
+		}
+		subs = failed
+	}
+	if len(subs) == 0 {
+		return
+	}
+
+	p.logger().Error("Failed to restore WebSocket subscriptions", "subscriptions", len(subs), LogKeyError, lastErr)
+	owners := make(map[poolOwner]struct{})
+	for _, sub := range subs {
+		owners[sub.owner] = struct{}{}
+	}
+	for owner := range owners {
+		owner.connectionLost(fmt.Errorf("%w: %w", ErrConnectionLost, lastErr), false)
+	}
+}
+
+// rebalance moves subscriptions from the most loaded connections to the least loaded ones, one at a time, until
+// their loads differ by one at most. It runs once a lost connection is replaced, and stops at the first failure.
+func (p *WSPool) rebalance() {
+	p.mu.Lock()
+	if p.rebalancing {
+		p.mu.Unlock()
+		return
+	}
+	p.rebalancing = true
+	p.mu.Unlock()
+	defer func() {
+		p.mu.Lock()
+		p.rebalancing = false
+		p.mu.Unlock()
+	}()
+
+	for {
+		p.mu.Lock()
+		if p.closed {
+			p.mu.Unlock()
+			return
+		}
+		sub, from, to := p.nextMove()
+		if sub == nil {
+			p.mu.Unlock()
+			return
+		}
+		// the handlers move at once, the messages still received on from are dropped
+		p.detach(sub)
+		p.attach(to, sub)
+		p.mu.Unlock()
+
+		// from may be lost meanwhile, the subscription is on to anyway
+		from.ws.Unsubscribe(sub.channel, "")
+		err := to.ws.subscribe(sub.channel, "", generateToken(sub.auth))
+
+		p.mu.Lock()
+		sub.pending = false
+		if err == nil {
+			cancelled := sub.cancelled
+			p.mu.Unlock()
+			if cancelled {
+				// unsubscribed while it moved, unsubscribe didn't reach Lighter before the subscription
+				to.ws.Unsubscribe(sub.channel, "")
+			}
+			p.logger().Debug("WebSocket subscription moved", LogKeyChannel, sub.channel)
+			continue
+		}
+		if sub.conn == to {
+			p.detach(sub)
+		}
+		if errors.Is(err, ErrSubscriptionLimit) {
+			to.full = true
+		}
+		cancelled := sub.cancelled
+		p.mu.Unlock()
+		if cancelled {
+			return
+		}
+
+		p.logger().Warn("Failed to move WebSocket subscription", LogKeyChannel, sub.channel, LogKeyError, err)
+		// placed again like a new subscription, its owner is told when that fails too
+		if err := p.subscribe(sub); err != nil {
+			p.logger().Error("Failed to restore moved WebSocket subscription", LogKeyChannel, sub.channel, LogKeyError, err)
+			sub.owner.connectionLost(fmt.Errorf("%w: %w", ErrConnectionLost, err), false)
+		}
+		return
+	}
+}
+
+// nextMove returns a subscription of the most loaded connection to move to the least loaded one with room, nil when
+// their loads differ by one at most. Must be called with p.mu held.
+func (p *WSPool) nextMove() (sub *poolSubscription, from, to *poolConn) {
+	limit := p.poolConfig.MaxSubscriptionsPerConnection
+	for _, c := range p.conns {
+		if !c.ws.IsConnected() {
+			continue
+		}
+		if from == nil || len(c.subs) > len(from.subs) {
+			from = c
+		}
+		if c.full || (limit > 0 && len(c.subs) >= limit) {
+			continue
+		}
+		if to == nil || len(c.subs) < len(to.subs) {
+			to = c
+		}
+	}
+	if from == nil || to == nil || len(from.subs)-len(to.subs) <= 1 {
+		return nil, nil, nil
+	}
+	for candidate := range from.subs {
+		if candidate.pending || to.carries(candidate.channel) {
+			continue
+		}
+		// the first channel, so that the moves don't depend on the map order
+		if sub == nil || candidate.channel < sub.channel {
+			sub = candidate
+		}
+	}
+	if sub == nil {
+		return nil, nil, nil
+	}
+	return sub, from, to
+}
+
+// serverError passes an error sent by Lighter to the owners of the subscriptions of its connection
+func (p *WSPool) serverError(c *poolConn, err error) {
+	p.mu.Lock()
+	owners := make(map[poolOwner]struct{})
+	for sub := range c.subs {
+		owners[sub.owner] = struct{}{}
+	}
+	if len(owners) == 0 {
+		for owner := range p.owners {
+			owners[owner] = struct{}{}
+		}
+	}
+	p.mu.Unlock()
+
+	for owner := range owners {
+		owner.serverError(err)
+	}
+}
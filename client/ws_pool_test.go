@@ -0,0 +1,324 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// testWSServer accepts WebSocket connections once release is closed, confirms their subscriptions and records them
+type testWSServer struct {
+	config *WSConfig
+
+	mu         sync.Mutex
+	subscribes []WSSubscribeMessage
+	// headers are the Authorization headers of the connections
+	headers []string
+	conns   []*websocket.Conn
+	// respond, if set, returns the frames replied to a subscription instead of its confirmation
+	respond func(msg WSSubscribeMessage) []string
+	// refuse makes the new connections fail
+	refuse atomic.Bool
+}
+
+func newTestWSServer(t *testing.T, release chan struct{}) *testWSServer {
+	t.Helper()
+	s := &testWSServer{}
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		if s.refuse.Load() {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		s.mu.Lock()
+		s.headers = append(s.headers, r.Header.Get("Authorization"))
+		s.conns = append(s.conns, conn)
+		s.mu.Unlock()
+		for {
+			var msg WSSubscribeMessage
+			if err := conn.ReadJSON(&msg); err != nil {
+				return
+			}
+			if msg.Type != MessageTypeSubscribe {
+				continue
+			}
+			// the writes are under the lock, with the ones of send
+			s.mu.Lock()
+			s.subscribes = append(s.subscribes, msg)
+			if s.respond == nil {
+				conn.WriteJSON(map[string]string{"type": MessageTypeSubscribed, "channel": msg.Channel})
+			} else {
+				for _, frame := range s.respond(msg) {
+					conn.WriteMessage(websocket.TextMessage, []byte(frame))
+				}
+			}
+			s.mu.Unlock()
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	s.config = DefaultWSConfig()
+	s.config.URL = "ws" + strings.TrimPrefix(server.URL, "http")
+	s.config.Logger = DiscardLogger()
+	return s
+}
+
+// drop closes the connections
+func (s *testWSServer) drop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, conn := range s.conns {
+		conn.Close()
+	}
+	s.conns = nil
+}
+
+// dropFirst closes the oldest connection
+func (s *testWSServer) dropFirst() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.conns[0].Close()
+	s.conns = s.conns[1:]
+}
+
+// send writes frames to the connections
+func (s *testWSServer) send(t *testing.T, frames ...string) {
+	t.Helper()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, conn := range s.conns {
+		for _, frame := range frames {
+			if err := conn.WriteMessage(websocket.TextMessage, []byte(frame)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+}
+
+func (s *testWSServer) subscribed() []WSSubscribeMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.subscribes)
+}
+
+func TestWSPoolDialsOutsideTheLock(t *testing.T) {
+	release := make(chan struct{})
+	p := NewWSPool(newTestWSServer(t, release).config, WSPoolConfig{MaxConnections: 2})
+	defer p.Close()
+
+	const dials = 5
+	var wg sync.WaitGroup
+	errs := make(chan error, dials)
+	for i := 0; i < dials; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := p.dial()
+			errs <- err
+		}()
+	}
+
+	// the pool isn't locked while the connections are opened, and only MaxConnections are opened
+	loaded := make(chan []int)
+	go func() { loaded <- p.Load() }()
+	select {
+	case load := <-loaded:
+		if len(load) != 0 {
+			t.Errorf("expected no connection yet, got %v", load)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the pool is locked while dialing")
+	}
+	close(release)
+	wg.Wait()
+	close(errs)
+
+	var full int
+	for err := range errs {
+		switch {
+		case errors.Is(err, ErrPoolFull):
+			full++
+		case err != nil:
+			t.Errorf("unexpected error %v", err)
+		}
+	}
+	if load := p.Load(); len(load) != 2 || full != dials-2 {
+		t.Errorf("expected 2 connections & %d dials refused, got %v & %d", dials-2, load, full)
+	}
+}
+
+func TestWSPoolClosedWhileDialing(t *testing.T) {
+	release := make(chan struct{})
+	p := NewWSPool(newTestWSServer(t, release).config, WSPoolConfig{MaxConnections: 2})
+
+	dialed := make(chan error)
+	go func() {
+		_, err := p.dial()
+		dialed <- err
+	}()
+	// the dial has its slot
+	waitFor(t, "the dial", func() bool {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		return p.dialing == 1
+	})
+	p.Close()
+	close(release)
+	if err := <-dialed; err == nil {
+		t.Fatal("expected the dial to fail once the pool is closed")
+	}
+	if load := p.Load(); len(load) != 0 {
+		t.Errorf("expected no connection in a closed pool, got %v", load)
+	}
+}
+
+func TestWSPoolSubscribeWaitsForDial(t *testing.T) {
+	release := make(chan struct{})
+	server := newTestWSServer(t, release)
+	p := NewWSPool(server.config, WSPoolConfig{MaxConnections: 1})
+	defer p.Close()
+
+	registered := make(chan error)
+	go func() { registered <- p.register(nil) }()
+	waitFor(t, "the dial", func() bool {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		return p.dialing == 1
+	})
+
+	// the pool has no connection nor room for one, but the one being opened takes the subscription
+	subscribed := make(chan error)
+	go func() { subscribed <- p.subscribe(&poolSubscription{channel: "order_book/1"}) }()
+	close(release)
+	if err := <-registered; err != nil {
+		t.Fatal(err)
+	}
+	if err := <-subscribed; err != nil {
+		t.Fatal(err)
+	}
+	if load := p.Load(); !slices.Equal(load, []int{1}) {
+		t.Errorf("expected a connection with the subscription, got %v", load)
+	}
+	if subs := server.subscribed(); len(subs) != 1 || subs[0].Channel != "order_book/1" {
+		t.Errorf("expected the order book to be subscribed, got %v", subs)
+	}
+}
+
+// testPoolOwner counts the lost connections
+type testPoolOwner struct {
+	lost atomic.Int32
+}
+
+func (o *testPoolOwner) connectionLost(error, bool) { o.lost.Add(1) }
+func (o *testPoolOwner) serverError(error)          {}
+
+func TestWSPoolGeneratesTokens(t *testing.T) {
+	release := make(chan struct{})
+	close(release)
+	server := newTestWSServer(t, release)
+	server.config.ReconnectDelay = time.Millisecond
+	var tokens atomic.Int32
+	tokenGen := func() string { return fmt.Sprintf("token-%d", tokens.Add(1)) }
+
+	p := newServicePool(server.config, tokenGen)
+	p.poolConfig.Reconnect = true
+	defer p.Close()
+	owner := &testPoolOwner{}
+	if err := p.register(owner); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.subscribe(&poolSubscription{channel: "account_all/7", auth: tokenGen, owner: owner}); err != nil {
+		t.Fatal(err)
+	}
+
+	// the restore dials & subscribes with new tokens
+	server.drop()
+	waitFor(t, "the restore", func() bool { return len(server.subscribed()) == 2 })
+	if owner.lost.Load() != 1 {
+		t.Errorf("expected the owner to be told once about the lost connection, got %d", owner.lost.Load())
+	}
+	server.mu.Lock()
+	headers := slices.Clone(server.headers)
+	server.mu.Unlock()
+	var auths []string
+	for _, msg := range server.subscribed() {
+		auths = append(auths, msg.Auth)
+	}
+	if !slices.Equal(headers, []string{"Bearer token-1", "Bearer token-3"}) || !slices.Equal(auths, []string{"token-2", "token-4"}) {
+		t.Errorf("expected a new token per dial & subscription, got the headers %q & the auths %q", headers, auths)
+	}
+}
+
+func TestWSPoolRebalances(t *testing.T) {
+	release := make(chan struct{})
+	close(release)
+	server := newTestWSServer(t, release)
+	server.config.ReconnectDelay = time.Millisecond
+	p := NewWSPool(server.config, WSPoolConfig{MaxConnections: 2, Reconnect: true})
+	defer p.Close()
+	owner := &testPoolOwner{}
+	if err := p.register(owner); err != nil {
+		t.Fatal(err)
+	}
+	subscribe := func(channel string) {
+		t.Helper()
+		if err := p.subscribe(&poolSubscription{channel: channel, owner: owner}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 1; i <= 4; i++ {
+		subscribe(fmt.Sprintf("order_book/%d", i))
+	}
+	if load := p.Load(); !slices.Equal(load, []int{2, 2}) {
+		t.Fatalf("expected the subscriptions spread over 2 connections, got %v", load)
+	}
+
+	// the lost connection can't be replaced, its subscriptions are restored on the other one
+	server.refuse.Store(true)
+	server.dropFirst()
+	waitFor(t, "the restore", func() bool { return slices.Equal(p.Load(), []int{4}) })
+
+	// the replacement opened for a new subscription takes some of them
+	server.refuse.Store(false)
+	subscribe("trade/1")
+	waitFor(t, "the rebalance", func() bool {
+		load := p.Load()
+		slices.Sort(load)
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		return slices.Equal(load, []int{2, 3}) && !p.rebalancing
+	})
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	channels := make(map[string]int)
+	for _, c := range p.conns {
+		for sub := range c.subs {
+			channels[sub.channel]++
+			if sub.conn != c || sub.pending || len(sub.handlerIds) != len(sub.handlers) {
+				t.Errorf("expected %s settled on its connection", sub.channel)
+			}
+		}
+	}
+	if len(channels) != 5 {
+		t.Errorf("expected the 5 channels subscribed once each, got %v", channels)
+	}
+	if owner.lost.Load() != 1 {
+		t.Errorf("expected the owner to be told once about the lost connection, got %d", owner.lost.Load())
+	}
+}
@@ -3,14 +3,23 @@ package client
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
+
+	"github.com/u20024804/lighter-ex/decimal"
 )
 
 // LighterWebsocketPrivateService implements the new Bybit-style private interface
 type LighterWebsocketPrivateService struct {
-	wsClient   *WSClient
+	pool *WSPool
+	// ownPool is set when the pool was created by the service, it's closed with the service
+	ownPool bool
+	// tokenGen authenticates the subscriptions of the service, it's called for every subscription & restore
+	tokenGen   TokenGenerator
 	ctx        context.Context
 	cancel     context.CancelFunc
 	errHandler ErrHandler
@@ -18,9 +27,15 @@ type LighterWebsocketPrivateService struct {
 
 	// Subscription management
 	subscriptions map[string]*Subscription
+
+	// streams are ended when the connection is lost
+	streams streamRegistry
+
+	cancelOnDisconnect *CancelOnDisconnect
 }
 
-// TokenGenerator is a function type for generating auth tokens
+// TokenGenerator is a function type for generating auth tokens. It's called whenever a token is sent, so it must
+// return a token valid for a while, e.g. by caching one until it's about to expire.
 type TokenGenerator func() string
 
 // NewLighterWebsocketPrivateService creates a new private service
@@ -29,44 +44,111 @@ func NewLighterWebsocketPrivateService(config *WSConfig, tokenGen TokenGenerator
 		config = DefaultWSConfig()
 	}
 
-	wsClient := NewWSClient(config)
-	// Set authentication token if generator is provided
-	if tokenGen != nil {
-		token := tokenGen()
-		if token != "" {
-			wsClient.SetAuthToken(token)
-		}
-	}
+	// the tokens authenticate the connections of the service as well as its subscriptions
+	service := newLighterWebsocketPrivateService(newServicePool(config, tokenGen), tokenGen)
+	service.ownPool = true
+	return service
+}
+
+// NewLighterWebsocketPrivateServiceWithPool creates a private service whose subscriptions are spread over the
+// connections of a pool shared with other services, each subscription carrying a token of tokenGen, generated
+// when it's subscribed & restored. Closing the service doesn't close the pool.
+func NewLighterWebsocketPrivateServiceWithPool(pool *WSPool, tokenGen TokenGenerator) *LighterWebsocketPrivateService {
+	return newLighterWebsocketPrivateService(pool, tokenGen)
+}
 
+func newLighterWebsocketPrivateService(pool *WSPool, tokenGen TokenGenerator) *LighterWebsocketPrivateService {
 	return &LighterWebsocketPrivateService{
-		wsClient:      wsClient,
+		pool:          pool,
+		tokenGen:      tokenGen,
 		subscriptions: make(map[string]*Subscription),
 	}
 }
 
+// generateToken returns a new token of tokenGen, "" without one
+func generateToken(tokenGen TokenGenerator) string {
+	if tokenGen == nil {
+		return ""
+	}
+	return tokenGen()
+}
+
 // Start implements LighterWebsocketPrivateServiceI
 func (s *LighterWebsocketPrivateService) Start(ctx context.Context, errHandler ErrHandler) error {
 	s.ctx, s.cancel = context.WithCancel(ctx)
 	s.errHandler = errHandler
 
-	// Set disconnect callback to notify error handler when connection is lost
-	s.wsClient.SetOnDisconnected(func() {
-		log.Println("[LighterWS] Private service WebSocket disconnected")
-		if s.errHandler != nil {
-			s.errHandler(fmt.Errorf("WebSocket connection lost"))
-		}
-	})
-
-	if err := s.wsClient.Connect(s.ctx); err != nil {
+	if err := s.pool.register(s); err != nil {
 		return fmt.Errorf("failed to connect websocket: %w", err)
 	}
 
-	log.Println("[LighterWS] Private service started")
+	s.pool.logger().Info("Private service started")
 	return nil
 }
 
+// connectionLost notifies the cancel-on-disconnect policy & the error handler when a connection of the service is
+// lost. Unless the pool restores their subscriptions, the streams end and the lost subscriptions are dropped, so
+// they can be subscribed again.
+func (s *LighterWebsocketPrivateService) connectionLost(err error, restoring bool) {
+	s.pool.logger().Warn("Private service WebSocket disconnected", "restoring", restoring)
+	if !restoring {
+		s.streams.endAll(err)
+		s.dropLost()
+	}
+	if policy := s.getCancelOnDisconnect(); policy != nil {
+		policy.OnDisconnected()
+	}
+	if s.errHandler != nil {
+		s.errHandler(err)
+	}
+}
+
+// dropLost removes the subscriptions which lost their connection and aren't restored by the pool
+func (s *LighterWebsocketPrivateService) dropLost() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key, sub := range s.subscriptions {
+		if sub.poolSub == nil || s.pool.attached(sub.poolSub) {
+			continue
+		}
+		if sub.cancelFunc != nil {
+			sub.cancelFunc()
+		}
+		delete(s.subscriptions, key)
+		s.pool.logger().Info("Dropped lost subscription", LogKeyChannel, sub.poolSub.channel)
+	}
+}
+
+// serverError passes the errors sent by Lighter outside of a subscription to the error handler
+func (s *LighterWebsocketPrivateService) serverError(err error) {
+	if s.errHandler != nil {
+		s.errHandler(err)
+	}
+}
+
+// SetCancelOnDisconnect enables the cancel-on-disconnect policy: when the connection is lost, the policy holds new
+// orders and cancels the existing ones after its grace period, until SubscribeAccount receives a fresh snapshot of its account.
+// It only reacts to disconnects while the service is started with Start.
+//
+// A pool with WSPoolConfig.Reconnect subscribes the account again by itself. Otherwise, which is the case of the pool
+// of NewLighterWebsocketPrivateService, the lost subscriptions are dropped: call SubscribeAccount again to get the
+// snapshot releasing the orders.
+func (s *LighterWebsocketPrivateService) SetCancelOnDisconnect(policy *CancelOnDisconnect) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cancelOnDisconnect = policy
+}
+
+func (s *LighterWebsocketPrivateService) getCancelOnDisconnect() *CancelOnDisconnect {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.cancelOnDisconnect
+}
+
 // Close implements LighterWebsocketPrivateServiceI
 func (s *LighterWebsocketPrivateService) Close() error {
+	s.streams.endAll(ErrStreamClosed)
 	if s.cancel != nil {
 		s.cancel()
 	}
@@ -81,11 +163,11 @@ func (s *LighterWebsocketPrivateService) Close() error {
 	s.subscriptions = make(map[string]*Subscription)
 	s.mu.Unlock()
 
-	if s.wsClient != nil {
-		return s.wsClient.Disconnect()
+	s.pool.unregister(s)
+	s.pool.logger().Info("Private service closed")
+	if s.ownPool {
+		return s.pool.Close()
 	}
-
-	log.Println("[LighterWS] Private service closed")
 	return nil
 }
 
@@ -94,59 +176,323 @@ func (s *LighterWebsocketPrivateService) SubscribeAccount(
 	param LighterAccountParamKey,
 	callback func(LighterAccountResponse) error,
 ) (func() error, error) {
-	key := fmt.Sprintf("account_%d", param.AccountId)
+	// the span of an update covers its callback
+	deliver := func(response LighterAccountResponse) error {
+		span := startAccountUpdateSpan(response.RawAccountUpdate)
+		defer span.End()
+		return callback(response)
+	}
+	return subscribeAccountChannel(s, fmt.Sprintf("account_%d", param.AccountId),
+		fmt.Sprintf("account_all/%d", param.AccountId),
+		MessageTypeAccountSubscribed, MessageTypeAccount,
+		param.Dispatch, param.Staleness, s.decodeAccount, deliver)
+}
+
+// decodeAccount decodes an account_all message, passing the raw WSAccountUpdate to the callback. The snapshot of the
+// account of the cancel-on-disconnect policy resyncs it.
+func (s *LighterWebsocketPrivateService) decodeAccount(data []byte, isSnapshot bool) ([]LighterAccountResponse, error) {
+	var accountUpdate WSAccountUpdate
+	if err := json.Unmarshal(data, &accountUpdate); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal account update: %w", err)
+	}
+
+	if policy := s.getCancelOnDisconnect(); policy != nil && isSnapshot && accountUpdate.Account == policy.client.accountIndex {
+		policy.OnResynced()
+	}
+
+	return []LighterAccountResponse{{
+		AccountId:        accountUpdate.Account,
+		AvailableBalance: decimal.Zero, // Raw message doesn't have separate available balance
+		MarketStats:      positionsToMarketStats(accountUpdate.Positions),
+		Timestamp:        time.Now().UnixMilli(), // Raw message doesn't carry a timestamp, use the receive time
+		IsSnapshot:       isSnapshot,
+		RawAccountUpdate: &accountUpdate,
+	}}, nil
+}
+
+// AccountStream subscribes to an account as a Stream. config is the queue of the messages waiting to be read, and
+// what happens when it's full.
+func (s *LighterWebsocketPrivateService) AccountStream(accountId int64, config DispatchConfig) (*Stream[LighterAccountResponse], error) {
+	return subscribeStream(&s.streams, func(callback func(LighterAccountResponse) error) (func() error, error) {
+		return s.SubscribeAccount(LighterAccountParamKey{AccountId: accountId, Dispatch: &config}, callback)
+	})
+}
 
+// SubscribeOrders implements LighterWebsocketPrivateServiceI
+// The callback is called once per order, with the orders of every market unless param.MarketIds is set.
+func (s *LighterWebsocketPrivateService) SubscribeOrders(
+	param LighterOrdersParamKey,
+	callback func(LighterOrdersResponse) error,
+) (func() error, error) {
+	decode := decodeOrders(param.AccountId)
+	if len(param.MarketIds) == 0 {
+		return subscribeAccountChannel(s, fmt.Sprintf("orders_%d", param.AccountId),
+			fmt.Sprintf("%s/%d", ChannelAccountAllOrders, param.AccountId),
+			MessageTypeAccountAllOrdersSubscribed, MessageTypeAccountAllOrdersUpdate,
+			param.Dispatch, param.Staleness, decode, callback)
+	}
+
+	unsubs := make([]func() error, 0, len(param.MarketIds))
+	unsubAll := func() error {
+		var errs []error
+		for _, unsub := range unsubs {
+			if err := unsub(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		return errors.Join(errs...)
+	}
+	for _, marketId := range param.MarketIds {
+		unsub, err := subscribeAccountChannel(s, fmt.Sprintf("orders_%d_%d", param.AccountId, marketId),
+			fmt.Sprintf("%s/%d/%d", ChannelAccountOrders, marketId, param.AccountId),
+			MessageTypeAccountOrdersSubscribed, MessageTypeAccountOrdersUpdate,
+			param.Dispatch, param.Staleness, decode, callback)
+		if err != nil {
+			unsubAll()
+			return nil, err
+		}
+		unsubs = append(unsubs, unsub)
+	}
+	return unsubAll, nil
+}
+
+// decodeOrders returns the decoder of the account_orders & account_all_orders messages of an account
+func decodeOrders(accountId int64) func(data []byte, isSnapshot bool) ([]LighterOrdersResponse, error) {
+	return func(data []byte, isSnapshot bool) ([]LighterOrdersResponse, error) {
+		var msg struct {
+			Account int64              `json:"account"`
+			Orders  map[string][]Order `json:"orders"`
+		}
+		if err := json.Unmarshal(data, &msg); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal account orders: %w", err)
+		}
+		// the channel of the per market orders doesn't tell the account
+		if msg.Account != 0 && msg.Account != accountId {
+			return nil, nil
+		}
+		var responses []LighterOrdersResponse
+		for _, orders := range msg.Orders {
+			for i := range orders {
+				responses = append(responses, orderResponse(accountId, &orders[i], isSnapshot))
+			}
+		}
+		return responses, nil
+	}
+}
+
+// orderResponse converts an order of the account_orders messages
+func orderResponse(accountId int64, order *Order, isSnapshot bool) LighterOrdersResponse {
+	orderId := order.OrderId
+	if orderId == "" {
+		orderId = strconv.FormatInt(order.OrderIndex, 10)
+	}
+	var isAsk uint8
+	if order.IsAsk {
+		isAsk = 1
+	}
+	var timestamp int64
+	if order.Timestamp > 0 {
+		timestamp = serverTime(order.Timestamp).UnixMilli()
+	}
+	return LighterOrdersResponse{
+		AccountId:        accountId,
+		OrderId:          orderId,
+		ClientOrderIndex: order.ClientOrderIndex,
+		MarketId:         order.MarketIndex,
+		Status:           order.Status,
+		BaseQuantity:     order.InitialBaseAmount,
+		FilledQuantity:   order.FilledBaseAmount,
+		Price:            order.Price,
+		IsAsk:            isAsk,
+		Timestamp:        timestamp,
+		IsSnapshot:       isSnapshot,
+		RawOrder:         order,
+	}
+}
+
+// SubscribeUserStats implements LighterWebsocketPrivateServiceI
+func (s *LighterWebsocketPrivateService) SubscribeUserStats(
+	param LighterUserStatsParamKey,
+	callback func(LighterUserStatsResponse) error,
+) (func() error, error) {
+	return subscribeAccountChannel(s, fmt.Sprintf("user_stats_%d", param.AccountId),
+		fmt.Sprintf("%s/%d", ChannelUserStats, param.AccountId),
+		MessageTypeUserStatsSubscribed, MessageTypeUserStatsUpdate,
+		param.Dispatch, param.Staleness, decodeUserStats(param.AccountId), callback)
+}
+
+// decodeUserStats returns the decoder of the user_stats messages of an account
+func decodeUserStats(accountId int64) func(data []byte, isSnapshot bool) ([]LighterUserStatsResponse, error) {
+	return func(data []byte, isSnapshot bool) ([]LighterUserStatsResponse, error) {
+		var msg struct {
+			Stats WSUserStats `json:"stats"`
+		}
+		if err := json.Unmarshal(data, &msg); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal user stats: %w", err)
+		}
+		stats := msg.Stats
+		return []LighterUserStatsResponse{{
+			AccountId:        accountId,
+			Collateral:       stats.Collateral,
+			PortfolioValue:   stats.PortfolioValue,
+			Leverage:         stats.Leverage,
+			AvailableBalance: stats.AvailableBalance,
+			MarginUsage:      stats.MarginUsage,
+			BuyingPower:      stats.BuyingPower,
+			CrossStats:       stats.CrossStats,
+			TotalStats:       stats.TotalStats,
+			Timestamp:        time.Now().UnixMilli(),
+			IsSnapshot:       isSnapshot,
+		}}, nil
+	}
+}
+
+// SubscribeAccountTxs implements LighterWebsocketPrivateServiceI
+// The callback is called once per tx, in the order of the message.
+func (s *LighterWebsocketPrivateService) SubscribeAccountTxs(
+	param LighterAccountTxsParamKey,
+	callback func(LighterAccountTxsResponse) error,
+) (func() error, error) {
+	return subscribeAccountChannel(s, fmt.Sprintf("account_tx_%d", param.AccountId),
+		fmt.Sprintf("%s/%d", ChannelAccountTx, param.AccountId),
+		MessageTypeAccountTxSubscribed, MessageTypeAccountTxUpdate,
+		param.Dispatch, param.Staleness, decodeAccountTxs(param.AccountId), callback)
+}
+
+// decodeAccountTxs returns the decoder of the account_tx messages of an account
+func decodeAccountTxs(accountId int64) func(data []byte, isSnapshot bool) ([]LighterAccountTxsResponse, error) {
+	return func(data []byte, isSnapshot bool) ([]LighterAccountTxsResponse, error) {
+		var msg struct {
+			Txs []WSTransaction `json:"txs"`
+		}
+		if err := json.Unmarshal(data, &msg); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal account txs: %w", err)
+		}
+		responses := make([]LighterAccountTxsResponse, len(msg.Txs))
+		for i, tx := range msg.Txs {
+			responses[i] = LighterAccountTxsResponse{AccountId: accountId, Tx: tx, IsSnapshot: isSnapshot}
+		}
+		return responses, nil
+	}
+}
+
+// SubscribePoolData implements LighterWebsocketPrivateServiceI
+func (s *LighterWebsocketPrivateService) SubscribePoolData(
+	param LighterPoolParamKey,
+	callback func(LighterPoolDataResponse) error,
+) (func() error, error) {
+	return subscribeAccountChannel(s, fmt.Sprintf("pool_data_%d", param.PoolId),
+		fmt.Sprintf("%s/%d", ChannelPoolData, param.PoolId),
+		MessageTypePoolDataSubscribed, MessageTypePoolDataUpdate,
+		param.Dispatch, param.Staleness, decodePoolData(param.PoolId), callback)
+}
+
+// decodePoolData returns the decoder of the pool_data messages of a pool
+func decodePoolData(poolId int64) func(data []byte, isSnapshot bool) ([]LighterPoolDataResponse, error) {
+	return func(data []byte, isSnapshot bool) ([]LighterPoolDataResponse, error) {
+		var msg WSPoolData
+		if err := json.Unmarshal(data, &msg); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal pool data: %w", err)
+		}
+		return []LighterPoolDataResponse{{
+			PoolId:     poolId,
+			Data:       &msg,
+			Timestamp:  time.Now().UnixMilli(),
+			IsSnapshot: isSnapshot,
+		}}, nil
+	}
+}
+
+// SubscribePoolInfo implements LighterWebsocketPrivateServiceI
+func (s *LighterWebsocketPrivateService) SubscribePoolInfo(
+	param LighterPoolParamKey,
+	callback func(LighterPoolInfoResponse) error,
+) (func() error, error) {
+	return subscribeAccountChannel(s, fmt.Sprintf("pool_info_%d", param.PoolId),
+		fmt.Sprintf("%s/%d", ChannelPoolInfo, param.PoolId),
+		MessageTypePoolInfoSubscribed, MessageTypePoolInfoUpdate,
+		param.Dispatch, param.Staleness, decodePoolInfo(param.PoolId), callback)
+}
+
+// decodePoolInfo returns the decoder of the pool_info messages of a pool
+func decodePoolInfo(poolId int64) func(data []byte, isSnapshot bool) ([]LighterPoolInfoResponse, error) {
+	return func(data []byte, isSnapshot bool) ([]LighterPoolInfoResponse, error) {
+		var msg struct {
+			PoolInfo WSPoolInfo `json:"pool_info"`
+		}
+		if err := json.Unmarshal(data, &msg); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal pool info: %w", err)
+		}
+		return []LighterPoolInfoResponse{{
+			PoolId:     poolId,
+			Info:       msg.PoolInfo,
+			Timestamp:  time.Now().UnixMilli(),
+			IsSnapshot: isSnapshot,
+		}}, nil
+	}
+}
+
+// subscribeAccountChannel subscribes to a private channel with the token of the service. decode returns the responses
+// of a message, passed one by one to the callback. key identifies the subscription in the service.
+func subscribeAccountChannel[T any](
+	s *LighterWebsocketPrivateService,
+	key, channel, subscribedType, updateType string,
+	dispatch *DispatchConfig,
+	staleness *StalenessConfig,
+	decode func(data []byte, isSnapshot bool) ([]T, error),
+	callback func(T) error,
+) (func() error, error) {
 	// Check if already subscribed
 	s.mu.RLock()
 	if _, exists := s.subscriptions[key]; exists {
 		s.mu.RUnlock()
-		return nil, fmt.Errorf("already subscribed to account %d", param.AccountId)
+		return nil, fmt.Errorf("already subscribed to %s", channel)
 	}
 	s.mu.RUnlock()
 
 	// Create subscription context
 	subCtx, subCancel := context.WithCancel(s.ctx)
 
-	// First, add handlers for both snapshot and update messages
-	handler := func(data []byte) error {
-		var accountUpdate WSAccountUpdate
+	msgChannel := strings.Replace(channel, "/", ":", 1)
+	queue := newDispatcher(msgChannel, s.pool.config.dispatchConfig(dispatch), callback, s.pool.callbackErrorHandler(msgChannel)).start()
+	fresh := s.pool.freshness(msgChannel, staleness)
 
-		if err := json.Unmarshal(data, &accountUpdate); err != nil {
-			return fmt.Errorf("failed to unmarshal account update: %v", err)
-		}
-
-		// Check if it's an account message (either snapshot or update)
-		if accountUpdate.Type != MessageTypeAccount && accountUpdate.Type != MessageTypeAccountSubscribed {
+	handler := func(frame *WSFrame) error {
+		if !channelMatches(frame.Channel, channel) {
 			return nil
 		}
-
-		// Pass the raw WSAccountUpdate data directly to the callback
-		response := LighterAccountResponse{
-			AccountId:        accountUpdate.Account,
-			AvailableBalance: "",  // Raw message doesn't have separate available balance
-			MarketStats:      nil, // Will be populated with raw data
-			Timestamp:        0,
-			IsSnapshot:       accountUpdate.Type == MessageTypeAccountSubscribed,
-			RawAccountUpdate: &accountUpdate,
+		fresh.observe(0)
+		responses, err := decode(frame.Data, frame.Type == subscribedType)
+		if err != nil {
+			return err
 		}
-		return callback(response)
+		for _, response := range responses {
+			queue.push(response)
+		}
+		return nil
 	}
-
-	s.wsClient.AddHandler(MessageTypeAccount, handler)
-	s.wsClient.AddHandler(MessageTypeAccountSubscribed, handler)
-
-	// Connect to WebSocket first
-	err := s.wsClient.Connect(subCtx)
-	if err != nil {
-		subCancel()
-		return nil, fmt.Errorf("failed to connect to WebSocket: %w", err)
+	sub := &poolSubscription{
+		channel: channel,
+		auth:    s.tokenGen,
+		owner:   s,
+		handlers: map[string]WSFrameHandler{
+			subscribedType: handler,
+			updateType:     handler,
+		},
 	}
 
-	// Then subscribe to the account channel
-	channel := fmt.Sprintf("account_all/%d", param.AccountId)
-	if err := s.wsClient.Subscribe(channel, ""); err != nil {
+	// Wait for context cancellation
+	go func() {
+		<-subCtx.Done()
+		// Unsubscribe & clean up handlers
+		s.pool.unsubscribe(sub)
+		queue.close()
+		fresh.close()
+	}()
+
+	if err := s.pool.subscribe(sub); err != nil {
 		subCancel()
-		return nil, fmt.Errorf("failed to subscribe to channel %s: %w", channel, err)
+		return nil, err
 	}
 
 	// Create unsubscribe function
@@ -159,7 +505,7 @@ func (s *LighterWebsocketPrivateService) SubscribeAccount(
 				sub.cancelFunc()
 			}
 			delete(s.subscriptions, key)
-			log.Printf("[LighterWS] Unsubscribed from account %d", param.AccountId)
+			s.pool.logger().Info("Unsubscribed", LogKeyChannel, channel)
 		}
 		return nil
 	}
@@ -170,27 +516,10 @@ func (s *LighterWebsocketPrivateService) SubscribeAccount(
 		key:        key,
 		unsubFunc:  unsubFunc,
 		cancelFunc: subCancel,
+		poolSub:    sub,
 	}
 	s.mu.Unlock()
 
-	log.Printf("[LighterWS] Subscribed to account %d", param.AccountId)
+	s.pool.logger().Info("Subscribed", LogKeyChannel, channel)
 	return unsubFunc, nil
 }
-
-// SubscribeOrders implements LighterWebsocketPrivateServiceI
-func (s *LighterWebsocketPrivateService) SubscribeOrders(
-	param LighterOrdersParamKey,
-	callback func(LighterOrdersResponse) error,
-) (func() error, error) {
-	// For now, orders are included in account updates
-	// We can extract order-specific data from account updates
-	return s.SubscribeAccount(
-		LighterAccountParamKey{AccountId: param.AccountId},
-		func(accountResponse LighterAccountResponse) error {
-			// TODO: Extract order updates from account response if needed
-			// For now, we don't have separate order updates
-			log.Printf("[LighterWS] Order updates are included in account updates for account %d", param.AccountId)
-			return nil
-		},
-	)
-}
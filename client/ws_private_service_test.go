@@ -0,0 +1,239 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/u20024804/lighter-ex/decimal"
+)
+
+func TestDecodeOrders(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		// order ids of the responses
+		ids []string
+	}{
+		{"all markets", `{"type":"update/account_all_orders","account":5,"orders":{` +
+			`"1":[{"order_index":11,"market_index":1,"is_ask":true,"timestamp":1760000000}],` +
+			`"2":[{"order_id":"12","market_index":2,"timestamp":1760000000000},{"order_index":13,"market_index":2}]}}`,
+			[]string{"11", "12", "13"}},
+		{"one market without account", `{"type":"update/account_orders","orders":{"1":[{"order_index":11,"market_index":1}]}}`,
+			[]string{"11"}},
+		{"other account", `{"type":"update/account_orders","account":6,"orders":{"1":[{"order_index":11,"market_index":1}]}}`,
+			nil},
+		{"no order", `{"type":"update/account_all_orders","account":5,"orders":{}}`, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			responses, err := decodeOrders(5)([]byte(tt.data), true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var ids []string
+			for _, response := range responses {
+				if response.AccountId != 5 || !response.IsSnapshot || response.RawOrder == nil {
+					t.Errorf("unexpected order %+v", response)
+				}
+				ids = append(ids, response.OrderId)
+			}
+			slices.Sort(ids)
+			if !slices.Equal(ids, tt.ids) {
+				t.Errorf("expected the orders %v, got %v", tt.ids, ids)
+			}
+		})
+	}
+
+	responses, err := decodeOrders(5)([]byte(`{"orders":{"1":[{"order_index":11,"market_index":1,"is_ask":true,"timestamp":1760000000}]}}`), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(responses) != 1 || responses[0].MarketId != 1 || responses[0].IsAsk != 1 || responses[0].Timestamp != 1760000000000 {
+		t.Errorf("expected an ask of market 1 at 1760000000000, got %+v", responses)
+	}
+	if _, err := decodeOrders(5)([]byte(`{"orders":[]}`), false); err == nil {
+		t.Error("expected an error for malformed orders")
+	}
+}
+
+func TestDecodeUserStats(t *testing.T) {
+	data := `{"type":"update/user_stats","stats":{"collateral":"1000","portfolio_value":"1050","leverage":"2",` +
+		`"available_balance":"500","margin_usage":"0.5","buying_power":"1000","cross_stats":{"collateral":"900"}}}`
+	responses, err := decodeUserStats(5)([]byte(data), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(responses) != 1 {
+		t.Fatalf("expected a response, got %+v", responses)
+	}
+	stats := responses[0]
+	if stats.AccountId != 5 || stats.IsSnapshot || !stats.Collateral.Equal(decimal.MustParse("1000")) ||
+		!stats.PortfolioValue.Equal(decimal.MustParse("1050")) || !stats.MarginUsage.Equal(decimal.MustParse("0.5")) ||
+		stats.CrossStats == nil || !stats.CrossStats.Collateral.Equal(decimal.MustParse("900")) || stats.TotalStats != nil {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+func TestDecodeAccountTxs(t *testing.T) {
+	data := `{"type":"subscribed/account_tx","txs":[{"hash":"0xb","nonce":2},{"hash":"0xa","nonce":1}]}`
+	responses, err := decodeAccountTxs(5)([]byte(data), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var hashes []string
+	for _, response := range responses {
+		if response.AccountId != 5 || !response.IsSnapshot {
+			t.Errorf("unexpected tx %+v", response)
+		}
+		hashes = append(hashes, response.Tx.Hash)
+	}
+	// in the order of the message
+	if !slices.Equal(hashes, []string{"0xb", "0xa"}) {
+		t.Errorf("expected the txs 0xb & 0xa, got %v", hashes)
+	}
+}
+
+func TestDecodePool(t *testing.T) {
+	data, err := decodePoolData(3)([]byte(`{"type":"update/pool_data","account":3,"shares":[{}]}`), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 1 || data[0].PoolId != 3 || data[0].Data == nil || data[0].Data.Account != 3 || len(data[0].Data.Shares) != 1 {
+		t.Errorf("unexpected pool data %+v", data)
+	}
+
+	info, err := decodePoolInfo(3)([]byte(`{"type":"subscribed/pool_info","pool_info":{"status":1,"total_shares":100,`+
+		`"operator_fee":"0.1","share_prices":[{"timestamp":1760000000,"share_price":"1.05"}]}}`), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(info) != 1 || info[0].PoolId != 3 || !info[0].IsSnapshot || info[0].Info.TotalShares != 100 ||
+		!info[0].Info.OperatorFee.Equal(decimal.MustParse("0.1")) || len(info[0].Info.SharePrices) != 1 {
+		t.Errorf("unexpected pool info %+v", info)
+	}
+}
+
+// privateCallbacks records the callbacks of the private subscriptions as "orders 11", "user_stats 5"...
+type privateCallbacks struct {
+	mu        sync.Mutex
+	callbacks []string
+}
+
+func (c *privateCallbacks) add(format string, args ...any) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.callbacks = append(c.callbacks, fmt.Sprintf(format, args...))
+	return nil
+}
+
+func (c *privateCallbacks) get() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	callbacks := slices.Clone(c.callbacks)
+	slices.Sort(callbacks)
+	return callbacks
+}
+
+func TestPrivateSubscriptions(t *testing.T) {
+	release := make(chan struct{})
+	close(release)
+	server := newTestWSServer(t, release)
+	// the snapshots sent on subscription
+	snapshots := map[string]string{
+		"account_all/5":        `{"type":"subscribed/account_all","channel":"account_all:5","account":5}`,
+		"account_all_orders/5": `{"type":"subscribed/account_all_orders","channel":"account_all_orders:5","account":5,"orders":{"1":[{"order_index":11,"market_index":1}]}}`,
+		// the per market orders don't carry the account in their channel
+		"account_orders/2/5": `{"type":"subscribed/account_orders","channel":"account_orders:2","account":5,"orders":{"2":[{"order_index":21,"market_index":2}]}}`,
+		"user_stats/5":       `{"type":"subscribed/user_stats","channel":"user_stats:5","stats":{"collateral":"1000"}}`,
+		"account_tx/5":       `{"type":"subscribed/account_tx","channel":"account_tx:5","txs":[{"hash":"0xa"}]}`,
+		"pool_data/3":        `{"type":"subscribed/pool_data","channel":"pool_data:3","account":3}`,
+		"pool_info/3":        `{"type":"subscribed/pool_info","channel":"pool_info:3","pool_info":{"total_shares":100}}`,
+	}
+	server.respond = func(msg WSSubscribeMessage) []string {
+		return []string{snapshots[msg.Channel]}
+	}
+	s := NewLighterWebsocketPrivateService(server.config, func() string { return "token" })
+	if err := s.Start(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	var callbacks privateCallbacks
+	subscribe := func(name string, subscribe func() (func() error, error)) {
+		t.Helper()
+		if _, err := subscribe(); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+	}
+	subscribe("account", func() (func() error, error) {
+		return s.SubscribeAccount(LighterAccountParamKey{AccountId: 5}, func(r LighterAccountResponse) error {
+			return callbacks.add("account %d %t %t", r.AccountId, r.IsSnapshot, r.RawAccountUpdate != nil)
+		})
+	})
+	subscribe("all orders", func() (func() error, error) {
+		return s.SubscribeOrders(LighterOrdersParamKey{AccountId: 5}, func(r LighterOrdersResponse) error {
+			return callbacks.add("orders %s %t", r.OrderId, r.IsSnapshot)
+		})
+	})
+	subscribe("market orders", func() (func() error, error) {
+		return s.SubscribeOrders(LighterOrdersParamKey{AccountId: 5, MarketIds: []uint8{2}}, func(r LighterOrdersResponse) error {
+			return callbacks.add("market orders %s %t", r.OrderId, r.IsSnapshot)
+		})
+	})
+	subscribe("user stats", func() (func() error, error) {
+		return s.SubscribeUserStats(LighterUserStatsParamKey{AccountId: 5}, func(r LighterUserStatsResponse) error {
+			return callbacks.add("user_stats %d %s", r.AccountId, r.Collateral)
+		})
+	})
+	subscribe("account txs", func() (func() error, error) {
+		return s.SubscribeAccountTxs(LighterAccountTxsParamKey{AccountId: 5}, func(r LighterAccountTxsResponse) error {
+			return callbacks.add("account_tx %s", r.Tx.Hash)
+		})
+	})
+	subscribe("pool data", func() (func() error, error) {
+		return s.SubscribePoolData(LighterPoolParamKey{PoolId: 3}, func(r LighterPoolDataResponse) error {
+			return callbacks.add("pool_data %d", r.Data.Account)
+		})
+	})
+	subscribe("pool info", func() (func() error, error) {
+		return s.SubscribePoolInfo(LighterPoolParamKey{PoolId: 3}, func(r LighterPoolInfoResponse) error {
+			return callbacks.add("pool_info %d", r.Info.TotalShares)
+		})
+	})
+
+	expected := []string{
+		"account 5 true true",
+		"account_tx 0xa",
+		"market orders 21 true",
+		"orders 11 true",
+		"pool_data 3",
+		"pool_info 100",
+		"user_stats 5 1000",
+	}
+	waitFor(t, "the snapshots", func() bool { return len(callbacks.get()) >= len(expected) })
+	if got := callbacks.get(); !slices.Equal(got, expected) {
+		t.Errorf("expected the callbacks %v, got %v", expected, got)
+	}
+
+	// every subscription carries the token
+	var channels []string
+	for _, msg := range server.subscribed() {
+		if msg.Auth != "token" {
+			t.Errorf("expected the token for %s, got %q", msg.Channel, msg.Auth)
+		}
+		channels = append(channels, msg.Channel)
+	}
+	slices.Sort(channels)
+	if expected := slices.Sorted(maps.Keys(snapshots)); !slices.Equal(channels, expected) {
+		t.Errorf("expected the channels %v, got %v", expected, channels)
+	}
+
+	if _, err := s.SubscribeAccount(LighterAccountParamKey{AccountId: 5}, nil); err == nil || !strings.Contains(err.Error(), "already subscribed") {
+		t.Errorf("expected the account to be subscribed once, got %v", err)
+	}
+}
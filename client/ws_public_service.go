@@ -1,18 +1,21 @@
 package client
 
 import (
+	"cmp"
 	"context"
 	"encoding/json"
 	"fmt"
-	"log"
-	"strconv"
+	"slices"
 	"strings"
 	"sync"
+	"time"
 )
 
 // LighterWebsocketPublicService implements the new Bybit-style interface
 type LighterWebsocketPublicService struct {
-	wsClient   *WSClient
+	pool *WSPool
+	// ownPool is set when the pool was created by the service, it's closed with the service
+	ownPool    bool
 	ctx        context.Context
 	cancel     context.CancelFunc
 	errHandler ErrHandler
@@ -20,12 +23,20 @@ type LighterWebsocketPublicService struct {
 
 	// Subscription management
 	subscriptions map[string]*Subscription
+
+	// streams are ended when the connection is lost
+	streams streamRegistry
+
+	// bookOffsets is the offset of the last order book message per market, to detect gaps & resyncs
+	bookOffsets map[uint8]int64
 }
 
 type Subscription struct {
 	key        string
 	unsubFunc  func() error
 	cancelFunc context.CancelFunc
+	// poolSub is the subscription in the pool, when the service drops the lost ones
+	poolSub *poolSubscription
 }
 
 // NewLighterWebsocketPublicService creates a new public service
@@ -34,9 +45,18 @@ func NewLighterWebsocketPublicService(config *WSConfig) *LighterWebsocketPublicS
 		config = DefaultWSConfig()
 	}
 
+	service := NewLighterWebsocketPublicServiceWithPool(newServicePool(config, nil))
+	service.ownPool = true
+	return service
+}
+
+// NewLighterWebsocketPublicServiceWithPool creates a public service whose subscriptions are spread over the
+// connections of a pool shared with other services. Closing the service doesn't close the pool.
+func NewLighterWebsocketPublicServiceWithPool(pool *WSPool) *LighterWebsocketPublicService {
 	return &LighterWebsocketPublicService{
-		wsClient:      NewWSClient(config),
+		pool:          pool,
 		subscriptions: make(map[string]*Subscription),
+		bookOffsets:   make(map[uint8]int64),
 	}
 }
 
@@ -45,24 +65,36 @@ func (s *LighterWebsocketPublicService) Start(ctx context.Context, errHandler Er
 	s.ctx, s.cancel = context.WithCancel(ctx)
 	s.errHandler = errHandler
 
-	// Set disconnect callback to notify error handler when connection is lost
-	s.wsClient.SetOnDisconnected(func() {
-		log.Println("[LighterWS] Public service WebSocket disconnected")
-		if s.errHandler != nil {
-			s.errHandler(fmt.Errorf("WebSocket connection lost"))
-		}
-	})
-
-	if err := s.wsClient.Connect(s.ctx); err != nil {
+	if err := s.pool.register(s); err != nil {
 		return fmt.Errorf("failed to connect websocket: %w", err)
 	}
 
-	log.Println("[LighterWS] Public service started")
+	s.pool.logger().Info("Public service started")
 	return nil
 }
 
+// connectionLost notifies the error handler when a connection of the service is lost. The streams end unless the
+// pool restores their subscriptions.
+func (s *LighterWebsocketPublicService) connectionLost(err error, restoring bool) {
+	s.pool.logger().Warn("Public service WebSocket disconnected", "restoring", restoring)
+	if !restoring {
+		s.streams.endAll(err)
+	}
+	if s.errHandler != nil {
+		s.errHandler(err)
+	}
+}
+
+// serverError passes the errors sent by Lighter outside of a subscription to the error handler
+func (s *LighterWebsocketPublicService) serverError(err error) {
+	if s.errHandler != nil {
+		s.errHandler(err)
+	}
+}
+
 // Close implements LighterWebsocketPublicServiceI
 func (s *LighterWebsocketPublicService) Close() error {
+	s.streams.endAll(ErrStreamClosed)
 	if s.cancel != nil {
 		s.cancel()
 	}
@@ -77,11 +109,11 @@ func (s *LighterWebsocketPublicService) Close() error {
 	s.subscriptions = make(map[string]*Subscription)
 	s.mu.Unlock()
 
-	if s.wsClient != nil {
-		return s.wsClient.Disconnect()
+	s.pool.unregister(s)
+	s.pool.logger().Info("Public service closed")
+	if s.ownPool {
+		return s.pool.Close()
 	}
-
-	log.Println("[LighterWS] Public service closed")
 	return nil
 }
 
@@ -89,6 +121,22 @@ func (s *LighterWebsocketPublicService) Close() error {
 func (s *LighterWebsocketPublicService) SubscribeOrderBook(
 	param LighterOrderBookParamKey,
 	callback func(LighterOrderBookResponse) error,
+) (func() error, error) {
+	return s.SubscribeOrderBookFrames(param, func(f *WSOrderBookFrame) error {
+		return callback(LighterOrderBookResponse{
+			MarketId:   f.MarketId,
+			Bids:       toPriceLevels(f.Bids),
+			Asks:       toPriceLevels(f.Asks),
+			Timestamp:  f.Timestamp,
+			IsSnapshot: f.IsSnapshot,
+		})
+	})
+}
+
+// SubscribeOrderBookFrames implements LighterWebsocketPublicServiceI
+func (s *LighterWebsocketPublicService) SubscribeOrderBookFrames(
+	param LighterOrderBookParamKey,
+	callback func(*WSOrderBookFrame) error,
 ) (func() error, error) {
 	key := fmt.Sprintf("orderbook_%d", param.MarketId)
 
@@ -103,20 +151,7 @@ func (s *LighterWebsocketPublicService) SubscribeOrderBook(
 	// Create subscription context
 	subCtx, subCancel := context.WithCancel(s.ctx)
 
-	// Custom handler that converts internal updates to new format
-	handler := func(marketId uint8, bids, asks []PriceLevel, timestamp int64, isSnapshot bool) error {
-		response := LighterOrderBookResponse{
-			MarketId:   marketId,
-			Bids:       bids,
-			Asks:       asks,
-			Timestamp:  timestamp,
-			IsSnapshot: isSnapshot,
-		}
-		return callback(response)
-	}
-
-	// Start the order book service with our custom handler
-	err := s.startOrderBookService(subCtx, param.MarketId, handler)
+	err := s.startOrderBookService(subCtx, param.MarketId, param.Dispatch, param.Staleness, callback)
 	if err != nil {
 		subCancel()
 		return nil, fmt.Errorf("failed to start order book service: %w", err)
@@ -132,7 +167,8 @@ func (s *LighterWebsocketPublicService) SubscribeOrderBook(
 				sub.cancelFunc()
 			}
 			delete(s.subscriptions, key)
-			log.Printf("[LighterWS] Unsubscribed from order book market %d", param.MarketId)
+			delete(s.bookOffsets, param.MarketId)
+			s.pool.logger().Info("Unsubscribed from order book", LogKeyMarket, param.MarketId)
 		}
 		return nil
 	}
@@ -146,10 +182,25 @@ func (s *LighterWebsocketPublicService) SubscribeOrderBook(
 	}
 	s.mu.Unlock()
 
-	log.Printf("[LighterWS] Subscribed to order book market %d", param.MarketId)
+	s.pool.logger().Info("Subscribed to order book", LogKeyMarket, param.MarketId)
 	return unsubFunc, nil
 }
 
+// OrderBookStream subscribes to the order book of a market as a Stream. config is the queue of the messages waiting
+// to be read, and what happens when it's full.
+func (s *LighterWebsocketPublicService) OrderBookStream(marketId uint8, config DispatchConfig) (*Stream[LighterOrderBookResponse], error) {
+	return subscribeStream(&s.streams, func(callback func(LighterOrderBookResponse) error) (func() error, error) {
+		return s.SubscribeOrderBook(LighterOrderBookParamKey{MarketId: marketId, Dispatch: &config}, callback)
+	})
+}
+
+// TradesStream subscribes to the trades of a market as a Stream, see OrderBookStream
+func (s *LighterWebsocketPublicService) TradesStream(marketId uint8, config DispatchConfig) (*Stream[LighterTradesResponse], error) {
+	return subscribeStream(&s.streams, func(callback func(LighterTradesResponse) error) (func() error, error) {
+		return s.SubscribeTrades(LighterTradesParamKey{MarketId: marketId, Dispatch: &config}, callback)
+	})
+}
+
 // SubscribeTicker is not supported by Lighter - use UpdateBookTicker in wrapper instead
 // This method exists to maintain interface compatibility but always returns an error
 func (s *LighterWebsocketPublicService) SubscribeTicker() (func() error, error) {
@@ -157,15 +208,234 @@ func (s *LighterWebsocketPublicService) SubscribeTicker() (func() error, error)
 }
 
 // SubscribeTrades implements LighterWebsocketPublicServiceI
+// The callback is called once per trade, oldest first.
 func (s *LighterWebsocketPublicService) SubscribeTrades(
 	param LighterTradesParamKey,
 	callback func(LighterTradesResponse) error,
 ) (func() error, error) {
-	_ = fmt.Sprintf("trades_%d", param.MarketId) // TODO: implement
+	key := fmt.Sprintf("trades_%d", param.MarketId)
 
-	// Implementation similar to SubscribeOrderBook...
-	// For now, return a placeholder
-	return func() error { return nil }, fmt.Errorf("trades subscription not yet implemented")
+	// Check if already subscribed
+	s.mu.RLock()
+	if _, exists := s.subscriptions[key]; exists {
+		s.mu.RUnlock()
+		return nil, fmt.Errorf("already subscribed to trades for market %d", param.MarketId)
+	}
+	s.mu.RUnlock()
+
+	// Create subscription context
+	subCtx, subCancel := context.WithCancel(s.ctx)
+
+	err := s.startTradeService(subCtx, param.MarketId, param.Dispatch, param.Staleness, callback)
+	if err != nil {
+		subCancel()
+		return nil, fmt.Errorf("failed to start trade service: %w", err)
+	}
+
+	// Create unsubscribe function
+	unsubFunc := func() error {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		if sub, exists := s.subscriptions[key]; exists {
+			if sub.cancelFunc != nil {
+				sub.cancelFunc()
+			}
+			delete(s.subscriptions, key)
+			s.pool.logger().Info("Unsubscribed from trades", LogKeyMarket, param.MarketId)
+		}
+		return nil
+	}
+
+	// Store subscription
+	s.mu.Lock()
+	s.subscriptions[key] = &Subscription{
+		key:        key,
+		unsubFunc:  unsubFunc,
+		cancelFunc: subCancel,
+	}
+	s.mu.Unlock()
+
+	s.pool.logger().Info("Subscribed to trades", LogKeyMarket, param.MarketId)
+	return unsubFunc, nil
+}
+
+// SubscribeMarketStats implements LighterWebsocketPublicServiceI
+func (s *LighterWebsocketPublicService) SubscribeMarketStats(
+	param LighterMarketStatsParamKey,
+	callback func(LighterMarketStatsResponse) error,
+) (func() error, error) {
+	key := fmt.Sprintf("market_stats_%d", param.MarketId)
+	channel := fmt.Sprintf("%s/%d", ChannelMarketStats, param.MarketId)
+	return subscribeDecoded(s, key, channel, MessageTypeMarketStatsSubscribed, MessageTypeMarketStatsUpdate,
+		param.Dispatch, param.Staleness, decodeMarketStats(param.MarketId), callback)
+}
+
+// decodeMarketStats returns the decoder of the market_stats messages of a market
+func decodeMarketStats(marketId uint8) func(data []byte, isSnapshot bool) (LighterMarketStatsResponse, int64, error) {
+	return func(data []byte, isSnapshot bool) (LighterMarketStatsResponse, int64, error) {
+		var msg struct {
+			Timestamp   int64         `json:"timestamp"`
+			MarketStats WSMarketStats `json:"market_stats"`
+		}
+		if err := json.Unmarshal(data, &msg); err != nil {
+			return LighterMarketStatsResponse{}, 0, fmt.Errorf("failed to unmarshal market stats: %w", err)
+		}
+		stats := msg.MarketStats
+		return LighterMarketStatsResponse{
+			MarketId:           marketId,
+			MarkPrice:          stats.MarkPrice,
+			IndexPrice:         stats.IndexPrice,
+			LastTradePrice:     stats.LastTradePrice,
+			FundingRate:        stats.FundingRate,
+			CurrentFundingRate: stats.CurrentFundingRate,
+			FundingTimestamp:   stats.FundingTimestamp,
+			OpenInterest:       stats.OpenInterest,
+			DailyBaseVolume:    stats.DailyBaseTokenVolume,
+			DailyQuoteVolume:   stats.DailyQuoteTokenVolume,
+			DailyPriceLow:      stats.DailyPriceLow,
+			DailyPriceHigh:     stats.DailyPriceHigh,
+			DailyPriceChange:   stats.DailyPriceChange,
+			Timestamp:          messageMillis(msg.Timestamp),
+			IsSnapshot:         isSnapshot,
+		}, msg.Timestamp, nil
+	}
+}
+
+// SubscribeHeight implements LighterWebsocketPublicServiceI
+func (s *LighterWebsocketPublicService) SubscribeHeight(
+	param LighterHeightParamKey,
+	callback func(LighterHeightResponse) error,
+) (func() error, error) {
+	return subscribeDecoded(s, ChannelHeight, ChannelHeight, MessageTypeHeightSubscribed, MessageTypeHeightUpdate,
+		param.Dispatch, param.Staleness, decodeHeight, callback)
+}
+
+// decodeHeight decodes a height message
+func decodeHeight(data []byte, isSnapshot bool) (LighterHeightResponse, int64, error) {
+	var msg struct {
+		Timestamp int64 `json:"timestamp"`
+		Height    int64 `json:"height"`
+	}
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return LighterHeightResponse{}, 0, fmt.Errorf("failed to unmarshal height: %w", err)
+	}
+	return LighterHeightResponse{
+		Height:     msg.Height,
+		Timestamp:  messageMillis(msg.Timestamp),
+		IsSnapshot: isSnapshot,
+	}, msg.Timestamp, nil
+}
+
+// messageMillis is the server timestamp of a message in milliseconds, or the receive time for a message without one
+func messageMillis(serverTs int64) int64 {
+	if serverTs <= 0 {
+		return time.Now().UnixMilli()
+	}
+	return serverTime(serverTs).UnixMilli()
+}
+
+// MarketStatsStream subscribes to the stats of a market as a Stream, see OrderBookStream
+func (s *LighterWebsocketPublicService) MarketStatsStream(marketId uint8, config DispatchConfig) (*Stream[LighterMarketStatsResponse], error) {
+	return subscribeStream(&s.streams, func(callback func(LighterMarketStatsResponse) error) (func() error, error) {
+		return s.SubscribeMarketStats(LighterMarketStatsParamKey{MarketId: marketId, Dispatch: &config}, callback)
+	})
+}
+
+// HeightStream subscribes to the block height as a Stream, see OrderBookStream
+func (s *LighterWebsocketPublicService) HeightStream(config DispatchConfig) (*Stream[LighterHeightResponse], error) {
+	return subscribeStream(&s.streams, func(callback func(LighterHeightResponse) error) (func() error, error) {
+		return s.SubscribeHeight(LighterHeightParamKey{Dispatch: &config}, callback)
+	})
+}
+
+// subscribeDecoded subscribes to a channel whose snapshot & update messages are decoded by decode, one response each
+// with the server timestamp of the message, 0 if it has none. key identifies the subscription in the service.
+func subscribeDecoded[T any](
+	s *LighterWebsocketPublicService,
+	key, channel, subscribedType, updateType string,
+	dispatch *DispatchConfig,
+	staleness *StalenessConfig,
+	decode func(data []byte, isSnapshot bool) (T, int64, error),
+	callback func(T) error,
+) (func() error, error) {
+	// Check if already subscribed
+	s.mu.RLock()
+	if _, exists := s.subscriptions[key]; exists {
+		s.mu.RUnlock()
+		return nil, fmt.Errorf("already subscribed to %s", channel)
+	}
+	s.mu.RUnlock()
+
+	// Create subscription context
+	subCtx, subCancel := context.WithCancel(s.ctx)
+
+	// the messages carry the channel with a colon, e.g. "market_stats:1" for market_stats/1
+	msgChannel := strings.Replace(channel, "/", ":", 1)
+	queue := newDispatcher(msgChannel, s.pool.config.dispatchConfig(dispatch), callback, s.pool.callbackErrorHandler(msgChannel)).start()
+	fresh := s.pool.freshness(msgChannel, staleness)
+
+	handler := func(frame *WSFrame) error {
+		if frame.Channel != msgChannel {
+			return nil
+		}
+		msg, serverTs, err := decode(frame.Data, frame.Type == subscribedType)
+		fresh.observe(serverTs)
+		if err != nil {
+			return err
+		}
+		queue.push(msg)
+		return nil
+	}
+	sub := &poolSubscription{
+		channel: channel,
+		owner:   s,
+		handlers: map[string]WSFrameHandler{
+			subscribedType: handler,
+			updateType:     handler,
+		},
+	}
+
+	// Wait for context cancellation
+	go func() {
+		<-subCtx.Done()
+		// Unsubscribe & clean up handlers
+		s.pool.unsubscribe(sub)
+		queue.close()
+		fresh.close()
+	}()
+
+	if err := s.pool.subscribe(sub); err != nil {
+		subCancel()
+		return nil, err
+	}
+
+	// Create unsubscribe function
+	unsubFunc := func() error {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		if sub, exists := s.subscriptions[key]; exists {
+			if sub.cancelFunc != nil {
+				sub.cancelFunc()
+			}
+			delete(s.subscriptions, key)
+			s.pool.logger().Info("Unsubscribed", LogKeyChannel, channel)
+		}
+		return nil
+	}
+
+	// Store subscription
+	s.mu.Lock()
+	s.subscriptions[key] = &Subscription{
+		key:        key,
+		unsubFunc:  unsubFunc,
+		cancelFunc: subCancel,
+	}
+	s.mu.Unlock()
+
+	s.pool.logger().Info("Subscribed", LogKeyChannel, channel)
+	return unsubFunc, nil
 }
 
 // SubscribeAccount implements LighterWebsocketPublicServiceI
@@ -184,140 +454,192 @@ func (s *LighterWebsocketPublicService) SubscribeAccount(
 func (s *LighterWebsocketPublicService) startOrderBookService(
 	ctx context.Context,
 	marketId uint8,
-	handler func(uint8, []PriceLevel, []PriceLevel, int64, bool) error,
+	dispatch *DispatchConfig,
+	staleness *StalenessConfig,
+	callback func(*WSOrderBookFrame) error,
 ) error {
-	// Subscribe to order book channel
 	channel := fmt.Sprintf("order_book/%d", marketId)
-	if err := s.wsClient.Subscribe(channel, ""); err != nil {
-		return fmt.Errorf("failed to subscribe to channel %s: %w", channel, err)
-	}
 
-	// Add handlers for both snapshot and update messages
-	snapshotHandler := func(data []byte) error {
-		return s.handleOrderBookSnapshot(data, marketId, handler)
+	// handlers receive the order books of every market, so filter on the channel before decoding
+	msgChannel := fmt.Sprintf("%s:%d", ChannelOrderBook, marketId)
+	config := s.pool.config.dispatchConfig(dispatch)
+	if config.Policy == QueueDropOldest {
+		return ErrOrderBookDropOldest
 	}
-
-	updateHandler := func(data []byte) error {
-		return s.handleOrderBookUpdate(data, marketId, handler)
+	queue := newDispatcher(msgChannel, config, callback, s.pool.callbackErrorHandler(msgChannel))
+	queue.merge = mergeOrderBookFrames
+	queue.release = func(f *WSOrderBookFrame) { orderBookFramePool.Put(f) }
+	queue.start()
+	fresh := s.pool.freshness(msgChannel, staleness)
+
+	handler := func(frame *WSFrame) error {
+		if frame.Channel != msgChannel {
+			return nil
+		}
+		return s.handleOrderBook(frame.Data, marketId, queue, fresh)
 	}
 
-	s.wsClient.AddHandler(MessageTypeOrderBookSubscribed, snapshotHandler)
-	s.wsClient.AddHandler(MessageTypeOrderBookUpdate, updateHandler)
+	sub := &poolSubscription{
+		channel: channel,
+		owner:   s,
+		handlers: map[string]WSFrameHandler{
+			MessageTypeOrderBookSubscribed: handler,
+			MessageTypeOrderBookUpdate:     handler,
+		},
+	}
 
 	// Wait for context cancellation
 	go func() {
 		<-ctx.Done()
-		// Clean up handlers
-		s.wsClient.RemoveHandler(MessageTypeOrderBookSubscribed)
-		s.wsClient.RemoveHandler(MessageTypeOrderBookUpdate)
-		// Unsubscribe
-		s.wsClient.Unsubscribe(channel, "")
+		// Unsubscribe & clean up handlers
+		s.pool.unsubscribe(sub)
+		queue.close()
+		fresh.close()
 	}()
 
-	return nil
+	// the handlers are in place before subscribing, the confirmation carries the snapshot
+	return s.pool.subscribe(sub)
 }
 
-// handleOrderBookSnapshot processes snapshot messages
-func (s *LighterWebsocketPublicService) handleOrderBookSnapshot(
-	data []byte,
+// startTradeService is the internal method that handles trade subscriptions
+func (s *LighterWebsocketPublicService) startTradeService(
+	ctx context.Context,
 	marketId uint8,
-	handler func(uint8, []PriceLevel, []PriceLevel, int64, bool) error,
+	dispatch *DispatchConfig,
+	staleness *StalenessConfig,
+	callback func(LighterTradesResponse) error,
 ) error {
-	// Parse snapshot message (similar to existing handleOrderBookSnapshot)
-	var msg struct {
-		Type      string `json:"type"`
-		Channel   string `json:"channel"`
-		OrderBook struct {
-			Code   int            `json:"code"`
-			Asks   []WSPriceLevel `json:"asks"`
-			Bids   []WSPriceLevel `json:"bids"`
-			Offset int64          `json:"offset"`
-		} `json:"order_book"`
-		Timestamp int64 `json:"timestamp"`
-	}
+	channel := fmt.Sprintf("%s/%d", ChannelTrade, marketId)
 
-	if err := json.Unmarshal(data, &msg); err != nil {
-		return fmt.Errorf("failed to unmarshal order book snapshot: %w", err)
+	// handlers receive the trades of every market, so filter on the channel of the message
+	msgChannel := fmt.Sprintf("%s:%d", ChannelTrade, marketId)
+	deliver := func(msg tradesMessage) error {
+		return deliverTrades(msg, marketId, callback)
 	}
+	queue := newDispatcher(msgChannel, s.pool.config.dispatchConfig(dispatch), deliver, s.pool.callbackErrorHandler(msgChannel)).start()
+	fresh := s.pool.freshness(msgChannel, staleness)
 
-	// Extract market ID from channel if needed
-
-	if parts := strings.Split(msg.Channel, ":"); len(parts) == 2 {
-		if id, err := strconv.ParseUint(parts[1], 10, 8); err == nil {
-			marketId = uint8(id)
+	handler := func(frame *WSFrame) error {
+		if frame.Channel != msgChannel {
+			return nil
 		}
+		return handleTrades(frame.Data, queue, fresh)
 	}
 
-	// Convert to PriceLevel format
-	bids := make([]PriceLevel, 0, len(msg.OrderBook.Bids))
-	for _, bid := range msg.OrderBook.Bids {
-		bids = append(bids, PriceLevel{
-			Price:    bid.Price,
-			Quantity: bid.Size,
-		})
+	sub := &poolSubscription{
+		channel: channel,
+		owner:   s,
+		handlers: map[string]WSFrameHandler{
+			MessageTypeTradeSubscribed: handler,
+			MessageTypeTradeUpdate:     handler,
+		},
 	}
 
-	asks := make([]PriceLevel, 0, len(msg.OrderBook.Asks))
-	for _, ask := range msg.OrderBook.Asks {
-		asks = append(asks, PriceLevel{
-			Price:    ask.Price,
-			Quantity: ask.Size,
-		})
-	}
+	// Wait for context cancellation
+	go func() {
+		<-ctx.Done()
+		// Unsubscribe & clean up handlers
+		s.pool.unsubscribe(sub)
+		queue.close()
+		fresh.close()
+	}()
 
-	// Call handler with isSnapshot = true
-	return handler(marketId, bids, asks, msg.Timestamp, true)
+	// the handlers are in place before subscribing, the confirmation carries the snapshot
+	return s.pool.subscribe(sub)
 }
 
-// handleOrderBookUpdate processes incremental update messages
-func (s *LighterWebsocketPublicService) handleOrderBookUpdate(
-	data []byte,
-	marketId uint8,
-	handler func(uint8, []PriceLevel, []PriceLevel, int64, bool) error,
-) error {
-	// Parse update message (similar to existing handleOrderBookUpdate)
+// handleTrades processes both the trades sent on subscription and the new ones, fresh gets the time of the last one
+func handleTrades(data []byte, queue *dispatcher[tradesMessage], fresh *freshness) error {
 	var msg struct {
-		Type      string `json:"type"`
-		Channel   string `json:"channel"`
-		OrderBook struct {
-			Code   int            `json:"code"`
-			Asks   []WSPriceLevel `json:"asks"`
-			Bids   []WSPriceLevel `json:"bids"`
-			Offset int64          `json:"offset"`
-		} `json:"order_book"`
-		Timestamp int64 `json:"timestamp"`
+		Type   string    `json:"type"`
+		Trades []WSTrade `json:"trades"`
 	}
 
 	if err := json.Unmarshal(data, &msg); err != nil {
-		return fmt.Errorf("failed to unmarshal order book update: %w", err)
+		return fmt.Errorf("failed to unmarshal trades: %w", err)
+	}
+	var last int64
+	for _, trade := range msg.Trades {
+		last = max(last, trade.Timestamp)
 	}
+	fresh.observe(last)
+	// the callback gets the trades oldest first, whatever their order in the message
+	slices.SortStableFunc(msg.Trades, func(a, b WSTrade) int { return cmp.Compare(a.TradeId, b.TradeId) })
+	queue.push(tradesMessage{isSnapshot: msg.Type == MessageTypeTradeSubscribed, trades: msg.Trades})
+	return nil
+}
 
-	// Extract market ID from channel if needed
-	if marketId == 0 {
-		if parts := strings.Split(msg.Channel, ":"); len(parts) == 2 {
-			if id, err := strconv.ParseUint(parts[1], 10, 8); err == nil {
-				marketId = uint8(id)
-			}
+// tradesMessage is a trades message queued for the callback
+type tradesMessage struct {
+	isSnapshot bool
+	trades     []WSTrade
+}
+
+// deliverTrades calls the callback once per trade, in the order of msg which handleTrades sorted by trade id
+func deliverTrades(msg tradesMessage, marketId uint8, callback func(LighterTradesResponse) error) error {
+	isSnapshot := msg.isSnapshot
+	for _, trade := range msg.trades {
+		side := "sell"
+		if trade.IsMakerAsk {
+			side = "buy"
+		}
+		err := callback(LighterTradesResponse{
+			MarketId:    marketId,
+			TradeId:     trade.TradeId,
+			Price:       trade.Price,
+			Quantity:    trade.Size,
+			UsdAmount:   trade.UsdAmount,
+			Side:        side,
+			Timestamp:   trade.Timestamp,
+			BlockHeight: trade.BlockHeight,
+			IsSnapshot:  isSnapshot,
+		})
+		if err != nil {
+			return err
 		}
 	}
+	return nil
+}
 
-	// Convert to PriceLevel format
-	bids := make([]PriceLevel, 0, len(msg.OrderBook.Bids))
-	for _, bid := range msg.OrderBook.Bids {
-		bids = append(bids, PriceLevel{
-			Price:    bid.Price,
-			Quantity: bid.Size,
-		})
+// trackBookOffset reports the order book messages that don't follow the previous one of their market
+func (s *LighterWebsocketPublicService) trackBookOffset(marketId uint8, offset int64, isSnapshot bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	last, ok := s.bookOffsets[marketId]
+	switch {
+	case isSnapshot && ok:
+		metrics().OrderBookResync(marketId)
+	case !isSnapshot && (!ok || (offset != 0 && offset <= last)):
+		metrics().OrderBookGap(marketId)
 	}
+	s.bookOffsets[marketId] = offset
+}
 
-	asks := make([]PriceLevel, 0, len(msg.OrderBook.Asks))
-	for _, ask := range msg.OrderBook.Asks {
-		asks = append(asks, PriceLevel{
-			Price:    ask.Price,
-			Quantity: ask.Size,
-		})
+// handleOrderBook decodes snapshot & update messages into a pooled frame, which is released once delivered
+func (s *LighterWebsocketPublicService) handleOrderBook(
+	data []byte,
+	marketId uint8,
+	queue *dispatcher[*WSOrderBookFrame],
+	fresh *freshness,
+) error {
+	f := orderBookFramePool.Get().(*WSOrderBookFrame)
+	if err := DecodeOrderBookFrame(data, f); err != nil {
+		orderBookFramePool.Put(f)
+		return err
 	}
+	fresh.observe(f.Timestamp)
+	f.MarketId = marketId
+	s.trackBookOffset(marketId, f.Offset, f.IsSnapshot)
+	queue.push(f)
+	return nil
+}
 
-	// Call handler with isSnapshot = false
-	return handler(marketId, bids, asks, msg.Timestamp, false)
+// toPriceLevels copies the levels of a frame
+func toPriceLevels(levels []WSBookLevel) []PriceLevel {
+	priceLevels := make([]PriceLevel, len(levels))
+	for i, level := range levels {
+		priceLevels[i] = PriceLevel{Price: level.Price, Quantity: level.Size}
+	}
+	return priceLevels
 }
@@ -0,0 +1,132 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/u20024804/lighter-ex/decimal"
+)
+
+func TestHandleTradesOrder(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		ids  []int64
+	}{
+		{"oldest first", `{"type":"update/trade","trades":[{"trade_id":1},{"trade_id":2},{"trade_id":3}]}`, []int64{1, 2, 3}},
+		{"newest first", `{"type":"update/trade","trades":[{"trade_id":3},{"trade_id":2},{"trade_id":1}]}`, []int64{1, 2, 3}},
+		{"shuffled", `{"type":"subscribed/trade","trades":[{"trade_id":7},{"trade_id":5},{"trade_id":9},{"trade_id":6}]}`, []int64{5, 6, 7, 9}},
+		{"no trade", `{"type":"update/trade","trades":[]}`, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			queue := newDispatcher[tradesMessage]("trade:1", DispatchConfig{QueueSize: 1}, nil, nil)
+			if err := handleTrades([]byte(tt.data), queue, nil); err != nil {
+				t.Fatal(err)
+			}
+
+			var ids []int64
+			err := deliverTrades(queue.pop(), 1, func(trade LighterTradesResponse) error {
+				ids = append(ids, trade.TradeId)
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(ids, tt.ids) {
+				t.Errorf("expected the trades %v, got %v", tt.ids, ids)
+			}
+		})
+	}
+}
+
+func TestDecodeMarketStats(t *testing.T) {
+	const stats = `"market_stats":{"market_id":1,"mark_price":"3024.5","index_price":"3024.4","open_interest":"12.5",` +
+		`"funding_rate":"0.0001","current_funding_rate":"0.0002","funding_timestamp":1760000000000,` +
+		`"daily_base_token_volume":"100","daily_quote_token_volume":"302450"}`
+	tests := []struct {
+		name      string
+		data      string
+		serverTs  int64
+		timestamp int64
+	}{
+		{"milliseconds", `{"type":"update/market_stats","timestamp":1760000000123,` + stats + `}`, 1760000000123, 1760000000123},
+		{"seconds", `{"type":"update/market_stats","timestamp":1760000000,` + stats + `}`, 1760000000, 1760000000000},
+		{"no timestamp", `{"type":"update/market_stats",` + stats + `}`, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := time.Now().UnixMilli()
+			response, serverTs, err := decodeMarketStats(1)([]byte(tt.data), true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if serverTs != tt.serverTs {
+				t.Errorf("expected the server timestamp %d, got %d", tt.serverTs, serverTs)
+			}
+			if tt.timestamp == 0 && response.Timestamp < before || tt.timestamp != 0 && response.Timestamp != tt.timestamp {
+				t.Errorf("expected the timestamp %d, got %d", tt.timestamp, response.Timestamp)
+			}
+			if response.MarketId != 1 || !response.IsSnapshot || !response.MarkPrice.Equal(decimal.MustParse("3024.5")) ||
+				!response.IndexPrice.Equal(decimal.MustParse("3024.4")) || !response.OpenInterest.Equal(decimal.MustParse("12.5")) ||
+				!response.FundingRate.Equal(decimal.MustParse("0.0001")) || !response.CurrentFundingRate.Equal(decimal.MustParse("0.0002")) ||
+				response.FundingTimestamp != 1760000000000 || !response.DailyQuoteVolume.Equal(decimal.MustParse("302450")) {
+				t.Errorf("unexpected stats %+v", response)
+			}
+		})
+	}
+
+	if _, _, err := decodeMarketStats(1)([]byte(`{"market_stats":[]}`), false); err == nil {
+		t.Error("expected an error for malformed stats")
+	}
+}
+
+func TestDecodeHeight(t *testing.T) {
+	response, serverTs, err := decodeHeight([]byte(`{"type":"update/height","height":42,"timestamp":1760000000123}`), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.Height != 42 || response.Timestamp != 1760000000123 || response.IsSnapshot || serverTs != 1760000000123 {
+		t.Errorf("unexpected height %+v, server timestamp %d", response, serverTs)
+	}
+}
+
+func TestSubscribeMarketStatsLagging(t *testing.T) {
+	release := make(chan struct{})
+	close(release)
+	server := newTestWSServer(t, release)
+	lagging := time.Now().Add(-time.Minute).UnixMilli()
+	server.respond = func(msg WSSubscribeMessage) []string {
+		return []string{fmt.Sprintf(`{"type":"subscribed/market_stats","channel":"market_stats:1","timestamp":%d,`+
+			`"market_stats":{"market_id":1,"mark_price":"3024.5"}}`, lagging)}
+	}
+	var events staleEvents
+	server.config.Staleness = StalenessConfig{MaxLag: time.Second}
+	server.config.OnStaleness = events.add
+	s := NewLighterWebsocketPublicService(server.config)
+	if err := s.Start(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	received := make(chan LighterMarketStatsResponse, 1)
+	_, err := s.SubscribeMarketStats(LighterMarketStatsParamKey{MarketId: 1}, func(response LighterMarketStatsResponse) error {
+		received <- response
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case response := <-received:
+		if response.Timestamp != lagging || !response.IsSnapshot {
+			t.Errorf("expected the snapshot at the server time %d, got %+v", lagging, response)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the stats")
+	}
+	waitFor(t, "the lagging event", func() bool { return slices.Equal(events.get(), []string{"lagging"}) })
+}
@@ -0,0 +1,225 @@
+package client
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// wsConnCounter gives every connection of every WSClient a distinct id in recordings
+var wsConnCounter atomic.Uint64
+
+// WSRecord is a single frame of a recording
+type WSRecord struct {
+	// RecvTime is when the frame was read, in unix nanoseconds
+	RecvTime int64           `json:"ts"`
+	ConnId   uint64          `json:"conn"`
+	Channel  string          `json:"channel,omitempty"`
+	Frame    json.RawMessage `json:"frame"`
+}
+
+// WSRecorder writes every frame received by the WSClients using it to a gzip compressed JSON lines log.
+// Set it in WSConfig.Recorder.
+type WSRecorder struct {
+	mu     sync.Mutex
+	file   io.Closer
+	gz     *gzip.Writer
+	buf    *bufio.Writer
+	err    error
+	closed bool
+}
+
+// NewWSRecorder records to w. Close the recorder to flush the compressed stream, w is not closed.
+func NewWSRecorder(w io.Writer) *WSRecorder {
+	gz := gzip.NewWriter(w)
+	return &WSRecorder{
+		gz:  gz,
+		buf: bufio.NewWriterSize(gz, 64*1024),
+	}
+}
+
+// CreateWSRecording creates the file at path and records to it
+func CreateWSRecording(path string) (*WSRecorder, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	r := NewWSRecorder(f)
+	r.file = f
+	return r, nil
+}
+
+// Record appends a frame. Errors are kept and returned by Close, so recording never disturbs the feed.
+func (r *WSRecorder) Record(connId uint64, recvTime time.Time, data []byte) {
+	rec := WSRecord{
+		RecvTime: recvTime.UnixNano(),
+		ConnId:   connId,
+		Frame:    json.RawMessage(data),
+	}
+	peeked, err := PeekWSFrame(data)
+	if err == nil {
+		rec.Channel = peeked.Channel
+	}
+	line, err := json.Marshal(rec)
+	if err != nil {
+		// not JSON, keep it as a string
+		rec.Frame, _ = json.Marshal(string(data))
+		line, err = json.Marshal(rec)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed || r.err != nil {
+		return
+	}
+	if err != nil {
+		r.err = err
+		return
+	}
+	line = append(line, '\n')
+	if _, err := r.buf.Write(line); err != nil {
+		r.err = err
+	}
+}
+
+// Flush writes the buffered frames to the underlying writer, so they survive a crash
+func (r *WSRecorder) Flush() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil || r.closed {
+		return r.err
+	}
+	if err := r.buf.Flush(); err != nil {
+		r.err = err
+		return err
+	}
+	if err := r.gz.Flush(); err != nil {
+		r.err = err
+	}
+	return r.err
+}
+
+// Close flushes & terminates the log. It returns the first error met while recording.
+func (r *WSRecorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return r.err
+	}
+	r.closed = true
+
+	errs := []error{r.err, r.buf.Flush(), r.gz.Close()}
+	if r.file != nil {
+		errs = append(errs, r.file.Close())
+	}
+	r.err = errors.Join(errs...)
+	return r.err
+}
+
+// WSReplay feeds a recording into the handlers of WSClients, the same way the frames were received.
+// Set it in WSConfig.Replay, then the clients don't dial but get the frames of the recording once Run is called.
+// Subscribe first, so that the handlers are in place when the frames arrive.
+type WSReplay struct {
+	// Speed is the replay speed relative to the recording, 1 is real time. 0 or less replays as fast as possible.
+	Speed float64
+
+	reader io.Reader
+	closer io.Closer
+
+	mu      sync.Mutex
+	clients []*WSClient
+}
+
+// NewWSReplay replays the recording read from r
+func NewWSReplay(r io.Reader) *WSReplay {
+	return &WSReplay{Speed: 1, reader: r}
+}
+
+// OpenWSReplay replays the recording at path
+func OpenWSReplay(path string) (*WSReplay, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	replay := NewWSReplay(f)
+	replay.closer = f
+	return replay, nil
+}
+
+func (r *WSReplay) attach(ws *WSClient) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, c := range r.clients {
+		if c == ws {
+			return
+		}
+	}
+	r.clients = append(r.clients, ws)
+}
+
+// Run feeds every frame to the connected clients, in order, and returns at the end of the recording.
+// Every client gets every frame, the handlers ignore the ones they didn't subscribe to. The ConnId of the records
+// is not used: the frames of several recorded connections are all replayed on every client.
+func (r *WSReplay) Run(ctx context.Context) error {
+	if r.closer != nil {
+		defer r.closer.Close()
+	}
+	gz, err := gzip.NewReader(r.reader)
+	if err != nil {
+		return fmt.Errorf("invalid recording: %w", err)
+	}
+	defer gz.Close()
+
+	dec := json.NewDecoder(bufio.NewReaderSize(gz, 64*1024))
+	var start time.Time
+	var first int64
+	for {
+		var rec WSRecord
+		if err := dec.Decode(&rec); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("invalid recording: %w", err)
+		}
+
+		if r.Speed > 0 {
+			if start.IsZero() {
+				start, first = time.Now(), rec.RecvTime
+			}
+			due := start.Add(time.Duration(float64(rec.RecvTime-first) / r.Speed))
+			if wait := time.Until(due); wait > 0 {
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-time.After(wait):
+				}
+			}
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		data := []byte(rec.Frame)
+		var text string
+		if len(data) > 0 && data[0] == '"' && json.Unmarshal(data, &text) == nil {
+			data = []byte(text)
+		}
+
+		r.mu.Lock()
+		clients := r.clients
+		r.mu.Unlock()
+		for _, ws := range clients {
+			if ws.IsConnected() {
+				ws.handleMessage(data)
+			}
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package client
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"reflect"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWSRecorderRecord(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		channel string
+		frame   string
+	}{
+		{"frame", `{"type":"update/trade","channel":"trade:1","trades":[]}`, "trade:1", `{"type":"update/trade","channel":"trade:1","trades":[]}`},
+		{"no channel", `{"type":"ping"}`, "", `{"type":"ping"}`},
+		{"not JSON", `connected`, "", `"connected"`},
+		{"invalid JSON", `{"channel":"trade:1","trades":[}`, "", `"{\"channel\":\"trade:1\",\"trades\":[}"`},
+	}
+
+	var buf bytes.Buffer
+	r := NewWSRecorder(&buf)
+	for i, tt := range tests {
+		r.Record(uint64(i), time.Unix(0, int64(i)), []byte(tt.data))
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	gz, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dec := json.NewDecoder(gz)
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var rec WSRecord
+			if err := dec.Decode(&rec); err != nil {
+				t.Fatal(err)
+			}
+			if rec.ConnId != uint64(i) || rec.RecvTime != int64(i) {
+				t.Errorf("expected the record %d, got conn %d at %d", i, rec.ConnId, rec.RecvTime)
+			}
+			if rec.Channel != tt.channel {
+				t.Errorf("expected the channel %q, got %q", tt.channel, rec.Channel)
+			}
+			if string(rec.Frame) != tt.frame {
+				t.Errorf("expected the frame %s, got %s", tt.frame, rec.Frame)
+			}
+		})
+	}
+}
+
+// streamed is what the callbacks of a public service received
+type streamed struct {
+	mu     sync.Mutex
+	trades []LighterTradesResponse
+	books  []LighterOrderBookResponse
+}
+
+func (s *streamed) subscribe(t *testing.T, service *LighterWebsocketPublicService) {
+	t.Helper()
+	_, err := service.SubscribeTrades(LighterTradesParamKey{MarketId: 1}, func(trade LighterTradesResponse) error {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.trades = append(s.trades, trade)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = service.SubscribeOrderBook(LighterOrderBookParamKey{MarketId: 1}, func(book LighterOrderBookResponse) error {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.books = append(s.books, book)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func (s *streamed) count() (int, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.trades), len(s.books)
+}
+
+func TestWSRecordReplay(t *testing.T) {
+	frames := []string{
+		`{"type":"subscribed/trade","channel":"trade:1","trades":[{"trade_id":2,"price":"3024.65","size":"0.5","is_maker_ask":true,"timestamp":1760000000000},{"trade_id":1,"price":"3024.60","size":"1","timestamp":1759999999000}]}`,
+		string(orderBookMessage(MessageTypeOrderBookSubscribed, 3)),
+		// other markets & channels are recorded but not delivered
+		`{"type":"update/trade","channel":"trade:2","trades":[{"trade_id":3,"price":"1","size":"1"}]}`,
+		`{"type":"update/order_book","channel":"order_book:1","offset":4022,"order_book":{"code":0,"asks":[{"price":"3024.66","size":"0"}],"bids":[{"price":"3024.64","size":"2.5"}],"offset":4022},"timestamp":1760000000100}`,
+		`{"type":"update/trade","channel":"trade:1","trades":[{"trade_id":4,"price":"3024.66","size":"0.25","timestamp":1760000000200}]}`,
+	}
+
+	// record a live session
+	release := make(chan struct{})
+	close(release)
+	server := newTestWSServer(t, release)
+	var recording bytes.Buffer
+	recorder := NewWSRecorder(&recording)
+	server.config.Recorder = recorder
+	live := NewLighterWebsocketPublicService(server.config)
+	if err := live.Start(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+	var recorded streamed
+	recorded.subscribe(t, live)
+	server.send(t, frames...)
+	waitFor(t, "live callbacks", func() bool {
+		trades, books := recorded.count()
+		return trades == 3 && books == 2
+	})
+	if err := live.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := recorder.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// replay it without a server
+	config := DefaultWSConfig()
+	config.Logger = DiscardLogger()
+	config.Replay = NewWSReplay(&recording)
+	config.Replay.Speed = 0
+	replayed := NewLighterWebsocketPublicService(config)
+	if err := replayed.Start(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+	defer replayed.Close()
+	var got streamed
+	got.subscribe(t, replayed)
+	if err := config.Replay.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "replayed callbacks", func() bool {
+		trades, books := got.count()
+		return trades == 3 && books == 2
+	})
+
+	got.mu.Lock()
+	defer got.mu.Unlock()
+	if ids := []int64{got.trades[0].TradeId, got.trades[1].TradeId, got.trades[2].TradeId}; !slices.Equal(ids, []int64{1, 2, 4}) {
+		t.Errorf("expected the trades 1, 2 & 4 in order, got %v", ids)
+	}
+	if !reflect.DeepEqual(got.trades, recorded.trades) {
+		t.Errorf("expected the replayed trades\n%+v\nto be the live ones\n%+v", got.trades, recorded.trades)
+	}
+	if !got.books[0].IsSnapshot || len(got.books[0].Asks) != 3 || got.books[1].IsSnapshot || len(got.books[1].Bids) != 1 {
+		t.Errorf("expected a snapshot of 3 levels per side then an update, got %+v", got.books)
+	}
+	if !reflect.DeepEqual(got.books, recorded.books) {
+		t.Errorf("expected the replayed order books\n%+v\nto be the live ones\n%+v", got.books, recorded.books)
+	}
+}
@@ -0,0 +1,250 @@
+package client
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// StalenessConfig sets when a subscription is stale. A socket whose pings flow can still stop sending the messages
+// of a channel, so each subscription tracks its own freshness. The zero value doesn't track anything.
+type StalenessConfig struct {
+	// MaxAge is the longest time without a message of the subscription, 0 for no limit
+	MaxAge time.Duration
+	// MaxLag is the longest delay between the server timestamp of a message and its reception, 0 for no limit.
+	// It includes the offset between the local & server clocks. Account messages have no timestamp.
+	MaxLag time.Duration
+}
+
+func (c StalenessConfig) enabled() bool {
+	return c.MaxAge > 0 || c.MaxLag > 0
+}
+
+// stalenessConfig returns the config of a subscription: its own if set, else the one of the connection
+func (c *WSConfig) stalenessConfig(override *StalenessConfig) StalenessConfig {
+	if override != nil {
+		return *override
+	}
+	return c.Staleness
+}
+
+// StaleReason is why a subscription is stale
+type StaleReason int
+
+const (
+	// StaleQuiet is a subscription without messages for StalenessConfig.MaxAge
+	StaleQuiet StaleReason = iota + 1
+	// StaleLagging is a subscription whose messages are older than StalenessConfig.MaxLag on reception
+	StaleLagging
+)
+
+func (r StaleReason) String() string {
+	switch r {
+	case StaleQuiet:
+		return "quiet"
+	case StaleLagging:
+		return "lagging"
+	}
+	return fmt.Sprintf("StaleReason(%d)", int(r))
+}
+
+// StalenessEvent reports a subscription going stale or recovering, see WSConfig.OnStaleness. The reasons are tracked
+// apart: a subscription can be quiet & lagging at once, and recovers from each with its own event.
+type StalenessEvent struct {
+	// Channel is the channel of the subscription, e.g. "order_book:1"
+	Channel string
+	// Stale is false when the subscription recovers
+	Stale bool
+	// Reason is why the subscription is or was stale
+	Reason StaleReason
+	// LastMessage is the reception time of the last message, or the start of the subscription before the first one
+	LastMessage time.Time
+	// Lag is the delay of the last message with a server timestamp
+	Lag time.Duration
+}
+
+// freshness tracks the messages of a subscription and reports when they stop or lag
+type freshness struct {
+	channel string
+	config  StalenessConfig
+	onEvent func(StalenessEvent)
+	done    chan struct{}
+
+	mu      sync.Mutex
+	last    time.Time
+	lag     time.Duration
+	quiet   bool
+	lagging bool
+	closed  bool
+	// pending are the events to report in the order of their transitions, by one caller at a time
+	pending   []staleReport
+	reporting bool
+}
+
+// newFreshness starts tracking a subscription, nil if config doesn't track anything. A subscription without any
+// message for MaxAge after it starts is stale.
+func newFreshness(channel string, config StalenessConfig, onEvent func(StalenessEvent)) *freshness {
+	if !config.enabled() {
+		return nil
+	}
+	f := &freshness{
+		channel: channel,
+		config:  config,
+		onEvent: onEvent,
+		done:    make(chan struct{}),
+		last:    time.Now(),
+	}
+	if config.MaxAge > 0 {
+		go f.watch()
+	}
+	return f
+}
+
+// observe records a message received now, serverTs is its server timestamp or 0.
+// A message without timestamp ends the quiet period but doesn't tell whether the subscription still lags.
+func (f *freshness) observe(serverTs int64) {
+	if f == nil {
+		return
+	}
+	now := time.Now()
+
+	f.mu.Lock()
+	f.last = now
+	var reports []staleReport
+	if report, changed := f.transition(StaleQuiet, false); changed {
+		reports = append(reports, report)
+	}
+	if serverTs > 0 {
+		f.lag = now.Sub(serverTime(serverTs))
+		lagging := f.config.MaxLag > 0 && f.lag > f.config.MaxLag
+		if report, changed := f.transition(StaleLagging, lagging); changed {
+			reports = append(reports, report)
+		}
+	}
+	f.report(reports...)
+}
+
+// watch reports the subscription as quiet once no message arrived for MaxAge, it checks four times per MaxAge
+func (f *freshness) watch() {
+	ticker := time.NewTicker(max(f.config.MaxAge/4, time.Millisecond))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-f.done:
+			return
+		case now := <-ticker.C:
+			f.mu.Lock()
+			if now.Sub(f.last) <= f.config.MaxAge {
+				f.mu.Unlock()
+				continue
+			}
+			if report, changed := f.transition(StaleQuiet, true); changed {
+				f.report(report)
+			} else {
+				f.mu.Unlock()
+			}
+		}
+	}
+}
+
+// staleReport is an event to report, with whether the subscription is stale for any reason after it.
+// A closing report only clears the metric.
+type staleReport struct {
+	event   StalenessEvent
+	stale   bool
+	closing bool
+}
+
+// transition sets whether the subscription is stale for reason, and returns the event to report if it changed.
+// Must be called with f.mu held.
+func (f *freshness) transition(reason StaleReason, stale bool) (staleReport, bool) {
+	state := &f.quiet
+	if reason == StaleLagging {
+		state = &f.lagging
+	}
+	if f.closed || *state == stale {
+		return staleReport{}, false
+	}
+	*state = stale
+	return staleReport{
+		event: StalenessEvent{
+			Channel:     f.channel,
+			Stale:       stale,
+			Reason:      reason,
+			LastMessage: f.last,
+			Lag:         f.lag,
+		},
+		stale: f.quiet || f.lagging,
+	}, true
+}
+
+// report queues the events and releases f.mu, which must be held. The caller finding no report in progress passes
+// the queued events on in order, outside of f.mu, the others return once theirs are queued.
+// The metric tells whether the subscription is stale for any reason.
+func (f *freshness) report(reports ...staleReport) {
+	f.pending = append(f.pending, reports...)
+	if f.reporting || len(f.pending) == 0 {
+		f.mu.Unlock()
+		return
+	}
+	f.reporting = true
+	for len(f.pending) > 0 {
+		reports := f.pending
+		f.pending = nil
+		f.mu.Unlock()
+
+		for _, report := range reports {
+			metrics().WSStale(f.channel, report.stale)
+			if !report.closing && f.onEvent != nil {
+				f.onEvent(report.event)
+			}
+		}
+		f.mu.Lock()
+	}
+	f.reporting = false
+	f.mu.Unlock()
+}
+
+// close stops the tracking, a stale subscription isn't reported as recovered
+func (f *freshness) close() {
+	if f == nil {
+		return
+	}
+	f.mu.Lock()
+	if f.closed {
+		f.mu.Unlock()
+		return
+	}
+	f.closed = true
+	close(f.done)
+	if !f.quiet && !f.lagging {
+		f.mu.Unlock()
+		return
+	}
+	// after the events queued, which would set the metric again
+	f.report(staleReport{closing: true})
+}
+
+// serverTime converts a timestamp of Lighter, in milliseconds or seconds
+func serverTime(ts int64) time.Time {
+	if ts < 1e12 {
+		return time.Unix(ts, 0)
+	}
+	return time.UnixMilli(ts)
+}
+
+// freshness starts tracking a subscription of the pool, with the events logged & passed to WSConfig.OnStaleness
+func (p *WSPool) freshness(channel string, override *StalenessConfig) *freshness {
+	return newFreshness(channel, p.config.stalenessConfig(override), func(event StalenessEvent) {
+		if event.Stale {
+			p.logger().Warn("Subscription stale", LogKeyChannel, event.Channel, "reason", event.Reason,
+				"last_message", event.LastMessage, "lag", event.Lag)
+		} else {
+			p.logger().Info("Subscription recovered", LogKeyChannel, event.Channel, "reason", event.Reason)
+		}
+		if p.config.OnStaleness != nil {
+			p.config.OnStaleness(event)
+		}
+	})
+}
@@ -0,0 +1,146 @@
+package client
+
+import (
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// staleEvents records the staleness events as "quiet", "lagging", "quiet recovered"...
+type staleEvents struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (e *staleEvents) add(event StalenessEvent) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	s := event.Reason.String()
+	if !event.Stale {
+		s += " recovered"
+	}
+	e.events = append(e.events, s)
+}
+
+func (e *staleEvents) get() []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return slices.Clone(e.events)
+}
+
+func TestFreshnessLagging(t *testing.T) {
+	lagging := time.Now().Add(-time.Minute).UnixMilli()
+	tests := []struct {
+		name string
+		// server timestamps of the messages, 0 for none & -1 for a lagging one
+		observed []int64
+		events   []string
+	}{
+		{"fresh", []int64{0, 1}, nil},
+		{"lagging", []int64{-1, -1}, []string{"lagging"}},
+		{"recovered", []int64{-1, 1}, []string{"lagging", "lagging recovered"}},
+		// a message without timestamp doesn't tell whether the subscription still lags
+		{"no timestamp", []int64{-1, 0, 0}, []string{"lagging"}},
+		{"no timestamp then recovered", []int64{-1, 0, 1, -1}, []string{"lagging", "lagging recovered", "lagging"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var events staleEvents
+			f := newFreshness("trade:1", StalenessConfig{MaxLag: time.Second}, events.add)
+			defer f.close()
+			for _, ts := range tt.observed {
+				switch ts {
+				case -1:
+					ts = lagging
+				case 1:
+					ts = time.Now().UnixMilli()
+				}
+				f.observe(ts)
+			}
+			if got := events.get(); !slices.Equal(got, tt.events) {
+				t.Errorf("expected the events %q, got %q", tt.events, got)
+			}
+		})
+	}
+}
+
+func TestFreshnessQuietWhileLagging(t *testing.T) {
+	var events staleEvents
+	f := newFreshness("trade:1", StalenessConfig{MaxAge: 20 * time.Millisecond, MaxLag: time.Second}, events.add)
+	defer f.close()
+
+	f.observe(time.Now().Add(-time.Minute).UnixMilli())
+	waitFor(t, "the quiet subscription", func() bool { return len(events.get()) == 2 })
+	// a new message ends the quiet period, not the lag
+	f.observe(0)
+	f.observe(time.Now().UnixMilli())
+
+	wanted := []string{"lagging", "quiet", "quiet recovered", "lagging recovered"}
+	if got := events.get(); !slices.Equal(got, wanted) {
+		t.Errorf("expected the events %q, got %q", wanted, got)
+	}
+}
+
+func TestFreshnessClosed(t *testing.T) {
+	var events staleEvents
+	f := newFreshness("trade:1", StalenessConfig{MaxAge: time.Millisecond}, events.add)
+	waitFor(t, "the quiet subscription", func() bool { return len(events.get()) == 1 })
+	f.close()
+	f.observe(0)
+	if got := events.get(); !slices.Equal(got, []string{"quiet"}) {
+		t.Errorf("expected no event once closed, got %q", got)
+	}
+	if f := newFreshness("trade:1", StalenessConfig{}, nil); f != nil {
+		t.Errorf("expected no tracking without limits, got %+v", f)
+	}
+}
+
+func TestFreshnessReportsInOrder(t *testing.T) {
+	var events staleEvents
+	entered, release := make(chan struct{}), make(chan struct{})
+	var once sync.Once
+	f := newFreshness("trade:1", StalenessConfig{MaxLag: time.Second}, func(event StalenessEvent) {
+		// the first event is slow to handle
+		once.Do(func() {
+			close(entered)
+			<-release
+		})
+		events.add(event)
+	})
+	defer f.close()
+
+	lagged := make(chan struct{})
+	go func() {
+		defer close(lagged)
+		f.observe(time.Now().Add(-time.Minute).UnixMilli())
+	}()
+	<-entered
+	// the recovery happens while the lagging event is handled, it's queued and reported after it
+	f.observe(time.Now().UnixMilli())
+	if got := events.get(); len(got) != 0 {
+		t.Errorf("expected the recovery to wait for the lagging event, got %v", got)
+	}
+	close(release)
+	<-lagged
+
+	if got := events.get(); !slices.Equal(got, []string{"lagging", "lagging recovered"}) {
+		t.Errorf("expected the events in the order of the transitions, got %v", got)
+	}
+}
+
+func TestFreshnessClosedOnEvent(t *testing.T) {
+	var f *freshness
+	var events staleEvents
+	f = newFreshness("trade:1", StalenessConfig{MaxLag: time.Second}, func(event StalenessEvent) {
+		events.add(event)
+		// e.g. unsubscribing a lagging subscription
+		f.close()
+	})
+
+	f.observe(time.Now().Add(-time.Minute).UnixMilli())
+	f.observe(time.Now().UnixMilli())
+	if got := events.get(); !slices.Equal(got, []string{"lagging"}) {
+		t.Errorf("expected no event once closed, got %v", got)
+	}
+}
@@ -0,0 +1,214 @@
+package client
+
+import (
+	"errors"
+	"iter"
+	"sync"
+)
+
+var (
+	// ErrStreamClosed ends the streams closed with Stream.Close or by the Close of their service
+	ErrStreamClosed = errors.New("stream closed")
+	// ErrConnectionLost ends the streams of a service whose WebSocket is disconnected
+	ErrConnectionLost = errors.New("WebSocket connection lost")
+)
+
+// Stream is a subscription read from a channel instead of a callback:
+//
+//	for {
+//		select {
+//		case book, ok := <-stream.C():
+//			if !ok {
+//				return stream.Err()
+//			}
+//			...
+//		case <-ctx.Done():
+//			return stream.Close()
+//		}
+//	}
+//
+// The channel is unbuffered, the messages wait in the queue of the subscription, see DispatchConfig.
+type Stream[T any] struct {
+	c    chan T
+	done chan struct{}
+	once sync.Once
+
+	mu     sync.RWMutex
+	err    error
+	closed bool
+
+	// unsubMu isn't mu: a send holds mu until the message is read
+	unsubMu  sync.Mutex
+	unsub    func() error
+	ended    bool
+	registry *streamRegistry
+}
+
+func newStream[T any](registry *streamRegistry) *Stream[T] {
+	return &Stream[T]{
+		c:        make(chan T),
+		done:     make(chan struct{}),
+		registry: registry,
+	}
+}
+
+// C returns the channel of the messages, closed when the stream ends
+func (s *Stream[T]) C() <-chan T {
+	return s.c
+}
+
+// Err returns why the stream ended: nil while it runs, then ErrStreamClosed or an error matching ErrConnectionLost
+func (s *Stream[T]) Err() error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.err
+}
+
+// All returns the messages as an iterator, which ends with the stream. Breaking the loop doesn't close the stream.
+func (s *Stream[T]) All() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for msg := range s.c {
+			if !yield(msg) {
+				return
+			}
+		}
+	}
+}
+
+// Close unsubscribes and ends the stream with ErrStreamClosed
+func (s *Stream[T]) Close() error {
+	return s.end(ErrStreamClosed)
+}
+
+func (s *Stream[T]) end(err error) error {
+	if s.registry != nil {
+		s.registry.remove(s)
+	}
+	// terminated first, so that Err is set once a subscription in flight sees the stream ended
+	s.terminate(err)
+	s.unsubMu.Lock()
+	unsub := s.unsub
+	s.unsub, s.ended = nil, true
+	s.unsubMu.Unlock()
+
+	if unsub != nil {
+		return unsub()
+	}
+	return nil
+}
+
+// setUnsub sets the unsubscribe function once subscribed, false if the stream ended meanwhile
+func (s *Stream[T]) setUnsub(unsub func() error) bool {
+	s.unsubMu.Lock()
+	defer s.unsubMu.Unlock()
+	if s.ended {
+		return false
+	}
+	s.unsub = unsub
+	return true
+}
+
+// send is the callback of the subscription, it waits for the reader unless the stream ended
+func (s *Stream[T]) send(msg T) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		return nil
+	}
+	select {
+	case s.c <- msg:
+	case <-s.done:
+	}
+	return nil
+}
+
+func (s *Stream[T]) terminate(err error) {
+	s.once.Do(func() {
+		// done releases a pending send, which holds the read lock
+		close(s.done)
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.err = err
+		s.closed = true
+		close(s.c)
+	})
+}
+
+// streamEnder is a Stream of any type
+type streamEnder interface {
+	end(err error) error
+}
+
+// streamRegistry holds the streams of a service, to end them when the connection is lost
+type streamRegistry struct {
+	mu      sync.Mutex
+	streams map[streamEnder]struct{}
+}
+
+func (r *streamRegistry) add(s streamEnder) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.streams == nil {
+		r.streams = make(map[streamEnder]struct{})
+	}
+	r.streams[s] = struct{}{}
+}
+
+func (r *streamRegistry) remove(s streamEnder) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.streams, s)
+}
+
+// endAll ends every stream with err
+func (r *streamRegistry) endAll(err error) {
+	r.mu.Lock()
+	streams := r.streams
+	r.streams = nil
+	r.mu.Unlock()
+
+	for s := range streams {
+		s.end(err)
+	}
+}
+
+// NewStream turns a callback subscription into a Stream, for the implementations of the service interfaces which
+// don't queue their callbacks. The messages wait in a queue set by config, whose conflation isn't supported.
+// Closing the stream calls the unsubscribe function returned by subscribe.
+func NewStream[T any](config DispatchConfig, subscribe func(callback func(T) error) (func() error, error)) (*Stream[T], error) {
+	if config.QueueSize <= 0 {
+		config.QueueSize = DefaultQueueSize
+	}
+	s := newStream[T](nil)
+	queue := newDispatcher("stream", config, s.send, nil).start()
+	unsub, err := subscribe(func(msg T) error {
+		queue.push(msg)
+		return nil
+	})
+	if err != nil {
+		queue.close()
+		return nil, err
+	}
+	s.setUnsub(func() error {
+		queue.close()
+		return unsub()
+	})
+	return s, nil
+}
+
+// subscribeStream subscribes a new stream with the subscribe function of a service, whose callbacks are queued.
+// The stream is registered first, so that a connection lost while subscribing ends it.
+func subscribeStream[T any](registry *streamRegistry, subscribe func(callback func(T) error) (func() error, error)) (*Stream[T], error) {
+	s := newStream[T](registry)
+	registry.add(s)
+	unsub, err := subscribe(s.send)
+	if err != nil {
+		s.end(err)
+		return nil, err
+	}
+	if !s.setUnsub(unsub) {
+		unsub()
+		return nil, s.Err()
+	}
+	return s, nil
+}
@@ -0,0 +1,207 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeSubscription stands for the subscription of a service: it passes the messages pushed with deliver to the
+// callback from its own goroutine, like the queue of a subscription
+type fakeSubscription[T any] struct {
+	callback func(T) error
+	msgs     chan T
+	unsubs   atomic.Int32
+	// subscribing, if set, runs before subscribe returns
+	subscribing func()
+}
+
+func (f *fakeSubscription[T]) subscribe(callback func(T) error) (func() error, error) {
+	f.callback = callback
+	f.msgs = make(chan T, 16)
+	go func() {
+		for msg := range f.msgs {
+			callback(msg)
+		}
+	}()
+	if f.subscribing != nil {
+		f.subscribing()
+	}
+	return func() error {
+		if f.unsubs.Add(1) == 1 {
+			close(f.msgs)
+		}
+		return nil
+	}, nil
+}
+
+func receive[T any](t *testing.T, s *Stream[T]) (T, bool) {
+	t.Helper()
+	select {
+	case msg, ok := <-s.C():
+		return msg, ok
+	case <-time.After(time.Second):
+		t.Fatal("no message received")
+	}
+	var zero T
+	return zero, false
+}
+
+func TestStreamClose(t *testing.T) {
+	var registry streamRegistry
+	var sub fakeSubscription[int]
+	s, err := subscribeStream(&registry, sub.subscribe)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub.msgs <- 1
+	sub.msgs <- 2
+	for _, want := range []int{1, 2} {
+		if msg, ok := receive(t, s); !ok || msg != want {
+			t.Fatalf("expected %d, got %d %v", want, msg, ok)
+		}
+	}
+	if err := s.Err(); err != nil {
+		t.Errorf("expected no error while the stream runs, got %v", err)
+	}
+
+	// a message pending when the stream is closed doesn't block the subscription
+	sub.msgs <- 3
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	for range s.C() {
+	}
+	if !errors.Is(s.Err(), ErrStreamClosed) || sub.unsubs.Load() != 1 {
+		t.Errorf("expected a closed stream unsubscribed once, got %v and %d unsubscriptions", s.Err(), sub.unsubs.Load())
+	}
+	if err := s.Close(); err != nil || sub.unsubs.Load() != 1 {
+		t.Errorf("expected a second Close to do nothing, got %v and %d unsubscriptions", err, sub.unsubs.Load())
+	}
+	if len(registry.streams) != 0 {
+		t.Errorf("expected the stream removed from the registry, got %d", len(registry.streams))
+	}
+}
+
+func TestStreamConnectionLost(t *testing.T) {
+	var registry streamRegistry
+	var sub1, sub2 fakeSubscription[int]
+	s1, err := subscribeStream(&registry, sub1.subscribe)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s2, err := subscribeStream(&registry, sub2.subscribe)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	registry.endAll(fmt.Errorf("%w: read: EOF", ErrConnectionLost))
+	for _, s := range []*Stream[int]{s1, s2} {
+		if _, ok := receive(t, s); ok {
+			t.Error("expected the stream channel closed")
+		}
+		if err := s.Err(); !errors.Is(err, ErrConnectionLost) {
+			t.Errorf("expected the stream ended by the connection loss, got %v", err)
+		}
+	}
+	if sub1.unsubs.Load() != 1 || sub2.unsubs.Load() != 1 {
+		t.Errorf("expected the subscriptions unsubscribed, got %d & %d", sub1.unsubs.Load(), sub2.unsubs.Load())
+	}
+	// closing the ended stream keeps the reason
+	s1.Close()
+	if !errors.Is(s1.Err(), ErrConnectionLost) {
+		t.Errorf("expected Close to keep the reason, got %v", s1.Err())
+	}
+}
+
+func TestStreamLostWhileSubscribing(t *testing.T) {
+	var registry streamRegistry
+	sub := fakeSubscription[int]{subscribing: func() {
+		registry.endAll(ErrConnectionLost)
+	}}
+	s, err := subscribeStream(&registry, sub.subscribe)
+	if !errors.Is(err, ErrConnectionLost) || s != nil {
+		t.Fatalf("expected the subscription to fail with the connection loss, got %v", err)
+	}
+	if sub.unsubs.Load() != 1 {
+		t.Errorf("expected the subscription unsubscribed, got %d", sub.unsubs.Load())
+	}
+
+	failing := func(func(int) error) (func() error, error) { return nil, errors.New("subscribe failed") }
+	if _, err := subscribeStream(&registry, failing); err == nil {
+		t.Error("expected the error of the subscription")
+	}
+	if len(registry.streams) != 0 {
+		t.Errorf("expected no stream left in the registry, got %d", len(registry.streams))
+	}
+}
+
+func TestStreamAllBreak(t *testing.T) {
+	var registry streamRegistry
+	var sub fakeSubscription[int]
+	s, err := subscribeStream(&registry, sub.subscribe)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	for i := 1; i <= 3; i++ {
+		sub.msgs <- i
+	}
+
+	var got []int
+	for msg := range s.All() {
+		got = append(got, msg)
+		if len(got) == 2 {
+			break
+		}
+	}
+	if len(got) != 2 || got[0] != 1 || got[1] != 2 {
+		t.Errorf("expected 1 & 2, got %v", got)
+	}
+	// breaking the loop doesn't close the stream
+	if err := s.Err(); err != nil {
+		t.Errorf("expected the stream still running, got %v", err)
+	}
+	if msg, ok := receive(t, s); !ok || msg != 3 {
+		t.Errorf("expected the next message, got %d %v", msg, ok)
+	}
+
+	// the loop ends with the stream
+	received, done := make(chan int), make(chan struct{})
+	go func() {
+		defer close(done)
+		for msg := range s.All() {
+			received <- msg
+		}
+	}()
+	sub.msgs <- 4
+	if msg := <-received; msg != 4 {
+		t.Errorf("expected 4, got %d", msg)
+	}
+	s.Close()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("the iteration didn't end with the stream")
+	}
+}
+
+func TestNewStream(t *testing.T) {
+	var sub fakeSubscription[string]
+	s, err := NewStream(DispatchConfig{QueueSize: 2}, sub.subscribe)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub.msgs <- "a"
+	if msg, ok := receive(t, s); !ok || msg != "a" {
+		t.Errorf("expected a, got %q %v", msg, ok)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := receive(t, s); ok || !errors.Is(s.Err(), ErrStreamClosed) || sub.unsubs.Load() != 1 {
+		t.Errorf("expected a closed & unsubscribed stream, got %v and %d unsubscriptions", s.Err(), sub.unsubs.Load())
+	}
+}
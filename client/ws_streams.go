@@ -1,9 +1,5 @@
 package client
 
-import (
-	"log"
-)
-
 // Market Data Streams - Legacy methods removed
 // Use LighterWebsocketPublicService / LighterWebsocketPrivateService instead
 
@@ -25,7 +21,7 @@ func ConvertWSOrderBookToKubi(wsData *WSOrderBookUpdate, marketId uint8) (*Order
 	bids := make([]PriceLevel, 0, len(wsData.Bids))
 	for i, bid := range wsData.Bids {
 		if len(bid) < 2 {
-			log.Printf("[WSClient] Warning: bid[%d] has insufficient length %d, skipping", i, len(bid))
+			logger().Warn("Skipping malformed bid", LogKeyMarket, marketId, "index", i, "length", len(bid))
 			continue
 		}
 		bids = append(bids, PriceLevel{
@@ -37,7 +33,7 @@ func ConvertWSOrderBookToKubi(wsData *WSOrderBookUpdate, marketId uint8) (*Order
 	asks := make([]PriceLevel, 0, len(wsData.Asks))
 	for i, ask := range wsData.Asks {
 		if len(ask) < 2 {
-			log.Printf("[WSClient] Warning: ask[%d] has insufficient length %d, skipping", i, len(ask))
+			logger().Warn("Skipping malformed ask", LogKeyMarket, marketId, "index", i, "length", len(ask))
 			continue
 		}
 		asks = append(asks, PriceLevel{
@@ -2,19 +2,25 @@ package client
 
 import (
 	"context"
+	"log/slog"
 	"time"
+
+	"github.com/u20024804/lighter-ex/decimal"
 )
 
 // WebSocket message types
 type WSMessage struct {
-	Type string      `json:"type"`
-	Data interface{} `json:"data,omitempty"`
+	Type    string      `json:"type"`
+	Channel string      `json:"channel,omitempty"`
+	Data    interface{} `json:"data,omitempty"`
 }
 
 type WSSubscribeMessage struct {
 	Type    string `json:"type"`
 	Channel string `json:"channel"`
 	Symbol  string `json:"symbol,omitempty"`
+	// Auth authenticates the private channels of a connection shared with other accounts, see WSPool
+	Auth string `json:"auth,omitempty"`
 }
 
 type WSUnsubscribeMessage struct {
@@ -27,104 +33,213 @@ type WSUnsubscribeMessage struct {
 
 // Market data types
 type WSOrderBookUpdate struct {
-	Symbol    string     `json:"symbol"`
-	Bids      [][]string `json:"bids"`
-	Asks      [][]string `json:"asks"`
-	Timestamp int64      `json:"timestamp"`
+	Symbol    string              `json:"symbol"`
+	Bids      [][]decimal.Decimal `json:"bids"`
+	Asks      [][]decimal.Decimal `json:"asks"`
+	Timestamp int64               `json:"timestamp"`
 }
 
-// Note: WSTickerUpdate and WSTradeUpdate types removed because 
-// ticker and trade streams are not supported by Lighter WebSocket API
+// Note: WSTickerUpdate type removed because ticker streams are not supported by Lighter WebSocket API.
+// Trades of the trade channel are decoded as WSTrade
 
 // Account data types
+
 type WSAccountUpdate struct {
-	Account              int64                       `json:"account"`
-	Channel              string                      `json:"channel"`
-	Type                 string                      `json:"type"`
-	DailyTradesCount     int                         `json:"daily_trades_count"`
-	DailyVolume          float64                     `json:"daily_volume"`
-	MonthlyTradesCount   int                         `json:"monthly_trades_count"`
-	MonthlyVolume        float64                     `json:"monthly_volume"`
-	TotalTradesCount     int                         `json:"total_trades_count"`
-	TotalVolume          float64                     `json:"total_volume"`
-	WeeklyTradesCount    int                         `json:"weekly_trades_count"`
-	WeeklyVolume         float64                     `json:"weekly_volume"`
-	Positions            map[string]*WSPosition      `json:"positions"`
-	Shares               []WSShare                   `json:"shares"`
-	Trades               map[string][]WSTrade        `json:"trades"`
-	FundingHistories     map[string]interface{}      `json:"funding_histories"`
+	Account            int64                          `json:"account"`
+	Channel            string                         `json:"channel"`
+	Type               string                         `json:"type"`
+	DailyTradesCount   int                            `json:"daily_trades_count"`
+	DailyVolume        decimal.Decimal                `json:"daily_volume"`
+	MonthlyTradesCount int                            `json:"monthly_trades_count"`
+	MonthlyVolume      decimal.Decimal                `json:"monthly_volume"`
+	TotalTradesCount   int                            `json:"total_trades_count"`
+	TotalVolume        decimal.Decimal                `json:"total_volume"`
+	WeeklyTradesCount  int                            `json:"weekly_trades_count"`
+	WeeklyVolume       decimal.Decimal                `json:"weekly_volume"`
+	Positions          map[string]*WSPosition         `json:"positions"`
+	Shares             []WSShare                      `json:"shares"`
+	Trades             map[string][]WSTrade           `json:"trades"`
+	FundingHistories   map[string][]WSPositionFunding `json:"funding_histories"`
 }
 
 type WSPosition struct {
-	MarketId                 uint8   `json:"market_id"`
-	Symbol                   string  `json:"symbol"`
-	InitialMarginFraction    string  `json:"initial_margin_fraction"`
-	OpenOrderCount           int     `json:"open_order_count"`
-	PendingOrderCount        int     `json:"pending_order_count"`
-	PositionTiedOrderCount   int     `json:"position_tied_order_count"`
-	Sign                     int8    `json:"sign"`
-	Position                 string  `json:"position"`
-	AvgEntryPrice            string  `json:"avg_entry_price"`
-	PositionValue            string  `json:"position_value"`
-	UnrealizedPnl            string  `json:"unrealized_pnl"`
-	RealizedPnl              string  `json:"realized_pnl"`
-	LiquidationPrice         string  `json:"liquidation_price"`
-	MarginMode               int     `json:"margin_mode"`
-	AllocatedMargin          string  `json:"allocated_margin"`
+	MarketId               uint8           `json:"market_id"`
+	Symbol                 string          `json:"symbol"`
+	InitialMarginFraction  decimal.Decimal `json:"initial_margin_fraction"`
+	OpenOrderCount         int             `json:"open_order_count"`
+	PendingOrderCount      int             `json:"pending_order_count"`
+	PositionTiedOrderCount int             `json:"position_tied_order_count"`
+	Sign                   int8            `json:"sign"`
+	Position               decimal.Decimal `json:"position"`
+	AvgEntryPrice          decimal.Decimal `json:"avg_entry_price"`
+	PositionValue          decimal.Decimal `json:"position_value"`
+	UnrealizedPnl          decimal.Decimal `json:"unrealized_pnl"`
+	RealizedPnl            decimal.Decimal `json:"realized_pnl"`
+	LiquidationPrice       decimal.Decimal `json:"liquidation_price"`
+	MarginMode             int             `json:"margin_mode"`
+	AllocatedMargin        decimal.Decimal `json:"allocated_margin"`
 }
 
 type WSShare struct {
-	PublicPoolIndex int64  `json:"public_pool_index"`
-	SharesAmount    int64  `json:"shares_amount"`
-	EntryUsdc       string `json:"entry_usdc"`
+	PublicPoolIndex int64           `json:"public_pool_index"`
+	SharesAmount    int64           `json:"shares_amount"`
+	EntryUsdc       decimal.Decimal `json:"entry_usdc"`
 }
 
 type WSTrade struct {
-	TradeId                               int64  `json:"trade_id"`
-	TxHash                                string `json:"tx_hash"`
-	Type                                  string `json:"type"`
-	MarketId                              int    `json:"market_id"`
-	Size                                  string `json:"size"`
-	Price                                 string `json:"price"`
-	UsdAmount                             string `json:"usd_amount"`
-	AskId                                 int64  `json:"ask_id"`
-	BidId                                 int64  `json:"bid_id"`
-	AskAccountId                          int64  `json:"ask_account_id"`
-	BidAccountId                          int64  `json:"bid_account_id"`
-	IsMakerAsk                            bool   `json:"is_maker_ask"`
-	BlockHeight                           int64  `json:"block_height"`
-	Timestamp                             int64  `json:"timestamp"`
-	TakerPositionSizeBefore              string `json:"taker_position_size_before"`
-	TakerEntryQuoteBefore                string `json:"taker_entry_quote_before"`
-	TakerInitialMarginFractionBefore     int    `json:"taker_initial_margin_fraction_before"`
-	MakerFee                             int    `json:"maker_fee"`
-	MakerPositionSizeBefore              string `json:"maker_position_size_before"`
-	MakerEntryQuoteBefore                string `json:"maker_entry_quote_before"`
-	MakerInitialMarginFractionBefore     int    `json:"maker_initial_margin_fraction_before"`
+	TradeId                          int64           `json:"trade_id"`
+	TxHash                           string          `json:"tx_hash"`
+	Type                             string          `json:"type"`
+	MarketId                         int             `json:"market_id"`
+	Size                             decimal.Decimal `json:"size"`
+	Price                            decimal.Decimal `json:"price"`
+	UsdAmount                        decimal.Decimal `json:"usd_amount"`
+	AskId                            int64           `json:"ask_id"`
+	BidId                            int64           `json:"bid_id"`
+	AskAccountId                     int64           `json:"ask_account_id"`
+	BidAccountId                     int64           `json:"bid_account_id"`
+	IsMakerAsk                       bool            `json:"is_maker_ask"`
+	BlockHeight                      int64           `json:"block_height"`
+	Timestamp                        int64           `json:"timestamp"`
+	TakerPositionSizeBefore          decimal.Decimal `json:"taker_position_size_before"`
+	TakerEntryQuoteBefore            decimal.Decimal `json:"taker_entry_quote_before"`
+	TakerInitialMarginFractionBefore int             `json:"taker_initial_margin_fraction_before"`
+	MakerFee                         int             `json:"maker_fee"`
+	MakerPositionSizeBefore          decimal.Decimal `json:"maker_position_size_before"`
+	MakerEntryQuoteBefore            decimal.Decimal `json:"maker_entry_quote_before"`
+	MakerInitialMarginFractionBefore int             `json:"maker_initial_margin_fraction_before"`
 }
 
 type WSOrderUpdate struct {
+	AccountIndex     int64           `json:"account_index"`
+	OrderId          string          `json:"order_id"`
+	ClientOrderIndex int64           `json:"client_order_index"`
+	MarketId         uint8           `json:"market_id"`
+	Status           string          `json:"status"`
+	BaseQuantity     decimal.Decimal `json:"base_quantity"`
+	FilledQuantity   decimal.Decimal `json:"filled_quantity"`
+	Price            decimal.Decimal `json:"price"`
+	IsAsk            uint8           `json:"is_ask"`
+	Timestamp        int64           `json:"timestamp"`
+}
+
+// WSPositionFunding is a funding payment of a position
+type WSPositionFunding struct {
+	Timestamp    int64           `json:"timestamp"`
+	MarketId     uint8           `json:"market_id"`
+	FundingId    int64           `json:"funding_id"`
+	Change       decimal.Decimal `json:"change"`
+	Rate         decimal.Decimal `json:"rate"`
+	PositionSize decimal.Decimal `json:"position_size"`
+	PositionSide string          `json:"position_side"`
+}
+
+// WSUserStatsValues are the stats of an account, or of its cross margin part
+type WSUserStatsValues struct {
+	Collateral       decimal.Decimal `json:"collateral"`
+	PortfolioValue   decimal.Decimal `json:"portfolio_value"`
+	Leverage         decimal.Decimal `json:"leverage"`
+	AvailableBalance decimal.Decimal `json:"available_balance"`
+	MarginUsage      decimal.Decimal `json:"margin_usage"`
+	BuyingPower      decimal.Decimal `json:"buying_power"`
+}
+
+// WSUserStats is the stats object of the user_stats messages
+type WSUserStats struct {
+	WSUserStatsValues
+	CrossStats *WSUserStatsValues `json:"cross_stats,omitempty"`
+	TotalStats *WSUserStatsValues `json:"total_stats,omitempty"`
+}
+
+// WSTransaction is a tx of the account_tx messages
+type WSTransaction struct {
+	Hash             string `json:"hash"`
+	Type             uint8  `json:"type"`
+	Info             string `json:"info"`       // the tx, as JSON
+	EventInfo        string `json:"event_info"` // the events of the tx, as JSON
+	Status           int    `json:"status"`
+	TransactionIndex int64  `json:"transaction_index"`
+	L1Address        string `json:"l1_address"`
 	AccountIndex     int64  `json:"account_index"`
-	OrderId          string `json:"order_id"`
-	ClientOrderIndex int64  `json:"client_order_index"`
-	MarketId         uint8  `json:"market_id"`
-	Status           string `json:"status"`
-	BaseQuantity     string `json:"base_quantity"`
-	FilledQuantity   string `json:"filled_quantity"`
-	Price            string `json:"price"`
-	IsAsk            uint8  `json:"is_ask"`
-	Timestamp        int64  `json:"timestamp"`
+	Nonce            int64  `json:"nonce"`
+	ExpireAt         int64  `json:"expire_at"`
+	BlockHeight      int64  `json:"block_height"`
+	QueuedAt         int64  `json:"queued_at"`
+	ExecutedAt       int64  `json:"executed_at"`
+	SequenceIndex    int64  `json:"sequence_index"`
+	ParentHash       string `json:"parent_hash"`
+}
+
+// WSPoolData is a pool_data message: the trades, orders, positions, shares & fundings of a public pool, by market
+type WSPoolData struct {
+	Account          int64                          `json:"account"`
+	Channel          string                         `json:"channel"`
+	Type             string                         `json:"type"`
+	Trades           map[string][]WSTrade           `json:"trades"`
+	Orders           map[string][]Order             `json:"orders"`
+	Positions        map[string]*WSPosition         `json:"positions"`
+	Shares           []WSShare                      `json:"shares"`
+	FundingHistories map[string][]WSPositionFunding `json:"funding_histories"`
+}
+
+// WSPoolInfo is the pool_info object of the pool_info messages
+type WSPoolInfo struct {
+	Status                int                 `json:"status"`
+	OperatorFee           decimal.Decimal     `json:"operator_fee"`
+	MinOperatorShareRate  decimal.Decimal     `json:"min_operator_share_rate"`
+	TotalShares           int64               `json:"total_shares"`
+	OperatorShares        int64               `json:"operator_shares"`
+	AnnualPercentageYield decimal.Decimal     `json:"annual_percentage_yield"`
+	DailyReturns          []WSPoolDailyReturn `json:"daily_returns"`
+	SharePrices           []WSPoolSharePrice  `json:"share_prices"`
+}
+
+type WSPoolDailyReturn struct {
+	Timestamp   int64           `json:"timestamp"`
+	DailyReturn decimal.Decimal `json:"daily_return"`
+}
+
+type WSPoolSharePrice struct {
+	Timestamp  int64           `json:"timestamp"`
+	SharePrice decimal.Decimal `json:"share_price"`
+}
+
+// WSMarketStats is the market_stats object of the market_stats messages
+type WSMarketStats struct {
+	MarketId              int             `json:"market_id"`
+	IndexPrice            decimal.Decimal `json:"index_price"`
+	MarkPrice             decimal.Decimal `json:"mark_price"`
+	OpenInterest          decimal.Decimal `json:"open_interest"`
+	LastTradePrice        decimal.Decimal `json:"last_trade_price"`
+	CurrentFundingRate    decimal.Decimal `json:"current_funding_rate"`
+	FundingRate           decimal.Decimal `json:"funding_rate"`
+	FundingTimestamp      int64           `json:"funding_timestamp"`
+	DailyBaseTokenVolume  decimal.Decimal `json:"daily_base_token_volume"`
+	DailyQuoteTokenVolume decimal.Decimal `json:"daily_quote_token_volume"`
+	DailyPriceLow         decimal.Decimal `json:"daily_price_low"`
+	DailyPriceHigh        decimal.Decimal `json:"daily_price_high"`
+	DailyPriceChange      decimal.Decimal `json:"daily_price_change"`
 }
 
 // Channel constants - based on Python implementation
-// Note: Only order_book and account_all are actually supported by Lighter WebSocket API
+// Note: ticker & markprice are not supported by Lighter WebSocket API, market_stats carries the mark price
 const (
-	ChannelOrderBook = "order_book"
-	ChannelAccount   = "account_all"
-	ChannelOrders    = "orders"
+	ChannelOrderBook   = "order_book"
+	ChannelAccount     = "account_all"
+	ChannelOrders      = "orders"
+	ChannelTrade       = "trade"
+	ChannelMarketStats = "market_stats"
+	ChannelHeight      = "height"
+
+	// Private channels, authenticated by the token of the subscription
+	ChannelAccountOrders    = "account_orders"
+	ChannelAccountAllOrders = "account_all_orders"
+	ChannelUserStats        = "user_stats"
+	ChannelAccountTx        = "account_tx"
+	ChannelPoolData         = "pool_data"
+	ChannelPoolInfo         = "pool_info"
 	// The following channels are not supported by Lighter WebSocket API:
 	// ChannelTicker    = "ticker"      // REMOVED - not supported
-	// ChannelTrades    = "trades"      // REMOVED - not supported  
 	// ChannelMarkPrice = "markprice"   // REMOVED - not supported
 )
 
@@ -139,12 +254,32 @@ const (
 	MessageTypeUnsubscribed = "unsubscribed"
 	
 	// Subscription confirmation messages
-	MessageTypeOrderBookSubscribed = "subscribed/order_book"
-	MessageTypeAccountSubscribed   = "subscribed/account_all"
+	MessageTypeOrderBookSubscribed   = "subscribed/order_book"
+	MessageTypeAccountSubscribed     = "subscribed/account_all"
+	MessageTypeTradeSubscribed       = "subscribed/trade"
+	MessageTypeMarketStatsSubscribed = "subscribed/market_stats"
+	MessageTypeHeightSubscribed      = "subscribed/height"
+
+	MessageTypeAccountOrdersSubscribed    = "subscribed/account_orders"
+	MessageTypeAccountAllOrdersSubscribed = "subscribed/account_all_orders"
+	MessageTypeUserStatsSubscribed        = "subscribed/user_stats"
+	MessageTypeAccountTxSubscribed        = "subscribed/account_tx"
+	MessageTypePoolDataSubscribed         = "subscribed/pool_data"
+	MessageTypePoolInfoSubscribed         = "subscribed/pool_info"
 	
 	// Data update messages (the actual data streams)
-	MessageTypeOrderBookUpdate = "update/order_book"
-	MessageTypeAccountUpdate   = "update/account_all"
+	MessageTypeOrderBookUpdate   = "update/order_book"
+	MessageTypeAccountUpdate     = "update/account_all"
+	MessageTypeTradeUpdate       = "update/trade"
+	MessageTypeMarketStatsUpdate = "update/market_stats"
+	MessageTypeHeightUpdate      = "update/height"
+
+	MessageTypeAccountOrdersUpdate    = "update/account_orders"
+	MessageTypeAccountAllOrdersUpdate = "update/account_all_orders"
+	MessageTypeUserStatsUpdate        = "update/user_stats"
+	MessageTypeAccountTxUpdate        = "update/account_tx"
+	MessageTypePoolDataUpdate         = "update/pool_data"
+	MessageTypePoolInfoUpdate         = "update/pool_info"
 	
 	// Deprecated: Use MessageTypeOrderBookUpdate instead
 	MessageTypeOrderBook = "update/order_book"
@@ -154,16 +289,16 @@ const (
 
 // WSPriceLevel represents a single price level in WebSocket orderbook messages  
 type WSPriceLevel struct {
-	Price string `json:"price"`
-	Size  string `json:"size"`
+	Price decimal.Decimal `json:"price"`
+	Size  decimal.Decimal `json:"size"`
 }
 
 // WebSocket order book state for incremental updates
 type WSOrderBookState struct {
-	MarketId  uint8             `json:"market_id"`
-	Bids      map[string]string `json:"bids"`
-	Asks      map[string]string `json:"asks"`
-	Timestamp int64             `json:"timestamp"`
+	MarketId  uint8                      `json:"market_id"`
+	Bids      map[string]decimal.Decimal `json:"bids"`
+	Asks      map[string]decimal.Decimal `json:"asks"`
+	Timestamp int64                      `json:"timestamp"`
 }
 
 // WebSocket configuration
@@ -174,16 +309,35 @@ type WSConfig struct {
 	ReadTimeout    time.Duration
 	WriteTimeout   time.Duration
 	MaxReconnects  int
+	// Recorder, if set, records every received frame
+	Recorder *WSRecorder
+	// Replay, if set, replaces the connection by a recording, see WSReplay
+	Replay *WSReplay
+	// Logger, if set, replaces the logger set with SetLogger. Use DiscardLogger to silence the client.
+	Logger *slog.Logger
+	// Dispatch is the queue of the callbacks of the subscriptions, unless they set their own. With QueueDropOldest,
+	// the order book subscriptions must set their own policy, see ErrOrderBookDropOldest.
+	Dispatch DispatchConfig
+	// SubscribeTimeout bounds the wait for the confirmation of a subscription, DefaultSubscribeTimeout if 0
+	SubscribeTimeout time.Duration
+	// Staleness sets when the subscriptions are stale, unless they set their own. Not tracked by default.
+	Staleness StalenessConfig
+	// OnStaleness, if set, is called when a subscription goes stale or recovers
+	OnStaleness func(StalenessEvent)
 }
 
+// DefaultSubscribeTimeout is the SubscribeTimeout of the configs without one
+const DefaultSubscribeTimeout = 10 * time.Second
+
 func DefaultWSConfig() *WSConfig {
 	return &WSConfig{
-		URL:            "wss://mainnet.zklighter.elliot.ai/stream",
-		ReconnectDelay: 5 * time.Second,
-		PingInterval:   30 * time.Second,
-		ReadTimeout:    60 * time.Second,
-		WriteTimeout:   10 * time.Second,
-		MaxReconnects:  10,
+		URL:              "wss://mainnet.zklighter.elliot.ai/stream",
+		ReconnectDelay:   5 * time.Second,
+		PingInterval:     30 * time.Second,
+		ReadTimeout:      60 * time.Second,
+		WriteTimeout:     10 * time.Second,
+		MaxReconnects:    10,
+		SubscribeTimeout: DefaultSubscribeTimeout,
 	}
 }
 
@@ -199,12 +353,37 @@ type LighterWebsocketPublicServiceI interface {
 		func(LighterOrderBookResponse) error,
 	) (func() error, error)
 
+	// SubscribeOrderBookFrames is SubscribeOrderBook without allocations: the frames are pooled & only valid during
+	// the callback
+	SubscribeOrderBookFrames(
+		LighterOrderBookParamKey,
+		func(*WSOrderBookFrame) error,
+	) (func() error, error)
+
 	// SubscribeTicker removed - not supported by Lighter
 
 	SubscribeTrades(
 		LighterTradesParamKey,
 		func(LighterTradesResponse) error,
 	) (func() error, error)
+
+	// SubscribeMarketStats replaces the polling of GetOrderBookDetails & GetFundingRates. It covers the perp markets:
+	// the spot/perp stats aren't supported yet.
+	SubscribeMarketStats(
+		LighterMarketStatsParamKey,
+		func(LighterMarketStatsResponse) error,
+	) (func() error, error)
+
+	SubscribeHeight(
+		LighterHeightParamKey,
+		func(LighterHeightResponse) error,
+	) (func() error, error)
+
+	// OrderBookStream, TradesStream, MarketStatsStream & HeightStream are the subscriptions above read from a channel
+	OrderBookStream(marketId uint8, config DispatchConfig) (*Stream[LighterOrderBookResponse], error)
+	TradesStream(marketId uint8, config DispatchConfig) (*Stream[LighterTradesResponse], error)
+	MarketStatsStream(marketId uint8, config DispatchConfig) (*Stream[LighterMarketStatsResponse], error)
+	HeightStream(config DispatchConfig) (*Stream[LighterHeightResponse], error)
 }
 
 // Private service interface for authenticated subscriptions
@@ -221,25 +400,107 @@ type LighterWebsocketPrivateServiceI interface {
 		LighterOrdersParamKey,
 		func(LighterOrdersResponse) error,
 	) (func() error, error)
+
+	SubscribeUserStats(
+		LighterUserStatsParamKey,
+		func(LighterUserStatsResponse) error,
+	) (func() error, error)
+
+	SubscribeAccountTxs(
+		LighterAccountTxsParamKey,
+		func(LighterAccountTxsResponse) error,
+	) (func() error, error)
+
+	// SubscribePoolData & SubscribePoolInfo follow a public pool, the account of the pool must be authenticated
+	SubscribePoolData(
+		LighterPoolParamKey,
+		func(LighterPoolDataResponse) error,
+	) (func() error, error)
+
+	SubscribePoolInfo(
+		LighterPoolParamKey,
+		func(LighterPoolInfoResponse) error,
+	) (func() error, error)
+
+	// AccountStream is SubscribeAccount read from a channel
+	AccountStream(accountId int64, config DispatchConfig) (*Stream[LighterAccountResponse], error)
 }
 
 // Parameter types
 type LighterOrderBookParamKey struct {
 	MarketId uint8
+	// Dispatch, if set, replaces WSConfig.Dispatch for this subscription
+	Dispatch *DispatchConfig
+	// Staleness, if set, replaces WSConfig.Staleness for this subscription
+	Staleness *StalenessConfig
 }
 
 // LighterTickerParamKey removed - not supported by Lighter
 
 type LighterTradesParamKey struct {
 	MarketId uint8
+	// Dispatch, if set, replaces WSConfig.Dispatch for this subscription
+	Dispatch *DispatchConfig
+	// Staleness, if set, replaces WSConfig.Staleness for this subscription
+	Staleness *StalenessConfig
+}
+
+type LighterMarketStatsParamKey struct {
+	MarketId uint8
+	// Dispatch, if set, replaces WSConfig.Dispatch for this subscription
+	Dispatch *DispatchConfig
+	// Staleness, if set, replaces WSConfig.Staleness for this subscription
+	Staleness *StalenessConfig
+}
+
+type LighterHeightParamKey struct {
+	// Dispatch, if set, replaces WSConfig.Dispatch for this subscription
+	Dispatch *DispatchConfig
+	// Staleness, if set, replaces WSConfig.Staleness for this subscription
+	Staleness *StalenessConfig
 }
 
 type LighterAccountParamKey struct {
 	AccountId int64
+	// Dispatch, if set, replaces WSConfig.Dispatch for this subscription
+	Dispatch *DispatchConfig
+	// Staleness, if set, replaces WSConfig.Staleness for this subscription
+	Staleness *StalenessConfig
 }
 
 type LighterOrdersParamKey struct {
 	AccountId int64
+	// MarketIds are the markets of the orders, every market if empty
+	MarketIds []uint8
+	// Dispatch, if set, replaces WSConfig.Dispatch for this subscription
+	Dispatch *DispatchConfig
+	// Staleness, if set, replaces WSConfig.Staleness for this subscription
+	Staleness *StalenessConfig
+}
+
+type LighterUserStatsParamKey struct {
+	AccountId int64
+	// Dispatch, if set, replaces WSConfig.Dispatch for this subscription
+	Dispatch *DispatchConfig
+	// Staleness, if set, replaces WSConfig.Staleness for this subscription
+	Staleness *StalenessConfig
+}
+
+type LighterAccountTxsParamKey struct {
+	AccountId int64
+	// Dispatch, if set, replaces WSConfig.Dispatch for this subscription
+	Dispatch *DispatchConfig
+	// Staleness, if set, replaces WSConfig.Staleness for this subscription
+	Staleness *StalenessConfig
+}
+
+type LighterPoolParamKey struct {
+	// PoolId is the account index of the public pool
+	PoolId int64
+	// Dispatch, if set, replaces WSConfig.Dispatch for this subscription
+	Dispatch *DispatchConfig
+	// Staleness, if set, replaces WSConfig.Staleness for this subscription
+	Staleness *StalenessConfig
 }
 
 // Response types
@@ -254,17 +515,45 @@ type LighterOrderBookResponse struct {
 // LighterTickerResponse removed - not supported by Lighter
 
 type LighterTradesResponse struct {
-	MarketId  uint8  `json:"market_id"`
-	Symbol    string `json:"symbol"`
-	Price     string `json:"price"`
-	Quantity  string `json:"quantity"`
-	Side      string `json:"side"`
-	Timestamp int64  `json:"timestamp"`
+	MarketId    uint8           `json:"market_id"`
+	Symbol      string          `json:"symbol"`
+	TradeId     int64           `json:"trade_id"`
+	Price       decimal.Decimal `json:"price"`
+	Quantity    decimal.Decimal `json:"quantity"`
+	UsdAmount   decimal.Decimal `json:"usd_amount"`
+	Side        string          `json:"side"`      // taker side, "buy" or "sell"
+	Timestamp   int64           `json:"timestamp"` // milliseconds
+	BlockHeight int64           `json:"block_height"`
+	IsSnapshot  bool            `json:"is_snapshot"` // trades sent on subscription, which happened before it
+}
+
+type LighterMarketStatsResponse struct {
+	MarketId           uint8           `json:"market_id"`
+	MarkPrice          decimal.Decimal `json:"mark_price"`
+	IndexPrice         decimal.Decimal `json:"index_price"`
+	LastTradePrice     decimal.Decimal `json:"last_trade_price"`
+	FundingRate        decimal.Decimal `json:"funding_rate"`         // rate of the last funding
+	CurrentFundingRate decimal.Decimal `json:"current_funding_rate"` // estimated rate of the next funding
+	FundingTimestamp   int64           `json:"funding_timestamp"`    // milliseconds
+	OpenInterest       decimal.Decimal `json:"open_interest"`
+	DailyBaseVolume    decimal.Decimal `json:"daily_base_volume"`
+	DailyQuoteVolume   decimal.Decimal `json:"daily_quote_volume"`
+	DailyPriceLow      decimal.Decimal `json:"daily_price_low"`
+	DailyPriceHigh     decimal.Decimal `json:"daily_price_high"`
+	DailyPriceChange   decimal.Decimal `json:"daily_price_change"`
+	Timestamp          int64           `json:"timestamp"`   // milliseconds, the server time, or the receive time for a message without it
+	IsSnapshot         bool            `json:"is_snapshot"` // stats sent on subscription
+}
+
+type LighterHeightResponse struct {
+	Height     int64 `json:"height"`
+	Timestamp  int64 `json:"timestamp"`   // milliseconds, the server time, or the receive time for a message without it
+	IsSnapshot bool  `json:"is_snapshot"` // height sent on subscription
 }
 
 type LighterAccountResponse struct {
 	AccountId        int64                `json:"account_id"`
-	AvailableBalance string               `json:"available_balance"`
+	AvailableBalance decimal.Decimal      `json:"available_balance"`
 	MarketStats      []AccountMarketStats `json:"market_stats,omitempty"`
 	Timestamp        int64                `json:"timestamp"`
 	IsSnapshot       bool                 `json:"is_snapshot"`
@@ -272,15 +561,50 @@ type LighterAccountResponse struct {
 }
 
 type LighterOrdersResponse struct {
-	AccountId        int64  `json:"account_id"`
-	OrderId          string `json:"order_id"`
-	ClientOrderIndex int64  `json:"client_order_index"`
-	MarketId         uint8  `json:"market_id"`
-	Status           string `json:"status"`
-	BaseQuantity     string `json:"base_quantity"`
-	FilledQuantity   string `json:"filled_quantity"`
-	Price            string `json:"price"`
-	IsAsk            uint8  `json:"is_ask"`
-	Timestamp        int64  `json:"timestamp"`
-	IsSnapshot       bool   `json:"is_snapshot"`
+	AccountId        int64           `json:"account_id"`
+	OrderId          string          `json:"order_id"`
+	ClientOrderIndex int64           `json:"client_order_index"`
+	MarketId         uint8           `json:"market_id"`
+	Status           string          `json:"status"`
+	BaseQuantity     decimal.Decimal `json:"base_quantity"`
+	FilledQuantity   decimal.Decimal `json:"filled_quantity"`
+	Price            decimal.Decimal `json:"price"`
+	IsAsk            uint8           `json:"is_ask"`
+	Timestamp        int64           `json:"timestamp"`
+	IsSnapshot       bool            `json:"is_snapshot"`
+	RawOrder         *Order          `json:"-"` // the order as sent by Lighter, nil for the paper exchange
+}
+
+type LighterUserStatsResponse struct {
+	AccountId        int64              `json:"account_id"`
+	Collateral       decimal.Decimal    `json:"collateral"`
+	PortfolioValue   decimal.Decimal    `json:"portfolio_value"`
+	Leverage         decimal.Decimal    `json:"leverage"`
+	AvailableBalance decimal.Decimal    `json:"available_balance"`
+	MarginUsage      decimal.Decimal    `json:"margin_usage"`
+	BuyingPower      decimal.Decimal    `json:"buying_power"`
+	CrossStats       *WSUserStatsValues `json:"cross_stats,omitempty"` // the cross margin part of the stats
+	TotalStats       *WSUserStatsValues `json:"total_stats,omitempty"`
+	Timestamp        int64              `json:"timestamp"` // milliseconds, the receive time: the messages have no timestamp
+	IsSnapshot       bool               `json:"is_snapshot"`
+}
+
+type LighterAccountTxsResponse struct {
+	AccountId  int64         `json:"account_id"`
+	Tx         WSTransaction `json:"tx"`
+	IsSnapshot bool          `json:"is_snapshot"` // txs sent on subscription, which happened before it
+}
+
+type LighterPoolDataResponse struct {
+	PoolId     int64       `json:"pool_id"`
+	Data       *WSPoolData `json:"data"`
+	Timestamp  int64       `json:"timestamp"` // milliseconds, the receive time: the messages have no timestamp
+	IsSnapshot bool        `json:"is_snapshot"`
+}
+
+type LighterPoolInfoResponse struct {
+	PoolId     int64      `json:"pool_id"`
+	Info       WSPoolInfo `json:"info"`
+	Timestamp  int64      `json:"timestamp"` // milliseconds, the receive time: the messages have no timestamp
+	IsSnapshot bool       `json:"is_snapshot"`
 }
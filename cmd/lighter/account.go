@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	curve "github.com/elliottech/poseidon_crypto/curve/ecgfp5"
+	schnorr "github.com/elliottech/poseidon_crypto/signature/schnorr"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/u20024804/lighter-ex/client"
+)
+
+func runKeygen(a *app, args []string) error {
+	fs := newFlagSet("keygen")
+	seed := fs.String("seed", "", "derive the key from a seed instead of using a random one")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var seedP *string
+	if *seed != "" {
+		seedP = seed
+	}
+	key := curve.SampleScalar(seedP)
+
+	res := struct {
+		PrivateKey string `json:"private_key"`
+		PublicKey  string `json:"public_key"`
+	}{
+		PrivateKey: hexutil.Encode(key.ToLittleEndianBytes()),
+		PublicKey:  hexutil.Encode(schnorr.SchnorrPkFromSk(key).ToLittleEndianBytes()),
+	}
+	return a.print(res, func(w io.Writer) {
+		fmt.Fprintf(w, "private key:\t%s\n", res.PrivateKey)
+		fmt.Fprintf(w, "public key:\t%s\n", res.PublicKey)
+	})
+}
+
+func runCheckKey(a *app, args []string) error {
+	if err := newFlagSet("check-key").Parse(args); err != nil {
+		return err
+	}
+	if err := a.tx.CheckApiKey(); err != nil {
+		return err
+	}
+
+	res := struct {
+		AccountIndex int64 `json:"account_index"`
+		ApiKeyIndex  uint8 `json:"api_key_index"`
+		Ok           bool  `json:"ok"`
+	}{a.tx.GetAccountIndex(), a.tx.GetApiKeyIndex(), true}
+	return a.print(res, func(w io.Writer) {
+		fmt.Fprintf(w, "api key %d of account %d matches the private key\n", res.ApiKeyIndex, res.AccountIndex)
+	})
+}
+
+func runMarkets(a *app, args []string) error {
+	if err := newFlagSet("markets").Parse(args); err != nil {
+		return err
+	}
+	resp, err := a.http.GetOrderBookDetails(0)
+	if err != nil {
+		return err
+	}
+	return a.print(resp.OrderBookDetails, func(w io.Writer) {
+		fmt.Fprintln(w, "ID\tSYMBOL\tSTATUS\tSIZE DEC\tPRICE DEC\tMIN IMF\tMMF\tLAST PRICE")
+		for _, m := range resp.OrderBookDetails {
+			fmt.Fprintf(w, "%d\t%s\t%s\t%d\t%d\t%d\t%d\t%v\n", m.MarketId, m.Symbol, m.Status, m.SizeDecimals, m.PriceDecimals,
+				m.MinInitialMarginFraction, m.MaintenanceMarginFraction, m.LastTradePrice)
+		}
+	})
+}
+
+func (a *app) account(args []string, name string) (*client.Account, error) {
+	fs := newFlagSet(name)
+	accountIndex := fs.Int64("account", a.profile.AccountIndex, "account index")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	resp, err := a.http.GetAccount(*accountIndex)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Accounts) == 0 {
+		return nil, fmt.Errorf("account %d not found", *accountIndex)
+	}
+	return &resp.Accounts[0], nil
+}
+
+func runAccount(a *app, args []string) error {
+	acc, err := a.account(args, "account")
+	if err != nil {
+		return err
+	}
+	return a.print(acc, func(w io.Writer) {
+		fmt.Fprintf(w, "index:\t%d\n", acc.Index)
+		fmt.Fprintf(w, "l1 address:\t%s\n", acc.L1Address)
+		fmt.Fprintf(w, "collateral:\t%s\n", acc.Collateral)
+		fmt.Fprintf(w, "available balance:\t%s\n", acc.AvailableBalance)
+		fmt.Fprintf(w, "total asset value:\t%s\n", acc.TotalAssetValue)
+		fmt.Fprintf(w, "cross asset value:\t%s\n", acc.CrossAssetValue)
+		fmt.Fprintf(w, "open orders:\t%d\n", acc.TotalOrderCount)
+		fmt.Fprintf(w, "positions:\t%d\n", len(acc.Positions))
+	})
+}
+
+func runPositions(a *app, args []string) error {
+	acc, err := a.account(args, "positions")
+	if err != nil {
+		return err
+	}
+	positions := make([]client.Position, 0, len(acc.Positions))
+	for _, p := range acc.Positions {
+		if p.Sign != 0 || p.OpenOrderCount != 0 {
+			positions = append(positions, p)
+		}
+	}
+	return a.print(positions, func(w io.Writer) {
+		fmt.Fprintln(w, "MARKET\tSYMBOL\tSIDE\tSIZE\tENTRY\tVALUE\tUPNL\tLIQ PRICE\tMODE")
+		for _, p := range positions {
+			side := "long"
+			if p.Sign < 0 {
+				side = "short"
+			}
+			mode := "cross"
+			if p.MarginMode == 1 {
+				mode = "isolated"
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", p.MarketId, p.Symbol, side, p.Position, p.AvgEntryPrice,
+				p.PositionValue, p.UnrealizedPnl, p.LiquidationPrice, mode)
+		}
+	})
+}
+
+func runOrders(a *app, args []string) error {
+	fs := newFlagSet("orders")
+	marketId := marketFlag(fs)
+	inactive := fs.Bool("inactive", false, "show inactive orders instead of active ones")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	auth, err := a.tx.GetAuthToken(time.Now().Add(time.Hour))
+	if err != nil {
+		return err
+	}
+
+	var resp *client.OrdersResponse
+	if *inactive {
+		resp, err = a.http.GetInactiveOrders(a.tx.GetAccountIndex(), *marketId, auth)
+	} else {
+		resp, err = a.http.GetActiveOrders(a.tx.GetAccountIndex(), *marketId, auth)
+	}
+	if err != nil {
+		return err
+	}
+
+	return a.print(resp.Orders, func(w io.Writer) {
+		fmt.Fprintln(w, "INDEX\tCLIENT INDEX\tSIDE\tTYPE\tPRICE\tSIZE\tREMAINING\tFILLED\tSTATUS")
+		for _, o := range resp.Orders {
+			side := "buy"
+			if o.IsAsk {
+				side = "sell"
+			}
+			fmt.Fprintf(w, "%d\t%d\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", o.OrderIndex, o.ClientOrderIndex, side, o.Type, o.Price,
+				o.InitialBaseAmount, o.RemainingBaseAmount, o.FilledBaseAmount, o.Status)
+		}
+	})
+}
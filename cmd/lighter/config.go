@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+const (
+	defaultURL     = "https://mainnet.zklighter.elliot.ai"
+	defaultChainId = 304
+)
+
+// Profile holds everything needed to talk to Lighter on behalf of one (account, apiKey) pair.
+type Profile struct {
+	URL          string `json:"url"`
+	PrivateKey   string `json:"private_key"`
+	AccountIndex int64  `json:"account_index"`
+	ApiKeyIndex  uint8  `json:"api_key_index"`
+	ChainId      uint32 `json:"chain_id"`
+}
+
+// ConfigFile is the layout of the profile file, by default ~/.lighter/config.json
+//
+//	{
+//	  "default": "main",
+//	  "profiles": {
+//	    "main": {"url": "...", "private_key": "0x...", "account_index": 1, "api_key_index": 2, "chain_id": 304}
+//	  }
+//	}
+type ConfigFile struct {
+	Default  string              `json:"default"`
+	Profiles map[string]*Profile `json:"profiles"`
+}
+
+func defaultConfigPath() string {
+	if p := os.Getenv("LIGHTER_CONFIG"); p != "" {
+		return p
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".lighter", "config.json")
+}
+
+// loadProfile reads the selected profile from the config file, if there is one, and then applies the
+// LIGHTER_* environment variables on top of it, so the environment always wins.
+func loadProfile(path, name string) (*Profile, error) {
+	profile := &Profile{
+		URL:     defaultURL,
+		ChainId: defaultChainId,
+	}
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		if err == nil {
+			cfg := &ConfigFile{}
+			if err := json.Unmarshal(data, cfg); err != nil {
+				return nil, fmt.Errorf("failed to parse config %s. err: %v", path, err)
+			}
+			if name == "" {
+				name = cfg.Default
+			}
+			if name != "" {
+				p, ok := cfg.Profiles[name]
+				if !ok {
+					return nil, fmt.Errorf("profile %q not found in %s", name, path)
+				}
+				mergeProfile(profile, p)
+			}
+		} else if name != "" {
+			return nil, fmt.Errorf("profile %q requested but %s does not exist", name, path)
+		}
+	}
+
+	if err := applyEnv(profile); err != nil {
+		return nil, err
+	}
+	return profile, nil
+}
+
+func mergeProfile(dst, src *Profile) {
+	if src.URL != "" {
+		dst.URL = src.URL
+	}
+	if src.PrivateKey != "" {
+		dst.PrivateKey = src.PrivateKey
+	}
+	if src.AccountIndex != 0 {
+		dst.AccountIndex = src.AccountIndex
+	}
+	if src.ApiKeyIndex != 0 {
+		dst.ApiKeyIndex = src.ApiKeyIndex
+	}
+	if src.ChainId != 0 {
+		dst.ChainId = src.ChainId
+	}
+}
+
+func applyEnv(p *Profile) error {
+	if v := os.Getenv("LIGHTER_URL"); v != "" {
+		p.URL = v
+	}
+	if v := os.Getenv("LIGHTER_PRIVATE_KEY"); v != "" {
+		p.PrivateKey = v
+	}
+	if v := os.Getenv("LIGHTER_ACCOUNT_INDEX"); v != "" {
+		i, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid LIGHTER_ACCOUNT_INDEX %q", v)
+		}
+		p.AccountIndex = i
+	}
+	if v := os.Getenv("LIGHTER_API_KEY_INDEX"); v != "" {
+		i, err := strconv.ParseUint(v, 10, 8)
+		if err != nil {
+			return fmt.Errorf("invalid LIGHTER_API_KEY_INDEX %q", v)
+		}
+		p.ApiKeyIndex = uint8(i)
+	}
+	if v := os.Getenv("LIGHTER_CHAIN_ID"); v != "" {
+		i, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			return fmt.Errorf("invalid LIGHTER_CHAIN_ID %q", v)
+		}
+		p.ChainId = uint32(i)
+	}
+	return nil
+}
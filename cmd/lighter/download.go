@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/u20024804/lighter-ex/downloader"
+)
+
+func runDownload(a *app, args []string) error {
+	fs := newFlagSet("download")
+	markets := fs.String("markets", "", "comma separated market indexes, e.g. 0,1")
+	datasets := fs.String("datasets", downloader.Candles, "comma separated datasets: candles, fundings, trades")
+	start := fs.String("start", "", "start date (UTC), e.g. 2024-01-31 or 2024-01-31T12:00:00Z")
+	end := fs.String("end", "", "end date (UTC), exclusive, defaults to now")
+	resolution := fs.String("resolution", "1", "candle resolution: 1, 5, 15, 60, 240 or 1D")
+	format := fs.String("format", downloader.FormatCSV, "csv or parquet")
+	partition := fs.String("partition", downloader.PartitionDay, "one file per day or month")
+	dir := fs.String("dir", "data", "output directory")
+	limit := fs.Int("limit", 0, "max rows per request, 0 uses the API defaults")
+	interval := fs.Duration("interval", 200*time.Millisecond, "pause between requests")
+	resume := fs.Bool("resume", true, "continue from the last stored timestamp")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	config := downloader.Config{
+		Resolution:      *resolution,
+		Format:          *format,
+		Partition:       *partition,
+		Dir:             *dir,
+		Limit:           int32(*limit),
+		RequestInterval: *interval,
+		Resume:          *resume,
+		Datasets:        strings.Split(*datasets, ","),
+	}
+	for _, m := range strings.Split(*markets, ",") {
+		if m == "" {
+			continue
+		}
+		id, err := strconv.ParseUint(strings.TrimSpace(m), 10, 8)
+		if err != nil {
+			return fmt.Errorf("invalid market %q", m)
+		}
+		config.Markets = append(config.Markets, uint8(id))
+	}
+
+	var err error
+	if config.Start, err = parseDate(*start); err != nil {
+		return fmt.Errorf("invalid -start: %w", err)
+	}
+	if *end != "" {
+		if config.End, err = parseDate(*end); err != nil {
+			return fmt.Errorf("invalid -end: %w", err)
+		}
+	}
+
+	d, err := downloader.New(a.http, config)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if err := d.Run(ctx); err != nil {
+		return err
+	}
+
+	return a.print(config, func(w io.Writer) {
+		fmt.Fprintf(w, "downloaded %s of markets %s to %s\n", *datasets, *markets, *dir)
+	})
+}
+
+func parseDate(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, fmt.Errorf("missing date")
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.DateOnly, s)
+}
@@ -0,0 +1,174 @@
+// Command lighter is a small CLI around HTTPClient & TxClient for everyday account operations.
+//
+// Usage:
+//
+//	lighter [-config path] [-profile name] [-json] <command> [flags]
+//
+// The (account, apiKey) pair is read from the profile file (see ConfigFile) and can be overridden with
+// LIGHTER_URL, LIGHTER_PRIVATE_KEY, LIGHTER_ACCOUNT_INDEX, LIGHTER_API_KEY_INDEX & LIGHTER_CHAIN_ID.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/u20024804/lighter-ex/client"
+)
+
+type command struct {
+	usage string
+	// needsKey means the command signs something, so a TxClient has to be created
+	needsKey bool
+	run      func(a *app, args []string) error
+}
+
+var commands = map[string]command{
+	"keygen":     {usage: "generate a new API key pair", run: runKeygen},
+	"check-key":  {usage: "check that the private key matches the API key registered on Lighter", needsKey: true, run: runCheckKey},
+	"markets":    {usage: "list markets with their decimals & margin fractions", run: runMarkets},
+	"account":    {usage: "show account balances", run: runAccount},
+	"positions":  {usage: "show open positions", run: runPositions},
+	"orders":     {usage: "show active (or inactive) orders for a market", needsKey: true, run: runOrders},
+	"order":      {usage: "place an order", needsKey: true, run: runOrder},
+	"modify":     {usage: "modify a resting order", needsKey: true, run: runModify},
+	"cancel":     {usage: "cancel an order", needsKey: true, run: runCancel},
+	"cancel-all": {usage: "cancel all orders, now or scheduled", needsKey: true, run: runCancelAll},
+	"transfer":   {usage: "transfer USDC to another account", needsKey: true, run: runTransfer},
+	"withdraw":   {usage: "withdraw USDC to L1", needsKey: true, run: runWithdraw},
+	"leverage":   {usage: "update leverage & margin mode for a market", needsKey: true, run: runLeverage},
+	"margin":     {usage: "add or remove isolated margin", needsKey: true, run: runMargin},
+	"download":   {usage: "download historical candles, fundings & trades to CSV or Parquet", run: runDownload},
+}
+
+type app struct {
+	profile *Profile
+	json    bool
+	out     io.Writer
+
+	http *client.HTTPClient
+	tx   *client.TxClient
+}
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return
+		}
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	fs := flag.NewFlagSet("lighter", flag.ContinueOnError)
+	configPath := fs.String("config", defaultConfigPath(), "path to the profile file")
+	profileName := fs.String("profile", os.Getenv("LIGHTER_PROFILE"), "profile to use from the config file")
+	jsonOutput := fs.Bool("json", false, "print results as JSON")
+	fs.Usage = func() { printUsage(fs) }
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() == 0 {
+		printUsage(fs)
+		return fmt.Errorf("missing command")
+	}
+	cmd, ok := commands[fs.Arg(0)]
+	if !ok {
+		printUsage(fs)
+		return fmt.Errorf("unknown command %q", fs.Arg(0))
+	}
+
+	a := &app{json: *jsonOutput, out: os.Stdout}
+	if fs.Arg(0) != "keygen" {
+		profile, err := loadProfile(*configPath, *profileName)
+		if err != nil {
+			return err
+		}
+		a.profile = profile
+		a.http = client.NewHTTPClient(profile.URL)
+		if a.http == nil {
+			return fmt.Errorf("missing url")
+		}
+		if cmd.needsKey {
+			if profile.PrivateKey == "" {
+				return fmt.Errorf("missing private key, set it in the profile or via LIGHTER_PRIVATE_KEY")
+			}
+			a.tx, err = client.NewTxClient(a.http, profile.PrivateKey, profile.AccountIndex, profile.ApiKeyIndex, profile.ChainId)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return cmd.run(a, fs.Args()[1:])
+}
+
+func printUsage(fs *flag.FlagSet) {
+	w := fs.Output()
+	fmt.Fprintln(w, "usage: lighter [flags] <command> [command flags]")
+	fmt.Fprintln(w, "\nflags:")
+	fs.PrintDefaults()
+	fmt.Fprintln(w, "\ncommands:")
+
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, name := range names {
+		fmt.Fprintf(tw, "  %s\t%s\n", name, commands[name].usage)
+	}
+	tw.Flush()
+}
+
+// print writes v as JSON when -json is set, and uses text to render it as a table otherwise
+func (a *app) print(v interface{}, text func(w io.Writer)) error {
+	if a.json {
+		enc := json.NewEncoder(a.out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	}
+
+	tw := tabwriter.NewWriter(a.out, 0, 4, 2, ' ', 0)
+	text(tw)
+	return tw.Flush()
+}
+
+func newFlagSet(name string) *flag.FlagSet {
+	return flag.NewFlagSet("lighter "+name, flag.ContinueOnError)
+}
+
+// marketFlag defines the -market flag of a command
+func marketFlag(fs *flag.FlagSet) *uint8 {
+	marketId := new(uint8)
+	fs.Var((*marketIndex)(marketId), "market", "market index")
+	return marketId
+}
+
+// marketIndex is a flag.Value refusing the indexes that don't fit in a uint8, rather than truncating them
+type marketIndex uint8
+
+func (m *marketIndex) String() string {
+	if m == nil {
+		return "0"
+	}
+	return strconv.Itoa(int(*m))
+}
+
+func (m *marketIndex) Set(s string) error {
+	v, err := strconv.ParseUint(s, 10, 8)
+	if err != nil {
+		return fmt.Errorf("invalid market index %s, expected 0 to 255", s)
+	}
+	*m = marketIndex(v)
+	return nil
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMarketFlag(t *testing.T) {
+	tests := []struct {
+		value   string
+		market  uint8
+		invalid bool
+	}{
+		{"0", 0, false},
+		{"1", 1, false},
+		{"255", 255, false},
+		{"256", 0, true},
+		{"-1", 0, true},
+		{"1.5", 0, true},
+		{"", 0, true},
+		{"eth", 0, true},
+	}
+	for _, tt := range tests {
+		fs := newFlagSet("test")
+		marketId := marketFlag(fs)
+		err := fs.Parse([]string{"-market", tt.value})
+		if tt.invalid {
+			if err == nil || !strings.Contains(err.Error(), "expected 0 to 255") {
+				t.Errorf("-market %q: expected an error, got %d, %v", tt.value, *marketId, err)
+			}
+			continue
+		}
+		if err != nil || *marketId != tt.market {
+			t.Errorf("-market %q: expected %d, got %d, %v", tt.value, tt.market, *marketId, err)
+		}
+	}
+}
+
+// the flags are validated before anything is fetched, so the commands run without clients
+func TestCommandFlags(t *testing.T) {
+	tests := []struct {
+		command string
+		args    []string
+	}{
+		{"order", []string{"-market", "300"}},
+		{"modify", []string{"-market", "-2"}},
+		{"cancel", []string{"-market", "x"}},
+		{"orders", []string{"-market", "1000"}},
+		{"order", []string{"-unknown"}},
+	}
+	for _, tt := range tests {
+		err := commands[tt.command].run(&app{}, tt.args)
+		if err == nil {
+			t.Errorf("%s %v: expected an error", tt.command, tt.args)
+		}
+	}
+}
@@ -0,0 +1,350 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/u20024804/lighter-ex/types"
+	"github.com/u20024804/lighter-ex/types/txtypes"
+)
+
+const defaultOrderExpiry = time.Hour * 24 * 28
+
+type txResult struct {
+	TxType uint8  `json:"tx_type"`
+	TxHash string `json:"tx_hash"`
+	TxInfo string `json:"tx_info,omitempty"`
+	Sent   bool   `json:"sent"`
+}
+
+// submit sends a signed tx, or only prints it when -dry-run is set
+func (a *app) submit(tx txtypes.TxInfo, dryRun bool) error {
+	res := txResult{TxType: tx.GetTxType(), TxHash: tx.GetTxHash()}
+	if dryRun {
+		txInfo, err := tx.GetTxInfo()
+		if err != nil {
+			return err
+		}
+		res.TxInfo = txInfo
+	} else {
+		hash, err := a.http.SendRawTx(tx)
+		if err != nil {
+			return err
+		}
+		if hash != "" {
+			res.TxHash = hash
+		}
+		res.Sent = true
+	}
+
+	return a.print(res, func(w io.Writer) {
+		if res.Sent {
+			fmt.Fprintf(w, "sent tx %s\n", res.TxHash)
+		} else {
+			fmt.Fprintf(w, "signed tx %s (not sent)\n", res.TxHash)
+			fmt.Fprintln(w, res.TxInfo)
+		}
+	})
+}
+
+func runOrder(a *app, args []string) error {
+	fs := newFlagSet("order")
+	marketId := marketFlag(fs)
+	side := fs.String("side", "", "buy or sell")
+	size := fs.String("size", "", "size in base currency, e.g. 0.5")
+	price := fs.String("price", "", "limit price, or worst acceptable price for market orders")
+	orderType := fs.String("type", "limit", "limit, market, stop-loss, stop-loss-limit, take-profit or take-profit-limit")
+	tif := fs.String("tif", "gtt", "time in force: gtt, ioc or post-only")
+	triggerPrice := fs.String("trigger", "", "trigger price for stop-loss & take-profit orders")
+	reduceOnly := fs.Bool("reduce-only", false, "only reduce an existing position")
+	expiry := fs.Duration("expiry", defaultOrderExpiry, "time until the order expires")
+	clientOrderIndex := fs.Int64("client-order-index", txtypes.NilClientOrderIndex, "client order index")
+	dryRun := fs.Bool("dry-run", false, "sign the tx but don't send it")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	market, err := a.market(*marketId)
+	if err != nil {
+		return err
+	}
+
+	req := &types.CreateOrderTxReq{
+		MarketIndex:      *marketId,
+		ClientOrderIndex: *clientOrderIndex,
+	}
+
+	switch *side {
+	case "buy":
+		req.IsAsk = 0
+	case "sell":
+		req.IsAsk = 1
+	default:
+		return fmt.Errorf("side should be buy or sell")
+	}
+
+	if req.BaseAmount, err = parseScaled(*size, market.SizeDecimals); err != nil {
+		return fmt.Errorf("invalid size. err: %v", err)
+	}
+	if req.Price, err = parsePrice(*price, market.PriceDecimals); err != nil {
+		return fmt.Errorf("invalid price. err: %v", err)
+	}
+	if *triggerPrice != "" {
+		if req.TriggerPrice, err = parsePrice(*triggerPrice, market.PriceDecimals); err != nil {
+			return fmt.Errorf("invalid trigger price. err: %v", err)
+		}
+	}
+	if *reduceOnly {
+		req.ReduceOnly = 1
+	}
+
+	switch *orderType {
+	case "limit":
+		req.Type = txtypes.LimitOrder
+	case "market":
+		req.Type = txtypes.MarketOrder
+		*tif = "ioc"
+	case "stop-loss":
+		req.Type = txtypes.StopLossOrder
+		*tif = "ioc"
+	case "stop-loss-limit":
+		req.Type = txtypes.StopLossLimitOrder
+	case "take-profit":
+		req.Type = txtypes.TakeProfitOrder
+		*tif = "ioc"
+	case "take-profit-limit":
+		req.Type = txtypes.TakeProfitLimitOrder
+	default:
+		return fmt.Errorf("unknown order type %q", *orderType)
+	}
+
+	switch *tif {
+	case "gtt":
+		req.TimeInForce = txtypes.GoodTillTime
+	case "ioc":
+		req.TimeInForce = txtypes.ImmediateOrCancel
+	case "post-only":
+		req.TimeInForce = txtypes.PostOnly
+	default:
+		return fmt.Errorf("unknown time in force %q", *tif)
+	}
+
+	// market & plain IOC limit orders can't carry an expiry, everything else needs one
+	if req.Type != txtypes.MarketOrder && !(req.Type == txtypes.LimitOrder && req.TimeInForce == txtypes.ImmediateOrCancel) {
+		req.OrderExpiry = time.Now().Add(*expiry).UnixMilli()
+	}
+
+	tx, err := a.tx.GetCreateOrderTransaction(req, nil)
+	if err != nil {
+		return err
+	}
+	return a.submit(tx, *dryRun)
+}
+
+func runModify(a *app, args []string) error {
+	fs := newFlagSet("modify")
+	marketId := marketFlag(fs)
+	index := fs.Int64("index", 0, "order index, or client order index")
+	size := fs.String("size", "", "new size in base currency")
+	price := fs.String("price", "", "new price")
+	triggerPrice := fs.String("trigger", "", "new trigger price")
+	dryRun := fs.Bool("dry-run", false, "sign the tx but don't send it")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	market, err := a.market(*marketId)
+	if err != nil {
+		return err
+	}
+
+	req := &types.ModifyOrderTxReq{
+		MarketIndex: *marketId,
+		Index:       *index,
+	}
+	if req.BaseAmount, err = parseScaled(*size, market.SizeDecimals); err != nil {
+		return fmt.Errorf("invalid size. err: %v", err)
+	}
+	if req.Price, err = parsePrice(*price, market.PriceDecimals); err != nil {
+		return fmt.Errorf("invalid price. err: %v", err)
+	}
+	if *triggerPrice != "" {
+		if req.TriggerPrice, err = parsePrice(*triggerPrice, market.PriceDecimals); err != nil {
+			return fmt.Errorf("invalid trigger price. err: %v", err)
+		}
+	}
+
+	tx, err := a.tx.GetModifyOrderTransaction(req, nil)
+	if err != nil {
+		return err
+	}
+	return a.submit(tx, *dryRun)
+}
+
+func runCancel(a *app, args []string) error {
+	fs := newFlagSet("cancel")
+	marketId := marketFlag(fs)
+	index := fs.Int64("index", 0, "order index, or client order index")
+	dryRun := fs.Bool("dry-run", false, "sign the tx but don't send it")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	tx, err := a.tx.GetCancelOrderTransaction(&types.CancelOrderTxReq{
+		MarketIndex: *marketId,
+		Index:       *index,
+	}, nil)
+	if err != nil {
+		return err
+	}
+	return a.submit(tx, *dryRun)
+}
+
+func runCancelAll(a *app, args []string) error {
+	fs := newFlagSet("cancel-all")
+	schedule := fs.Duration("schedule", 0, "schedule the cancel-all this far in the future instead of cancelling now")
+	abort := fs.Bool("abort", false, "abort a previously scheduled cancel-all")
+	dryRun := fs.Bool("dry-run", false, "sign the tx but don't send it")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	req := &types.CancelAllOrdersTxReq{TimeInForce: txtypes.ImmediateCancelAll}
+	switch {
+	case *abort && *schedule != 0:
+		return fmt.Errorf("-abort and -schedule can't be used together")
+	case *abort:
+		req.TimeInForce = txtypes.AbortScheduledCancelAll
+	case *schedule != 0:
+		req.TimeInForce = txtypes.ScheduledCancelAll
+		req.Time = time.Now().Add(*schedule).UnixMilli()
+	}
+
+	tx, err := a.tx.GetCancelAllOrdersTransaction(req, nil)
+	if err != nil {
+		return err
+	}
+	return a.submit(tx, *dryRun)
+}
+
+func runTransfer(a *app, args []string) error {
+	fs := newFlagSet("transfer")
+	to := fs.Int64("to", 0, "destination account index")
+	amount := fs.String("amount", "", "amount in USDC, e.g. 100.5")
+	memo := fs.String("memo", "", "memo, at most 32 bytes")
+	dryRun := fs.Bool("dry-run", false, "sign the tx but don't send it")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	usdcAmount, err := parseScaled(*amount, usdcDecimals)
+	if err != nil {
+		return fmt.Errorf("invalid amount. err: %v", err)
+	}
+	if len(*memo) > 32 {
+		return fmt.Errorf("memo should be at most 32 bytes")
+	}
+
+	auth, err := a.tx.GetAuthToken(time.Now().Add(time.Hour))
+	if err != nil {
+		return err
+	}
+	feeInfo, err := a.http.GetTransferFeeInfo(a.tx.GetAccountIndex(), *to, auth)
+	if err != nil {
+		return err
+	}
+
+	req := &types.TransferTxReq{
+		ToAccountIndex: *to,
+		USDCAmount:     usdcAmount,
+		Fee:            feeInfo.TransferFee,
+	}
+	copy(req.Memo[:], *memo)
+
+	tx, err := a.tx.GetTransferTransaction(req, nil)
+	if err != nil {
+		return err
+	}
+	return a.submit(tx, *dryRun)
+}
+
+func runWithdraw(a *app, args []string) error {
+	fs := newFlagSet("withdraw")
+	amount := fs.String("amount", "", "amount in USDC, e.g. 100.5")
+	dryRun := fs.Bool("dry-run", false, "sign the tx but don't send it")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	usdcAmount, err := parseScaled(*amount, usdcDecimals)
+	if err != nil || usdcAmount <= 0 {
+		return fmt.Errorf("invalid amount %q", *amount)
+	}
+
+	tx, err := a.tx.GetWithdrawTransaction(&types.WithdrawTxReq{USDCAmount: uint64(usdcAmount)}, nil)
+	if err != nil {
+		return err
+	}
+	return a.submit(tx, *dryRun)
+}
+
+func runLeverage(a *app, args []string) error {
+	fs := newFlagSet("leverage")
+	marketId := marketFlag(fs)
+	leverage := fs.Float64("leverage", 0, "target leverage, e.g. 10")
+	isolated := fs.Bool("isolated", false, "use isolated margin instead of cross margin")
+	dryRun := fs.Bool("dry-run", false, "sign the tx but don't send it")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *leverage < 1 {
+		return fmt.Errorf("leverage should be at least 1")
+	}
+
+	req := &types.UpdateLeverageTxReq{
+		MarketIndex:           *marketId,
+		InitialMarginFraction: uint16(float64(txtypes.MarginFractionTick) / *leverage),
+		MarginMode:            txtypes.CrossMargin,
+	}
+	if *isolated {
+		req.MarginMode = txtypes.IsolatedMargin
+	}
+
+	tx, err := a.tx.GetUpdateLeverageTransaction(req, nil)
+	if err != nil {
+		return err
+	}
+	return a.submit(tx, *dryRun)
+}
+
+func runMargin(a *app, args []string) error {
+	fs := newFlagSet("margin")
+	marketId := marketFlag(fs)
+	amount := fs.String("amount", "", "amount in USDC, e.g. 100.5")
+	remove := fs.Bool("remove", false, "remove margin from the isolated position instead of adding it")
+	dryRun := fs.Bool("dry-run", false, "sign the tx but don't send it")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	usdcAmount, err := parseScaled(*amount, usdcDecimals)
+	if err != nil {
+		return fmt.Errorf("invalid amount. err: %v", err)
+	}
+
+	req := &types.UpdateMarginTxReq{
+		MarketIndex: *marketId,
+		USDCAmount:  usdcAmount,
+		Direction:   txtypes.AddToIsolatedMargin,
+	}
+	if *remove {
+		req.Direction = txtypes.RemoveFromIsolatedMargin
+	}
+
+	tx, err := a.tx.GetUpdateMarginTransaction(req, nil)
+	if err != nil {
+		return err
+	}
+	return a.submit(tx, *dryRun)
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/u20024804/lighter-ex/client"
+)
+
+const usdcDecimals = 6
+
+// parseScaled converts a human readable decimal, e.g. "3012.25", into an integer with the given number of decimals.
+// It refuses to round, so a value with more decimals than the market supports is an error.
+func parseScaled(s string, decimals uint8) (int64, error) {
+	if s == "" {
+		return 0, fmt.Errorf("empty amount")
+	}
+	amount := s
+	neg := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(s, "-")
+
+	intPart, fracPart, _ := strings.Cut(s, ".")
+	// a single sign, then digits: ParseInt would accept a second sign, and nothing is 0 below
+	if intPart+fracPart == "" || !isDigits(intPart) || !isDigits(fracPart) {
+		return 0, fmt.Errorf("invalid amount %s", amount)
+	}
+	fracPart = strings.TrimRight(fracPart, "0")
+	if len(fracPart) > int(decimals) {
+		return 0, fmt.Errorf("%s has more than %d decimals", s, decimals)
+	}
+	fracPart += strings.Repeat("0", int(decimals)-len(fracPart))
+	if intPart == "" {
+		intPart = "0"
+	}
+
+	v, err := strconv.ParseInt(intPart+fracPart, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount %s", s)
+	}
+	if neg {
+		v = -v
+	}
+	return v, nil
+}
+
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func parsePrice(s string, decimals uint8) (uint32, error) {
+	v, err := parseScaled(s, decimals)
+	if err != nil {
+		return 0, err
+	}
+	if v < 0 || v > math.MaxUint32 {
+		return 0, fmt.Errorf("price %s out of range", s)
+	}
+	return uint32(v), nil
+}
+
+// market fetches the details of a single market, which are needed to scale prices & sizes
+func (a *app) market(marketId uint8) (*client.OrderBookDetail, error) {
+	resp, err := a.http.GetOrderBookDetails(marketId)
+	if err != nil {
+		return nil, err
+	}
+	for i := range resp.OrderBookDetails {
+		if resp.OrderBookDetails[i].MarketId == marketId {
+			return &resp.OrderBookDetails[i], nil
+		}
+	}
+	return nil, fmt.Errorf("market %d not found", marketId)
+}
@@ -0,0 +1,73 @@
+package main
+
+import "testing"
+
+func TestParseScaled(t *testing.T) {
+	tests := []struct {
+		s        string
+		decimals uint8
+		v        int64
+		invalid  bool
+	}{
+		{"3012.25", 2, 301225, false},
+		{"3012.250", 2, 301225, false},
+		{"0.5", 4, 5000, false},
+		{".5", 1, 5, false},
+		{"5.", 1, 50, false},
+		{"-1.5", 2, -150, false},
+		{"-0", 2, 0, false},
+		{"007", 0, 7, false},
+		{"1.255", 2, 0, true},
+		{"", 2, 0, true},
+		{"-", 2, 0, true},
+		{".", 2, 0, true},
+		{"-.", 2, 0, true},
+		{"--5", 2, 0, true},
+		{"-+5", 2, 0, true},
+		{"+5", 2, 0, true},
+		{"1.2.3", 2, 0, true},
+		{"1e3", 2, 0, true},
+		{" 1", 2, 0, true},
+		{"0x10", 0, 0, true},
+		{"92233720368547758.08", 2, 0, true},
+	}
+	for _, tt := range tests {
+		v, err := parseScaled(tt.s, tt.decimals)
+		if tt.invalid {
+			if err == nil {
+				t.Errorf("parseScaled(%q, %d) = %d, expected an error", tt.s, tt.decimals, v)
+			}
+			continue
+		}
+		if err != nil || v != tt.v {
+			t.Errorf("parseScaled(%q, %d) = %d, %v, expected %d", tt.s, tt.decimals, v, err, tt.v)
+		}
+	}
+}
+
+func TestParsePrice(t *testing.T) {
+	tests := []struct {
+		s       string
+		v       uint32
+		invalid bool
+	}{
+		{"3012.25", 301225, false},
+		{"0", 0, false},
+		{"42949672.95", 4294967295, false},
+		{"42949672.96", 0, true},
+		{"-1", 0, true},
+		{"--1", 0, true},
+	}
+	for _, tt := range tests {
+		v, err := parsePrice(tt.s, 2)
+		if tt.invalid {
+			if err == nil {
+				t.Errorf("parsePrice(%q) = %d, expected an error", tt.s, v)
+			}
+			continue
+		}
+		if err != nil || v != tt.v {
+			t.Errorf("parsePrice(%q) = %d, %v, expected %d", tt.s, v, err, tt.v)
+		}
+	}
+}
@@ -0,0 +1,551 @@
+// Package decimal implements the fixed-point numbers used for prices, sizes & USDC amounts in the REST & WS models.
+//
+// A Decimal is an int64 coefficient and a number of decimals, its scale. Values parsed from the API are exact, and
+// additions, subtractions & multiplications don't accumulate float rounding errors. A result whose coefficient
+// doesn't fit in an int64 loses its last decimals, rounded half away from zero. Only an integer part that doesn't
+// fit in an int64 makes the arithmetic panic, like an integer overflow would.
+package decimal
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// MaxScale is the maximum number of decimals of a Decimal
+const MaxScale = 18
+
+var pow10 = func() (p [MaxScale + 1]int64) {
+	p[0] = 1
+	for i := 1; i <= MaxScale; i++ {
+		p[i] = p[i-1] * 10
+	}
+	return p
+}()
+
+// maxExponent & maxDigits bound the numbers accepted by Parse. Numbers out of the int64 range go through big.Int,
+// so an unbounded exponent or number of digits would let an untrusted input allocate or loop without limit.
+const (
+	maxExponent = 64
+	maxDigits   = 128
+)
+
+// Decimal is a fixed-point decimal number. The zero value is 0.
+type Decimal struct {
+	coef  int64
+	scale uint8
+}
+
+// Zero is the zero Decimal
+var Zero = Decimal{}
+
+// New returns coef * 10^-scale, e.g. New(30125, 1) is 3012.5
+func New(coef int64, scale uint8) Decimal {
+	if scale > MaxScale {
+		return must(fitBig(big.NewInt(coef), int(scale)))
+	}
+	return Decimal{coef: coef, scale: scale}
+}
+
+// FromTicks converts an integer amount of ticks, as used in txs, to a Decimal. For instance a BaseAmount of 1500
+// on a market with 4 size decimals is 0.1500.
+func FromTicks(ticks int64, decimals uint8) Decimal {
+	return New(ticks, decimals)
+}
+
+// NewFromInt returns v as a Decimal
+func NewFromInt(v int64) Decimal {
+	return Decimal{coef: v}
+}
+
+// NewFromFloat returns the shortest Decimal that converts back to f. It panics if f is NaN, infinite or out of range.
+func NewFromFloat(f float64) Decimal {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		panic(fmt.Sprintf("decimal: can't convert %v", f))
+	}
+	d, err := Parse(strconv.FormatFloat(f, 'g', -1, 64))
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+// Parse parses a decimal number, e.g. "3012.25", "-0.0010" or "1e-5". The number of decimals is kept,
+// so "1.50" is printed back as "1.50". Exponents beyond ±64 and numbers of more than 128 digits are refused.
+func Parse(s string) (Decimal, error) {
+	str := s
+	exp := 0
+	if i := strings.IndexAny(s, "eE"); i >= 0 {
+		e, err := strconv.Atoi(s[i+1:])
+		if err != nil {
+			return Zero, fmt.Errorf("decimal: invalid number %q", str)
+		}
+		if e > maxExponent || e < -maxExponent {
+			return Zero, fmt.Errorf("decimal: exponent of %q is out of range", str)
+		}
+		exp, s = e, s[:i]
+	}
+	neg := false
+	if s != "" && (s[0] == '-' || s[0] == '+') {
+		neg, s = s[0] == '-', s[1:]
+	}
+	intPart, fracPart, _ := strings.Cut(s, ".")
+	digits := intPart + fracPart
+	if digits == "" {
+		return Zero, fmt.Errorf("decimal: invalid number %q", str)
+	}
+	if len(digits) > maxDigits {
+		return Zero, fmt.Errorf("decimal: %q has too many digits", str)
+	}
+	for i := 0; i < len(digits); i++ {
+		if digits[i] < '0' || digits[i] > '9' {
+			return Zero, fmt.Errorf("decimal: invalid number %q", str)
+		}
+	}
+
+	scale := len(fracPart) - exp
+	if len(digits) <= 18 && scale >= 0 && scale <= MaxScale {
+		coef, _ := strconv.ParseInt(digits, 10, 64)
+		if neg {
+			coef = -coef
+		}
+		return Decimal{coef: coef, scale: uint8(scale)}, nil
+	}
+
+	b, _ := new(big.Int).SetString(digits, 10)
+	if scale < 0 {
+		b.Mul(b, new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(-scale)), nil))
+		scale = 0
+	}
+	if neg {
+		b.Neg(b)
+	}
+	d, ok := fitBig(b, scale)
+	if !ok {
+		return Zero, fmt.Errorf("decimal: %q is out of range", str)
+	}
+	return d, nil
+}
+
+// ParseBytes is like Parse, without allocating for the plain numbers of up to 18 digits used by the API
+func ParseBytes(b []byte) (Decimal, error) {
+	i, neg := 0, false
+	if len(b) > 0 && (b[0] == '-' || b[0] == '+') {
+		neg, i = b[0] == '-', 1
+	}
+	var coef int64
+	digits, scale, dot := 0, 0, false
+	for ; i < len(b); i++ {
+		c := b[i]
+		switch {
+		case c >= '0' && c <= '9':
+			coef = coef*10 + int64(c-'0')
+			digits++
+			if dot {
+				scale++
+			}
+		case c == '.' && !dot:
+			dot = true
+		default:
+			// exponents & malformed numbers
+			return Parse(string(b))
+		}
+	}
+	if digits == 0 || digits > 18 || scale > MaxScale {
+		return Parse(string(b))
+	}
+	if neg {
+		coef = -coef
+	}
+	return Decimal{coef: coef, scale: uint8(scale)}, nil
+}
+
+// MustParse is like Parse but panics on error, for constants
+func MustParse(s string) Decimal {
+	d, err := Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+// Coefficient returns the integer coefficient, the value is Coefficient() * 10^-Scale()
+func (d Decimal) Coefficient() int64 {
+	return d.coef
+}
+
+// Scale returns the number of decimals
+func (d Decimal) Scale() uint8 {
+	return d.scale
+}
+
+// Ticks converts d to an integer amount of ticks with the given decimals, the inverse of FromTicks.
+// It fails if d has more decimals, round it first if that's intended.
+func (d Decimal) Ticks(decimals uint8) (int64, error) {
+	if d.scale > decimals {
+		p := pow10[d.scale-decimals]
+		if d.coef%p != 0 {
+			return 0, fmt.Errorf("decimal: %s has more than %d decimals", d, decimals)
+		}
+		return d.coef / p, nil
+	}
+	if decimals > MaxScale {
+		return 0, fmt.Errorf("decimal: %d decimals is out of range", decimals)
+	}
+	ticks, ok := mul64(d.coef, pow10[decimals-d.scale])
+	if !ok {
+		return 0, fmt.Errorf("decimal: %s is out of range with %d decimals", d, decimals)
+	}
+	return ticks, nil
+}
+
+// Add returns d + d2
+func (d Decimal) Add(d2 Decimal) Decimal {
+	if a, b, scale, ok := align(d, d2); ok {
+		if sum, ok := add64(a, b); ok {
+			return Decimal{coef: sum, scale: scale}
+		}
+	}
+	scale := max(d.scale, d2.scale)
+	return must(fitBig(new(big.Int).Add(d.bigAt(scale), d2.bigAt(scale)), int(scale)))
+}
+
+// Sub returns d - d2
+func (d Decimal) Sub(d2 Decimal) Decimal {
+	return d.Add(d2.Neg())
+}
+
+// Mul returns d * d2
+func (d Decimal) Mul(d2 Decimal) Decimal {
+	scale := int(d.scale) + int(d2.scale)
+	if scale <= MaxScale {
+		if p, ok := mul64(d.coef, d2.coef); ok {
+			return Decimal{coef: p, scale: uint8(scale)}
+		}
+	}
+	return must(fitBig(new(big.Int).Mul(big.NewInt(d.coef), big.NewInt(d2.coef)), scale))
+}
+
+// Div returns d / d2 with as many decimals as the coefficient can hold, up to MaxScale. It panics if d2 is zero.
+func (d Decimal) Div(d2 Decimal) Decimal {
+	return d.DivRound(d2, MaxScale)
+}
+
+// DivRound returns d / d2 rounded half away from zero to the given number of decimals. It panics if d2 is zero.
+func (d Decimal) DivRound(d2 Decimal, scale uint8) Decimal {
+	if d2.coef == 0 {
+		panic("decimal: division by zero")
+	}
+	if scale > MaxScale {
+		scale = MaxScale
+	}
+	// coef = d.coef * 10^(scale - d.scale + d2.scale) / d2.coef
+	num, den := big.NewInt(d.coef), big.NewInt(d2.coef)
+	if e := int64(scale) - int64(d.scale) + int64(d2.scale); e >= 0 {
+		num.Mul(num, new(big.Int).Exp(big.NewInt(10), big.NewInt(e), nil))
+	} else {
+		den.Mul(den, new(big.Int).Exp(big.NewInt(10), big.NewInt(-e), nil))
+	}
+	return must(fitBig(roundDiv(num, den), int(scale)))
+}
+
+// Neg returns -d
+func (d Decimal) Neg() Decimal {
+	if d.coef == math.MinInt64 {
+		return must(fitBig(new(big.Int).Neg(big.NewInt(d.coef)), int(d.scale)))
+	}
+	return Decimal{coef: -d.coef, scale: d.scale}
+}
+
+// Abs returns |d|
+func (d Decimal) Abs() Decimal {
+	if d.coef < 0 {
+		return d.Neg()
+	}
+	return d
+}
+
+// Sign returns -1, 0 or 1
+func (d Decimal) Sign() int {
+	switch {
+	case d.coef < 0:
+		return -1
+	case d.coef > 0:
+		return 1
+	}
+	return 0
+}
+
+// IsZero returns true if d is 0, whatever its number of decimals
+func (d Decimal) IsZero() bool {
+	return d.coef == 0
+}
+
+// IsPositive returns true if d > 0
+func (d Decimal) IsPositive() bool {
+	return d.coef > 0
+}
+
+// IsNegative returns true if d < 0
+func (d Decimal) IsNegative() bool {
+	return d.coef < 0
+}
+
+// Cmp returns -1 if d < d2, 0 if they are equal and 1 if d > d2
+func (d Decimal) Cmp(d2 Decimal) int {
+	if a, b, _, ok := align(d, d2); ok {
+		switch {
+		case a < b:
+			return -1
+		case a > b:
+			return 1
+		}
+		return 0
+	}
+	scale := max(d.scale, d2.scale)
+	return d.bigAt(scale).Cmp(d2.bigAt(scale))
+}
+
+// Equal returns true if d and d2 have the same value, e.g. 1.5 & 1.50
+func (d Decimal) Equal(d2 Decimal) bool {
+	return d.Cmp(d2) == 0
+}
+
+// Normalize returns d without trailing zero decimals, so equal values have the same representation, e.g. as map keys
+func (d Decimal) Normalize() Decimal {
+	if d.coef == 0 {
+		return Zero
+	}
+	for d.scale > 0 && d.coef%10 == 0 {
+		d.coef /= 10
+		d.scale--
+	}
+	return d
+}
+
+func (d Decimal) LessThan(d2 Decimal) bool           { return d.Cmp(d2) < 0 }
+func (d Decimal) LessThanOrEqual(d2 Decimal) bool    { return d.Cmp(d2) <= 0 }
+func (d Decimal) GreaterThan(d2 Decimal) bool        { return d.Cmp(d2) > 0 }
+func (d Decimal) GreaterThanOrEqual(d2 Decimal) bool { return d.Cmp(d2) >= 0 }
+
+// Min returns the smallest of the given decimals
+func Min(first Decimal, rest ...Decimal) Decimal {
+	for _, d := range rest {
+		if d.LessThan(first) {
+			first = d
+		}
+	}
+	return first
+}
+
+// Max returns the largest of the given decimals
+func Max(first Decimal, rest ...Decimal) Decimal {
+	for _, d := range rest {
+		if d.GreaterThan(first) {
+			first = d
+		}
+	}
+	return first
+}
+
+// Round rounds d half away from zero to the given number of decimals
+func (d Decimal) Round(scale uint8) Decimal {
+	if scale >= d.scale {
+		return d
+	}
+	p := pow10[d.scale-scale]
+	q, r := d.coef/p, d.coef%p
+	if r < 0 {
+		if -r >= p-(-r) {
+			q--
+		}
+	} else if r >= p-r {
+		q++
+	}
+	return Decimal{coef: q, scale: scale}
+}
+
+// Truncate drops the decimals after the given number, rounding toward zero
+func (d Decimal) Truncate(scale uint8) Decimal {
+	if scale >= d.scale {
+		return d
+	}
+	return Decimal{coef: d.coef / pow10[d.scale-scale], scale: scale}
+}
+
+// Floor rounds d toward negative infinity to the given number of decimals, e.g. to round a buy price to the tick
+func (d Decimal) Floor(scale uint8) Decimal {
+	t := d.Truncate(scale)
+	if d.coef < 0 && !t.Equal(d) {
+		t.coef--
+	}
+	return t
+}
+
+// Ceil rounds d toward positive infinity to the given number of decimals, e.g. to round a sell price to the tick
+func (d Decimal) Ceil(scale uint8) Decimal {
+	t := d.Truncate(scale)
+	if d.coef > 0 && !t.Equal(d) {
+		t.coef++
+	}
+	return t
+}
+
+// Float64 returns the nearest float64 to d
+func (d Decimal) Float64() float64 {
+	if d.scale == 0 {
+		return float64(d.coef)
+	}
+	f, _ := strconv.ParseFloat(d.String(), 64)
+	return f
+}
+
+// String returns d with all its decimals, e.g. "-0.0100"
+func (d Decimal) String() string {
+	if d.scale == 0 {
+		return strconv.FormatInt(d.coef, 10)
+	}
+	u := uint64(d.coef)
+	if d.coef < 0 {
+		u = -u
+	}
+	digits := strconv.FormatUint(u, 10)
+	scale := int(d.scale)
+	if len(digits) <= scale {
+		digits = strings.Repeat("0", scale-len(digits)+1) + digits
+	}
+	s := digits[:len(digits)-scale] + "." + digits[len(digits)-scale:]
+	if d.coef < 0 {
+		return "-" + s
+	}
+	return s
+}
+
+// StringFixed returns d rounded or padded with zeros to the given number of decimals
+func (d Decimal) StringFixed(scale uint8) string {
+	r := d.Round(scale)
+	s := r.String()
+	if pad := int(scale) - int(r.scale); pad > 0 {
+		if r.scale == 0 {
+			s += "."
+		}
+		s += strings.Repeat("0", pad)
+	}
+	return s
+}
+
+// MarshalJSON encodes d as a string, so no precision is lost by JSON parsers using floats
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + d.String() + `"`), nil
+}
+
+// UnmarshalJSON accepts a string or a number. An empty string is 0, null leaves d unchanged.
+func (d *Decimal) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	if len(data) >= 2 && data[0] == '"' && data[len(data)-1] == '"' {
+		data = data[1 : len(data)-1]
+	}
+	return d.UnmarshalText(data)
+}
+
+// MarshalText implements encoding.TextMarshaler
+func (d Decimal) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler, an empty text is 0
+func (d *Decimal) UnmarshalText(text []byte) error {
+	if len(text) == 0 {
+		*d = Zero
+		return nil
+	}
+	v, err := ParseBytes(text)
+	if err != nil {
+		return err
+	}
+	*d = v
+	return nil
+}
+
+// align returns the coefficients of a & b with the same number of decimals, false if one of them overflows
+func align(a, b Decimal) (int64, int64, uint8, bool) {
+	switch {
+	case a.scale == b.scale:
+		return a.coef, b.coef, a.scale, true
+	case a.scale > b.scale:
+		bc, ok := mul64(b.coef, pow10[a.scale-b.scale])
+		return a.coef, bc, a.scale, ok
+	default:
+		ac, ok := mul64(a.coef, pow10[b.scale-a.scale])
+		return ac, b.coef, b.scale, ok
+	}
+}
+
+func (d Decimal) bigAt(scale uint8) *big.Int {
+	b := big.NewInt(d.coef)
+	if scale > d.scale {
+		b.Mul(b, big.NewInt(pow10[scale-d.scale]))
+	}
+	return b
+}
+
+// fitBig converts b * 10^-scale to a Decimal, dropping the decimals that don't fit. It returns false if the integer
+// part doesn't fit.
+func fitBig(b *big.Int, scale int) (Decimal, bool) {
+	ten := big.NewInt(10)
+	for scale > MaxScale || (scale > 0 && !b.IsInt64()) {
+		b = roundDiv(b, ten)
+		scale--
+	}
+	if !b.IsInt64() {
+		return Zero, false
+	}
+	return Decimal{coef: b.Int64(), scale: uint8(scale)}, true
+}
+
+func must(d Decimal, ok bool) Decimal {
+	if !ok {
+		panic("decimal: overflow")
+	}
+	return d
+}
+
+// roundDiv returns num / den rounded half away from zero
+func roundDiv(num, den *big.Int) *big.Int {
+	q, r := new(big.Int).QuoRem(num, den, new(big.Int))
+	if r.Sign() == 0 {
+		return q
+	}
+	r.Abs(r).Lsh(r, 1)
+	if r.Cmp(new(big.Int).Abs(den)) >= 0 {
+		if num.Sign()*den.Sign() < 0 {
+			q.Sub(q, big.NewInt(1))
+		} else {
+			q.Add(q, big.NewInt(1))
+		}
+	}
+	return q
+}
+
+func add64(a, b int64) (int64, bool) {
+	c := a + b
+	if (a > 0 && b > 0 && c < 0) || (a < 0 && b < 0 && c >= 0) {
+		return 0, false
+	}
+	return c, true
+}
+
+func mul64(a, b int64) (int64, bool) {
+	if a == 0 || b == 0 {
+		return 0, true
+	}
+	c := a * b
+	if (a == -1 && b == math.MinInt64) || (b == -1 && a == math.MinInt64) || c/b != a {
+		return 0, false
+	}
+	return c, true
+}
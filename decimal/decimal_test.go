@@ -0,0 +1,245 @@
+package decimal
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"0", "0"},
+		{"3012.25", "3012.25"},
+		{"-0.0010", "-0.0010"},
+		{"+1.50", "1.50"},
+		{".5", "0.5"},
+		{"5.", "5"},
+		{"1e-5", "0.00001"},
+		{"1.5E3", "1500"},
+		{"2.5e+2", "250"},
+		{"123456789012345678", "123456789012345678"},
+		{"0.123456789012345678", "0.123456789012345678"},
+		// more decimals than MaxScale are rounded
+		{"0.1234567890123456789", "0.123456789012345679"},
+		{"1e-64", "0.000000000000000000"},
+		// a coefficient out of the int64 range loses its last decimals
+		{"1234567890123456789.5", "1234567890123456790"},
+		{"-9223372036854775808", "-9223372036854775808"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			d, err := Parse(tt.in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := d.String(); got != tt.want {
+				t.Errorf("Parse(%q) = %s, want %s", tt.in, got, tt.want)
+			}
+			b, err := ParseBytes([]byte(tt.in))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if b != d {
+				t.Errorf("ParseBytes(%q) = %v, Parse = %v", tt.in, b, d)
+			}
+		})
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := []string{
+		"",
+		"-",
+		".",
+		"abc",
+		"1.2.3",
+		"1e",
+		"e5",
+		"1e5.5",
+		"--1",
+		"1_000",
+		"1e65",
+		"1e-65",
+		"1e999999999",
+		"1e-999999999",
+		"1" + strings.Repeat("0", 200),
+		"99999999999999999999",
+	}
+	for _, in := range tests {
+		t.Run(in, func(t *testing.T) {
+			start := time.Now()
+			if d, err := Parse(in); err == nil {
+				t.Errorf("Parse(%q) = %s, expected an error", in, d)
+			}
+			if _, err := ParseBytes([]byte(in)); err == nil {
+				t.Errorf("ParseBytes(%q) expected an error", in)
+			}
+			if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+				t.Errorf("Parse(%q) took %s", in, elapsed)
+			}
+		})
+	}
+}
+
+func TestArithmetic(t *testing.T) {
+	d := MustParse
+	tests := []struct {
+		name string
+		got  Decimal
+		want string
+	}{
+		{"add", d("0.1").Add(d("0.2")), "0.3"},
+		{"add scales", d("1.5").Add(d("0.25")), "1.75"},
+		{"sub", d("1").Sub(d("1.001")), "-0.001"},
+		{"mul", d("1.5").Mul(d("-2.25")), "-3.375"},
+		{"mul rounded", d("0.123456789").Mul(d("0.123456789123")), "0.015241578765375706"},
+		{"mul big", d("9223372036854775807").Mul(d("0.5")), "4611686018427387904"},
+		{"div", d("1").Div(d("3")), "0.333333333333333333"},
+		{"div round", d("2").DivRound(d("3"), 2), "0.67"},
+		{"div round negative", d("-2").DivRound(d("3"), 2), "-0.67"},
+		{"div exact", d("7.5").DivRound(d("2.5"), 0), "3"},
+		{"neg", d("1.20").Neg(), "-1.20"},
+		{"abs", d("-1.20").Abs(), "1.20"},
+		{"round half up", d("1.25").Round(1), "1.3"},
+		{"round half negative", d("-1.25").Round(1), "-1.3"},
+		{"round down", d("1.24").Round(1), "1.2"},
+		{"truncate", d("-1.29").Truncate(1), "-1.2"},
+		{"floor", d("-1.21").Floor(1), "-1.3"},
+		{"floor exact", d("1.20").Floor(1), "1.2"},
+		{"ceil", d("1.21").Ceil(1), "1.3"},
+		{"ceil negative", d("-1.29").Ceil(1), "-1.2"},
+		{"min", Min(d("2"), d("-1"), d("1")), "-1"},
+		{"max", Max(d("2"), d("-1"), d("3.5")), "3.5"},
+		{"from ticks", FromTicks(1500, 4), "0.1500"},
+		{"from float", NewFromFloat(0.1), "0.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.got.String(); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCmp(t *testing.T) {
+	d := MustParse
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1.5", "1.50", 0},
+		{"-0", "0.000", 0},
+		{"1.05", "1.5", -1},
+		{"-1", "-2", 1},
+		{"9223372036854775807", "0.000000000000000001", 1},
+	}
+	for _, tt := range tests {
+		if got := d(tt.a).Cmp(d(tt.b)); got != tt.want {
+			t.Errorf("%s cmp %s = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+		if got := d(tt.b).Cmp(d(tt.a)); got != -tt.want {
+			t.Errorf("%s cmp %s = %d, want %d", tt.b, tt.a, got, -tt.want)
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"1.50", "1.5"},
+		{"1.5", "1.5"},
+		{"-0.000", "0"},
+		{"100", "100"},
+		{"100.00", "100"},
+		{"-2.0100", "-2.01"},
+	}
+	for _, tt := range tests {
+		got := MustParse(tt.in).Normalize()
+		if got != MustParse(tt.want) {
+			t.Errorf("%s.Normalize() = %v (scale %d), want %s", tt.in, got, got.Scale(), tt.want)
+		}
+	}
+}
+
+func TestTicks(t *testing.T) {
+	tests := []struct {
+		in       string
+		decimals uint8
+		want     int64
+		err      bool
+	}{
+		{"0.15", 4, 1500, false},
+		{"0.1500", 2, 15, false},
+		{"0.155", 2, 0, true},
+		{"-3", 2, -300, false},
+		{"9223372036854775807", 1, 0, true},
+	}
+	for _, tt := range tests {
+		got, err := MustParse(tt.in).Ticks(tt.decimals)
+		if (err != nil) != tt.err || got != tt.want {
+			t.Errorf("%s.Ticks(%d) = %d, %v", tt.in, tt.decimals, got, err)
+		}
+	}
+}
+
+func TestOverflowPanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic")
+		}
+	}()
+	MustParse("9223372036854775807").Add(NewFromInt(1))
+}
+
+func TestJSON(t *testing.T) {
+	var v struct {
+		A Decimal `json:"a"`
+		B Decimal `json:"b"`
+		C Decimal `json:"c"`
+		D Decimal `json:"d"`
+	}
+	v.D = NewFromInt(7)
+	if err := json.Unmarshal([]byte(`{"a":"1.50","b":-0.25,"c":"","d":null}`), &v); err != nil {
+		t.Fatal(err)
+	}
+	if v.A.String() != "1.50" || v.B.String() != "-0.25" || !v.C.IsZero() || v.D.String() != "7" {
+		t.Errorf("unexpected values %+v", v)
+	}
+	out, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != `{"a":"1.50","b":"-0.25","c":"0","d":"7"}` {
+		t.Errorf("unexpected JSON %s", out)
+	}
+	if err := json.Unmarshal([]byte(`{"a":"1e999999999"}`), &v); err == nil {
+		t.Error("expected an error for an out of range exponent")
+	}
+}
+
+func FuzzParseBytes(f *testing.F) {
+	for _, s := range []string{"0", "3012.25", "-0.0010", "1e-5", "123456789012345678901", "1e64", ".5"} {
+		f.Add(s)
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		d, err := Parse(s)
+		b, errBytes := ParseBytes([]byte(s))
+		if (err != nil) != (errBytes != nil) || d != b {
+			t.Fatalf("Parse(%q) = %v, %v but ParseBytes = %v, %v", s, d, err, b, errBytes)
+		}
+		if err != nil {
+			return
+		}
+		// the printed value parses back to the same decimal
+		again, err := Parse(d.String())
+		if err != nil || again != d {
+			t.Fatalf("Parse(%q) = %v, which parses back to %v, %v", s, d, again, err)
+		}
+	})
+}
@@ -0,0 +1,216 @@
+package downloader
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/u20024804/lighter-ex/candles"
+	"github.com/u20024804/lighter-ex/client"
+)
+
+// Dataset kinds
+const (
+	Candles  = "candles"
+	Fundings = "fundings"
+	Trades   = "trades"
+)
+
+// Row is a single record of a dataset. The column order returned by Header & Record is part of the schema and
+// must not change, add new columns at the end.
+type Row interface {
+	Time() int64
+	// Key identifies the row, rows with the same key are written once
+	Key() string
+	Header() []string
+	Record() []string
+}
+
+// CandleRow is the schema of the candles dataset. Decimals are kept as returned by the API, so no precision is lost.
+type CandleRow struct {
+	MarketId            int32  `parquet:"market_id"`
+	Resolution          string `parquet:"resolution"`
+	Timestamp           int64  `parquet:"timestamp"`
+	Open                string `parquet:"open"`
+	High                string `parquet:"high"`
+	Low                 string `parquet:"low"`
+	Close               string `parquet:"close"`
+	Volume              string `parquet:"volume"`
+	QuoteVolume         string `parquet:"quote_volume"`
+	TradesCount         int32  `parquet:"trades_count"`
+	TakerBuyVolume      string `parquet:"taker_buy_volume"`
+	TakerBuyQuoteVolume string `parquet:"taker_buy_quote_volume"`
+}
+
+func (r CandleRow) Time() int64 { return r.Timestamp }
+func (r CandleRow) Key() string { return strconv.FormatInt(r.Timestamp, 10) }
+func (r CandleRow) Header() []string {
+	return []string{"market_id", "resolution", "timestamp", "open", "high", "low", "close", "volume", "quote_volume", "trades_count", "taker_buy_volume", "taker_buy_quote_volume"}
+}
+func (r CandleRow) Record() []string {
+	return []string{itoa(int64(r.MarketId)), r.Resolution, itoa(r.Timestamp), r.Open, r.High, r.Low, r.Close, r.Volume, r.QuoteVolume, itoa(int64(r.TradesCount)), r.TakerBuyVolume, r.TakerBuyQuoteVolume}
+}
+
+// FundingRow is the schema of the fundings dataset
+type FundingRow struct {
+	MarketId        int32  `parquet:"market_id"`
+	Timestamp       int64  `parquet:"timestamp"`
+	FundingRate     string `parquet:"funding_rate"`
+	IndexPrice      string `parquet:"index_price"`
+	MarkPrice       string `parquet:"mark_price"`
+	PremiumRate     string `parquet:"premium_rate"`
+	NextFundingTime int64  `parquet:"next_funding_time"`
+}
+
+func (r FundingRow) Time() int64 { return r.Timestamp }
+func (r FundingRow) Key() string { return strconv.FormatInt(r.Timestamp, 10) }
+func (r FundingRow) Header() []string {
+	return []string{"market_id", "timestamp", "funding_rate", "index_price", "mark_price", "premium_rate", "next_funding_time"}
+}
+func (r FundingRow) Record() []string {
+	return []string{itoa(int64(r.MarketId)), itoa(r.Timestamp), r.FundingRate, r.IndexPrice, r.MarkPrice, r.PremiumRate, itoa(r.NextFundingTime)}
+}
+
+// TradeRow is the schema of the trades dataset
+type TradeRow struct {
+	MarketId      int32  `parquet:"market_id"`
+	TradeId       int64  `parquet:"trade_id"`
+	Timestamp     int64  `parquet:"timestamp"`
+	Price         string `parquet:"price"`
+	Size          string `parquet:"size"`
+	QuoteQuantity string `parquet:"quote_quantity"`
+	Side          string `parquet:"side"`
+	IsMakerAsk    bool   `parquet:"is_maker_ask"`
+	BlockHeight   int64  `parquet:"block_height"`
+	TxHash        string `parquet:"tx_hash"`
+}
+
+func (r TradeRow) Time() int64 { return r.Timestamp }
+func (r TradeRow) Key() string { return strconv.FormatInt(r.TradeId, 10) }
+func (r TradeRow) Header() []string {
+	return []string{"market_id", "trade_id", "timestamp", "price", "size", "quote_quantity", "side", "is_maker_ask", "block_height", "tx_hash"}
+}
+func (r TradeRow) Record() []string {
+	return []string{itoa(int64(r.MarketId)), itoa(r.TradeId), itoa(r.Timestamp), r.Price, r.Size, r.QuoteQuantity, r.Side, strconv.FormatBool(r.IsMakerAsk), itoa(r.BlockHeight), r.TxHash}
+}
+
+func itoa(v int64) string {
+	return strconv.FormatInt(v, 10)
+}
+
+// fetchFunc returns the rows in [start, end), in milliseconds, at most limit of them, oldest first
+type fetchFunc[T Row] func(marketId uint8, start, end int64, limit int32) ([]T, error)
+
+func candleFetcher(apiClient *client.HTTPClient, resolution string) (fetchFunc[CandleRow], int64, error) {
+	d, err := candles.ResolutionDuration(resolution)
+	if err != nil {
+		return nil, 0, err
+	}
+	fetch := func(marketId uint8, start, end int64, limit int32) ([]CandleRow, error) {
+		// the API counts bars back from the end, so the window is bounded to limit bars
+		if maxEnd := start + int64(limit)*d.Milliseconds(); end > maxEnd {
+			end = maxEnd
+		}
+		resp, err := apiClient.GetCandlesticks(marketId, resolution, start, end, limit, nil)
+		if err != nil {
+			return nil, err
+		}
+		rows := make([]CandleRow, 0, len(resp.Candlesticks))
+		for _, c := range resp.Candlesticks {
+			if c.Timestamp < start || c.Timestamp >= end {
+				continue
+			}
+			rows = append(rows, CandleRow{
+				MarketId:            int32(marketId),
+				Resolution:          resolution,
+				Timestamp:           c.Timestamp,
+				Open:                c.Open.String(),
+				High:                c.High.String(),
+				Low:                 c.Low.String(),
+				Close:               c.Close.String(),
+				Volume:              c.Volume.String(),
+				QuoteVolume:         c.QuoteVolume.String(),
+				TradesCount:         c.TradesCount,
+				TakerBuyVolume:      c.TakerBuyVolume.String(),
+				TakerBuyQuoteVolume: c.TakerBuyQuoteVolume.String(),
+			})
+		}
+		return rows, nil
+	}
+	return fetch, d.Milliseconds(), nil
+}
+
+func fundingFetcher(apiClient *client.HTTPClient) fetchFunc[FundingRow] {
+	return func(marketId uint8, start, end int64, limit int32) ([]FundingRow, error) {
+		last := end - 1
+		resp, err := apiClient.GetFundings(marketId, &start, &last, &limit)
+		if err != nil {
+			return nil, err
+		}
+		rows := make([]FundingRow, 0, len(resp.Fundings))
+		for _, f := range resp.Fundings {
+			rows = append(rows, FundingRow{
+				MarketId:        int32(marketId),
+				Timestamp:       f.Timestamp,
+				FundingRate:     f.FundingRate.String(),
+				IndexPrice:      f.IndexPrice.String(),
+				MarkPrice:       f.MarkPrice.String(),
+				PremiumRate:     f.PremiumRate.String(),
+				NextFundingTime: f.NextFundingTime,
+			})
+		}
+		return rows, nil
+	}
+}
+
+func tradeFetcher(apiClient *client.HTTPClient) fetchFunc[TradeRow] {
+	return func(marketId uint8, start, end int64, limit int32) ([]TradeRow, error) {
+		last := end - 1
+		resp, err := apiClient.GetTrades(&marketId, nil, &start, &last, &limit, nil)
+		if err != nil {
+			return nil, err
+		}
+		rows := make([]TradeRow, 0, len(resp.Trades))
+		for _, t := range resp.Trades {
+			rows = append(rows, TradeRow{
+				MarketId:      int32(marketId),
+				TradeId:       t.TradeId,
+				Timestamp:     t.Timestamp,
+				Price:         t.Price.String(),
+				Size:          t.Size.String(),
+				QuoteQuantity: t.QuoteQuantity.String(),
+				Side:          t.Side,
+				IsMakerAsk:    t.IsMakerAsk,
+				BlockHeight:   t.BlockHeight,
+				TxHash:        t.TxHash,
+			})
+		}
+		return rows, nil
+	}
+}
+
+// partitionName returns the name of the partition holding t
+func partitionName(t time.Time, partition string) string {
+	if partition == PartitionMonth {
+		return t.UTC().Format("2006-01")
+	}
+	return t.UTC().Format("2006-01-02")
+}
+
+// partitionBounds returns [start, end) of the partition holding t
+func partitionBounds(t time.Time, partition string) (time.Time, time.Time) {
+	t = t.UTC()
+	if partition == PartitionMonth {
+		start := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+		return start, start.AddDate(0, 1, 0)
+	}
+	start := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	return start, start.AddDate(0, 0, 1)
+}
+
+func checkFormat(format string) error {
+	if format != FormatCSV && format != FormatParquet {
+		return fmt.Errorf("unsupported format %q, use %s or %s", format, FormatCSV, FormatParquet)
+	}
+	return nil
+}
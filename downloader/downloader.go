@@ -0,0 +1,320 @@
+// Package downloader fetches historical candles, fundings and trades into partitioned CSV or Parquet files.
+//
+// Files are laid out as
+//
+//	<dir>/<dataset>[/<resolution>]/market=<id>/<partition>.<format>
+//
+// with one file per UTC day (or month), so a dataset can be extended or repaired partition by partition.
+// All timestamps are in milliseconds.
+package downloader
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/u20024804/lighter-ex/client"
+)
+
+// Partitioning of the files
+const (
+	PartitionDay   = "day"
+	PartitionMonth = "month"
+)
+
+const stateFile = "_state.json"
+
+// Config describes what to download
+type Config struct {
+	Markets  []uint8
+	Datasets []string
+	Start    time.Time
+	End      time.Time
+	// Resolution of the candles dataset, defaults to "1"
+	Resolution string
+	// Format is FormatCSV (default) or FormatParquet
+	Format string
+	// Partition is PartitionDay (default) or PartitionMonth
+	Partition string
+	Dir       string
+	// Limit is the max number of rows per request, defaults to 500 for candles & 100 for the others
+	Limit int32
+	// RequestInterval is waited between requests, to stay within the rate limits
+	RequestInterval time.Duration
+	// Resume skips what a previous run already stored, based on the last stored timestamp of every market
+	Resume bool
+}
+
+// logger returns the logger of the SDK, see client.SetLogger
+func logger() *slog.Logger {
+	return client.Logger()
+}
+
+// Downloader fetches datasets with an HTTPClient
+type Downloader struct {
+	apiClient *client.HTTPClient
+	config    Config
+}
+
+func New(apiClient *client.HTTPClient, config Config) (*Downloader, error) {
+	if apiClient == nil {
+		return nil, fmt.Errorf("HTTPClient is nil")
+	}
+	if config.Resolution == "" {
+		config.Resolution = "1"
+	}
+	if config.Format == "" {
+		config.Format = FormatCSV
+	}
+	if err := checkFormat(config.Format); err != nil {
+		return nil, err
+	}
+	if config.Partition == "" {
+		config.Partition = PartitionDay
+	}
+	if config.Partition != PartitionDay && config.Partition != PartitionMonth {
+		return nil, fmt.Errorf("unsupported partition %q, use %s or %s", config.Partition, PartitionDay, PartitionMonth)
+	}
+	if config.End.IsZero() {
+		config.End = time.Now()
+	}
+	if !config.Start.Before(config.End) {
+		return nil, fmt.Errorf("start should be before end")
+	}
+	if len(config.Markets) == 0 {
+		return nil, fmt.Errorf("no market to download")
+	}
+	if config.Dir == "" {
+		return nil, fmt.Errorf("no output directory")
+	}
+	return &Downloader{apiClient: apiClient, config: config}, nil
+}
+
+// Run downloads every dataset of every market
+func (d *Downloader) Run(ctx context.Context) error {
+	for _, dataset := range d.config.Datasets {
+		for _, marketId := range d.config.Markets {
+			var err error
+			switch dataset {
+			case Candles:
+				fetch, step, ferr := candleFetcher(d.apiClient, d.config.Resolution)
+				if ferr != nil {
+					return ferr
+				}
+				err = download(ctx, d, filepath.Join(Candles, d.config.Resolution), marketId, d.limit(500), pageByWindow(fetch, step))
+			case Fundings:
+				err = download(ctx, d, Fundings, marketId, d.limit(100), pageByRows(fundingFetcher(d.apiClient)))
+			case Trades:
+				err = download(ctx, d, Trades, marketId, d.limit(100), pageByRows(tradeFetcher(d.apiClient)))
+			default:
+				return fmt.Errorf("unknown dataset %q, use %s, %s or %s", dataset, Candles, Fundings, Trades)
+			}
+			if err != nil {
+				return fmt.Errorf("%s of market %d: %w", dataset, marketId, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (d *Downloader) limit(def int32) int32 {
+	if d.config.Limit > 0 {
+		return d.config.Limit
+	}
+	return def
+}
+
+// state is stored next to the partitions of a market
+type state struct {
+	LastTimestamp int64  `json:"last_timestamp"`
+	Format        string `json:"format"`
+	Partition     string `json:"partition"`
+}
+
+func readState(dir string) (*state, error) {
+	b, err := os.ReadFile(filepath.Join(dir, stateFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	s := &state{}
+	if err := json.Unmarshal(b, s); err != nil {
+		return nil, fmt.Errorf("invalid %s in %s: %w", stateFile, dir, err)
+	}
+	return s, nil
+}
+
+func writeState(dir string, s *state) error {
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(dir, stateFile+".tmp")
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(dir, stateFile))
+}
+
+// window is a time range [start, end) left to fetch
+type window struct {
+	start, end int64
+}
+
+// pageFunc fetches rows of a window and returns them along with the windows left to fetch
+type pageFunc[T Row] func(marketId uint8, w window, limit int32) ([]T, []window, error)
+
+// pageByWindow pages endpoints that return a fixed number of rows per time window, like candles
+func pageByWindow[T Row](fetch fetchFunc[T], step int64) pageFunc[T] {
+	return func(marketId uint8, w window, limit int32) ([]T, []window, error) {
+		rows, err := fetch(marketId, w.start, w.end, limit)
+		if next := w.start + int64(limit)*step; next < w.end {
+			return rows, []window{{next, w.end}}, err
+		}
+		return rows, nil, err
+	}
+}
+
+// pageByRows pages endpoints that return limit rows of the window, like fundings & trades. Whether those are the
+// oldest or the newest rows isn't documented, so it's told by the order of the page: oldest first pages forward
+// from the last timestamp, newest first pages backward from the first one.
+func pageByRows[T Row](fetch fetchFunc[T]) pageFunc[T] {
+	return func(marketId uint8, w window, limit int32) ([]T, []window, error) {
+		rows, err := fetch(marketId, w.start, w.end, limit)
+		if err != nil || len(rows) < int(limit) {
+			return rows, nil, err
+		}
+		// rows sharing the boundary timestamp may continue in the next page, they are deduplicated by key
+		first, last := rows[0].Time(), rows[len(rows)-1].Time()
+		switch {
+		case first < last:
+			return rows, []window{{last, w.end}}, nil
+		case first > last:
+			return rows, []window{{w.start, last + 1}}, nil
+		}
+		// a page at a single timestamp doesn't tell the order, so both sides are fetched
+		logger().Warn("More rows than a page at a single timestamp, some may be missing", client.LogKeyMarket, marketId,
+			"timestamp", first, "limit", limit)
+		var rest []window
+		if w.start < first {
+			rest = append(rest, window{w.start, first})
+		}
+		if first+1 < w.end {
+			rest = append(rest, window{first + 1, w.end})
+		}
+		return rows, rest, nil
+	}
+}
+
+func download[T Row](ctx context.Context, d *Downloader, dataset string, marketId uint8, limit int32, page pageFunc[T]) error {
+	cfg := d.config
+	dir := filepath.Join(cfg.Dir, dataset, fmt.Sprintf("market=%d", marketId))
+
+	from := cfg.Start
+	if cfg.Resume {
+		s, err := readState(dir)
+		if err != nil {
+			return err
+		}
+		if s != nil {
+			if s.Format != cfg.Format || s.Partition != cfg.Partition {
+				return fmt.Errorf("%s was written as %s/%s partitions, can't resume as %s/%s", dir, s.Format, s.Partition, cfg.Format, cfg.Partition)
+			}
+			// the partition holding the last row may be incomplete, so it's fetched again
+			if last := time.UnixMilli(s.LastTimestamp); last.After(from) {
+				from, _ = partitionBounds(last, cfg.Partition)
+				if from.Before(cfg.Start) {
+					from = cfg.Start
+				}
+			}
+		}
+	}
+
+	partStart, _ := partitionBounds(from, cfg.Partition)
+	for ; partStart.Before(cfg.End); _, partStart = partitionBounds(partStart, cfg.Partition) {
+		_, partEnd := partitionBounds(partStart, cfg.Partition)
+		start, end := partStart, partEnd
+		if start.Before(from) {
+			start = from
+		}
+		if end.After(cfg.End) {
+			end = cfg.End
+		}
+
+		rows, err := fetchRange(ctx, d, marketId, start.UnixMilli(), end.UnixMilli(), limit, page)
+		if err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			continue
+		}
+
+		path := filepath.Join(dir, partitionName(partStart, cfg.Partition)+"."+cfg.Format)
+		if err := writeRows(path, cfg.Format, rows); err != nil {
+			return err
+		}
+		err = writeState(dir, &state{
+			LastTimestamp: rows[len(rows)-1].Time(),
+			Format:        cfg.Format,
+			Partition:     cfg.Partition,
+		})
+		if err != nil {
+			return err
+		}
+		logger().Info("Partition downloaded", client.LogKeyMarket, marketId, "path", path, "rows", len(rows))
+	}
+	return nil
+}
+
+// fetchRange returns the deduplicated rows of [start, end), oldest first
+func fetchRange[T Row](ctx context.Context, d *Downloader, marketId uint8, start, end int64, limit int32, page pageFunc[T]) ([]T, error) {
+	seen := make(map[string]struct{})
+	var rows []T
+	for pending := []window{{start, end}}; len(pending) > 0; {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		w := pending[len(pending)-1]
+		batch, rest, err := page(marketId, w, limit)
+		if err != nil {
+			return nil, err
+		}
+		for _, row := range batch {
+			if row.Time() < start || row.Time() >= end {
+				continue
+			}
+			if _, ok := seen[row.Key()]; ok {
+				continue
+			}
+			seen[row.Key()] = struct{}{}
+			rows = append(rows, row)
+		}
+		pending = pending[:len(pending)-1]
+		for _, w := range rest {
+			if w.start < w.end {
+				pending = append(pending, w)
+			}
+		}
+
+		if d.config.RequestInterval > 0 && len(pending) > 0 {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(d.config.RequestInterval):
+			}
+		}
+	}
+
+	sort.SliceStable(rows, func(i, j int) bool {
+		return rows[i].Time() < rows[j].Time()
+	})
+	return rows, nil
+}
@@ -0,0 +1,102 @@
+package downloader
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sort"
+	"strconv"
+	"testing"
+
+	"github.com/u20024804/lighter-ex/client"
+)
+
+// tradeServer serves the trades of /api/v1/trades between start_timestamp & end_timestamp, limit at a time, either
+// the oldest or the newest ones
+func tradeServer(t *testing.T, trades []client.Trade, newestFirst bool) (*httptest.Server, *int) {
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		q := r.URL.Query()
+		start, _ := strconv.ParseInt(q.Get("start_timestamp"), 10, 64)
+		end, _ := strconv.ParseInt(q.Get("end_timestamp"), 10, 64)
+		limit, _ := strconv.Atoi(q.Get("limit"))
+
+		var page []client.Trade
+		for _, trade := range trades {
+			if trade.Timestamp >= start && trade.Timestamp <= end {
+				page = append(page, trade)
+			}
+		}
+		sort.SliceStable(page, func(i, j int) bool {
+			if newestFirst {
+				return page[i].Timestamp > page[j].Timestamp
+			}
+			return page[i].Timestamp < page[j].Timestamp
+		})
+		if len(page) > limit {
+			page = page[:limit]
+		}
+		resp := client.TradesResponse{ResultCode: client.ResultCode{Code: 200}, Trades: page}
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			t.Error(err)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &requests
+}
+
+func TestFetchRangePages(t *testing.T) {
+	var trades []client.Trade
+	for i, ts := range []int64{5, 10, 10, 12, 20, 20, 20, 31, 40, 41, 41, 55, 60, 99} {
+		trades = append(trades, client.Trade{TradeId: int64(i + 1), Timestamp: ts})
+	}
+	// the partition is [10, 60)
+	var want []int64
+	for _, trade := range trades {
+		if trade.Timestamp >= 10 && trade.Timestamp < 60 {
+			want = append(want, trade.TradeId)
+		}
+	}
+
+	tests := []struct {
+		name        string
+		newestFirst bool
+		limit       int32
+	}{
+		// the 3 trades at 20 make a page at a single timestamp
+		{"oldest first", false, 3},
+		{"newest first", true, 3},
+		{"oldest first, pages of 4", false, 4},
+		{"newest first, pages of 4", true, 4},
+		{"single page", false, 100},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, requests := tradeServer(t, trades, tt.newestFirst)
+			d := &Downloader{apiClient: client.NewHTTPClient(srv.URL)}
+
+			rows, err := fetchRange(context.Background(), d, 1, 10, 60, tt.limit, pageByRows(tradeFetcher(d.apiClient)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []int64
+			for _, row := range rows {
+				got = append(got, row.TradeId)
+			}
+			sorted := slices.Clone(got)
+			slices.Sort(sorted)
+			if !slices.Equal(sorted, want) {
+				t.Fatalf("expected the trades %v, got %v", want, got)
+			}
+			if !slices.IsSortedFunc(rows, func(a, b TradeRow) int { return int(a.Timestamp - b.Timestamp) }) {
+				t.Errorf("expected the rows oldest first, got %v", got)
+			}
+			if tt.limit < int32(len(want)) && *requests < 2 {
+				t.Errorf("expected several pages, got %d requests", *requests)
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package downloader
+
+import (
+	"encoding/csv"
+	"os"
+	"path/filepath"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// Output formats
+const (
+	FormatCSV     = "csv"
+	FormatParquet = "parquet"
+)
+
+// writeRows writes rows to path atomically, so an interrupted download never leaves a truncated partition behind
+func writeRows[T Row](path, format string, rows []T) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+
+	var err error
+	if format == FormatParquet {
+		err = parquet.WriteFile(tmp, rows)
+	} else {
+		err = writeCSV(tmp, rows)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func writeCSV[T Row](path string, rows []T) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	var zero T
+	if err := w.Write(zero.Header()); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		if err := w.Write(row.Record()); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,238 @@
+// Package margin implements client side margin & liquidation math, so values shown to users can be
+// computed before a trade and checked against the ones reported by the exchange.
+//
+// All amounts are decimals in USDC and human readable units (not scaled by size or price decimals). Sums & products
+// are exact, divisions keep Precision decimals.
+package margin
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/u20024804/lighter-ex/client"
+	"github.com/u20024804/lighter-ex/decimal"
+)
+
+// Precision is the number of decimals kept by the divisions, e.g. for the liquidation prices
+const Precision = 12
+
+var (
+	ErrUnknownMarket   = errors.New("unknown market")
+	ErrNoPosition      = errors.New("no position on market")
+	ErrInvalidLeverage = errors.New("invalid leverage")
+	ErrInvalidPrice    = errors.New("invalid price")
+)
+
+// Health describes the margin requirements of the cross margin part of an account, or of a single isolated position
+type Health struct {
+	// AccountValue is the collateral plus unrealized pnl
+	AccountValue                 decimal.Decimal
+	InitialMarginRequirement     decimal.Decimal
+	MaintenanceMarginRequirement decimal.Decimal
+	CloseoutMarginRequirement    decimal.Decimal
+	// FreeCollateral is what's left for new orders, AccountValue - InitialMarginRequirement
+	FreeCollateral decimal.Decimal
+	// MarginUsage is InitialMarginRequirement / AccountValue, above 1 no risk increasing orders are accepted.
+	// It's 0 when the account value is 0.
+	MarginUsage decimal.Decimal
+	// Ratio is AccountValue / MaintenanceMarginRequirement, the position(s) get liquidated below 1.
+	// It's 0 without any requirement, use IsLiquidatable rather than comparing it to 1.
+	Ratio decimal.Decimal
+}
+
+// IsLiquidatable returns true if the account value is under the maintenance requirement
+func (h *Health) IsLiquidatable() bool {
+	return h.AccountValue.LessThan(h.MaintenanceMarginRequirement)
+}
+
+// Calculator does the margin math for a set of markets
+type Calculator struct {
+	markets map[uint8]MarketParams
+}
+
+// NewCalculator creates a calculator for the given markets
+func NewCalculator(markets ...MarketParams) *Calculator {
+	c := &Calculator{markets: make(map[uint8]MarketParams, len(markets))}
+	for _, m := range markets {
+		c.markets[m.MarketId] = m
+	}
+	return c
+}
+
+// NewCalculatorFromDetails creates a calculator from the response of GetOrderBookDetails
+func NewCalculatorFromDetails(details []client.OrderBookDetail) *Calculator {
+	markets := make([]MarketParams, 0, len(details))
+	for i := range details {
+		markets = append(markets, MarketParamsFromDetail(&details[i]))
+	}
+	return NewCalculator(markets...)
+}
+
+// Market returns the parameters of a market
+func (c *Calculator) Market(marketId uint8) (MarketParams, error) {
+	m, ok := c.markets[marketId]
+	if !ok {
+		return m, fmt.Errorf("%w %d", ErrUnknownMarket, marketId)
+	}
+	return m, nil
+}
+
+// initialMarginFraction returns the fraction used for the initial requirement of a position
+func (c *Calculator) initialMarginFraction(m MarketParams, p *Position) decimal.Decimal {
+	imf := p.InitialMarginFraction
+	if imf.IsZero() {
+		imf = m.DefaultInitialMarginFraction
+	}
+	return decimal.Max(imf, m.MinInitialMarginFraction)
+}
+
+// addRequirements adds the requirements of a position to h
+func (c *Calculator) addRequirements(h *Health, p *Position) error {
+	m, err := c.Market(p.MarketId)
+	if err != nil {
+		return err
+	}
+	notional := p.Notional()
+	h.InitialMarginRequirement = h.InitialMarginRequirement.Add(notional.Mul(c.initialMarginFraction(m, p)))
+	h.MaintenanceMarginRequirement = h.MaintenanceMarginRequirement.Add(notional.Mul(m.MaintenanceMarginFraction))
+	h.CloseoutMarginRequirement = h.CloseoutMarginRequirement.Add(notional.Mul(m.CloseoutMarginFraction))
+	return nil
+}
+
+func (h *Health) finish() {
+	h.FreeCollateral = h.AccountValue.Sub(h.InitialMarginRequirement)
+	h.MarginUsage = ratio(h.InitialMarginRequirement, h.AccountValue)
+	h.Ratio = ratio(h.AccountValue, h.MaintenanceMarginRequirement)
+}
+
+// ratio returns a / b, 0 if b is 0
+func ratio(a, b decimal.Decimal) decimal.Decimal {
+	if b.IsZero() {
+		return decimal.Zero
+	}
+	return a.DivRound(b, Precision)
+}
+
+// Health computes the health of the cross margin part of the account. Isolated positions are ignored.
+func (c *Calculator) Health(s *Snapshot) (Health, error) {
+	h := Health{AccountValue: s.Collateral}
+	for i := range s.Positions {
+		p := &s.Positions[i]
+		if p.IsIsolated() {
+			continue
+		}
+		h.AccountValue = h.AccountValue.Add(p.UnrealizedPnl())
+		if err := c.addRequirements(&h, p); err != nil {
+			return h, err
+		}
+	}
+	h.finish()
+	return h, nil
+}
+
+// IsolatedHealth computes the health of a single isolated position, backed by its allocated margin
+func (c *Calculator) IsolatedHealth(s *Snapshot, marketId uint8) (Health, error) {
+	p := s.Position(marketId)
+	if p == nil {
+		return Health{}, fmt.Errorf("%w %d", ErrNoPosition, marketId)
+	}
+	h := Health{AccountValue: p.AllocatedMargin.Add(p.UnrealizedPnl())}
+	if err := c.addRequirements(&h, p); err != nil {
+		return h, err
+	}
+	h.finish()
+	return h, nil
+}
+
+// LiquidationPrice returns the mark price at which the position on a market gets liquidated, assuming the price of
+// every other market stays where it is. 0 means the position can't be liquidated by a move of its own market.
+func (c *Calculator) LiquidationPrice(s *Snapshot, marketId uint8) (decimal.Decimal, error) {
+	p := s.Position(marketId)
+	if p == nil {
+		return decimal.Zero, fmt.Errorf("%w %d", ErrNoPosition, marketId)
+	}
+	m, err := c.Market(marketId)
+	if err != nil {
+		return decimal.Zero, err
+	}
+
+	var h Health
+	if p.IsIsolated() {
+		h, err = c.IsolatedHealth(s, marketId)
+	} else {
+		h, err = c.Health(s)
+	}
+	if err != nil {
+		return decimal.Zero, err
+	}
+
+	// Solve AccountValue(x) = MaintenanceRequirement(x) for the mark price x of this market:
+	//   AccountValue(x)           = AV - size * mark + size * x
+	//   MaintenanceRequirement(x) = MMR - |size| * mark * mmf + |size| * x * mmf
+	otherRequirement := h.MaintenanceMarginRequirement.Sub(p.Notional().Mul(m.MaintenanceMarginFraction))
+	valueAtZero := h.AccountValue.Sub(p.Size.Mul(p.MarkPrice))
+	denominator := p.Size.Sub(p.Size.Abs().Mul(m.MaintenanceMarginFraction))
+	if denominator.IsZero() {
+		return decimal.Zero, nil
+	}
+	price := otherRequirement.Sub(valueAtZero).DivRound(denominator, Precision)
+	if !price.IsPositive() {
+		return decimal.Zero, nil
+	}
+	return price, nil
+}
+
+// LiquidationPrices returns the liquidation price of every position, keyed by market
+func (c *Calculator) LiquidationPrices(s *Snapshot) (map[uint8]decimal.Decimal, error) {
+	prices := make(map[uint8]decimal.Decimal, len(s.Positions))
+	for i := range s.Positions {
+		price, err := c.LiquidationPrice(s, s.Positions[i].MarketId)
+		if err != nil {
+			return nil, err
+		}
+		prices[s.Positions[i].MarketId] = price
+	}
+	return prices, nil
+}
+
+// MaxOrderSize returns the largest size of a cross margin order at price that keeps the account within its
+// initial margin requirement when the position uses the given leverage. An order against the current position
+// first closes it, which releases its margin, before opening the other side.
+func (c *Calculator) MaxOrderSize(s *Snapshot, marketId uint8, isAsk bool, price, leverage decimal.Decimal) (decimal.Decimal, error) {
+	if !price.IsPositive() {
+		return decimal.Zero, ErrInvalidPrice
+	}
+	if !leverage.IsPositive() {
+		return decimal.Zero, ErrInvalidLeverage
+	}
+	m, err := c.Market(marketId)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	imf := decimal.NewFromInt(1).DivRound(leverage, Precision)
+	if imf.LessThan(m.MinInitialMarginFraction) {
+		maxLeverage := decimal.NewFromInt(1).DivRound(m.MinInitialMarginFraction, 2)
+		return decimal.Zero, fmt.Errorf("%w: %sx is above the maximum of %sx for market %d", ErrInvalidLeverage, leverage.StringFixed(2), maxLeverage.StringFixed(2), marketId)
+	}
+
+	h, err := c.Health(s)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	free := h.FreeCollateral
+
+	closable := decimal.Zero
+	if p := s.Position(marketId); p != nil && !p.IsIsolated() {
+		// the current position is re-margined at the new leverage
+		free = free.Add(p.Notional().Mul(c.initialMarginFraction(m, p).Sub(imf)))
+		if p.Size.IsPositive() == isAsk {
+			closable = p.Size.Abs()
+			free = free.Add(p.Notional().Mul(imf))
+		}
+	}
+
+	if !free.IsPositive() {
+		return closable, nil
+	}
+	return closable.Add(free.DivRound(price.Mul(imf), Precision)), nil
+}
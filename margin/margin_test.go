@@ -0,0 +1,267 @@
+package margin
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/u20024804/lighter-ex/client"
+	"github.com/u20024804/lighter-ex/decimal"
+	"github.com/u20024804/lighter-ex/types/txtypes"
+)
+
+var d = decimal.MustParse
+
+// testMarket has a 10% default & 2% min initial fraction, 5% maintenance & 3% closeout
+var testMarket = MarketParamsFromDetail(&client.OrderBookDetail{
+	MarketId:                     1,
+	DefaultInitialMarginFraction: 1_000,
+	MinInitialMarginFraction:     200,
+	MaintenanceMarginFraction:    500,
+	CloseoutMarginFraction:       300,
+})
+
+func position(size, entry, mark string) Position {
+	return Position{MarketId: 1, Size: d(size), EntryPrice: d(entry), MarkPrice: d(mark)}
+}
+
+func assertDecimal(t *testing.T, name string, got decimal.Decimal, want string) {
+	t.Helper()
+	if !got.Equal(d(want)) {
+		t.Errorf("%s = %s, want %s", name, got, want)
+	}
+}
+
+func TestMarketParamsFromDetail(t *testing.T) {
+	assertDecimal(t, "default imf", testMarket.DefaultInitialMarginFraction, "0.1")
+	assertDecimal(t, "min imf", testMarket.MinInitialMarginFraction, "0.02")
+	assertDecimal(t, "mmf", testMarket.MaintenanceMarginFraction, "0.05")
+	assertDecimal(t, "cmf", testMarket.CloseoutMarginFraction, "0.03")
+}
+
+func TestHealth(t *testing.T) {
+	c := NewCalculator(testMarket)
+	isolated := position("5", "100", "50")
+	isolated.MarketId = 1
+	isolated.MarginMode = txtypes.IsolatedMargin
+
+	tests := []struct {
+		name                                     string
+		snapshot                                 Snapshot
+		value, imr, mmr, cmr, free, usage, ratio string
+		liquidatable                             bool
+	}{
+		{
+			name:     "no position",
+			snapshot: Snapshot{Collateral: d("1000")},
+			value:    "1000", imr: "0", mmr: "0", cmr: "0", free: "1000", usage: "0", ratio: "0",
+		},
+		{
+			name:     "long in profit",
+			snapshot: Snapshot{Collateral: d("1000"), Positions: []Position{position("2", "100", "110")}},
+			value:    "1020", imr: "22", mmr: "11", cmr: "6.6", free: "998", usage: "0.021568627451", ratio: "92.727272727273",
+		},
+		{
+			name:     "short at a loss",
+			snapshot: Snapshot{Collateral: d("100"), Positions: []Position{position("-10", "100", "109")}},
+			value:    "10", imr: "109", mmr: "54.5", cmr: "32.7", free: "-99", usage: "10.9", ratio: "0.183486238532",
+			liquidatable: true,
+		},
+		{
+			name:     "isolated positions are ignored",
+			snapshot: Snapshot{Collateral: d("1000"), Positions: []Position{isolated}},
+			value:    "1000", imr: "0", mmr: "0", cmr: "0", free: "1000", usage: "0", ratio: "0",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, err := c.Health(&tt.snapshot)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertDecimal(t, "account value", h.AccountValue, tt.value)
+			assertDecimal(t, "initial requirement", h.InitialMarginRequirement, tt.imr)
+			assertDecimal(t, "maintenance requirement", h.MaintenanceMarginRequirement, tt.mmr)
+			assertDecimal(t, "closeout requirement", h.CloseoutMarginRequirement, tt.cmr)
+			assertDecimal(t, "free collateral", h.FreeCollateral, tt.free)
+			assertDecimal(t, "margin usage", h.MarginUsage, tt.usage)
+			assertDecimal(t, "ratio", h.Ratio, tt.ratio)
+			if h.IsLiquidatable() != tt.liquidatable {
+				t.Errorf("IsLiquidatable = %v", h.IsLiquidatable())
+			}
+		})
+	}
+}
+
+func TestHealthLeverage(t *testing.T) {
+	c := NewCalculator(testMarket)
+	tests := []struct {
+		imf, want string
+	}{
+		// the fraction picked with UpdateLeverage
+		{"0.25", "50"},
+		// 0 is the default of the market
+		{"0", "20"},
+		// a fraction below the minimum of the market is raised to it
+		{"0.01", "4"},
+	}
+	for _, tt := range tests {
+		p := position("2", "100", "100")
+		p.InitialMarginFraction = d(tt.imf)
+		h, err := c.Health(&Snapshot{Collateral: d("1000"), Positions: []Position{p}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertDecimal(t, "initial requirement with imf "+tt.imf, h.InitialMarginRequirement, tt.want)
+	}
+}
+
+func TestIsolatedHealth(t *testing.T) {
+	c := NewCalculator(testMarket)
+	p := position("-4", "100", "105")
+	p.MarginMode = txtypes.IsolatedMargin
+	p.AllocatedMargin = d("50")
+	s := &Snapshot{Collateral: d("1000"), Positions: []Position{p}}
+
+	h, err := c.IsolatedHealth(s, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertDecimal(t, "account value", h.AccountValue, "30")
+	assertDecimal(t, "maintenance requirement", h.MaintenanceMarginRequirement, "21")
+	if h.IsLiquidatable() {
+		t.Error("the position isn't liquidatable yet")
+	}
+
+	if _, err := c.IsolatedHealth(s, 2); !errors.Is(err, ErrNoPosition) {
+		t.Errorf("expected ErrNoPosition, got %v", err)
+	}
+}
+
+func TestLiquidationPrice(t *testing.T) {
+	c := NewCalculator(testMarket)
+	isolated := position("-4", "100", "105")
+	isolated.MarginMode = txtypes.IsolatedMargin
+	isolated.AllocatedMargin = d("50")
+
+	tests := []struct {
+		name     string
+		snapshot Snapshot
+		want     string
+	}{
+		// 100 + 10 * (x - 100) = 10 * x * 0.05
+		{"long", Snapshot{Collateral: d("100"), Positions: []Position{position("10", "100", "100")}}, "94.736842105263"},
+		// 100 - 10 * (x - 100) = 10 * x * 0.05
+		{"short", Snapshot{Collateral: d("100"), Positions: []Position{position("-10", "100", "100")}}, "104.761904761905"},
+		// a long backed by more than its value can't be liquidated
+		{"over collateralized long", Snapshot{Collateral: d("2000"), Positions: []Position{position("10", "100", "100")}}, "0"},
+		// 50 - 4 * (x - 100) = 4 * x * 0.05
+		{"isolated short", Snapshot{Collateral: d("0"), Positions: []Position{isolated}}, "107.142857142857"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			price, err := c.LiquidationPrice(&tt.snapshot, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertDecimal(t, "liquidation price", price, tt.want)
+			if price.IsZero() {
+				return
+			}
+
+			// just past the liquidation price, the position is liquidatable
+			p := tt.snapshot.Position(1)
+			step := d("0.000001")
+			if p.Size.IsPositive() {
+				step = step.Neg()
+			}
+			tt.snapshot.SetMarkPrice(1, price.Add(step))
+			var h Health
+			if p.IsIsolated() {
+				h, err = c.IsolatedHealth(&tt.snapshot, 1)
+			} else {
+				h, err = c.Health(&tt.snapshot)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !h.IsLiquidatable() {
+				t.Errorf("not liquidatable at %s: %+v", price.Add(step), h)
+			}
+		})
+	}
+}
+
+func TestMaxOrderSize(t *testing.T) {
+	c := NewCalculator(testMarket)
+	long := Snapshot{Collateral: d("1000"), Positions: []Position{position("2", "100", "100")}}
+
+	tests := []struct {
+		name     string
+		snapshot Snapshot
+		isAsk    bool
+		leverage string
+		want     string
+		err      error
+	}{
+		{"no position", Snapshot{Collateral: d("1000")}, false, "5", "50", nil},
+		// the long keeps 20 of margin at 10x
+		{"buy more", long, false, "10", "98", nil},
+		// selling first closes the long, which releases its margin
+		{"sell", long, true, "10", "102", nil},
+		// the long is re-margined at 5x
+		{"buy at another leverage", long, false, "5", "48", nil},
+		{"no free collateral", Snapshot{Collateral: d("10"), Positions: []Position{position("2", "100", "100")}}, false, "10", "0", nil},
+		{"leverage above the maximum", long, false, "100", "", ErrInvalidLeverage},
+		{"zero leverage", long, false, "0", "", ErrInvalidLeverage},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			size, err := c.MaxOrderSize(&tt.snapshot, 1, tt.isAsk, d("100"), d(tt.leverage))
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("expected %v, got %v", tt.err, err)
+			}
+			if err == nil {
+				assertDecimal(t, "max size", size, tt.want)
+			}
+		})
+	}
+
+	if _, err := c.MaxOrderSize(&long, 1, false, d("0"), d("5")); !errors.Is(err, ErrInvalidPrice) {
+		t.Errorf("expected ErrInvalidPrice, got %v", err)
+	}
+	if _, err := c.MaxOrderSize(&long, 9, false, d("100"), d("5")); !errors.Is(err, ErrUnknownMarket) {
+		t.Errorf("expected ErrUnknownMarket, got %v", err)
+	}
+}
+
+func TestFromAccount(t *testing.T) {
+	account := &client.Account{
+		AccountIndex: 7,
+		Collateral:   d("1234.5"),
+		Positions: []client.Position{
+			{MarketId: 1, Sign: -1, Position: d("2.5"), AvgEntryPrice: d("100"), PositionValue: d("262.5"), InitialMarginFraction: d("20")},
+			{MarketId: 2, Sign: 1, Position: d("1"), AvgEntryPrice: d("10"), MarginMode: int(txtypes.IsolatedMargin), AllocatedMargin: d("3")},
+			{MarketId: 3, Sign: 1, Position: d("0")},
+		},
+	}
+	s := FromAccount(account)
+	if s.AccountIndex != 7 || !s.Collateral.Equal(d("1234.5")) || len(s.Positions) != 2 {
+		t.Fatalf("unexpected snapshot %+v", s)
+	}
+
+	short := s.Position(1)
+	assertDecimal(t, "size", short.Size, "-2.5")
+	assertDecimal(t, "mark price", short.MarkPrice, "105")
+	assertDecimal(t, "imf", short.InitialMarginFraction, "0.2")
+	assertDecimal(t, "pnl", short.UnrealizedPnl(), "-12.5")
+
+	isolated := s.Position(2)
+	if !isolated.IsIsolated() {
+		t.Error("position 2 is isolated")
+	}
+	// without a position value, the mark price is the entry price
+	assertDecimal(t, "mark price", isolated.MarkPrice, "10")
+	if s.Position(3) != nil {
+		t.Error("empty positions are dropped")
+	}
+}
@@ -0,0 +1,155 @@
+package margin
+
+import (
+	"fmt"
+
+	"github.com/u20024804/lighter-ex/client"
+	"github.com/u20024804/lighter-ex/decimal"
+	"github.com/u20024804/lighter-ex/types/txtypes"
+)
+
+// MarketParams holds the risk parameters of a market as fractions, e.g. 0.05 for 5%
+type MarketParams struct {
+	MarketId                     uint8
+	DefaultInitialMarginFraction decimal.Decimal
+	MinInitialMarginFraction     decimal.Decimal
+	MaintenanceMarginFraction    decimal.Decimal
+	CloseoutMarginFraction       decimal.Decimal
+}
+
+// MarketParamsFromDetail converts the margin fractions of an OrderBookDetail, which are in MarginFractionTick units
+func MarketParamsFromDetail(d *client.OrderBookDetail) MarketParams {
+	return MarketParams{
+		MarketId:                     d.MarketId,
+		DefaultInitialMarginFraction: fractionFromTicks(d.DefaultInitialMarginFraction),
+		MinInitialMarginFraction:     fractionFromTicks(d.MinInitialMarginFraction),
+		MaintenanceMarginFraction:    fractionFromTicks(d.MaintenanceMarginFraction),
+		CloseoutMarginFraction:       fractionFromTicks(d.CloseoutMarginFraction),
+	}
+}
+
+// fractionFromTicks converts a margin fraction in MarginFractionTick units, which is a power of 10, so it's exact
+func fractionFromTicks(ticks uint32) decimal.Decimal {
+	return decimal.NewFromInt(int64(ticks)).DivRound(decimal.NewFromInt(txtypes.MarginFractionTick), Precision)
+}
+
+// Position is a position reduced to the numbers the margin math needs
+type Position struct {
+	MarketId uint8
+	// Size is signed, negative for shorts
+	Size       decimal.Decimal
+	EntryPrice decimal.Decimal
+	MarkPrice  decimal.Decimal
+	// InitialMarginFraction is the fraction picked with UpdateLeverage, 0 means the market default
+	InitialMarginFraction decimal.Decimal
+	MarginMode            uint8
+	// AllocatedMargin is only meaningful for isolated positions
+	AllocatedMargin decimal.Decimal
+	// ReportedLiquidationPrice is the liquidation price returned by the exchange, 0 if unknown
+	ReportedLiquidationPrice decimal.Decimal
+}
+
+// Notional returns the absolute value of the position at mark price
+func (p *Position) Notional() decimal.Decimal {
+	return p.Size.Abs().Mul(p.MarkPrice)
+}
+
+// UnrealizedPnl returns the pnl of the position at mark price
+func (p *Position) UnrealizedPnl() decimal.Decimal {
+	return p.Size.Mul(p.MarkPrice.Sub(p.EntryPrice))
+}
+
+// IsIsolated returns true if the position uses isolated margin
+func (p *Position) IsIsolated() bool {
+	return p.MarginMode == txtypes.IsolatedMargin
+}
+
+// Snapshot is the state of an account the calculator works on
+type Snapshot struct {
+	AccountIndex int64
+	// Collateral is the cross margin collateral, excluding the margin allocated to isolated positions and any unrealized pnl
+	Collateral decimal.Decimal
+	Positions  []Position
+}
+
+// Position returns the position on a market, or nil if there's none
+func (s *Snapshot) Position(marketId uint8) *Position {
+	for i := range s.Positions {
+		if s.Positions[i].MarketId == marketId {
+			return &s.Positions[i]
+		}
+	}
+	return nil
+}
+
+// SetMarkPrice overrides the mark price of a position, e.g. with a price from the order book stream
+func (s *Snapshot) SetMarkPrice(marketId uint8, price decimal.Decimal) {
+	if p := s.Position(marketId); p != nil {
+		p.MarkPrice = price
+	}
+}
+
+// FromAccount builds a snapshot from a REST account.
+// Mark prices are derived from PositionValue / Position, as the account doesn't carry them.
+func FromAccount(account *client.Account) *Snapshot {
+	snapshot := &Snapshot{
+		AccountIndex: account.AccountIndex,
+		Collateral:   account.Collateral,
+		Positions:    make([]Position, 0, len(account.Positions)),
+	}
+	for _, p := range account.Positions {
+		position := newPosition(p.MarketId, p.Sign, p.Position, p.AvgEntryPrice, p.PositionValue, p.InitialMarginFraction, p.MarginMode, p.AllocatedMargin, p.LiquidationPrice)
+		if !position.Size.IsZero() {
+			snapshot.Positions = append(snapshot.Positions, position)
+		}
+	}
+	return snapshot
+}
+
+// FromWSPositions builds a snapshot from the positions of an account_all message.
+// The stream doesn't carry the collateral, so it has to be passed, e.g. from a previous GetAccount call.
+func FromWSPositions(accountIndex int64, collateral decimal.Decimal, positions map[uint8]client.WSPosition) *Snapshot {
+	snapshot := &Snapshot{
+		AccountIndex: accountIndex,
+		Collateral:   collateral,
+		Positions:    make([]Position, 0, len(positions)),
+	}
+	for marketId, p := range positions {
+		position := newPosition(marketId, int(p.Sign), p.Position, p.AvgEntryPrice, p.PositionValue, p.InitialMarginFraction, p.MarginMode, p.AllocatedMargin, p.LiquidationPrice)
+		if !position.Size.IsZero() {
+			snapshot.Positions = append(snapshot.Positions, position)
+		}
+	}
+	return snapshot
+}
+
+// FromAccountState builds a snapshot from the live positions of an AccountState and the collateral of its last REST seed
+func FromAccountState(state *client.AccountState) (*Snapshot, error) {
+	account := state.Account()
+	if account == nil {
+		return nil, fmt.Errorf("account state of %d was not seeded, collateral is unknown", state.AccountIndex())
+	}
+	return FromWSPositions(state.AccountIndex(), account.Collateral, state.Positions()), nil
+}
+
+func newPosition(marketId uint8, sign int, size, entryPrice, value, imf decimal.Decimal, marginMode int, allocatedMargin, liquidationPrice decimal.Decimal) Position {
+	p := Position{
+		MarketId:                 marketId,
+		MarginMode:               uint8(marginMode),
+		Size:                     size.Abs(),
+		EntryPrice:               entryPrice,
+		AllocatedMargin:          allocatedMargin,
+		ReportedLiquidationPrice: liquidationPrice,
+		// initial_margin_fraction is reported in percent
+		InitialMarginFraction: imf.Mul(decimal.New(1, 2)),
+	}
+	// position is reported as an absolute value, the direction is in sign
+	if sign < 0 {
+		p.Size = p.Size.Neg()
+	}
+	p.MarkPrice = p.EntryPrice
+	if !p.Size.IsZero() && !value.IsZero() {
+		p.MarkPrice = value.DivRound(p.Size, Precision).Abs()
+	}
+	return p
+}
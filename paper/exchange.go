@@ -0,0 +1,385 @@
+// Package paper is a local exchange simulator for paper trading. Exchange takes the signed txs of a TxClient
+// (through TxClient.SetTxSender) and publishes fills & account updates like LighterWebsocketPrivateService,
+// so a strategy can switch between paper and live trading by configuration only.
+//
+// Orders are matched against an order book fed from the public stream, live or replayed from a recording.
+// Margin requirements are not enforced.
+package paper
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	schnorr "github.com/elliottech/poseidon_crypto/signature/schnorr"
+	"github.com/u20024804/lighter-ex/client"
+	"github.com/u20024804/lighter-ex/decimal"
+	"github.com/u20024804/lighter-ex/types/txtypes"
+)
+
+// usdcDecimals is the precision fees & pnl are rounded to
+const usdcDecimals = 6
+
+var (
+	_ client.TxSender                        = (*Exchange)(nil)
+	_ client.LighterWebsocketPrivateServiceI = (*Exchange)(nil)
+)
+
+// Fees are fractions of the notional, e.g. 0.0002 for 2 bps
+type Fees struct {
+	Maker decimal.Decimal
+	Taker decimal.Decimal
+}
+
+// Config configures an Exchange
+type Config struct {
+	AccountIndex int64
+	// Collateral is the starting USDC balance
+	Collateral decimal.Decimal
+	// Markets provides the decimals & fees of the tradable markets, usually from GetOrderBookDetails
+	Markets []client.OrderBookDetail
+	// Fees overrides the fees of a market. By default MakerFee & TakerFee of the market are read as percentages.
+	Fees map[uint8]Fees
+	// ChainId & PublicKeys (hex, per API key index) enable signature checks. Without public keys, any signature is accepted.
+	ChainId    uint32
+	PublicKeys map[uint8]string
+	// Now is the clock of the exchange, time.Now by default. Set it to follow the time of a replay.
+	Now func() time.Time
+}
+
+// market keeps its book in ticks, like the amounts of the txs: price ticks to size ticks
+type market struct {
+	detail    client.OrderBookDetail
+	fees      Fees
+	bids      map[int64]int64
+	asks      map[int64]int64
+	lastPrice int64
+}
+
+func (m *market) price(ticks int64) decimal.Decimal {
+	return decimal.FromTicks(ticks, m.detail.PriceDecimals)
+}
+
+func (m *market) size(ticks int64) decimal.Decimal {
+	return decimal.FromTicks(ticks, m.detail.SizeDecimals)
+}
+
+// Order statuses, as reported by Lighter
+const (
+	StatusPending           = "pending" // trigger order waiting for its trigger, or child of a grouped order
+	StatusOpen              = "open"
+	StatusFilled            = "filled"
+	StatusCanceled          = "canceled"
+	StatusCanceledPostOnly  = "canceled-post-only"
+	StatusCanceledReduce    = "canceled-reduce-only"
+	StatusCanceledExpired   = "canceled-expired"
+	StatusCanceledNotFilled = "canceled-not-filled"
+)
+
+type order struct {
+	index       int64
+	clientIndex int64
+	marketId    uint8
+	isAsk       bool
+	orderType   uint8
+	timeInForce uint8
+	reduceOnly  bool
+	price       int64
+	trigger     int64
+	initial     int64
+	remaining   int64
+	expiry      int64
+	status      string
+	createdAt   int64
+
+	// grouped orders: children wait for their parent to fill, OCO peers cancel each other
+	parent   int64
+	ocoPeers []int64
+
+	// tradeFilled is the size filled by public trades, not yet taken off the crossing levels of the book.
+	// It's reset by a snapshot.
+	tradeFilled int64
+}
+
+func (o *order) active() bool {
+	return o.status == StatusOpen || o.status == StatusPending
+}
+
+type position struct {
+	size     int64 // signed, in size ticks
+	entry    decimal.Decimal
+	realized decimal.Decimal
+}
+
+// Exchange simulates the matching engine & account of a single account
+type Exchange struct {
+	cfg Config
+
+	mu                 sync.Mutex
+	markets            map[uint8]*market
+	orders             map[int64]*order
+	nextOrderIndex     int64
+	nextTradeId        int64
+	nonces             map[uint8]int64
+	collateral         decimal.Decimal
+	positions          map[uint8]*position
+	scheduledCancelAll int64
+
+	// changes collected under mu, published after it's released
+	changedMarkets map[uint8]bool
+	newTrades      []client.WSTrade
+	orderUpdates   []client.LighterOrdersResponse
+
+	subsMu      sync.RWMutex
+	accountSubs map[int]func(client.LighterAccountResponse) error
+	orderSubs   map[int]func(client.LighterOrdersResponse) error
+	nextSubId   int
+	errHandler  client.ErrHandler
+}
+
+// NewExchange creates a simulator with the given account & markets
+func NewExchange(cfg Config) *Exchange {
+	if cfg.Now == nil {
+		cfg.Now = time.Now
+	}
+	e := &Exchange{
+		cfg:            cfg,
+		markets:        make(map[uint8]*market, len(cfg.Markets)),
+		orders:         make(map[int64]*order),
+		nextOrderIndex: txtypes.MinOrderIndex,
+		nextTradeId:    1,
+		nonces:         make(map[uint8]int64),
+		collateral:     cfg.Collateral,
+		positions:      make(map[uint8]*position),
+		changedMarkets: make(map[uint8]bool),
+		accountSubs:    make(map[int]func(client.LighterAccountResponse) error),
+		orderSubs:      make(map[int]func(client.LighterOrdersResponse) error),
+	}
+	for _, d := range cfg.Markets {
+		fees, ok := cfg.Fees[d.MarketId]
+		if !ok {
+			percent := decimal.New(1, 2)
+			fees = Fees{Maker: d.MakerFee.Mul(percent), Taker: d.TakerFee.Mul(percent)}
+		}
+		e.markets[d.MarketId] = &market{
+			detail: d,
+			fees:   fees,
+			bids:   make(map[int64]int64),
+			asks:   make(map[int64]int64),
+		}
+	}
+	return e
+}
+
+func (e *Exchange) now() int64 {
+	return e.cfg.Now().UnixMilli()
+}
+
+// GetNextNonce implements client.TxSender
+func (e *Exchange) GetNextNonce(accountIndex int64, apiKeyIndex uint8) (int64, error) {
+	if accountIndex != e.cfg.AccountIndex {
+		return 0, fmt.Errorf("account %d is not simulated", accountIndex)
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.nonces[apiKeyIndex], nil
+}
+
+// SendRawTx implements client.TxSender. It accepts order, cancel, modify, grouped order & cancel-all txs.
+func (e *Exchange) SendRawTx(tx txtypes.TxInfo) (string, error) {
+	if err := tx.Validate(); err != nil {
+		return "", err
+	}
+
+	e.mu.Lock()
+	err := e.sendTx(tx)
+	e.mu.Unlock()
+	e.publish()
+	if err != nil {
+		return "", err
+	}
+	return tx.GetTxHash(), nil
+}
+
+func (e *Exchange) sendTx(tx txtypes.TxInfo) error {
+	var accountIndex, nonce, expiredAt int64
+	var apiKeyIndex uint8
+	var sig []byte
+	switch t := tx.(type) {
+	case *txtypes.L2CreateOrderTxInfo:
+		accountIndex, apiKeyIndex, nonce, expiredAt, sig = t.AccountIndex, t.ApiKeyIndex, t.Nonce, t.ExpiredAt, t.Sig
+	case *txtypes.L2CreateGroupedOrdersTxInfo:
+		accountIndex, apiKeyIndex, nonce, expiredAt, sig = t.AccountIndex, t.ApiKeyIndex, t.Nonce, t.ExpiredAt, t.Sig
+	case *txtypes.L2CancelOrderTxInfo:
+		accountIndex, apiKeyIndex, nonce, expiredAt, sig = t.AccountIndex, t.ApiKeyIndex, t.Nonce, t.ExpiredAt, t.Sig
+	case *txtypes.L2ModifyOrderTxInfo:
+		accountIndex, apiKeyIndex, nonce, expiredAt, sig = t.AccountIndex, t.ApiKeyIndex, t.Nonce, t.ExpiredAt, t.Sig
+	case *txtypes.L2CancelAllOrdersTxInfo:
+		accountIndex, apiKeyIndex, nonce, expiredAt, sig = t.AccountIndex, t.ApiKeyIndex, t.Nonce, t.ExpiredAt, t.Sig
+	default:
+		return fmt.Errorf("tx type %d is not supported by the paper exchange", tx.GetTxType())
+	}
+
+	if accountIndex != e.cfg.AccountIndex {
+		return fmt.Errorf("account %d is not simulated", accountIndex)
+	}
+	now := e.now()
+	if expiredAt != 0 && expiredAt < now {
+		return fmt.Errorf("tx expired at %d", expiredAt)
+	}
+	if expected := e.nonces[apiKeyIndex]; nonce != expected {
+		return fmt.Errorf("invalid nonce %d, expected %d", nonce, expected)
+	}
+	if err := e.checkSignature(tx, apiKeyIndex, sig); err != nil {
+		return err
+	}
+	e.nonces[apiKeyIndex]++
+
+	e.expire(now)
+
+	switch t := tx.(type) {
+	case *txtypes.L2CreateOrderTxInfo:
+		_, err := e.createOrder(t.OrderInfo, now)
+		return err
+	case *txtypes.L2CreateGroupedOrdersTxInfo:
+		return e.createGroupedOrders(t, now)
+	case *txtypes.L2CancelOrderTxInfo:
+		o := e.findOrder(t.MarketIndex, t.Index)
+		if o == nil {
+			return fmt.Errorf("order %d not found", t.Index)
+		}
+		e.cancel(o, StatusCanceled, now)
+	case *txtypes.L2ModifyOrderTxInfo:
+		return e.modifyOrder(t, now)
+	case *txtypes.L2CancelAllOrdersTxInfo:
+		switch t.TimeInForce {
+		case txtypes.ImmediateCancelAll:
+			e.cancelAll(now)
+		case txtypes.ScheduledCancelAll:
+			e.scheduledCancelAll = t.Time
+		case txtypes.AbortScheduledCancelAll:
+			e.scheduledCancelAll = 0
+		}
+	}
+	return nil
+}
+
+func (e *Exchange) checkSignature(tx txtypes.TxInfo, apiKeyIndex uint8, sig []byte) error {
+	if len(e.cfg.PublicKeys) == 0 {
+		return nil
+	}
+	pubKey, ok := e.cfg.PublicKeys[apiKeyIndex]
+	if !ok {
+		return fmt.Errorf("api key %d is not registered", apiKeyIndex)
+	}
+	pk, err := hex.DecodeString(strings.TrimPrefix(pubKey, "0x"))
+	if err != nil {
+		return fmt.Errorf("invalid public key of api key %d: %w", apiKeyIndex, err)
+	}
+	msgHash, err := tx.Hash(e.cfg.ChainId)
+	if err != nil {
+		return err
+	}
+	if err := schnorr.Validate(pk, msgHash, sig); err != nil {
+		return fmt.Errorf("invalid signature: %w", err)
+	}
+	return nil
+}
+
+// Tick expires orders & fires the scheduled cancel-all at the current time, when no market data comes in
+func (e *Exchange) Tick() {
+	e.mu.Lock()
+	e.expire(e.now())
+	e.mu.Unlock()
+	e.publish()
+}
+
+// expire cancels expired orders and runs the scheduled cancel-all if its time has come. Must be called with e.mu held.
+func (e *Exchange) expire(now int64) {
+	if e.scheduledCancelAll != 0 && now >= e.scheduledCancelAll {
+		e.scheduledCancelAll = 0
+		e.cancelAll(now)
+	}
+	for _, o := range e.orders {
+		if o.active() && o.expiry > 0 && now >= o.expiry {
+			e.cancel(o, StatusCanceledExpired, now)
+		}
+	}
+}
+
+// Account returns the simulated account in the REST format, e.g. to seed an AccountState with ApplyAccount
+func (e *Exchange) Account() *client.Account {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	account := &client.Account{
+		Index:        e.cfg.AccountIndex,
+		AccountIndex: e.cfg.AccountIndex,
+		Collateral:   e.collateral,
+	}
+	total := e.collateral
+	for marketId := range e.markets {
+		p := e.wsPosition(marketId)
+		if p == nil {
+			continue
+		}
+		total = total.Add(p.UnrealizedPnl)
+		account.Positions = append(account.Positions, client.Position{
+			MarketId:          p.MarketId,
+			Symbol:            p.Symbol,
+			OpenOrderCount:    p.OpenOrderCount,
+			PendingOrderCount: p.PendingOrderCount,
+			Sign:              int(p.Sign),
+			Position:          p.Position,
+			AvgEntryPrice:     p.AvgEntryPrice,
+			PositionValue:     p.PositionValue,
+			UnrealizedPnl:     p.UnrealizedPnl,
+			RealizedPnl:       p.RealizedPnl,
+			MarginMode:        p.MarginMode,
+		})
+	}
+	account.TotalAssetValue = total
+	account.CrossAssetValue = account.TotalAssetValue
+	account.AvailableBalance = account.Collateral
+	return account
+}
+
+// ActiveOrders returns the open & pending orders of a market in the REST format
+func (e *Exchange) ActiveOrders(marketId uint8) []client.Order {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	m := e.markets[marketId]
+	var orders []client.Order
+	for _, o := range e.orders {
+		if o.marketId != marketId || !o.active() || m == nil {
+			continue
+		}
+		side := "buy"
+		if o.isAsk {
+			side = "sell"
+		}
+		orders = append(orders, client.Order{
+			OrderIndex:          o.index,
+			ClientOrderIndex:    o.clientIndex,
+			OrderId:             strconv.FormatInt(o.index, 10),
+			MarketIndex:         marketId,
+			OwnerAccountIndex:   e.cfg.AccountIndex,
+			InitialBaseAmount:   m.size(o.initial),
+			RemainingBaseAmount: m.size(o.remaining),
+			FilledBaseAmount:    m.size(o.initial - o.remaining),
+			Price:               m.price(o.price),
+			IsAsk:               o.isAsk,
+			Side:                side,
+			ReduceOnly:          o.reduceOnly,
+			TriggerPrice:        m.price(o.trigger),
+			Status:              o.status,
+			Timestamp:           o.createdAt / 1000,
+			OrderExpiry:         o.expiry,
+		})
+	}
+	return orders
+}
@@ -0,0 +1,326 @@
+package paper
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/u20024804/lighter-ex/client"
+	"github.com/u20024804/lighter-ex/decimal"
+	"github.com/u20024804/lighter-ex/types/txtypes"
+)
+
+// testExchange simulates market 1, with prices in 0.1 ticks & sizes in 0.01 ticks, and records the order statuses
+type testExchange struct {
+	*Exchange
+	t        *testing.T
+	clock    time.Time
+	statuses map[int64]string
+	nonce    int64
+}
+
+func newTestExchange(t *testing.T) *testExchange {
+	x := &testExchange{
+		t:        t,
+		clock:    time.UnixMilli(1_700_000_000_000),
+		statuses: make(map[int64]string),
+	}
+	x.Exchange = NewExchange(Config{
+		AccountIndex: 1,
+		Collateral:   decimal.MustParse("1000"),
+		Markets:      []client.OrderBookDetail{{MarketId: 1, SizeDecimals: 2, PriceDecimals: 1}},
+		Fees:         map[uint8]Fees{1: {}},
+		Now:          func() time.Time { return x.clock },
+	})
+	_, err := x.SubscribeOrders(client.LighterOrdersParamKey{AccountId: 1}, func(resp client.LighterOrdersResponse) error {
+		index, err := strconv.ParseInt(resp.OrderId, 10, 64)
+		if err != nil {
+			return err
+		}
+		x.statuses[index] = resp.Status
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 99.0 x 5 / 100.5 x 2
+	x.book(true, []client.PriceLevel{level("99", "5")}, []client.PriceLevel{level("100.5", "2")})
+	return x
+}
+
+func level(price, size string) client.PriceLevel {
+	return client.PriceLevel{Price: decimal.MustParse(price), Quantity: decimal.MustParse(size)}
+}
+
+func (x *testExchange) book(isSnapshot bool, bids, asks []client.PriceLevel) {
+	x.t.Helper()
+	err := x.ApplyOrderBook(client.LighterOrderBookResponse{MarketId: 1, Bids: bids, Asks: asks, IsSnapshot: isSnapshot})
+	if err != nil {
+		x.t.Fatal(err)
+	}
+}
+
+// expiry is an order expiry an hour from now
+func (x *testExchange) expiry() int64 {
+	return x.clock.Add(time.Hour).UnixMilli()
+}
+
+// create sends an order and returns its index
+func (x *testExchange) create(info txtypes.OrderInfo) int64 {
+	x.t.Helper()
+	info.MarketIndex = 1
+	index := x.nextOrderIndex
+	if _, err := x.SendRawTx(&txtypes.L2CreateOrderTxInfo{AccountIndex: 1, OrderInfo: &info, Nonce: x.nonce}); err != nil {
+		x.t.Fatal(err)
+	}
+	x.nonce++
+	return index
+}
+
+// createGrouped sends grouped orders and returns the index of the first one, the others follow
+func (x *testExchange) createGrouped(groupingType uint8, orders ...txtypes.OrderInfo) int64 {
+	x.t.Helper()
+	infos := make([]*txtypes.OrderInfo, len(orders))
+	for i := range orders {
+		orders[i].MarketIndex = 1
+		infos[i] = &orders[i]
+	}
+	index := x.nextOrderIndex
+	tx := &txtypes.L2CreateGroupedOrdersTxInfo{AccountIndex: 1, GroupingType: groupingType, Orders: infos, Nonce: x.nonce}
+	if _, err := x.SendRawTx(tx); err != nil {
+		x.t.Fatal(err)
+	}
+	x.nonce++
+	return index
+}
+
+func (x *testExchange) expectStatus(index int64, status string) {
+	x.t.Helper()
+	if got := x.statuses[index]; got != status {
+		x.t.Errorf("expected order %d to be %s, got %s", index, status, got)
+	}
+}
+
+func (x *testExchange) expectPosition(size int64) {
+	x.t.Helper()
+	var got int64
+	if p, ok := x.positions[1]; ok {
+		got = p.size
+	}
+	if got != size {
+		x.t.Errorf("expected a position of %d, got %d", size, got)
+	}
+}
+
+func TestTimeInForce(t *testing.T) {
+	tests := []struct {
+		name     string
+		order    txtypes.OrderInfo
+		status   string
+		position int64
+	}{
+		{"resting limit", txtypes.OrderInfo{BaseAmount: 100, Price: 995, Type: txtypes.LimitOrder, TimeInForce: txtypes.GoodTillTime}, StatusOpen, 0},
+		{"crossing limit", txtypes.OrderInfo{BaseAmount: 100, Price: 1010, Type: txtypes.LimitOrder, TimeInForce: txtypes.GoodTillTime}, StatusFilled, 100},
+		{"partially filled limit", txtypes.OrderInfo{BaseAmount: 300, Price: 1010, Type: txtypes.LimitOrder, TimeInForce: txtypes.GoodTillTime}, StatusOpen, 200},
+		{"ioc", txtypes.OrderInfo{BaseAmount: 300, Price: 1010, Type: txtypes.LimitOrder, TimeInForce: txtypes.ImmediateOrCancel}, StatusCanceledNotFilled, 200},
+		{"ioc not crossing", txtypes.OrderInfo{BaseAmount: 100, Price: 995, Type: txtypes.LimitOrder, TimeInForce: txtypes.ImmediateOrCancel}, StatusCanceledNotFilled, 0},
+		{"post only", txtypes.OrderInfo{BaseAmount: 100, Price: 1000, Type: txtypes.LimitOrder, TimeInForce: txtypes.PostOnly}, StatusOpen, 0},
+		{"crossing post only", txtypes.OrderInfo{BaseAmount: 100, Price: 1005, Type: txtypes.LimitOrder, TimeInForce: txtypes.PostOnly}, StatusCanceledPostOnly, 0},
+		{"market", txtypes.OrderInfo{BaseAmount: 100, Price: 980, IsAsk: 1, Type: txtypes.MarketOrder, TimeInForce: txtypes.ImmediateOrCancel}, StatusFilled, -100},
+		{"market beyond the book", txtypes.OrderInfo{BaseAmount: 600, Price: 980, IsAsk: 1, Type: txtypes.MarketOrder, TimeInForce: txtypes.ImmediateOrCancel}, StatusCanceledNotFilled, -500},
+		{"market beyond its price", txtypes.OrderInfo{BaseAmount: 100, Price: 1000, IsAsk: 1, Type: txtypes.MarketOrder, TimeInForce: txtypes.ImmediateOrCancel}, StatusCanceledNotFilled, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			x := newTestExchange(t)
+			if tt.order.TimeInForce != txtypes.ImmediateOrCancel {
+				tt.order.OrderExpiry = x.expiry()
+			}
+			index := x.create(tt.order)
+			x.expectStatus(index, tt.status)
+			x.expectPosition(tt.position)
+		})
+	}
+}
+
+func TestReduceOnly(t *testing.T) {
+	x := newTestExchange(t)
+	sell := txtypes.OrderInfo{BaseAmount: 200, Price: 990, IsAsk: 1, Type: txtypes.LimitOrder, TimeInForce: txtypes.ImmediateOrCancel, ReduceOnly: 1}
+
+	// nothing to reduce
+	x.expectStatus(x.create(sell), StatusCanceledReduce)
+	x.expectPosition(0)
+
+	// the fill is bounded to the position
+	x.create(txtypes.OrderInfo{BaseAmount: 100, Price: 1010, Type: txtypes.MarketOrder, TimeInForce: txtypes.ImmediateOrCancel})
+	x.expectPosition(100)
+	sell.TimeInForce, sell.OrderExpiry = txtypes.GoodTillTime, x.expiry()
+	x.expectStatus(x.create(sell), StatusCanceledReduce)
+	x.expectPosition(0)
+
+	// a resting reduce-only order is cancelled once the position is closed
+	x.create(txtypes.OrderInfo{BaseAmount: 100, Price: 1010, Type: txtypes.MarketOrder, TimeInForce: txtypes.ImmediateOrCancel})
+	sell.Price = 1050
+	resting := x.create(sell)
+	x.expectStatus(resting, StatusOpen)
+	x.create(txtypes.OrderInfo{BaseAmount: 100, Price: 980, IsAsk: 1, Type: txtypes.MarketOrder, TimeInForce: txtypes.ImmediateOrCancel})
+	x.expectStatus(resting, StatusCanceledReduce)
+	x.expectPosition(0)
+}
+
+func TestTriggers(t *testing.T) {
+	tests := []struct {
+		name  string
+		order txtypes.OrderInfo
+		// the book moves to bid-ask
+		bid, ask string
+		status   string
+	}{
+		{"stop loss not reached", txtypes.OrderInfo{IsAsk: 1, Type: txtypes.StopLossLimitOrder, TriggerPrice: 980, Price: 970}, "98.5", "99", StatusPending},
+		{"stop loss", txtypes.OrderInfo{IsAsk: 1, Type: txtypes.StopLossLimitOrder, TriggerPrice: 980, Price: 970}, "97.5", "98", StatusFilled},
+		{"stop loss of a short", txtypes.OrderInfo{Type: txtypes.StopLossOrder, TriggerPrice: 1020, Price: 1050, TimeInForce: txtypes.ImmediateOrCancel}, "102", "102.5", StatusFilled},
+		{"take profit not reached", txtypes.OrderInfo{IsAsk: 1, Type: txtypes.TakeProfitLimitOrder, TriggerPrice: 1020, Price: 1010}, "101", "101.5", StatusPending},
+		{"take profit", txtypes.OrderInfo{IsAsk: 1, Type: txtypes.TakeProfitLimitOrder, TriggerPrice: 1020, Price: 1010}, "102", "102.5", StatusFilled},
+		// the order rests at its limit price once triggered
+		{"stop loss limit resting", txtypes.OrderInfo{IsAsk: 1, Type: txtypes.StopLossLimitOrder, TriggerPrice: 980, Price: 985}, "97.5", "98", StatusOpen},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			x := newTestExchange(t)
+			tt.order.BaseAmount, tt.order.OrderExpiry = 100, x.expiry()
+			if tt.order.Type == txtypes.StopLossLimitOrder || tt.order.Type == txtypes.TakeProfitLimitOrder {
+				tt.order.TimeInForce = txtypes.GoodTillTime
+			}
+			index := x.create(tt.order)
+			x.expectStatus(index, StatusPending)
+
+			x.book(true, []client.PriceLevel{level(tt.bid, "5")}, []client.PriceLevel{level(tt.ask, "5")})
+			x.expectStatus(index, tt.status)
+		})
+	}
+}
+
+func TestGroupedOrders(t *testing.T) {
+	t.Run("one triggers the other", func(t *testing.T) {
+		x := newTestExchange(t)
+		expiry := x.expiry()
+		parent := x.createGrouped(txtypes.GroupingType_OneTriggersTheOther,
+			txtypes.OrderInfo{BaseAmount: 100, Price: 995, Type: txtypes.LimitOrder, TimeInForce: txtypes.GoodTillTime, OrderExpiry: expiry},
+			txtypes.OrderInfo{IsAsk: 1, ReduceOnly: 1, Type: txtypes.StopLossLimitOrder, TriggerPrice: 980, Price: 970, TimeInForce: txtypes.GoodTillTime, OrderExpiry: expiry},
+		)
+		x.expectStatus(parent, StatusOpen)
+		x.expectStatus(parent+1, StatusPending)
+
+		// the child waits for its parent, even when the book reaches its trigger
+		x.book(true, []client.PriceLevel{level("96", "5")}, []client.PriceLevel{level("99.6", "5")})
+		x.expectStatus(parent+1, StatusPending)
+		x.book(true, []client.PriceLevel{level("99", "5")}, []client.PriceLevel{level("99.5", "5")})
+		x.expectStatus(parent, StatusFilled)
+		x.expectStatus(parent+1, StatusPending)
+		x.expectPosition(100)
+
+		// then it's triggered by the book, with the size of its parent
+		x.book(true, []client.PriceLevel{level("97.5", "5")}, []client.PriceLevel{level("98", "5")})
+		x.expectStatus(parent+1, StatusFilled)
+		x.expectPosition(0)
+	})
+
+	t.Run("one cancels the other", func(t *testing.T) {
+		x := newTestExchange(t)
+		x.create(txtypes.OrderInfo{BaseAmount: 100, Price: 1010, Type: txtypes.MarketOrder, TimeInForce: txtypes.ImmediateOrCancel})
+		expiry := x.expiry()
+		stop := x.createGrouped(txtypes.GroupingType_OneCancelsTheOther,
+			txtypes.OrderInfo{BaseAmount: 100, IsAsk: 1, ReduceOnly: 1, Type: txtypes.StopLossLimitOrder, TriggerPrice: 980, Price: 970, TimeInForce: txtypes.GoodTillTime, OrderExpiry: expiry},
+			txtypes.OrderInfo{BaseAmount: 100, IsAsk: 1, ReduceOnly: 1, Type: txtypes.TakeProfitLimitOrder, TriggerPrice: 1020, Price: 1010, TimeInForce: txtypes.GoodTillTime, OrderExpiry: expiry},
+		)
+		x.expectStatus(stop, StatusPending)
+		x.expectStatus(stop+1, StatusPending)
+
+		x.book(true, []client.PriceLevel{level("97.5", "5")}, []client.PriceLevel{level("98", "5")})
+		x.expectStatus(stop, StatusFilled)
+		x.expectStatus(stop+1, StatusCanceled)
+		x.expectPosition(0)
+	})
+
+	t.Run("one triggers a one cancels the other", func(t *testing.T) {
+		x := newTestExchange(t)
+		expiry := x.expiry()
+		parent := x.createGrouped(txtypes.GroupingType_OneTriggersAOneCancelsTheOther,
+			txtypes.OrderInfo{BaseAmount: 100, Price: 1010, Type: txtypes.LimitOrder, TimeInForce: txtypes.GoodTillTime, OrderExpiry: expiry},
+			txtypes.OrderInfo{IsAsk: 1, ReduceOnly: 1, Type: txtypes.StopLossLimitOrder, TriggerPrice: 980, Price: 970, TimeInForce: txtypes.GoodTillTime, OrderExpiry: expiry},
+			txtypes.OrderInfo{IsAsk: 1, ReduceOnly: 1, Type: txtypes.TakeProfitLimitOrder, TriggerPrice: 1020, Price: 1010, TimeInForce: txtypes.GoodTillTime, OrderExpiry: expiry},
+		)
+		x.expectStatus(parent, StatusFilled)
+		x.expectStatus(parent+1, StatusPending)
+		x.expectStatus(parent+2, StatusPending)
+
+		x.book(true, []client.PriceLevel{level("102", "5")}, []client.PriceLevel{level("102.5", "5")})
+		x.expectStatus(parent+2, StatusFilled)
+		x.expectStatus(parent+1, StatusCanceled)
+		x.expectPosition(0)
+	})
+
+	t.Run("cancelling the parent cancels the children", func(t *testing.T) {
+		x := newTestExchange(t)
+		expiry := x.expiry()
+		parent := x.createGrouped(txtypes.GroupingType_OneTriggersTheOther,
+			txtypes.OrderInfo{BaseAmount: 100, Price: 995, Type: txtypes.LimitOrder, TimeInForce: txtypes.GoodTillTime, OrderExpiry: expiry},
+			txtypes.OrderInfo{IsAsk: 1, ReduceOnly: 1, Type: txtypes.StopLossLimitOrder, TriggerPrice: 980, Price: 970, TimeInForce: txtypes.GoodTillTime, OrderExpiry: expiry},
+		)
+		_, err := x.SendRawTx(&txtypes.L2CancelOrderTxInfo{AccountIndex: 1, MarketIndex: 1, Index: parent, Nonce: x.nonce})
+		if err != nil {
+			t.Fatal(err)
+		}
+		x.expectStatus(parent, StatusCanceled)
+		x.expectStatus(parent+1, StatusCanceled)
+	})
+}
+
+func TestExpiry(t *testing.T) {
+	x := newTestExchange(t)
+	expiring := x.create(txtypes.OrderInfo{BaseAmount: 100, Price: 990, Type: txtypes.LimitOrder, TimeInForce: txtypes.GoodTillTime, OrderExpiry: x.clock.Add(time.Minute).UnixMilli()})
+	lasting := x.create(txtypes.OrderInfo{BaseAmount: 100, Price: 980, Type: txtypes.LimitOrder, TimeInForce: txtypes.GoodTillTime, OrderExpiry: x.expiry()})
+
+	x.clock = x.clock.Add(time.Minute - time.Millisecond)
+	x.Tick()
+	x.expectStatus(expiring, StatusOpen)
+
+	x.clock = x.clock.Add(time.Millisecond)
+	x.Tick()
+	x.expectStatus(expiring, StatusCanceledExpired)
+	x.expectStatus(lasting, StatusOpen)
+
+	// the scheduled cancel-all fires at its time
+	_, err := x.SendRawTx(&txtypes.L2CancelAllOrdersTxInfo{AccountIndex: 1, TimeInForce: txtypes.ScheduledCancelAll, Time: x.clock.Add(time.Minute).UnixMilli(), Nonce: x.nonce})
+	if err != nil {
+		t.Fatal(err)
+	}
+	x.clock = x.clock.Add(time.Minute)
+	x.Tick()
+	x.expectStatus(lasting, StatusCanceled)
+}
+
+func TestApplyTrade(t *testing.T) {
+	x := newTestExchange(t)
+	index := x.create(txtypes.OrderInfo{BaseAmount: 100, Price: 1000, Type: txtypes.LimitOrder, TimeInForce: txtypes.GoodTillTime, OrderExpiry: x.expiry()})
+
+	// a trade through the bid fills it at its price
+	err := x.ApplyTrade(client.LighterTradesResponse{MarketId: 1, Price: decimal.MustParse("99.9"), Quantity: decimal.MustParse("0.4")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	x.expectStatus(index, StatusOpen)
+	x.expectPosition(40)
+
+	// what's left of the same seller shows up in the book, only the rest of it fills the bid
+	x.book(false, nil, []client.PriceLevel{level("99.9", "0.5")})
+	x.expectPosition(50)
+	if size, ok := x.markets[1].asks[999]; ok {
+		t.Errorf("expected the crossing level to be consumed, %d left", size)
+	}
+
+	// a new crossing level fills the bid normally
+	x.book(false, nil, []client.PriceLevel{level("99.8", "1")})
+	x.expectStatus(index, StatusFilled)
+	x.expectPosition(100)
+}
@@ -0,0 +1,570 @@
+package paper
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/u20024804/lighter-ex/client"
+	"github.com/u20024804/lighter-ex/decimal"
+	"github.com/u20024804/lighter-ex/types/txtypes"
+)
+
+// AttachOrderBook feeds the book of a market from the public stream. The returned function unsubscribes.
+func (e *Exchange) AttachOrderBook(service client.LighterWebsocketPublicServiceI, marketId uint8) (func() error, error) {
+	return service.SubscribeOrderBook(client.LighterOrderBookParamKey{MarketId: marketId}, func(resp client.LighterOrderBookResponse) error {
+		return e.ApplyOrderBook(resp)
+	})
+}
+
+// AttachTrades feeds the public trades of a market, which fill resting orders they trade through
+func (e *Exchange) AttachTrades(service client.LighterWebsocketPublicServiceI, marketId uint8) (func() error, error) {
+	return service.SubscribeTrades(client.LighterTradesParamKey{MarketId: marketId}, func(resp client.LighterTradesResponse) error {
+		return e.ApplyTrade(resp)
+	})
+}
+
+// ApplyOrderBook merges an order book message, then fills the resting orders it crosses and fires triggers
+func (e *Exchange) ApplyOrderBook(resp client.LighterOrderBookResponse) error {
+	e.mu.Lock()
+	m, ok := e.markets[resp.MarketId]
+	if !ok {
+		e.mu.Unlock()
+		return nil
+	}
+	if resp.IsSnapshot {
+		m.bids = make(map[int64]int64)
+		m.asks = make(map[int64]int64)
+		for _, o := range e.orders {
+			if o.marketId == resp.MarketId {
+				o.tradeFilled = 0
+			}
+		}
+	}
+	err := m.applyLevels(m.bids, resp.Bids)
+	if err == nil {
+		err = m.applyLevels(m.asks, resp.Asks)
+	}
+
+	now := e.now()
+	e.expire(now)
+	e.matchResting(m, now)
+	e.checkTriggers(m, now)
+	e.mu.Unlock()
+
+	e.publish()
+	return err
+}
+
+func (m *market) applyLevels(side map[int64]int64, levels []client.PriceLevel) error {
+	for _, level := range levels {
+		price, err := level.Price.Ticks(m.detail.PriceDecimals)
+		if err != nil {
+			return fmt.Errorf("invalid price level: %w", err)
+		}
+		size, err := level.Quantity.Ticks(m.detail.SizeDecimals)
+		if err != nil {
+			return fmt.Errorf("invalid price level size: %w", err)
+		}
+		if size <= 0 {
+			delete(side, price)
+		} else {
+			side[price] = size
+		}
+	}
+	return nil
+}
+
+// ApplyTrade fills the resting orders a public trade went through, at their own price. The crossing levels of the
+// next book messages may be what's left of the same liquidity, so they don't fill the orders again for that size.
+func (e *Exchange) ApplyTrade(resp client.LighterTradesResponse) error {
+	e.mu.Lock()
+	m, ok := e.markets[resp.MarketId]
+	if !ok || resp.IsSnapshot {
+		e.mu.Unlock()
+		return nil
+	}
+	price, err := resp.Price.Ticks(m.detail.PriceDecimals)
+	if err != nil {
+		e.mu.Unlock()
+		return fmt.Errorf("invalid trade price: %w", err)
+	}
+	size, err := resp.Quantity.Ticks(m.detail.SizeDecimals)
+	if err != nil {
+		e.mu.Unlock()
+		return fmt.Errorf("invalid trade size: %w", err)
+	}
+
+	now := e.now()
+	e.expire(now)
+	m.lastPrice = price
+	for _, o := range e.sortedOrders(resp.MarketId, StatusOpen) {
+		if size <= 0 {
+			break
+		}
+		if (!o.isAsk && o.price > price) || (o.isAsk && o.price < price) {
+			qty := e.clip(o, min(o.remaining, size))
+			if qty <= 0 {
+				continue
+			}
+			e.fill(m, o, qty, o.price, true, now)
+			o.tradeFilled += qty
+			size -= qty
+		}
+	}
+	e.checkTriggers(m, now)
+	e.mu.Unlock()
+
+	e.publish()
+	return nil
+}
+
+// sortedOrders returns the orders of a market with the given status, oldest first, so matching is deterministic
+func (e *Exchange) sortedOrders(marketId uint8, status string) []*order {
+	var orders []*order
+	for _, o := range e.orders {
+		if o.marketId == marketId && o.status == status {
+			orders = append(orders, o)
+		}
+	}
+	sort.Slice(orders, func(i, j int) bool { return orders[i].index < orders[j].index })
+	return orders
+}
+
+// levels returns the prices of a side of the book, best first
+func levels(side map[int64]int64, isAsk bool) []int64 {
+	prices := make([]int64, 0, len(side))
+	for p := range side {
+		prices = append(prices, p)
+	}
+	if isAsk {
+		sort.Slice(prices, func(i, j int) bool { return prices[i] < prices[j] })
+	} else {
+		sort.Slice(prices, func(i, j int) bool { return prices[i] > prices[j] })
+	}
+	return prices
+}
+
+func (m *market) best(isAsk bool) (int64, bool) {
+	side := m.bids
+	if isAsk {
+		side = m.asks
+	}
+	prices := levels(side, isAsk)
+	if len(prices) == 0 {
+		return 0, false
+	}
+	return prices[0], true
+}
+
+// markPrice is the mid of the book, or the last trade price if the book is one sided
+func (m *market) markPrice() decimal.Decimal {
+	bid, hasBid := m.best(false)
+	ask, hasAsk := m.best(true)
+	if hasBid && hasAsk {
+		return m.price(bid + ask).Mul(decimal.New(5, 1))
+	}
+	return m.price(m.lastPrice)
+}
+
+func (m *market) crosses(o *order) bool {
+	if o.isAsk {
+		bid, ok := m.best(false)
+		return ok && bid >= o.price
+	}
+	ask, ok := m.best(true)
+	return ok && ask <= o.price
+}
+
+// newOrder converts a signed order, it's not placed yet
+func (e *Exchange) newOrder(info *txtypes.OrderInfo, now int64) (*order, error) {
+	if _, ok := e.markets[info.MarketIndex]; !ok {
+		return nil, fmt.Errorf("market %d is not simulated", info.MarketIndex)
+	}
+	if info.Type == txtypes.TWAPOrder {
+		return nil, fmt.Errorf("TWAP orders are not supported by the paper exchange")
+	}
+	o := &order{
+		index:       e.nextOrderIndex,
+		clientIndex: info.ClientOrderIndex,
+		marketId:    info.MarketIndex,
+		isAsk:       info.IsAsk == 1,
+		orderType:   info.Type,
+		timeInForce: info.TimeInForce,
+		reduceOnly:  info.ReduceOnly == 1,
+		price:       int64(info.Price),
+		trigger:     int64(info.TriggerPrice),
+		initial:     info.BaseAmount,
+		remaining:   info.BaseAmount,
+		expiry:      info.OrderExpiry,
+		status:      StatusPending,
+		createdAt:   now,
+	}
+	e.nextOrderIndex++
+	e.orders[o.index] = o
+	return o, nil
+}
+
+// activate makes an order live: trigger orders wait for their trigger, the others are placed
+func (e *Exchange) activate(o *order, now int64) {
+	m := e.markets[o.marketId]
+	if o.trigger > 0 {
+		e.orderUpdate(o)
+		e.checkTriggers(m, now)
+		return
+	}
+	e.place(m, o, now)
+}
+
+func (e *Exchange) createOrder(info *txtypes.OrderInfo, now int64) (*order, error) {
+	o, err := e.newOrder(info, now)
+	if err != nil {
+		return nil, err
+	}
+	e.activate(o, now)
+	return o, nil
+}
+
+func (e *Exchange) createGroupedOrders(tx *txtypes.L2CreateGroupedOrdersTxInfo, now int64) error {
+	orders := make([]*order, 0, len(tx.Orders))
+	for _, info := range tx.Orders {
+		o, err := e.newOrder(info, now)
+		if err != nil {
+			for _, created := range orders {
+				delete(e.orders, created.index)
+			}
+			return err
+		}
+		orders = append(orders, o)
+	}
+
+	link := func(a, b *order) {
+		a.ocoPeers = append(a.ocoPeers, b.index)
+		b.ocoPeers = append(b.ocoPeers, a.index)
+	}
+	switch tx.GroupingType {
+	case txtypes.GroupingType_OneTriggersTheOther:
+		orders[1].parent = orders[0].index
+	case txtypes.GroupingType_OneCancelsTheOther:
+		link(orders[0], orders[1])
+	case txtypes.GroupingType_OneTriggersAOneCancelsTheOther:
+		orders[1].parent = orders[0].index
+		orders[2].parent = orders[0].index
+		link(orders[1], orders[2])
+	}
+	// children without size take the size of their parent
+	for _, o := range orders {
+		if o.parent != 0 && o.initial == 0 {
+			o.initial, o.remaining = orders[0].initial, orders[0].initial
+		}
+	}
+
+	for _, o := range orders {
+		if o.parent == 0 && o.status == StatusPending {
+			e.activate(o, now)
+		} else if o.parent != 0 {
+			e.orderUpdate(o)
+		}
+	}
+	return nil
+}
+
+func (e *Exchange) findOrder(marketId uint8, index int64) *order {
+	if index >= txtypes.MinOrderIndex {
+		if o, ok := e.orders[index]; ok && o.marketId == marketId {
+			return o
+		}
+		return nil
+	}
+	for _, o := range e.orders {
+		if o.marketId == marketId && o.clientIndex == index {
+			return o
+		}
+	}
+	return nil
+}
+
+func (e *Exchange) modifyOrder(tx *txtypes.L2ModifyOrderTxInfo, now int64) error {
+	o := e.findOrder(tx.MarketIndex, tx.Index)
+	if o == nil {
+		return fmt.Errorf("order %d not found", tx.Index)
+	}
+	m := e.markets[o.marketId]
+	filled := o.initial - o.remaining
+	o.remaining = tx.BaseAmount
+	o.initial = filled + o.remaining
+	o.price = int64(tx.Price)
+	o.trigger = int64(tx.TriggerPrice)
+
+	if o.status == StatusOpen {
+		e.place(m, o, now)
+		return nil
+	}
+	e.orderUpdate(o)
+	if o.parent == 0 {
+		e.checkTriggers(m, now)
+	}
+	return nil
+}
+
+// place runs an order against the book and rests what's left, according to its time in force
+func (e *Exchange) place(m *market, o *order, now int64) {
+	o.status = StatusOpen
+	if o.timeInForce == txtypes.PostOnly && m.crosses(o) {
+		e.cancel(o, StatusCanceledPostOnly, now)
+		return
+	}
+	if o.reduceOnly {
+		reducible := e.reducible(o)
+		if o.initial == 0 {
+			// reduce-only orders without size close the whole position
+			o.initial, o.remaining = reducible, reducible
+		}
+		if reducible == 0 {
+			e.cancel(o, StatusCanceledReduce, now)
+			return
+		}
+	}
+
+	e.take(m, o, now)
+	if o.status != StatusOpen {
+		return
+	}
+	if o.reduceOnly && e.reducible(o) == 0 {
+		e.cancel(o, StatusCanceledReduce, now)
+		return
+	}
+	if o.orderType == txtypes.MarketOrder || o.orderType == txtypes.StopLossOrder || o.orderType == txtypes.TakeProfitOrder || o.timeInForce == txtypes.ImmediateOrCancel {
+		e.cancel(o, StatusCanceledNotFilled, now)
+		return
+	}
+	e.orderUpdate(o)
+}
+
+// take fills an order as taker against the opposite side of the book, up to its limit price
+func (e *Exchange) take(m *market, o *order, now int64) {
+	e.sweep(m, o, false, now)
+}
+
+// matchResting fills the resting orders crossed by the book, as maker at their own price
+func (e *Exchange) matchResting(m *market, now int64) {
+	for _, o := range e.sortedOrders(m.detail.MarketId, StatusOpen) {
+		e.sweep(m, o, true, now)
+	}
+}
+
+// sweep consumes the levels of the book an order crosses
+func (e *Exchange) sweep(m *market, o *order, isMaker bool, now int64) {
+	side := m.asks
+	if o.isAsk {
+		side = m.bids
+	}
+	for _, p := range levels(side, !o.isAsk) {
+		if (!o.isAsk && p > o.price) || (o.isAsk && p < o.price) || o.status != StatusOpen {
+			break
+		}
+		if isMaker && o.tradeFilled > 0 {
+			// the size already filled by public trades is taken off the crossing levels first
+			skipped := min(o.tradeFilled, side[p])
+			o.tradeFilled -= skipped
+			if side[p] -= skipped; side[p] <= 0 {
+				delete(side, p)
+				continue
+			}
+		}
+		qty := e.clip(o, min(o.remaining, side[p]))
+		if qty <= 0 {
+			break
+		}
+		price := p
+		if isMaker {
+			price = o.price
+		}
+		e.fill(m, o, qty, price, isMaker, now)
+		if side[p] -= qty; side[p] <= 0 {
+			delete(side, p)
+		}
+	}
+}
+
+// checkTriggers places the trigger orders whose trigger price was reached by the mark price
+func (e *Exchange) checkTriggers(m *market, now int64) {
+	mark := m.markPrice()
+	if !mark.IsPositive() {
+		return
+	}
+	for _, o := range e.sortedOrders(m.detail.MarketId, StatusPending) {
+		if o.parent != 0 || o.trigger <= 0 {
+			continue
+		}
+		cmp := mark.Cmp(m.price(o.trigger))
+		var triggered bool
+		switch o.orderType {
+		case txtypes.StopLossOrder, txtypes.StopLossLimitOrder:
+			triggered = (o.isAsk && cmp <= 0) || (!o.isAsk && cmp >= 0)
+		case txtypes.TakeProfitOrder, txtypes.TakeProfitLimitOrder:
+			triggered = (o.isAsk && cmp >= 0) || (!o.isAsk && cmp <= 0)
+		}
+		if triggered {
+			e.place(m, o, now)
+		}
+	}
+}
+
+// reducible returns how much of the position an order on the opposite side can close
+func (e *Exchange) reducible(o *order) int64 {
+	p, ok := e.positions[o.marketId]
+	if !ok {
+		return 0
+	}
+	if o.isAsk && p.size > 0 {
+		return p.size
+	}
+	if !o.isAsk && p.size < 0 {
+		return -p.size
+	}
+	return 0
+}
+
+// clip bounds the fill of reduce-only orders to the position
+func (e *Exchange) clip(o *order, qty int64) int64 {
+	if !o.reduceOnly {
+		return qty
+	}
+	return min(qty, e.reducible(o))
+}
+
+func (e *Exchange) fill(m *market, o *order, qty, price int64, isMaker bool, now int64) {
+	o.remaining -= qty
+	if o.remaining <= 0 {
+		o.remaining = 0
+		o.status = StatusFilled
+	}
+
+	rate := m.fees.Taker
+	if isMaker {
+		rate = m.fees.Maker
+	}
+	notional := m.size(qty).Mul(m.price(price))
+	e.collateral = e.collateral.Sub(notional.Mul(rate).Round(usdcDecimals))
+	e.applyFill(m, o.isAsk, qty, price)
+	m.lastPrice = price
+
+	trade := client.WSTrade{
+		TradeId:     e.nextTradeId,
+		Type:        "trade",
+		MarketId:    int(o.marketId),
+		Size:        m.size(qty),
+		Price:       m.price(price),
+		UsdAmount:   notional,
+		IsMakerAsk:  isMaker == o.isAsk,
+		Timestamp:   now,
+		BlockHeight: e.nextTradeId,
+	}
+	if o.isAsk {
+		trade.AskId, trade.AskAccountId = o.index, e.cfg.AccountIndex
+	} else {
+		trade.BidId, trade.BidAccountId = o.index, e.cfg.AccountIndex
+	}
+	e.nextTradeId++
+	e.newTrades = append(e.newTrades, trade)
+	e.changedMarkets[o.marketId] = true
+	e.orderUpdate(o)
+	e.cancelUnreducible(o.marketId, now)
+
+	if o.status == StatusFilled {
+		delete(e.orders, o.index)
+		e.cancelPeers(o, now)
+		for _, child := range e.sortedOrders(o.marketId, StatusPending) {
+			if child.parent == o.index {
+				child.parent = 0
+				e.activate(child, now)
+			}
+		}
+	}
+}
+
+// applyFill updates the position, realizing the pnl of the closed part
+func (e *Exchange) applyFill(m *market, isAsk bool, qty, price int64) {
+	p, ok := e.positions[m.detail.MarketId]
+	if !ok {
+		p = &position{}
+		e.positions[m.detail.MarketId] = p
+	}
+	signed := qty
+	if isAsk {
+		signed = -qty
+	}
+	size := p.size
+	if size < 0 {
+		size = -size
+	}
+
+	if p.size == 0 || (p.size > 0) == (signed > 0) {
+		notional := m.size(size).Mul(p.entry).Add(m.size(qty).Mul(m.price(price)))
+		p.entry = notional.Div(m.size(size + qty))
+		p.size += signed
+		return
+	}
+
+	closed := min(qty, size)
+	pnl := m.size(closed).Mul(m.price(price).Sub(p.entry)).Round(usdcDecimals)
+	if p.size < 0 {
+		pnl = pnl.Neg()
+	}
+	p.realized = p.realized.Add(pnl)
+	e.collateral = e.collateral.Add(pnl)
+	p.size += signed
+	switch {
+	case p.size == 0:
+		p.entry = decimal.Zero
+	case (p.size > 0) == (signed > 0):
+		// flipped, the rest is opened at the fill price
+		p.entry = m.price(price)
+	}
+}
+
+// cancelUnreducible cancels the reduce-only orders left without a position to reduce
+func (e *Exchange) cancelUnreducible(marketId uint8, now int64) {
+	for _, o := range e.sortedOrders(marketId, StatusOpen) {
+		if o.reduceOnly && e.reducible(o) == 0 {
+			e.cancel(o, StatusCanceledReduce, now)
+		}
+	}
+}
+
+func (e *Exchange) cancel(o *order, status string, now int64) {
+	if !o.active() {
+		return
+	}
+	o.status = status
+	delete(e.orders, o.index)
+	e.orderUpdate(o)
+
+	e.cancelPeers(o, now)
+	for _, child := range e.sortedOrders(o.marketId, StatusPending) {
+		if child.parent == o.index {
+			e.cancel(child, StatusCanceled, now)
+		}
+	}
+}
+
+func (e *Exchange) cancelPeers(o *order, now int64) {
+	for _, index := range o.ocoPeers {
+		if peer, ok := e.orders[index]; ok {
+			e.cancel(peer, StatusCanceled, now)
+		}
+	}
+}
+
+func (e *Exchange) cancelAll(now int64) {
+	ids := make([]int64, 0, len(e.orders))
+	for index := range e.orders {
+		ids = append(ids, index)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	for _, index := range ids {
+		if o, ok := e.orders[index]; ok {
+			e.cancel(o, StatusCanceled, now)
+		}
+	}
+}
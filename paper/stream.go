@@ -0,0 +1,313 @@
+package paper
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+
+	"github.com/u20024804/lighter-ex/client"
+	"github.com/u20024804/lighter-ex/decimal"
+)
+
+// Start implements client.LighterWebsocketPrivateServiceI. Nothing is connected, errHandler receives the errors of the callbacks.
+func (e *Exchange) Start(_ context.Context, errHandler client.ErrHandler) error {
+	e.subsMu.Lock()
+	defer e.subsMu.Unlock()
+	e.errHandler = errHandler
+	return nil
+}
+
+// Close implements client.LighterWebsocketPrivateServiceI, it removes all subscriptions
+func (e *Exchange) Close() error {
+	e.subsMu.Lock()
+	defer e.subsMu.Unlock()
+	e.accountSubs = make(map[int]func(client.LighterAccountResponse) error)
+	e.orderSubs = make(map[int]func(client.LighterOrdersResponse) error)
+	return nil
+}
+
+// SubscribeAccount implements client.LighterWebsocketPrivateServiceI. The callback gets a snapshot of the account right away,
+// then an update with the changed positions & the new trades after every change.
+func (e *Exchange) SubscribeAccount(
+	param client.LighterAccountParamKey,
+	callback func(client.LighterAccountResponse) error,
+) (func() error, error) {
+	if param.AccountId != e.cfg.AccountIndex {
+		return nil, fmt.Errorf("account %d is not simulated", param.AccountId)
+	}
+
+	e.mu.Lock()
+	marketIds := make([]uint8, 0, len(e.markets))
+	for marketId := range e.markets {
+		marketIds = append(marketIds, marketId)
+	}
+	snapshot := e.accountUpdate(client.MessageTypeAccountSubscribed, marketIds, nil)
+	e.mu.Unlock()
+
+	e.subsMu.Lock()
+	id := e.nextSubId
+	e.nextSubId++
+	e.accountSubs[id] = callback
+	e.subsMu.Unlock()
+
+	if err := callback(snapshot); err != nil {
+		e.handleErr(err)
+	}
+	return func() error {
+		e.subsMu.Lock()
+		defer e.subsMu.Unlock()
+		delete(e.accountSubs, id)
+		return nil
+	}, nil
+}
+
+// SubscribeOrders implements client.LighterWebsocketPrivateServiceI. The callback gets every status & fill change of the
+// orders of param.MarketIds, or of every market if empty.
+func (e *Exchange) SubscribeOrders(
+	param client.LighterOrdersParamKey,
+	callback func(client.LighterOrdersResponse) error,
+) (func() error, error) {
+	if param.AccountId != e.cfg.AccountIndex {
+		return nil, fmt.Errorf("account %d is not simulated", param.AccountId)
+	}
+	if len(param.MarketIds) > 0 {
+		marketIds, all := param.MarketIds, callback
+		callback = func(resp client.LighterOrdersResponse) error {
+			if !slices.Contains(marketIds, resp.MarketId) {
+				return nil
+			}
+			return all(resp)
+		}
+	}
+
+	e.subsMu.Lock()
+	defer e.subsMu.Unlock()
+	id := e.nextSubId
+	e.nextSubId++
+	e.orderSubs[id] = callback
+	return func() error {
+		e.subsMu.Lock()
+		defer e.subsMu.Unlock()
+		delete(e.orderSubs, id)
+		return nil
+	}, nil
+}
+
+// SubscribeUserStats implements client.LighterWebsocketPrivateServiceI, the stats aren't simulated
+func (e *Exchange) SubscribeUserStats(
+	client.LighterUserStatsParamKey,
+	func(client.LighterUserStatsResponse) error,
+) (func() error, error) {
+	return nil, fmt.Errorf("%s is not simulated", client.ChannelUserStats)
+}
+
+// SubscribeAccountTxs implements client.LighterWebsocketPrivateServiceI, the txs aren't simulated
+func (e *Exchange) SubscribeAccountTxs(
+	client.LighterAccountTxsParamKey,
+	func(client.LighterAccountTxsResponse) error,
+) (func() error, error) {
+	return nil, fmt.Errorf("%s is not simulated", client.ChannelAccountTx)
+}
+
+// SubscribePoolData implements client.LighterWebsocketPrivateServiceI, the pools aren't simulated
+func (e *Exchange) SubscribePoolData(
+	client.LighterPoolParamKey,
+	func(client.LighterPoolDataResponse) error,
+) (func() error, error) {
+	return nil, fmt.Errorf("%s is not simulated", client.ChannelPoolData)
+}
+
+// SubscribePoolInfo implements client.LighterWebsocketPrivateServiceI, the pools aren't simulated
+func (e *Exchange) SubscribePoolInfo(
+	client.LighterPoolParamKey,
+	func(client.LighterPoolInfoResponse) error,
+) (func() error, error) {
+	return nil, fmt.Errorf("%s is not simulated", client.ChannelPoolInfo)
+}
+
+// AccountStream implements client.LighterWebsocketPrivateServiceI with SubscribeAccount
+func (e *Exchange) AccountStream(accountId int64, config client.DispatchConfig) (*client.Stream[client.LighterAccountResponse], error) {
+	return client.NewStream(config, func(callback func(client.LighterAccountResponse) error) (func() error, error) {
+		return e.SubscribeAccount(client.LighterAccountParamKey{AccountId: accountId}, callback)
+	})
+}
+
+func (e *Exchange) handleErr(err error) {
+	e.subsMu.RLock()
+	errHandler := e.errHandler
+	e.subsMu.RUnlock()
+	if errHandler != nil {
+		errHandler(err)
+	}
+}
+
+// orderUpdate records the current state of an order, to be published. Must be called with e.mu held.
+func (e *Exchange) orderUpdate(o *order) {
+	m := e.markets[o.marketId]
+	var isAsk uint8
+	if o.isAsk {
+		isAsk = 1
+	}
+	e.orderUpdates = append(e.orderUpdates, client.LighterOrdersResponse{
+		AccountId:        e.cfg.AccountIndex,
+		OrderId:          strconv.FormatInt(o.index, 10),
+		ClientOrderIndex: o.clientIndex,
+		MarketId:         o.marketId,
+		Status:           o.status,
+		BaseQuantity:     m.size(o.initial),
+		FilledQuantity:   m.size(o.initial - o.remaining),
+		Price:            m.price(o.price),
+		IsAsk:            isAsk,
+		Timestamp:        e.now(),
+	})
+	e.changedMarkets[o.marketId] = true
+}
+
+// wsPosition returns the position of a market in the stream format, nil when there's neither a position nor an order.
+// Must be called with e.mu held.
+func (e *Exchange) wsPosition(marketId uint8) *client.WSPosition {
+	m := e.markets[marketId]
+	var openOrders, pendingOrders int
+	for _, o := range e.orders {
+		if o.marketId != marketId {
+			continue
+		}
+		switch o.status {
+		case StatusOpen:
+			openOrders++
+		case StatusPending:
+			pendingOrders++
+		}
+	}
+	p, ok := e.positions[marketId]
+	if (!ok || (p.size == 0 && p.realized.IsZero())) && openOrders == 0 && pendingOrders == 0 {
+		return nil
+	}
+	if !ok {
+		p = &position{}
+	}
+
+	var sign int8
+	switch {
+	case p.size > 0:
+		sign = 1
+	case p.size < 0:
+		sign = -1
+	}
+	mark := m.markPrice()
+	if mark.IsZero() {
+		mark = p.entry
+	}
+	size := p.size
+	if size < 0 {
+		size = -size
+	}
+	return &client.WSPosition{
+		MarketId:          marketId,
+		Symbol:            m.detail.Symbol,
+		OpenOrderCount:    openOrders,
+		PendingOrderCount: pendingOrders,
+		Sign:              sign,
+		Position:          m.size(size),
+		AvgEntryPrice:     p.entry.Round(m.detail.PriceDecimals),
+		PositionValue:     m.size(size).Mul(mark).Round(usdcDecimals),
+		UnrealizedPnl:     m.size(p.size).Mul(mark.Sub(p.entry)).Round(usdcDecimals),
+		RealizedPnl:       p.realized,
+		MarginMode:        0,
+	}
+}
+
+// accountUpdate builds an account message with the given markets & trades. Must be called with e.mu held.
+func (e *Exchange) accountUpdate(msgType string, marketIds []uint8, trades []client.WSTrade) client.LighterAccountResponse {
+	sort.Slice(marketIds, func(i, j int) bool { return marketIds[i] < marketIds[j] })
+	update := &client.WSAccountUpdate{
+		Account:   e.cfg.AccountIndex,
+		Channel:   fmt.Sprintf("account_all:%d", e.cfg.AccountIndex),
+		Type:      msgType,
+		Positions: make(map[string]*client.WSPosition),
+		Trades:    make(map[string][]client.WSTrade),
+	}
+	var stats []client.AccountMarketStats
+	for _, marketId := range marketIds {
+		p := e.wsPosition(marketId)
+		if p == nil {
+			if msgType == client.MessageTypeAccountSubscribed {
+				continue
+			}
+			// the position was closed & its last order is gone, report it as empty
+			p = &client.WSPosition{MarketId: marketId, Symbol: e.markets[marketId].detail.Symbol, Position: decimal.Zero}
+		}
+		update.Positions[strconv.Itoa(int(marketId))] = p
+		stats = append(stats, client.AccountMarketStats{
+			MarketId:       p.MarketId,
+			OpenOrderCount: int64(p.OpenOrderCount),
+			Sign:           p.Sign,
+			Position:       p.Position,
+			AvgEntryPrice:  p.AvgEntryPrice,
+			PositionValue:  p.PositionValue,
+			UnrealizedPnl:  p.UnrealizedPnl,
+			RealizedPnl:    p.RealizedPnl,
+		})
+	}
+	for _, t := range trades {
+		key := strconv.Itoa(t.MarketId)
+		update.Trades[key] = append(update.Trades[key], t)
+		update.TotalTradesCount++
+		update.TotalVolume = update.TotalVolume.Add(t.UsdAmount)
+	}
+
+	return client.LighterAccountResponse{
+		AccountId:        e.cfg.AccountIndex,
+		AvailableBalance: e.collateral,
+		MarketStats:      stats,
+		Timestamp:        e.now(),
+		IsSnapshot:       msgType == client.MessageTypeAccountSubscribed,
+		RawAccountUpdate: update,
+	}
+}
+
+// publish sends the changes collected since the last call to the subscribers. It must be called without e.mu held,
+// so callbacks can send txs.
+func (e *Exchange) publish() {
+	e.mu.Lock()
+	if len(e.changedMarkets) == 0 && len(e.newTrades) == 0 && len(e.orderUpdates) == 0 {
+		e.mu.Unlock()
+		return
+	}
+	marketIds := make([]uint8, 0, len(e.changedMarkets))
+	for marketId := range e.changedMarkets {
+		marketIds = append(marketIds, marketId)
+	}
+	account := e.accountUpdate(client.MessageTypeAccount, marketIds, e.newTrades)
+	orders := e.orderUpdates
+	e.changedMarkets = make(map[uint8]bool)
+	e.newTrades = nil
+	e.orderUpdates = nil
+	e.mu.Unlock()
+
+	e.subsMu.RLock()
+	orderSubs := make([]func(client.LighterOrdersResponse) error, 0, len(e.orderSubs))
+	for _, sub := range e.orderSubs {
+		orderSubs = append(orderSubs, sub)
+	}
+	accountSubs := make([]func(client.LighterAccountResponse) error, 0, len(e.accountSubs))
+	for _, sub := range e.accountSubs {
+		accountSubs = append(accountSubs, sub)
+	}
+	e.subsMu.RUnlock()
+
+	for _, sub := range orderSubs {
+		for _, o := range orders {
+			if err := sub(o); err != nil {
+				e.handleErr(err)
+			}
+		}
+	}
+	for _, sub := range accountSubs {
+		if err := sub(account); err != nil {
+			e.handleErr(err)
+		}
+	}
+}
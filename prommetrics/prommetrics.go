@@ -0,0 +1,189 @@
+// Package prommetrics reports the measurements of the client package to Prometheus.
+//
+//	m, err := prommetrics.New(prometheus.DefaultRegisterer, "lighter")
+//	if err != nil {
+//		return err
+//	}
+//	client.SetMetrics(m)
+package prommetrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/u20024804/lighter-ex/client"
+)
+
+var _ client.Metrics = (*Metrics)(nil)
+
+// Metrics implements client.Metrics with Prometheus collectors
+type Metrics struct {
+	httpDuration   *prometheus.HistogramVec
+	httpErrors     *prometheus.CounterVec
+	txSent         *prometheus.CounterVec
+	signDuration   prometheus.Histogram
+	nonceFetches   *prometheus.CounterVec
+	wsConnected    *prometheus.GaugeVec
+	wsReconnects   *prometheus.CounterVec
+	wsMessages     *prometheus.CounterVec
+	wsHandler      *prometheus.HistogramVec
+	orderBookGaps  *prometheus.CounterVec
+	orderBookSyncs *prometheus.CounterVec
+	queueDepth     *prometheus.GaugeVec
+	queueOverflows *prometheus.CounterVec
+	staleSubs      *prometheus.GaugeVec
+}
+
+// New creates the collectors, named <namespace>_..., and registers them with reg
+func New(reg prometheus.Registerer, namespace string) (*Metrics, error) {
+	m := &Metrics{
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Duration of the REST calls per endpoint.",
+			Buckets:   prometheus.ExponentialBuckets(0.005, 2, 12),
+		}, []string{"endpoint"}),
+		httpErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_request_errors_total",
+			Help:      "Failed REST calls per endpoint.",
+		}, []string{"endpoint"}),
+		txSent: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "tx_sent_total",
+			Help:      "Txs sent per tx type & result code, 0 when no response was received.",
+		}, []string{"tx_type", "code"}),
+		signDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "sign_duration_seconds",
+			Help:      "Duration of the tx signatures.",
+			Buckets:   prometheus.ExponentialBuckets(0.00005, 2, 12),
+		}),
+		nonceFetches: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "nonce_fetches_total",
+			Help:      "Next nonce fetches per account & api key.",
+		}, []string{"account", "api_key"}),
+		wsConnected: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "ws_connected",
+			Help:      "Number of established WebSocket connections per URL.",
+		}, []string{"url"}),
+		wsReconnects: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "ws_reconnects_total",
+			Help:      "WebSocket reconnections per URL.",
+		}, []string{"url"}),
+		wsMessages: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "ws_messages_total",
+			Help:      "Received WebSocket messages per type.",
+		}, []string{"type"}),
+		wsHandler: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "ws_handler_duration_seconds",
+			Help:      "Duration of the handlers of a WebSocket message per type.",
+			Buckets:   prometheus.ExponentialBuckets(0.00001, 4, 10),
+		}, []string{"type"}),
+		orderBookGaps: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "order_book_gaps_total",
+			Help:      "Order book updates not following the previous message per market.",
+		}, []string{"market"}),
+		orderBookSyncs: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "order_book_resyncs_total",
+			Help:      "Order book snapshots replacing an existing book per market.",
+		}, []string{"market"}),
+		queueDepth: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "ws_queue_depth",
+			Help:      "Messages pending in the queue of a subscription.",
+		}, []string{"subscription"}),
+		queueOverflows: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "ws_queue_overflows_total",
+			Help:      "Messages arriving while the queue of a subscription is full, per subscription & policy.",
+		}, []string{"subscription", "policy"}),
+		staleSubs: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "ws_subscription_stale",
+			Help:      "1 while a subscription is stale, 0 otherwise.",
+		}, []string{"subscription"}),
+	}
+
+	for _, c := range []prometheus.Collector{
+		m.httpDuration, m.httpErrors, m.txSent, m.signDuration, m.nonceFetches,
+		m.wsConnected, m.wsReconnects, m.wsMessages, m.wsHandler, m.orderBookGaps, m.orderBookSyncs,
+		m.queueDepth, m.queueOverflows, m.staleSubs,
+	} {
+		if err := reg.Register(c); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+func (m *Metrics) HTTPRequest(endpoint string, duration time.Duration, err error) {
+	m.httpDuration.WithLabelValues(endpoint).Observe(duration.Seconds())
+	if err != nil {
+		m.httpErrors.WithLabelValues(endpoint).Inc()
+	}
+}
+
+func (m *Metrics) TxSent(txType uint8, code int32) {
+	m.txSent.WithLabelValues(strconv.Itoa(int(txType)), strconv.Itoa(int(code))).Inc()
+}
+
+func (m *Metrics) SignDuration(duration time.Duration) {
+	m.signDuration.Observe(duration.Seconds())
+}
+
+func (m *Metrics) NonceFetch(accountIndex int64, apiKeyIndex uint8) {
+	m.nonceFetches.WithLabelValues(strconv.FormatInt(accountIndex, 10), strconv.Itoa(int(apiKeyIndex))).Inc()
+}
+
+func (m *Metrics) WSConnectionState(url string, connected bool) {
+	if connected {
+		m.wsConnected.WithLabelValues(url).Inc()
+	} else {
+		m.wsConnected.WithLabelValues(url).Dec()
+	}
+}
+
+func (m *Metrics) WSReconnect(url string) {
+	m.wsReconnects.WithLabelValues(url).Inc()
+}
+
+func (m *Metrics) WSMessage(msgType string) {
+	m.wsMessages.WithLabelValues(msgType).Inc()
+}
+
+func (m *Metrics) WSHandlerDuration(msgType string, duration time.Duration) {
+	m.wsHandler.WithLabelValues(msgType).Observe(duration.Seconds())
+}
+
+func (m *Metrics) OrderBookGap(marketId uint8) {
+	m.orderBookGaps.WithLabelValues(strconv.Itoa(int(marketId))).Inc()
+}
+
+func (m *Metrics) OrderBookResync(marketId uint8) {
+	m.orderBookSyncs.WithLabelValues(strconv.Itoa(int(marketId))).Inc()
+}
+
+func (m *Metrics) WSQueueDepth(subscription string, depth int) {
+	m.queueDepth.WithLabelValues(subscription).Set(float64(depth))
+}
+
+func (m *Metrics) WSQueueOverflow(subscription string, policy client.QueuePolicy) {
+	m.queueOverflows.WithLabelValues(subscription, policy.String()).Inc()
+}
+
+func (m *Metrics) WSStale(subscription string, stale bool) {
+	if stale {
+		m.staleSubs.WithLabelValues(subscription).Set(1)
+	} else {
+		m.staleSubs.WithLabelValues(subscription).Set(0)
+	}
+}
@@ -0,0 +1,115 @@
+package prommetrics
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/u20024804/lighter-ex/client"
+)
+
+func TestNew(t *testing.T) {
+	reg := prometheus.NewPedanticRegistry()
+	m, err := New(reg, "lighter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.SignDuration(time.Millisecond)
+	m.TxSent(14, 200)
+	if problems, err := testutil.GatherAndLint(reg); err != nil || len(problems) > 0 {
+		t.Errorf("expected valid metrics, got %v %v", problems, err)
+	}
+
+	if _, err := New(reg, "lighter"); err == nil {
+		t.Error("expected a second registration to fail")
+	}
+	if _, err := New(reg, "other"); err != nil {
+		t.Errorf("expected another namespace to register, got %v", err)
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	m, err := New(reg, "lighter")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m.HTTPRequest("api/v1/account", 10*time.Millisecond, nil)
+	m.HTTPRequest("api/v1/account", 20*time.Millisecond, errors.New("timeout"))
+	m.TxSent(14, 200)
+	m.TxSent(14, 200)
+	m.TxSent(15, 0)
+	m.NonceFetch(7, 2)
+	m.WSConnectionState("wss://example", true)
+	m.WSConnectionState("wss://example", true)
+	m.WSConnectionState("wss://example", false)
+	m.WSReconnect("wss://example")
+	m.WSMessage("update/order_book")
+	m.WSHandlerDuration("update/order_book", time.Microsecond)
+	m.OrderBookGap(1)
+	m.OrderBookResync(1)
+	m.WSQueueDepth("order_book:1", 3)
+	m.WSQueueDepth("order_book:1", 1)
+	m.WSQueueOverflow("order_book:1", client.QueueConflate)
+	m.WSStale("trade:1", true)
+	m.WSStale("trade:2", true)
+	m.WSStale("trade:2", false)
+
+	tests := []struct {
+		name      string
+		collector prometheus.Collector
+		want      float64
+	}{
+		{"http errors", m.httpErrors.WithLabelValues("api/v1/account"), 1},
+		{"txs sent", m.txSent.WithLabelValues("14", "200"), 2},
+		{"txs without response", m.txSent.WithLabelValues("15", "0"), 1},
+		{"nonce fetches", m.nonceFetches.WithLabelValues("7", "2"), 1},
+		{"connections", m.wsConnected.WithLabelValues("wss://example"), 1},
+		{"reconnects", m.wsReconnects.WithLabelValues("wss://example"), 1},
+		{"messages", m.wsMessages.WithLabelValues("update/order_book"), 1},
+		{"gaps", m.orderBookGaps.WithLabelValues("1"), 1},
+		{"resyncs", m.orderBookSyncs.WithLabelValues("1"), 1},
+		{"queue depth", m.queueDepth.WithLabelValues("order_book:1"), 1},
+		{"overflows", m.queueOverflows.WithLabelValues("order_book:1", "conflate"), 1},
+		{"stale", m.staleSubs.WithLabelValues("trade:1"), 1},
+		{"recovered", m.staleSubs.WithLabelValues("trade:2"), 0},
+	}
+	for _, tt := range tests {
+		if got := testutil.ToFloat64(tt.collector); got != tt.want {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+		}
+	}
+
+	// the histograms count every observation
+	for name, want := range map[string]uint64{
+		"lighter_http_request_duration_seconds": 2,
+		"lighter_sign_duration_seconds":         0,
+		"lighter_ws_handler_duration_seconds":   1,
+	} {
+		if got := sampleCount(t, reg, name); got != want {
+			t.Errorf("%s: expected %d observations, got %d", name, want, got)
+		}
+	}
+}
+
+// sampleCount returns the number of observations of the histogram name, over all its labels
+func sampleCount(t *testing.T, reg prometheus.Gatherer, name string) uint64 {
+	t.Helper()
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var count uint64
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			count += metric.GetHistogram().GetSampleCount()
+		}
+	}
+	return count
+}
@@ -3,14 +3,16 @@ package main
 import (
 	"encoding/json"
 	"fmt"
-	"strings"
 	"time"
 
 	curve "github.com/elliottech/poseidon_crypto/curve/ecgfp5"
 	schnorr "github.com/elliottech/poseidon_crypto/signature/schnorr"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/u20024804/lighter-ex/client"
+	"github.com/u20024804/lighter-ex/signer"
 	"github.com/u20024804/lighter-ex/types"
+	"github.com/u20024804/lighter-ex/types/txtypes"
+	"github.com/u20024804/lighter-ex/types/vectors"
 )
 
 /*
@@ -141,22 +143,7 @@ func CheckClient(cApiKeyIndex C.int, cAccountIndex C.longlong) (ret *C.char) {
 	}
 
 	// check that the API key registered on Lighter matches this one
-	key, err := client.HTTP().GetApiKey(accountIndex, apiKeyIndex)
-	if err != nil {
-		err = fmt.Errorf("failed to get Api Keys. err: %v", err)
-		return
-	}
-
-	pubKeyBytes := client.GetKeyManager().PubKeyBytes()
-	pubKeyStr := hexutil.Encode(pubKeyBytes[:])
-	pubKeyStr = strings.Replace(pubKeyStr, "0x", "", 1)
-
-	ak := key.ApiKeys[0]
-	if ak.PublicKey != pubKeyStr {
-		err = fmt.Errorf("private key does not match the one on Lighter. ownPubKey: %s response: %+v", pubKeyStr, ak)
-		return
-	}
-
+	err = client.CheckApiKey()
 	return
 }
 
@@ -975,7 +962,7 @@ func SignUpdateMargin(cMarketIndex C.int, cUSDCAmount C.longlong, cDirection C.i
 	var txInfoStr string
 	defer func() {
 		if r := recover(); r != nil {
-			wrapErr(fmt.Errorf("panic: %v", r))
+			err = fmt.Errorf("%v", r)
 		}
 		if err != nil {
 			ret = C.StrOrErr{
@@ -989,7 +976,8 @@ func SignUpdateMargin(cMarketIndex C.int, cUSDCAmount C.longlong, cDirection C.i
 	}()
 
 	if txClient == nil {
-		err = fmt.Errorf("Client is not created, call CreateClient() first")
+		err = fmt.Errorf("client is not created, call CreateClient() first")
+		return
 	}
 
 	marketIndex := uint8(cMarketIndex)
@@ -1008,11 +996,143 @@ func SignUpdateMargin(cMarketIndex C.int, cUSDCAmount C.longlong, cDirection C.i
 	}
 
 	tx, err := txClient.GetUpdateMarginTransaction(txInfo, ops)
+	if err != nil {
+		return
+	}
 
 	txInfoBytes, err := json.Marshal(tx)
+	if err != nil {
+		return
+	}
+
 	txInfoStr = string(txInfoBytes)
+	return
+}
 
-	return ret
+//export CheckTestVectors
+func CheckTestVectors(cVectors *C.char) (ret *C.char) {
+	var err error
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+		if err != nil {
+			ret = wrapErr(err)
+		}
+	}()
+
+	// an empty string checks the vectors embedded in the library
+	var f *vectors.File
+	if data := C.GoString(cVectors); data != "" {
+		f, err = vectors.Parse([]byte(data))
+	} else {
+		f, err = vectors.Load()
+	}
+	if err != nil {
+		return
+	}
+
+	err = f.Run(buildVectorTx)
+	return
+}
+
+// buildVectorTx signs a vector through a TxClient, the same path used by the Sign* exports
+func buildVectorTx(key signer.KeyManager, v *vectors.Vector) (txtypes.TxInfo, error) {
+	c, err := client.NewTxClient(nil, hexutil.Encode(key.PrvKeyBytes()), v.AccountIndex, v.ApiKeyIndex, v.ChainId)
+	if err != nil {
+		return nil, err
+	}
+
+	ops := v.Ops()
+	decode := func(req interface{}) error {
+		return json.Unmarshal(v.Request, req)
+	}
+
+	switch v.TxType {
+	case txtypes.TxTypeL2ChangePubKey:
+		req := &types.ChangePubKeyReq{}
+		if err := decode(req); err != nil {
+			return nil, err
+		}
+		return c.GetChangePubKeyTransaction(req, ops)
+	case txtypes.TxTypeL2CreateSubAccount:
+		return c.GetCreateSubAccountTransaction(ops)
+	case txtypes.TxTypeL2CreatePublicPool:
+		req := &types.CreatePublicPoolTxReq{}
+		if err := decode(req); err != nil {
+			return nil, err
+		}
+		return c.GetCreatePublicPoolTransaction(req, ops)
+	case txtypes.TxTypeL2UpdatePublicPool:
+		req := &types.UpdatePublicPoolTxReq{}
+		if err := decode(req); err != nil {
+			return nil, err
+		}
+		return c.GetUpdatePublicPoolTransaction(req, ops)
+	case txtypes.TxTypeL2Transfer:
+		req := &types.TransferTxReq{}
+		if err := decode(req); err != nil {
+			return nil, err
+		}
+		return c.GetTransferTransaction(req, ops)
+	case txtypes.TxTypeL2Withdraw:
+		req := &types.WithdrawTxReq{}
+		if err := decode(req); err != nil {
+			return nil, err
+		}
+		return c.GetWithdrawTransaction(req, ops)
+	case txtypes.TxTypeL2CreateOrder:
+		req := &types.CreateOrderTxReq{}
+		if err := decode(req); err != nil {
+			return nil, err
+		}
+		return c.GetCreateOrderTransaction(req, ops)
+	case txtypes.TxTypeL2CancelOrder:
+		req := &types.CancelOrderTxReq{}
+		if err := decode(req); err != nil {
+			return nil, err
+		}
+		return c.GetCancelOrderTransaction(req, ops)
+	case txtypes.TxTypeL2CancelAllOrders:
+		req := &types.CancelAllOrdersTxReq{}
+		if err := decode(req); err != nil {
+			return nil, err
+		}
+		return c.GetCancelAllOrdersTransaction(req, ops)
+	case txtypes.TxTypeL2ModifyOrder:
+		req := &types.ModifyOrderTxReq{}
+		if err := decode(req); err != nil {
+			return nil, err
+		}
+		return c.GetModifyOrderTransaction(req, ops)
+	case txtypes.TxTypeL2MintShares:
+		req := &types.MintSharesTxReq{}
+		if err := decode(req); err != nil {
+			return nil, err
+		}
+		return c.GetMintSharesTransaction(req, ops)
+	case txtypes.TxTypeL2BurnShares:
+		req := &types.BurnSharesTxReq{}
+		if err := decode(req); err != nil {
+			return nil, err
+		}
+		return c.GetBurnSharesTransaction(req, ops)
+	case txtypes.TxTypeL2UpdateLeverage:
+		req := &types.UpdateLeverageTxReq{}
+		if err := decode(req); err != nil {
+			return nil, err
+		}
+		return c.GetUpdateLeverageTransaction(req, ops)
+	case txtypes.TxTypeL2UpdateMargin:
+		req := &types.UpdateMarginTxReq{}
+		if err := decode(req); err != nil {
+			return nil, err
+		}
+		return c.GetUpdateMarginTransaction(req, ops)
+	default:
+		// grouped orders have no sign function exposed through the library yet
+		return v.Construct(key)
+	}
 }
 
 func main() {}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	schnorr "github.com/elliottech/poseidon_crypto/signature/schnorr"
+	"github.com/u20024804/lighter-ex/types/txtypes"
+	"github.com/u20024804/lighter-ex/types/vectors"
+)
+
+func TestCheckTestVectors(t *testing.T) {
+	if err := checkVectors(""); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkVectors("not json"); err == nil {
+		t.Fatal("expected an error for invalid vectors")
+	}
+}
+
+// newTxInfo returns an empty tx of a type, to decode the output of the Sign* exports
+func newTxInfo(txType uint8) txtypes.TxInfo {
+	switch txType {
+	case txtypes.TxTypeL2ChangePubKey:
+		return &txtypes.L2ChangePubKeyTxInfo{}
+	case txtypes.TxTypeL2CreateSubAccount:
+		return &txtypes.L2CreateSubAccountTxInfo{}
+	case txtypes.TxTypeL2CreatePublicPool:
+		return &txtypes.L2CreatePublicPoolTxInfo{}
+	case txtypes.TxTypeL2UpdatePublicPool:
+		return &txtypes.L2UpdatePublicPoolTxInfo{}
+	case txtypes.TxTypeL2Transfer:
+		return &txtypes.L2TransferTxInfo{}
+	case txtypes.TxTypeL2Withdraw:
+		return &txtypes.L2WithdrawTxInfo{}
+	case txtypes.TxTypeL2CreateOrder:
+		return &txtypes.L2CreateOrderTxInfo{}
+	case txtypes.TxTypeL2CancelOrder:
+		return &txtypes.L2CancelOrderTxInfo{}
+	case txtypes.TxTypeL2CancelAllOrders:
+		return &txtypes.L2CancelAllOrdersTxInfo{}
+	case txtypes.TxTypeL2ModifyOrder:
+		return &txtypes.L2ModifyOrderTxInfo{}
+	case txtypes.TxTypeL2MintShares:
+		return &txtypes.L2MintSharesTxInfo{}
+	case txtypes.TxTypeL2BurnShares:
+		return &txtypes.L2BurnSharesTxInfo{}
+	case txtypes.TxTypeL2UpdateLeverage:
+		return &txtypes.L2UpdateLeverageTxInfo{}
+	case txtypes.TxTypeL2UpdateMargin:
+		return &txtypes.L2UpdateMarginTxInfo{}
+	}
+	return nil
+}
+
+// setExpiredAt sets the expiry of a tx decoded from a Sign* export
+func setExpiredAt(tx txtypes.TxInfo, expiredAt int64) {
+	switch t := tx.(type) {
+	case *txtypes.L2ChangePubKeyTxInfo:
+		t.ExpiredAt = expiredAt
+	case *txtypes.L2CreateSubAccountTxInfo:
+		t.ExpiredAt = expiredAt
+	case *txtypes.L2CreatePublicPoolTxInfo:
+		t.ExpiredAt = expiredAt
+	case *txtypes.L2UpdatePublicPoolTxInfo:
+		t.ExpiredAt = expiredAt
+	case *txtypes.L2TransferTxInfo:
+		t.ExpiredAt = expiredAt
+	case *txtypes.L2WithdrawTxInfo:
+		t.ExpiredAt = expiredAt
+	case *txtypes.L2CreateOrderTxInfo:
+		t.ExpiredAt = expiredAt
+	case *txtypes.L2CancelOrderTxInfo:
+		t.ExpiredAt = expiredAt
+	case *txtypes.L2CancelAllOrdersTxInfo:
+		t.ExpiredAt = expiredAt
+	case *txtypes.L2ModifyOrderTxInfo:
+		t.ExpiredAt = expiredAt
+	case *txtypes.L2MintSharesTxInfo:
+		t.ExpiredAt = expiredAt
+	case *txtypes.L2BurnSharesTxInfo:
+		t.ExpiredAt = expiredAt
+	case *txtypes.L2UpdateLeverageTxInfo:
+		t.ExpiredAt = expiredAt
+	case *txtypes.L2UpdateMarginTxInfo:
+		t.ExpiredAt = expiredAt
+	}
+}
+
+// TestSignExports signs every vector through the Sign* exports called by the Python SDK. They set the expiry of the
+// txs themselves, so the signature is checked against the hash of the returned tx, then the hash of the vector is
+// checked with the expiry of the vector.
+func TestSignExports(t *testing.T) {
+	f, err := vectors.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pk, err := hex.DecodeString(strings.TrimPrefix(f.PublicKey, "0x"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := range f.Vectors {
+		v := &f.Vectors[i]
+		t.Run(v.Name, func(t *testing.T) {
+			tx := newTxInfo(v.TxType)
+			if tx == nil {
+				t.Skipf("tx type %d has no sign export", v.TxType)
+			}
+			if err := createVectorClient(f, v); err != nil {
+				t.Fatal(err)
+			}
+			txInfo, err := signVector(v)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal([]byte(txInfo), tx); err != nil {
+				t.Fatal(err)
+			}
+
+			msgHash, err := tx.Hash(v.ChainId)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sig, err := vectors.Sig(tx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := schnorr.Validate(pk, msgHash, sig); err != nil {
+				t.Fatalf("invalid signature: %v", err)
+			}
+
+			setExpiredAt(tx, v.ExpiredAt)
+			msgHash, err = tx.Hash(v.ChainId)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := hex.EncodeToString(msgHash); got != v.Hash {
+				t.Fatalf("hash mismatch. expected: %s got: %s", v.Hash, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+// The helpers of this file call the exports with C values for the tests, which can't use cgo themselves.
+// They're not part of the library, which is built from sharedlib.go alone.
+
+/*
+#include <stdlib.h>
+*/
+import "C"
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"unsafe"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/u20024804/lighter-ex/types"
+	"github.com/u20024804/lighter-ex/types/txtypes"
+	"github.com/u20024804/lighter-ex/types/vectors"
+)
+
+// goErr converts an error returned by an export, nil if there's none
+func goErr(cErr *C.char) error {
+	if cErr == nil {
+		return nil
+	}
+	defer C.free(unsafe.Pointer(cErr))
+	return errors.New(C.GoString(cErr))
+}
+
+func goStrOrErr(str, cErr *C.char) (string, error) {
+	if err := goErr(cErr); err != nil {
+		return "", err
+	}
+	defer C.free(unsafe.Pointer(str))
+	return C.GoString(str), nil
+}
+
+// checkVectors calls CheckTestVectors, data empty for the embedded vectors
+func checkVectors(data string) error {
+	cData := C.CString(data)
+	defer C.free(unsafe.Pointer(cData))
+	return goErr(CheckTestVectors(cData))
+}
+
+// createVectorClient calls CreateClient with the key & the account of a vector
+func createVectorClient(f *vectors.File, v *vectors.Vector) error {
+	key, err := f.KeyManager()
+	if err != nil {
+		return err
+	}
+	cUrl := C.CString("")
+	defer C.free(unsafe.Pointer(cUrl))
+	cKey := C.CString(hexutil.Encode(key.PrvKeyBytes()))
+	defer C.free(unsafe.Pointer(cKey))
+	return goErr(CreateClient(cUrl, cKey, C.int(v.ChainId), C.int(v.ApiKeyIndex), C.longlong(v.AccountIndex)))
+}
+
+// signVector signs the tx of a vector through the Sign* export of its type and returns the tx info. The exports
+// don't take the expiry of the tx, so it's the default one rather than the one of the vector.
+func signVector(v *vectors.Vector) (string, error) {
+	decode := func(req interface{}) error {
+		return json.Unmarshal(v.Request, req)
+	}
+	nonce := C.longlong(v.Nonce)
+
+	switch v.TxType {
+	case txtypes.TxTypeL2ChangePubKey:
+		req := &types.ChangePubKeyReq{}
+		if err := decode(req); err != nil {
+			return "", err
+		}
+		cPubKey := C.CString(hexutil.Encode(req.PubKey[:]))
+		defer C.free(unsafe.Pointer(cPubKey))
+		ret := SignChangePubKey(cPubKey, nonce)
+		return goStrOrErr(ret.str, ret.err)
+	case txtypes.TxTypeL2CreateSubAccount:
+		ret := SignCreateSubAccount(nonce)
+		return goStrOrErr(ret.str, ret.err)
+	case txtypes.TxTypeL2CreatePublicPool:
+		req := &types.CreatePublicPoolTxReq{}
+		if err := decode(req); err != nil {
+			return "", err
+		}
+		ret := SignCreatePublicPool(C.longlong(req.OperatorFee), C.longlong(req.InitialTotalShares), C.longlong(req.MinOperatorShareRate), nonce)
+		return goStrOrErr(ret.str, ret.err)
+	case txtypes.TxTypeL2UpdatePublicPool:
+		req := &types.UpdatePublicPoolTxReq{}
+		if err := decode(req); err != nil {
+			return "", err
+		}
+		ret := SignUpdatePublicPool(C.longlong(req.PublicPoolIndex), C.int(req.Status), C.longlong(req.OperatorFee), C.longlong(req.MinOperatorShareRate), nonce)
+		return goStrOrErr(ret.str, ret.err)
+	case txtypes.TxTypeL2Transfer:
+		req := &types.TransferTxReq{}
+		if err := decode(req); err != nil {
+			return "", err
+		}
+		cMemo := C.CString(string(req.Memo[:]))
+		defer C.free(unsafe.Pointer(cMemo))
+		ret := SignTransfer(C.longlong(req.ToAccountIndex), C.longlong(req.USDCAmount), C.longlong(req.Fee), cMemo, nonce)
+		return goStrOrErr(ret.str, ret.err)
+	case txtypes.TxTypeL2Withdraw:
+		req := &types.WithdrawTxReq{}
+		if err := decode(req); err != nil {
+			return "", err
+		}
+		ret := SignWithdraw(C.longlong(req.USDCAmount), nonce)
+		return goStrOrErr(ret.str, ret.err)
+	case txtypes.TxTypeL2CreateOrder:
+		req := &types.CreateOrderTxReq{}
+		if err := decode(req); err != nil {
+			return "", err
+		}
+		ret := SignCreateOrder(C.int(req.MarketIndex), C.longlong(req.ClientOrderIndex), C.longlong(req.BaseAmount), C.int(req.Price),
+			C.int(req.IsAsk), C.int(req.Type), C.int(req.TimeInForce), C.int(req.ReduceOnly), C.int(req.TriggerPrice), C.longlong(req.OrderExpiry), nonce)
+		return goStrOrErr(ret.str, ret.err)
+	case txtypes.TxTypeL2CancelOrder:
+		req := &types.CancelOrderTxReq{}
+		if err := decode(req); err != nil {
+			return "", err
+		}
+		ret := SignCancelOrder(C.int(req.MarketIndex), C.longlong(req.Index), nonce)
+		return goStrOrErr(ret.str, ret.err)
+	case txtypes.TxTypeL2CancelAllOrders:
+		req := &types.CancelAllOrdersTxReq{}
+		if err := decode(req); err != nil {
+			return "", err
+		}
+		ret := SignCancelAllOrders(C.int(req.TimeInForce), C.longlong(req.Time), nonce)
+		return goStrOrErr(ret.str, ret.err)
+	case txtypes.TxTypeL2ModifyOrder:
+		req := &types.ModifyOrderTxReq{}
+		if err := decode(req); err != nil {
+			return "", err
+		}
+		ret := SignModifyOrder(C.int(req.MarketIndex), C.longlong(req.Index), C.longlong(req.BaseAmount), C.longlong(req.Price), C.longlong(req.TriggerPrice), nonce)
+		return goStrOrErr(ret.str, ret.err)
+	case txtypes.TxTypeL2MintShares:
+		req := &types.MintSharesTxReq{}
+		if err := decode(req); err != nil {
+			return "", err
+		}
+		ret := SignMintShares(C.longlong(req.PublicPoolIndex), C.longlong(req.ShareAmount), nonce)
+		return goStrOrErr(ret.str, ret.err)
+	case txtypes.TxTypeL2BurnShares:
+		req := &types.BurnSharesTxReq{}
+		if err := decode(req); err != nil {
+			return "", err
+		}
+		ret := SignBurnShares(C.longlong(req.PublicPoolIndex), C.longlong(req.ShareAmount), nonce)
+		return goStrOrErr(ret.str, ret.err)
+	case txtypes.TxTypeL2UpdateLeverage:
+		req := &types.UpdateLeverageTxReq{}
+		if err := decode(req); err != nil {
+			return "", err
+		}
+		ret := SignUpdateLeverage(C.int(req.MarketIndex), C.int(req.InitialMarginFraction), C.int(req.MarginMode), nonce)
+		return goStrOrErr(ret.str, ret.err)
+	case txtypes.TxTypeL2UpdateMargin:
+		req := &types.UpdateMarginTxReq{}
+		if err := decode(req); err != nil {
+			return "", err
+		}
+		ret := SignUpdateMargin(C.int(req.MarketIndex), C.longlong(req.USDCAmount), C.int(req.Direction), nonce)
+		return goStrOrErr(ret.str, ret.err)
+	default:
+		return "", fmt.Errorf("tx type %d has no sign export", v.TxType)
+	}
+}
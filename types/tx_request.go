@@ -18,6 +18,8 @@ type TransactOpts struct {
 	ExpiredAt        int64
 	Nonce            *int64
 	DryRun           bool
+	// SkipRiskChecks bypasses the client side risk checks & order hold of TxClient, e.g. to flatten positions in an emergency
+	SkipRiskChecks bool
 }
 
 type PublicKey = gFp5.Element
@@ -641,6 +643,7 @@ func ConvertUpdateLeverageTx(tx *UpdateLeverageTxReq, ops *TransactOpts) *txtype
 		ApiKeyIndex:           *ops.ApiKeyIndex,
 		MarketIndex:           tx.MarketIndex,
 		InitialMarginFraction: tx.InitialMarginFraction,
+		MarginMode:            tx.MarginMode,
 		ExpiredAt:             ops.ExpiredAt,
 		Nonce:                 *ops.Nonce,
 	}
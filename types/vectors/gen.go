@@ -0,0 +1,177 @@
+//go:build ignore
+
+// gen.go regenerates vectors.json. Only run it after an intentional change to the hashing of a tx type,
+// as every vector produced here becomes the reference for both the Go and the Python SDK.
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"os"
+
+	curve "github.com/elliottech/poseidon_crypto/curve/ecgfp5"
+	"github.com/u20024804/lighter-ex/signer"
+	"github.com/u20024804/lighter-ex/types"
+	"github.com/u20024804/lighter-ex/types/txtypes"
+	"github.com/u20024804/lighter-ex/types/vectors"
+)
+
+const (
+	seed      = "lighter-go golden vectors"
+	chainId   = 304
+	expiredAt = 1_700_000_600_000
+)
+
+func main() {
+	s := seed
+	key, err := signer.NewKeyManager(curve.SampleScalar(&s).ToLittleEndianBytes())
+	if err != nil {
+		log.Fatal(err)
+	}
+	pk := key.PubKeyBytes()
+
+	// the memo has no zero byte, so that it can be passed as a C string to SignTransfer
+	var memo [32]byte
+	copy(memo[:], "golden vector memo padded to 32b")
+
+	f := &vectors.File{
+		PrivateKey: hex.EncodeToString(key.PrvKeyBytes()),
+		PublicKey:  hex.EncodeToString(pk[:]),
+	}
+
+	// signatures are randomized, the ones of the vectors whose hash didn't change are kept to keep the diff readable
+	previous := make(map[string]vectors.Vector)
+	if data, err := os.ReadFile("vectors.json"); err == nil {
+		old, err := vectors.Parse(data)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, v := range old.Vectors {
+			previous[v.Name] = v
+		}
+	}
+
+	add := func(name string, txType uint8, nonce int64, req interface{}) {
+		v := vectors.Vector{
+			Name:         name,
+			TxType:       txType,
+			ChainId:      chainId,
+			AccountIndex: 140737488355000,
+			ApiKeyIndex:  3,
+			Nonce:        nonce,
+			ExpiredAt:    expiredAt,
+		}
+		if req != nil {
+			raw, err := json.Marshal(req)
+			if err != nil {
+				log.Fatal(err)
+			}
+			v.Request = raw
+		}
+
+		tx, err := v.Construct(key)
+		if err != nil {
+			log.Fatalf("%s: %v", name, err)
+		}
+		sig, err := vectors.Sig(tx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		v.Hash = tx.GetTxHash()
+		v.Sig = hex.EncodeToString(sig)
+		if old, ok := previous[name]; ok && old.Hash == v.Hash {
+			v.Sig = old.Sig
+		}
+		f.Vectors = append(f.Vectors, v)
+	}
+
+	add("change_pub_key", txtypes.TxTypeL2ChangePubKey, 1, &types.ChangePubKeyReq{PubKey: pk})
+	add("create_sub_account", txtypes.TxTypeL2CreateSubAccount, 2, nil)
+	add("create_public_pool", txtypes.TxTypeL2CreatePublicPool, 3, &types.CreatePublicPoolTxReq{
+		OperatorFee:          100_000,
+		InitialTotalShares:   txtypes.MinInitialTotalShares * 7,
+		MinOperatorShareRate: 500,
+	})
+	add("update_public_pool", txtypes.TxTypeL2UpdatePublicPool, 4, &types.UpdatePublicPoolTxReq{
+		PublicPoolIndex:      281474976710601,
+		Status:               1,
+		OperatorFee:          250_000,
+		MinOperatorShareRate: 1_000,
+	})
+	// the amount & fee are above 2^32, so that both 32-bit limbs are non-zero
+	add("transfer", txtypes.TxTypeL2Transfer, 5, &types.TransferTxReq{
+		ToAccountIndex: 281474976710602,
+		USDCAmount:     (7 << 32) + 123_456_789,
+		Fee:            (1 << 32) + 3,
+		Memo:           memo,
+	})
+	add("withdraw", txtypes.TxTypeL2Withdraw, 6, &types.WithdrawTxReq{USDCAmount: (5 << 32) + 42})
+	add("create_order_limit", txtypes.TxTypeL2CreateOrder, 7, &types.CreateOrderTxReq{
+		MarketIndex:      1,
+		ClientOrderIndex: 123_456,
+		BaseAmount:       1_000_000,
+		Price:            3_000_000_000,
+		IsAsk:            1,
+		Type:             txtypes.LimitOrder,
+		TimeInForce:      txtypes.PostOnly,
+		OrderExpiry:      1_702_000_000_000,
+	})
+	add("create_order_stop_loss", txtypes.TxTypeL2CreateOrder, 8, &types.CreateOrderTxReq{
+		MarketIndex:      254,
+		ClientOrderIndex: txtypes.MaxClientOrderIndex,
+		BaseAmount:       txtypes.MaxOrderBaseAmount,
+		Price:            txtypes.MaxOrderPrice,
+		IsAsk:            0,
+		Type:             txtypes.StopLossOrder,
+		TimeInForce:      txtypes.ImmediateOrCancel,
+		ReduceOnly:       1,
+		TriggerPrice:     txtypes.MaxOrderTriggerPrice - 1,
+		OrderExpiry:      1_702_000_000_000,
+	})
+	add("cancel_order", txtypes.TxTypeL2CancelOrder, 9, &types.CancelOrderTxReq{MarketIndex: 2, Index: txtypes.MinOrderIndex + 17})
+	add("cancel_all_orders_scheduled", txtypes.TxTypeL2CancelAllOrders, 10, &types.CancelAllOrdersTxReq{
+		TimeInForce: txtypes.ScheduledCancelAll,
+		Time:        expiredAt + txtypes.MinOrderCancelAllPeriod,
+	})
+	add("modify_order", txtypes.TxTypeL2ModifyOrder, 11, &types.ModifyOrderTxReq{
+		MarketIndex: 3,
+		Index:       txtypes.MaxOrderIndex,
+		BaseAmount:  2_500,
+		Price:       101_010,
+	})
+	add("mint_shares", txtypes.TxTypeL2MintShares, 12, &types.MintSharesTxReq{PublicPoolIndex: 281474976710601, ShareAmount: (3 << 32) + 9})
+	add("burn_shares", txtypes.TxTypeL2BurnShares, 13, &types.BurnSharesTxReq{PublicPoolIndex: 281474976710601, ShareAmount: (2 << 32) + 11})
+	add("update_leverage_cross", txtypes.TxTypeL2UpdateLeverage, 14, &types.UpdateLeverageTxReq{
+		MarketIndex:           4,
+		InitialMarginFraction: 500,
+		MarginMode:            txtypes.CrossMargin,
+	})
+	// the margin mode is part of the hash, an isolated update mustn't be signed as a cross one
+	add("update_leverage_isolated", txtypes.TxTypeL2UpdateLeverage, 17, &types.UpdateLeverageTxReq{
+		MarketIndex:           4,
+		InitialMarginFraction: 500,
+		MarginMode:            txtypes.IsolatedMargin,
+	})
+	add("create_grouped_orders_otoco", txtypes.TxTypeL2CreateGroupedOrders, 15, &types.CreateGroupedOrdersTxReq{
+		GroupingType: txtypes.GroupingType_OneTriggersAOneCancelsTheOther,
+		Orders: []*types.CreateOrderTxReq{
+			{MarketIndex: 5, BaseAmount: 10_000, Price: 200_000, IsAsk: 0, Type: txtypes.LimitOrder, TimeInForce: txtypes.GoodTillTime, OrderExpiry: 1_702_000_000_000},
+			{MarketIndex: 5, Price: 190_000, IsAsk: 1, Type: txtypes.StopLossOrder, TimeInForce: txtypes.ImmediateOrCancel, ReduceOnly: 1, TriggerPrice: 191_000, OrderExpiry: 1_702_000_000_000},
+			{MarketIndex: 5, Price: 220_000, IsAsk: 1, Type: txtypes.TakeProfitLimitOrder, TimeInForce: txtypes.GoodTillTime, ReduceOnly: 1, TriggerPrice: 219_000, OrderExpiry: 1_702_000_000_000},
+		},
+	})
+	add("update_margin", txtypes.TxTypeL2UpdateMargin, 16, &types.UpdateMarginTxReq{
+		MarketIndex: 6,
+		USDCAmount:  (9 << 32) + 1,
+		Direction:   txtypes.AddToIsolatedMargin,
+	})
+
+	out, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile("vectors.json", append(out, '\n'), 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
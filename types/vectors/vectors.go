@@ -0,0 +1,289 @@
+package vectors
+
+import (
+	_ "embed"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	schnorr "github.com/elliottech/poseidon_crypto/signature/schnorr"
+	"github.com/u20024804/lighter-ex/signer"
+	"github.com/u20024804/lighter-ex/types"
+	"github.com/u20024804/lighter-ex/types/txtypes"
+)
+
+//go:generate go run gen.go
+
+//go:embed vectors.json
+var vectorsJson []byte
+
+// File is the on-disk layout of vectors.json.
+// The same file is consumed by the Python SDK, so field names should not be changed.
+type File struct {
+	PrivateKey string   `json:"private_key"`
+	PublicKey  string   `json:"public_key"`
+	Vectors    []Vector `json:"vectors"`
+}
+
+// Vector describes a single tx, the hash it has to produce and a reference signature over that hash.
+// Signatures are randomized, so Sig can't be reproduced. Instead, it is checked to be valid for Hash under PublicKey,
+// and freshly produced signatures are checked the same way.
+type Vector struct {
+	Name         string          `json:"name"`
+	TxType       uint8           `json:"tx_type"`
+	ChainId      uint32          `json:"chain_id"`
+	AccountIndex int64           `json:"account_index"`
+	ApiKeyIndex  uint8           `json:"api_key_index"`
+	Nonce        int64           `json:"nonce"`
+	ExpiredAt    int64           `json:"expired_at"`
+	Request      json.RawMessage `json:"request,omitempty"`
+	Hash         string          `json:"hash"`
+	Sig          string          `json:"sig"`
+}
+
+// Load returns the vectors embedded in the package.
+func Load() (*File, error) {
+	return Parse(vectorsJson)
+}
+
+// Parse decodes a vectors file.
+func Parse(data []byte) (*File, error) {
+	f := &File{}
+	if err := json.Unmarshal(data, f); err != nil {
+		return nil, fmt.Errorf("failed to parse vectors. err: %w", err)
+	}
+	return f, nil
+}
+
+// KeyManager returns the key every vector is signed with.
+func (f *File) KeyManager() (signer.KeyManager, error) {
+	b, err := hex.DecodeString(strings.TrimPrefix(f.PrivateKey, "0x"))
+	if err != nil {
+		return nil, err
+	}
+	return signer.NewKeyManager(b)
+}
+
+// Ops returns the TransactOpts the vector was built with.
+func (v *Vector) Ops() *types.TransactOpts {
+	accountIndex, apiKeyIndex, nonce := v.AccountIndex, v.ApiKeyIndex, v.Nonce
+	return &types.TransactOpts{
+		FromAccountIndex: &accountIndex,
+		ApiKeyIndex:      &apiKeyIndex,
+		ExpiredAt:        v.ExpiredAt,
+		Nonce:            &nonce,
+	}
+}
+
+// Construct builds and signs the vector's tx through the matching types.Construct*Tx function.
+func (v *Vector) Construct(key signer.Signer) (txtypes.TxInfo, error) {
+	ops := v.Ops()
+	switch v.TxType {
+	case txtypes.TxTypeL2ChangePubKey:
+		req := &types.ChangePubKeyReq{}
+		if err := v.decodeRequest(req); err != nil {
+			return nil, err
+		}
+		return types.ConstructChangePubKeyTx(key, v.ChainId, req, ops)
+	case txtypes.TxTypeL2CreateSubAccount:
+		return types.ConstructCreateSubAccountTx(key, v.ChainId, ops)
+	case txtypes.TxTypeL2CreatePublicPool:
+		req := &types.CreatePublicPoolTxReq{}
+		if err := v.decodeRequest(req); err != nil {
+			return nil, err
+		}
+		return types.ConstructCreatePublicPoolTx(key, v.ChainId, req, ops)
+	case txtypes.TxTypeL2UpdatePublicPool:
+		req := &types.UpdatePublicPoolTxReq{}
+		if err := v.decodeRequest(req); err != nil {
+			return nil, err
+		}
+		return types.ConstructUpdatePublicPoolTx(key, v.ChainId, req, ops)
+	case txtypes.TxTypeL2Transfer:
+		req := &types.TransferTxReq{}
+		if err := v.decodeRequest(req); err != nil {
+			return nil, err
+		}
+		return types.ConstructTransferTx(key, v.ChainId, req, ops)
+	case txtypes.TxTypeL2Withdraw:
+		req := &types.WithdrawTxReq{}
+		if err := v.decodeRequest(req); err != nil {
+			return nil, err
+		}
+		return types.ConstructWithdrawTx(key, v.ChainId, req, ops)
+	case txtypes.TxTypeL2CreateOrder:
+		req := &types.CreateOrderTxReq{}
+		if err := v.decodeRequest(req); err != nil {
+			return nil, err
+		}
+		return types.ConstructCreateOrderTx(key, v.ChainId, req, ops)
+	case txtypes.TxTypeL2CancelOrder:
+		req := &types.CancelOrderTxReq{}
+		if err := v.decodeRequest(req); err != nil {
+			return nil, err
+		}
+		return types.ConstructL2CancelOrderTx(key, v.ChainId, req, ops)
+	case txtypes.TxTypeL2CancelAllOrders:
+		req := &types.CancelAllOrdersTxReq{}
+		if err := v.decodeRequest(req); err != nil {
+			return nil, err
+		}
+		return types.ConstructL2CancelAllOrdersTx(key, v.ChainId, req, ops)
+	case txtypes.TxTypeL2ModifyOrder:
+		req := &types.ModifyOrderTxReq{}
+		if err := v.decodeRequest(req); err != nil {
+			return nil, err
+		}
+		return types.ConstructL2ModifyOrderTx(key, v.ChainId, req, ops)
+	case txtypes.TxTypeL2MintShares:
+		req := &types.MintSharesTxReq{}
+		if err := v.decodeRequest(req); err != nil {
+			return nil, err
+		}
+		return types.ConstructMintSharesTx(key, v.ChainId, req, ops)
+	case txtypes.TxTypeL2BurnShares:
+		req := &types.BurnSharesTxReq{}
+		if err := v.decodeRequest(req); err != nil {
+			return nil, err
+		}
+		return types.ConstructBurnSharesTx(key, v.ChainId, req, ops)
+	case txtypes.TxTypeL2UpdateLeverage:
+		req := &types.UpdateLeverageTxReq{}
+		if err := v.decodeRequest(req); err != nil {
+			return nil, err
+		}
+		return types.ConstructUpdateLeverageTx(key, v.ChainId, req, ops)
+	case txtypes.TxTypeL2CreateGroupedOrders:
+		req := &types.CreateGroupedOrdersTxReq{}
+		if err := v.decodeRequest(req); err != nil {
+			return nil, err
+		}
+		return types.ConstructL2CreateGroupedOrdersTx(key, v.ChainId, req, ops)
+	case txtypes.TxTypeL2UpdateMargin:
+		req := &types.UpdateMarginTxReq{}
+		if err := v.decodeRequest(req); err != nil {
+			return nil, err
+		}
+		return types.ConstructUpdateMarginTx(key, v.ChainId, req, ops)
+	default:
+		return nil, fmt.Errorf("unsupported tx type %d", v.TxType)
+	}
+}
+
+func (v *Vector) decodeRequest(req interface{}) error {
+	if err := json.Unmarshal(v.Request, req); err != nil {
+		return fmt.Errorf("vector %s: failed to parse request. err: %w", v.Name, err)
+	}
+	return nil
+}
+
+// Check compares a tx built from the vector against the expected hash, and verifies both the tx's signature and the
+// reference one against the vector's public key.
+func (f *File) Check(v *Vector, tx txtypes.TxInfo, sig []byte) error {
+	if tx.GetTxType() != v.TxType {
+		return fmt.Errorf("vector %s: tx type mismatch. expected: %d got: %d", v.Name, v.TxType, tx.GetTxType())
+	}
+	if tx.GetTxHash() != v.Hash {
+		return fmt.Errorf("vector %s: hash mismatch. expected: %s got: %s", v.Name, v.Hash, tx.GetTxHash())
+	}
+
+	pk, err := hex.DecodeString(strings.TrimPrefix(f.PublicKey, "0x"))
+	if err != nil {
+		return fmt.Errorf("vector %s: invalid public key. err: %w", v.Name, err)
+	}
+	msgHash, err := hex.DecodeString(v.Hash)
+	if err != nil {
+		return fmt.Errorf("vector %s: invalid hash. err: %w", v.Name, err)
+	}
+	refSig, err := hex.DecodeString(v.Sig)
+	if err != nil {
+		return fmt.Errorf("vector %s: invalid sig. err: %w", v.Name, err)
+	}
+
+	if err := schnorr.Validate(pk, msgHash, refSig); err != nil {
+		return fmt.Errorf("vector %s: reference signature is invalid. err: %w", v.Name, err)
+	}
+	if err := schnorr.Validate(pk, msgHash, sig); err != nil {
+		return fmt.Errorf("vector %s: produced signature is invalid. err: %w", v.Name, err)
+	}
+	return nil
+}
+
+// Run builds every vector using build and checks the result. It stops at the first failure.
+func (f *File) Run(build func(key signer.KeyManager, v *Vector) (txtypes.TxInfo, error)) error {
+	key, err := f.KeyManager()
+	if err != nil {
+		return err
+	}
+
+	pk := key.PubKeyBytes()
+	if pkStr := hex.EncodeToString(pk[:]); pkStr != strings.TrimPrefix(f.PublicKey, "0x") {
+		return fmt.Errorf("public key mismatch. expected: %s got: %s", f.PublicKey, pkStr)
+	}
+
+	for i := range f.Vectors {
+		v := &f.Vectors[i]
+		tx, err := build(key, v)
+		if err != nil {
+			return fmt.Errorf("vector %s: failed to build tx. err: %w", v.Name, err)
+		}
+		sig, err := Sig(tx)
+		if err != nil {
+			return fmt.Errorf("vector %s: %w", v.Name, err)
+		}
+		if err := f.Check(v, tx, sig); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Verify runs every embedded vector against the types.Construct*Tx functions.
+func Verify() error {
+	f, err := Load()
+	if err != nil {
+		return err
+	}
+	return f.Run(func(key signer.KeyManager, v *Vector) (txtypes.TxInfo, error) {
+		return v.Construct(key)
+	})
+}
+
+// Sig extracts the signature from a signed tx.
+func Sig(tx txtypes.TxInfo) ([]byte, error) {
+	switch t := tx.(type) {
+	case *txtypes.L2ChangePubKeyTxInfo:
+		return t.Sig, nil
+	case *txtypes.L2CreateSubAccountTxInfo:
+		return t.Sig, nil
+	case *txtypes.L2CreatePublicPoolTxInfo:
+		return t.Sig, nil
+	case *txtypes.L2UpdatePublicPoolTxInfo:
+		return t.Sig, nil
+	case *txtypes.L2TransferTxInfo:
+		return t.Sig, nil
+	case *txtypes.L2WithdrawTxInfo:
+		return t.Sig, nil
+	case *txtypes.L2CreateOrderTxInfo:
+		return t.Sig, nil
+	case *txtypes.L2CancelOrderTxInfo:
+		return t.Sig, nil
+	case *txtypes.L2CancelAllOrdersTxInfo:
+		return t.Sig, nil
+	case *txtypes.L2ModifyOrderTxInfo:
+		return t.Sig, nil
+	case *txtypes.L2MintSharesTxInfo:
+		return t.Sig, nil
+	case *txtypes.L2BurnSharesTxInfo:
+		return t.Sig, nil
+	case *txtypes.L2UpdateLeverageTxInfo:
+		return t.Sig, nil
+	case *txtypes.L2CreateGroupedOrdersTxInfo:
+		return t.Sig, nil
+	case *txtypes.L2UpdateMarginTxInfo:
+		return t.Sig, nil
+	default:
+		return nil, fmt.Errorf("unsupported tx %T", tx)
+	}
+}
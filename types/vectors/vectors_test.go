@@ -0,0 +1,118 @@
+package vectors
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/u20024804/lighter-ex/types/txtypes"
+)
+
+func TestVectors(t *testing.T) {
+	f, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := f.KeyManager()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := range f.Vectors {
+		v := &f.Vectors[i]
+		t.Run(v.Name, func(t *testing.T) {
+			tx, err := v.Construct(key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sig, err := Sig(tx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := f.Check(v, tx, sig); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestVerify(t *testing.T) {
+	if err := Verify(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVectorsCoverEveryTxType(t *testing.T) {
+	f, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	covered := make(map[uint8]bool)
+	for _, v := range f.Vectors {
+		covered[v.TxType] = true
+	}
+
+	txTypes := []uint8{
+		txtypes.TxTypeL2ChangePubKey,
+		txtypes.TxTypeL2CreateSubAccount,
+		txtypes.TxTypeL2CreatePublicPool,
+		txtypes.TxTypeL2UpdatePublicPool,
+		txtypes.TxTypeL2Transfer,
+		txtypes.TxTypeL2Withdraw,
+		txtypes.TxTypeL2CreateOrder,
+		txtypes.TxTypeL2CancelOrder,
+		txtypes.TxTypeL2CancelAllOrders,
+		txtypes.TxTypeL2ModifyOrder,
+		txtypes.TxTypeL2MintShares,
+		txtypes.TxTypeL2BurnShares,
+		txtypes.TxTypeL2UpdateLeverage,
+		txtypes.TxTypeL2CreateGroupedOrders,
+		txtypes.TxTypeL2UpdateMargin,
+	}
+	for _, txType := range txTypes {
+		if !covered[txType] {
+			t.Errorf("no vector for tx type %d", txType)
+		}
+	}
+}
+
+func TestCheckDetectsChanges(t *testing.T) {
+	f, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := f.KeyManager()
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := f.Vectors[0]
+
+	tests := []struct {
+		name   string
+		change func(v *Vector)
+		err    string
+	}{
+		{"nonce", func(v *Vector) { v.Nonce++ }, "hash mismatch"},
+		{"expiry", func(v *Vector) { v.ExpiredAt++ }, "hash mismatch"},
+		{"chain id", func(v *Vector) { v.ChainId++ }, "hash mismatch"},
+		{"account", func(v *Vector) { v.AccountIndex++ }, "hash mismatch"},
+		{"reference sig", func(v *Vector) { v.Sig = strings.Repeat("00", len(v.Sig)/2) }, "reference signature is invalid"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changed := v
+			tt.change(&changed)
+			tx, err := changed.Construct(key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sig, err := Sig(tx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = f.Check(&changed, tx, sig)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("expected an error containing %q, got %v", tt.err, err)
+			}
+		})
+	}
+}